@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopass/internal/models"
+	"gopass/internal/storage"
+)
+
+func startAgent(t *testing.T, approve Approver, idle time.Duration) (*Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	// The socket's directory must be private
+	require.NoError(t, os.Chmod(dir, 0700))
+	s := storage.NewStorageAt(filepath.Join(dir, "data.enc"), "1234")
+	require.NoError(t, s.AddPassword(models.Password{ID: "p1", Name: "GitHub", Password: "s3cret"}))
+
+	srv := NewServer(s, "personal", approve, idle)
+	path := filepath.Join(dir, "agent.sock")
+	go srv.ListenAndServe(path)
+	t.Cleanup(srv.Stop)
+
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+	return srv, path
+}
+
+func TestClientReadsAndMutatesVault(t *testing.T) {
+	_, path := startAgent(t, nil, 0)
+
+	c, err := Dial(path)
+	require.NoError(t, err)
+	defer c.Close()
+	assert.Equal(t, "personal", c.Vault())
+
+	passwords, err := c.Passwords()
+	require.NoError(t, err)
+	require.Len(t, passwords, 1)
+	assert.Empty(t, passwords[0].Password, "lists carry no secrets")
+	p, err := c.RevealPassword("p1")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", p.Password)
+
+	require.NoError(t, c.AddNote(models.Note{ID: "n1", Title: "Wifi"}))
+	result, err := c.Search("wifi")
+	require.NoError(t, err)
+	assert.Len(t, result.Notes, 1)
+
+	require.NoError(t, c.DeletePassword("p1"))
+	assert.EqualError(t, c.DeletePassword("p1"), "password not found")
+}
+
+func TestApprovalIsAskedOncePerClient(t *testing.T) {
+	asked := 0
+	_, path := startAgent(t, func(peer Peer, req Request) bool {
+		asked++
+		return true
+	}, 0)
+
+	for i := 0; i < 3; i++ {
+		c, err := Dial(path)
+		require.NoError(t, err)
+		_, err = c.Passwords()
+		require.NoError(t, err)
+		c.Close()
+	}
+	assert.Equal(t, 1, asked, "connections from the same process share one approval")
+}
+
+func TestDeniedClientIsDisconnected(t *testing.T) {
+	_, path := startAgent(t, func(Peer, Request) bool { return false }, 0)
+
+	c, err := Dial(path)
+	assert.Nil(t, c)
+	assert.EqualError(t, err, "request denied")
+}
+
+func TestAgentStopsWhenIdle(t *testing.T) {
+	srv, _ := startAgent(t, nil, 100*time.Millisecond)
+
+	select {
+	case <-srv.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("agent did not stop after the idle timeout")
+	}
+}
+
+func TestAgentRefusesSocketDirOthersControl(t *testing.T) {
+	dir := t.TempDir()
+	s := storage.NewStorageAt(filepath.Join(dir, "data.enc"), "1234")
+	srv := NewServer(s, "personal", nil, time.Minute)
+
+	open := filepath.Join(dir, "open")
+	require.NoError(t, os.Mkdir(open, 0755))
+	assert.ErrorContains(t, srv.ListenAndServe(filepath.Join(open, "agent.sock")), "mode 0700")
+
+	link := filepath.Join(dir, "link")
+	require.NoError(t, os.Symlink(t.TempDir(), link))
+	assert.ErrorContains(t, srv.ListenAndServe(filepath.Join(link, "agent.sock")), "not a directory")
+}
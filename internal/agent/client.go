@@ -0,0 +1,183 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"gopass/internal/events"
+	"gopass/internal/models"
+	"gopass/internal/secmem"
+)
+
+// Client talks to a running agent over its socket.
+type Client struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+	vault   string
+}
+
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Only trust an agent run by the same user
+	peer, err := PeerCredentials(conn)
+	if err == nil && peer.UID != os.Getuid() {
+		err = fmt.Errorf("the agent on %s belongs to another user", path)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	c := &Client{conn: conn, scanner: scanner}
+
+	resp, err := c.do(Request{Op: OpPing})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.vault = resp.Vault
+	return c, nil
+}
+
+// Vault is the name of the vault the agent serves.
+func (c *Client) Vault() string {
+	return c.vault
+}
+
+func (c *Client) do(req Request) (Response, error) {
+	var resp Response
+	if err := json.NewEncoder(c.conn).Encode(req); err != nil {
+		return resp, err
+	}
+	if !c.scanner.Scan() {
+		if err := c.scanner.Err(); err != nil {
+			return resp, err
+		}
+		return resp, errors.New("agent closed the connection")
+	}
+	if err := json.Unmarshal(c.scanner.Bytes(), &resp); err != nil {
+		return resp, err
+	}
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+func (c *Client) Passwords() ([]models.Password, error) {
+	resp, err := c.do(Request{Op: OpList})
+	return resp.Passwords, err
+}
+
+func (c *Client) Notes() ([]models.Note, error) {
+	resp, err := c.do(Request{Op: OpList})
+	return resp.Notes, err
+}
+
+// RevealPassword fetches one password with its secrets.
+func (c *Client) RevealPassword(id string) (models.Password, error) {
+	resp, err := c.do(Request{Op: OpGet, Kind: events.KindPassword, ID: id})
+	if err != nil || len(resp.Passwords) == 0 {
+		return models.Password{}, err
+	}
+	return resp.Passwords[0], nil
+}
+
+// PasswordSecret is RevealPassword for just the password. It arrives as
+// JSON, so a copy stays on the heap all the same.
+func (c *Client) PasswordSecret(id string) (*secmem.Buffer, error) {
+	p, err := c.RevealPassword(id)
+	if err != nil {
+		return nil, err
+	}
+	return secmem.Copy([]byte(p.Password)), nil
+}
+
+func (c *Client) RevealNote(id string) (models.Note, error) {
+	resp, err := c.do(Request{Op: OpGet, Kind: events.KindNote, ID: id})
+	if err != nil || len(resp.Notes) == 0 {
+		return models.Note{}, err
+	}
+	return resp.Notes[0], nil
+}
+
+func (c *Client) RevealSSHKey(id string) (models.SSHKey, error) {
+	resp, err := c.do(Request{Op: OpGet, Kind: events.KindSSHKey, ID: id})
+	if err != nil || len(resp.SSHKeys) == 0 {
+		return models.SSHKey{}, err
+	}
+	return resp.SSHKeys[0], nil
+}
+
+func (c *Client) Search(query string) (models.SearchResult, error) {
+	resp, err := c.do(Request{Op: OpSearch, Query: query})
+	return models.SearchResult{Passwords: resp.Passwords, Notes: resp.Notes}, err
+}
+
+func (c *Client) AddPassword(p models.Password) error {
+	_, err := c.do(Request{Op: OpAdd, Password: &p})
+	return err
+}
+
+func (c *Client) UpdatePassword(p models.Password) error {
+	_, err := c.do(Request{Op: OpUpdate, Password: &p})
+	return err
+}
+
+func (c *Client) DeletePassword(id string) error {
+	_, err := c.do(Request{Op: OpDelete, Kind: events.KindPassword, ID: id})
+	return err
+}
+
+func (c *Client) AddNote(n models.Note) error {
+	_, err := c.do(Request{Op: OpAdd, Note: &n})
+	return err
+}
+
+func (c *Client) UpdateNote(n models.Note) error {
+	_, err := c.do(Request{Op: OpUpdate, Note: &n})
+	return err
+}
+
+func (c *Client) DeleteNote(id string) error {
+	_, err := c.do(Request{Op: OpDelete, Kind: events.KindNote, ID: id})
+	return err
+}
+
+func (c *Client) SSHKeys() ([]models.SSHKey, error) {
+	resp, err := c.do(Request{Op: OpList})
+	return resp.SSHKeys, err
+}
+
+func (c *Client) AddSSHKey(k models.SSHKey) error {
+	_, err := c.do(Request{Op: OpAdd, SSHKey: &k})
+	return err
+}
+
+func (c *Client) UpdateSSHKey(k models.SSHKey) error {
+	_, err := c.do(Request{Op: OpUpdate, SSHKey: &k})
+	return err
+}
+
+func (c *Client) DeleteSSHKey(id string) error {
+	_, err := c.do(Request{Op: OpDelete, Kind: events.KindSSHKey, ID: id})
+	return err
+}
+
+// Stop asks the agent to forget the vault and exit.
+func (c *Client) Stop() error {
+	_, err := c.do(Request{Op: OpStop})
+	return err
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package agent
+
+import "os"
+
+// Without unix owners and modes only the directory checks apply.
+func privateToUser(os.FileInfo) bool {
+	return true
+}
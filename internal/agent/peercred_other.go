@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package agent
+
+import (
+	"errors"
+	"net"
+)
+
+// PeerCredentials is not available here. Without peer credentials the
+// agent cannot tell who is connecting, so it refuses every client.
+func PeerCredentials(net.Conn) (Peer, error) {
+	return Peer{}, errors.New("peer credentials are not supported on this platform")
+}
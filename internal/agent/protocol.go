@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopass/internal/events"
+	"gopass/internal/models"
+)
+
+// Requests and responses are single JSON objects, one per line.
+const (
+	OpPing   = "ping"
+	OpList   = "list"
+	OpGet    = "get"
+	OpSearch = "search"
+	OpAdd    = "add"
+	OpUpdate = "update"
+	OpDelete = "delete"
+	OpStop   = "stop"
+)
+
+type Request struct {
+	Op       string           `json:"op"`
+	Kind     events.Kind      `json:"kind,omitempty"`
+	ID       string           `json:"id,omitempty"`
+	Query    string           `json:"query,omitempty"`
+	Password *models.Password `json:"password,omitempty"`
+	Note     *models.Note     `json:"note,omitempty"`
+	SSHKey   *models.SSHKey   `json:"ssh_key,omitempty"`
+}
+
+type Response struct {
+	Error     string            `json:"error,omitempty"`
+	Vault     string            `json:"vault,omitempty"`
+	Passwords []models.Password `json:"passwords,omitempty"`
+	Notes     []models.Note     `json:"notes,omitempty"`
+	SSHKeys   []models.SSHKey   `json:"ssh_keys,omitempty"`
+}
+
+// Peer identifies the process on the other end of a connection.
+type Peer struct {
+	PID int
+	UID int
+	Exe string
+}
+
+func (p Peer) String() string {
+	if p.Exe == "" {
+		return fmt.Sprintf("PID %d", p.PID)
+	}
+	return fmt.Sprintf("%s (PID %d)", p.Exe, p.PID)
+}
+
+// SocketPath is where the agent for vaultName listens: $GOPASS_AGENT_SOCK if
+// set, otherwise a per-user directory under $XDG_RUNTIME_DIR or the temp dir.
+func SocketPath(vaultName string) (string, error) {
+	if path := os.Getenv("GOPASS_AGENT_SOCK"); path != "" {
+		return path, nil
+	}
+	if vaultName == "" || strings.ContainsAny(vaultName, `/\`) {
+		return "", fmt.Errorf("invalid vault name %q", vaultName)
+	}
+
+	base := os.Getenv("XDG_RUNTIME_DIR")
+	if base == "" {
+		base = tempBase()
+	}
+	return filepath.Join(base, "gopass", "agent-"+vaultName+".sock"), nil
+}
+
+func tempBase() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("gopass-%d", os.Getuid()))
+}
+
+// CheckSocketDir makes sure no one else controls the directories of the
+// socket at path. Another user may have created them first in the shared
+// temp dir, to swap in a socket of their own. Sockets next to
+// $GOPASS_AGENT_SOCK are left to whoever chose it.
+func CheckSocketDir(path string) error {
+	if env := os.Getenv("GOPASS_AGENT_SOCK"); env != "" && filepath.Dir(path) == filepath.Dir(env) {
+		return nil
+	}
+	dirs := []string{filepath.Dir(path)}
+	if parent := filepath.Dir(dirs[0]); parent == tempBase() {
+		dirs = append(dirs, parent)
+	}
+	for _, dir := range dirs {
+		fi, err := os.Lstat(dir)
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 || !fi.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		if !privateToUser(fi) {
+			return fmt.Errorf("%s must be owned by the current user with mode 0700", dir)
+		}
+	}
+	return nil
+}
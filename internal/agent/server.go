@@ -0,0 +1,308 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gopass/internal/events"
+	"gopass/internal/models"
+	"gopass/internal/storage"
+)
+
+// Approver decides whether a client may use the vault. It is asked once per
+// client process; the answer is remembered until the agent stops.
+type Approver func(peer Peer, req Request) bool
+
+// AlwaysApprove lets every client of the same user in without asking.
+func AlwaysApprove(Peer, Request) bool { return true }
+
+type Server struct {
+	storage *storage.Storage
+	vault   string
+	approve Approver
+	idle    time.Duration
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]bool
+	timer    *time.Timer
+	stopped  bool
+	done     chan struct{}
+
+	// approveMu serialises prompts so concurrent clients do not interleave
+	approveMu sync.Mutex
+	approved  map[string]bool
+}
+
+// NewServer serves the unlocked storage of the named vault. The agent stops
+// by itself once no request has arrived for idle; zero disables that.
+func NewServer(s *storage.Storage, vault string, approve Approver, idle time.Duration) *Server {
+	if approve == nil {
+		approve = AlwaysApprove
+	}
+	return &Server{
+		storage:  s,
+		vault:    vault,
+		approve:  approve,
+		idle:     idle,
+		conns:    make(map[net.Conn]bool),
+		done:     make(chan struct{}),
+		approved: make(map[string]bool),
+	}
+}
+
+// ListenAndServe creates the socket at path, readable by the current user
+// only, and serves until the agent is stopped.
+func (srv *Server) ListenAndServe(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := CheckSocketDir(path); err != nil {
+		return err
+	}
+
+	// A socket file nobody answers on is left over from a crashed agent
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("an agent is already listening on %s", path)
+	}
+	os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return err
+	}
+	defer os.Remove(path)
+	return srv.Serve(l)
+}
+
+// Serve accepts connections on l until Stop is called or the idle timeout
+// expires.
+func (srv *Server) Serve(l net.Listener) error {
+	srv.mu.Lock()
+	srv.listener = l
+	srv.mu.Unlock()
+	srv.touch()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-srv.done:
+				return nil
+			default:
+				return err
+			}
+		}
+		if !srv.track(conn, true) {
+			conn.Close()
+			return nil
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer srv.track(conn, false)
+			srv.handle(conn)
+		}()
+	}
+}
+
+// Stop closes the listener and releases the vault.
+func (srv *Server) Stop() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.stopped {
+		return
+	}
+	srv.stopped = true
+	close(srv.done)
+	if srv.timer != nil {
+		srv.timer.Stop()
+	}
+	if srv.listener != nil {
+		srv.listener.Close()
+	}
+	for conn := range srv.conns {
+		conn.Close()
+	}
+	srv.storage.Close()
+}
+
+// track registers or forgets an open connection so Stop can close it. It
+// refuses new connections once the agent is stopping.
+func (srv *Server) track(conn net.Conn, open bool) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if !open {
+		delete(srv.conns, conn)
+		return true
+	}
+	if srv.stopped {
+		return false
+	}
+	srv.conns[conn] = true
+	return true
+}
+
+// Done is closed once the agent has stopped.
+func (srv *Server) Done() <-chan struct{} {
+	return srv.done
+}
+
+func (srv *Server) touch() {
+	if srv.idle <= 0 {
+		return
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.stopped {
+		return
+	}
+	if srv.timer == nil {
+		srv.timer = time.AfterFunc(srv.idle, srv.Stop)
+		return
+	}
+	srv.timer.Reset(srv.idle)
+}
+
+func (srv *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	enc := json.NewEncoder(conn)
+
+	peer, err := PeerCredentials(conn)
+	if err != nil {
+		enc.Encode(Response{Error: "cannot verify client: " + err.Error()})
+		return
+	}
+	if peer.UID != os.Getuid() {
+		enc.Encode(Response{Error: "client belongs to another user"})
+		return
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var req Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			enc.Encode(Response{Error: "malformed request: " + err.Error()})
+			return
+		}
+		srv.touch()
+
+		if !srv.isApproved(peer, req) {
+			enc.Encode(Response{Error: "request denied"})
+			return
+		}
+
+		resp := srv.dispatch(req)
+		resp.Vault = srv.vault
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+		if req.Op == OpStop {
+			srv.Stop()
+			return
+		}
+	}
+}
+
+func (srv *Server) isApproved(peer Peer, req Request) bool {
+	key := fmt.Sprintf("%d:%s", peer.PID, peer.Exe)
+
+	srv.approveMu.Lock()
+	defer srv.approveMu.Unlock()
+	if srv.approved[key] {
+		return true
+	}
+	if !srv.approve(peer, req) {
+		return false
+	}
+	srv.approved[key] = true
+	return true
+}
+
+func (srv *Server) dispatch(req Request) Response {
+	var resp Response
+	var err error
+
+	switch req.Op {
+	case OpPing, OpStop:
+	case OpList:
+		resp.Passwords = srv.storage.GetPasswords()
+		resp.Notes = srv.storage.GetNotes()
+		resp.SSHKeys = srv.storage.GetSSHKeys()
+	case OpSearch:
+		result := srv.storage.Search(req.Query)
+		resp.Passwords, resp.Notes = result.Passwords, result.Notes
+	case OpGet:
+		resp, err = srv.get(req)
+	case OpAdd, OpUpdate, OpDelete:
+		err = srv.mutate(req)
+	default:
+		err = fmt.Errorf("unknown operation %q", req.Op)
+	}
+
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	return resp
+}
+
+// get returns one entry with its secrets revealed.
+func (srv *Server) get(req Request) (Response, error) {
+	var resp Response
+	switch req.Kind {
+	case events.KindPassword:
+		p, err := srv.storage.RevealPassword(req.ID)
+		resp.Passwords = []models.Password{p}
+		return resp, err
+	case events.KindNote:
+		n, err := srv.storage.RevealNote(req.ID)
+		resp.Notes = []models.Note{n}
+		return resp, err
+	case events.KindSSHKey:
+		k, err := srv.storage.RevealSSHKey(req.ID)
+		resp.SSHKeys = []models.SSHKey{k}
+		return resp, err
+	default:
+		return resp, fmt.Errorf("unknown entry kind %q", req.Kind)
+	}
+}
+
+func (srv *Server) mutate(req Request) error {
+	switch {
+	case req.Op == OpAdd && req.Password != nil:
+		return srv.storage.AddPassword(*req.Password)
+	case req.Op == OpAdd && req.Note != nil:
+		return srv.storage.AddNote(*req.Note)
+	case req.Op == OpAdd && req.SSHKey != nil:
+		return srv.storage.AddSSHKey(*req.SSHKey)
+	case req.Op == OpUpdate && req.Password != nil:
+		return srv.storage.UpdatePassword(*req.Password)
+	case req.Op == OpUpdate && req.Note != nil:
+		return srv.storage.UpdateNote(*req.Note)
+	case req.Op == OpUpdate && req.SSHKey != nil:
+		return srv.storage.UpdateSSHKey(*req.SSHKey)
+	case req.Op == OpDelete && req.Kind == events.KindPassword:
+		return srv.storage.DeletePassword(req.ID)
+	case req.Op == OpDelete && req.Kind == events.KindNote:
+		return srv.storage.DeleteNote(req.ID)
+	case req.Op == OpDelete && req.Kind == events.KindSSHKey:
+		return srv.storage.DeleteSSHKey(req.ID)
+	default:
+		return fmt.Errorf("%s needs a password, a note or an ssh key", req.Op)
+	}
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopass/internal/models"
+	"gopass/internal/storage"
+)
+
+type fixture struct {
+	ts     *httptest.Server
+	tokens *TokenStore
+	log    *bytes.Buffer
+}
+
+func newFixture(t *testing.T) *fixture {
+	dir := t.TempDir()
+	s := storage.NewStorageAt(filepath.Join(dir, "data.enc"), "1234")
+	require.NoError(t, s.Lock())
+	t.Cleanup(func() { s.Close() })
+	require.NoError(t, s.AddPassword(models.Password{ID: "db", Folder: "infra/prod", Name: "db", Password: "pw1"}))
+	require.NoError(t, s.AddPassword(models.Password{ID: "mail", Folder: "personal", Name: "mail", Password: "pw2"}))
+	require.NoError(t, s.AddNote(models.Note{ID: "n1", Title: "wifi", Content: "secret"}))
+
+	f := &fixture{tokens: NewTokenStore(filepath.Join(dir, "api-tokens.json")), log: &bytes.Buffer{}}
+	f.ts = httptest.NewServer(NewServer(s, f.tokens, f.log))
+	t.Cleanup(f.ts.Close)
+	return f
+}
+
+func (f *fixture) token(t *testing.T, scope Scope) string {
+	secret, _, err := f.tokens.Create("test", scope)
+	require.NoError(t, err)
+	return secret
+}
+
+func (f *fixture) do(t *testing.T, token, method, path string, body any) (int, []byte) {
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+	req, err := http.NewRequest(method, f.ts.URL+path, &buf)
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := f.ts.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var out bytes.Buffer
+	out.ReadFrom(resp.Body)
+	return resp.StatusCode, out.Bytes()
+}
+
+func TestCRUDWithFullToken(t *testing.T) {
+	f := newFixture(t)
+	tok := f.token(t, Scope{})
+
+	status, body := f.do(t, tok, "POST", "/v1/passwords", models.Password{Name: "new", Folder: "infra", Password: "x"})
+	require.Equal(t, http.StatusCreated, status, string(body))
+	var created models.Password
+	require.NoError(t, json.Unmarshal(body, &created))
+	assert.NotEmpty(t, created.ID)
+
+	created.Password = "y"
+	status, _ = f.do(t, tok, "PUT", "/v1/passwords/"+created.ID, created)
+	assert.Equal(t, http.StatusOK, status)
+	status, body = f.do(t, tok, "GET", "/v1/passwords/"+created.ID, nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(body), `"password":"y"`)
+
+	status, body = f.do(t, tok, "GET", "/v1/search?q=wifi", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(body), `"title":"wifi"`)
+
+	status, _ = f.do(t, tok, "DELETE", "/v1/passwords/"+created.ID, nil)
+	assert.Equal(t, http.StatusNoContent, status)
+	status, _ = f.do(t, tok, "GET", "/v1/passwords/"+created.ID, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestScopedTokens(t *testing.T) {
+	f := newFixture(t)
+
+	readOnly := f.token(t, Scope{ReadOnly: true})
+	status, _ := f.do(t, readOnly, "GET", "/v1/notes", nil)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = f.do(t, readOnly, "DELETE", "/v1/passwords/db", nil)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	infra := f.token(t, Scope{Folders: []string{"infra"}})
+	status, body := f.do(t, infra, "GET", "/v1/passwords", nil)
+	assert.Equal(t, http.StatusOK, status)
+	var visible []models.Password
+	require.NoError(t, json.Unmarshal(body, &visible))
+	require.Len(t, visible, 1)
+	assert.Equal(t, "db", visible[0].ID)
+	status, _ = f.do(t, infra, "GET", "/v1/passwords/mail", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = f.do(t, infra, "POST", "/v1/passwords", models.Password{Name: "x", Folder: "personal"})
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = f.do(t, infra, "GET", "/v1/notes", nil)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	expired := f.token(t, Scope{ExpiresAt: time.Now().Add(-time.Minute)})
+	status, _ = f.do(t, expired, "GET", "/v1/passwords", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func TestRevocationAndAccessLog(t *testing.T) {
+	f := newFixture(t)
+	secret, tok, err := f.tokens.Create("ci", Scope{})
+	require.NoError(t, err)
+
+	status, _ := f.do(t, secret, "GET", "/v1/passwords", nil)
+	assert.Equal(t, http.StatusOK, status)
+	require.NoError(t, f.tokens.Revoke(tok.ID))
+	status, _ = f.do(t, secret, "GET", "/v1/passwords", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = f.do(t, "", "GET", "/v1/passwords", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	lines := strings.Split(strings.TrimSpace(f.log.String()), "\n")
+	require.Len(t, lines, 3)
+	var first, second AccessRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &second))
+	assert.Equal(t, tok.ID, first.TokenID)
+	assert.Equal(t, http.StatusOK, first.Status)
+	assert.Equal(t, "/v1/passwords", first.Path)
+	assert.Empty(t, second.TokenID)
+	assert.Equal(t, http.StatusUnauthorized, second.Status)
+	assert.NotContains(t, f.log.String(), secret)
+}
@@ -0,0 +1,350 @@
+// Package api serves a vault over a small JSON REST API for local tools.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gopass/internal/models"
+	"gopass/internal/storage"
+)
+
+// AccessRecord is one line of the access log.
+type AccessRecord struct {
+	Time    time.Time `json:"time"`
+	TokenID string    `json:"token_id,omitempty"`
+	Method  string    `json:"method"`
+	Path    string    `json:"path"`
+	Status  int       `json:"status"`
+	Remote  string    `json:"remote,omitempty"`
+}
+
+type Server struct {
+	storage *storage.Storage
+	tokens  *TokenStore
+	mux     *http.ServeMux
+
+	logMu     sync.Mutex
+	accessLog io.Writer
+}
+
+// NewServer serves s to holders of a token in tokens. Every request,
+// including rejected ones, is written to accessLog as a JSON line.
+func NewServer(s *storage.Storage, tokens *TokenStore, accessLog io.Writer) *Server {
+	srv := &Server{storage: s, tokens: tokens, accessLog: accessLog, mux: http.NewServeMux()}
+
+	srv.mux.HandleFunc("GET /v1/passwords", srv.listPasswords)
+	srv.mux.HandleFunc("POST /v1/passwords", srv.addPassword)
+	srv.mux.HandleFunc("GET /v1/passwords/{id}", srv.getPassword)
+	srv.mux.HandleFunc("PUT /v1/passwords/{id}", srv.updatePassword)
+	srv.mux.HandleFunc("DELETE /v1/passwords/{id}", srv.deletePassword)
+	srv.mux.HandleFunc("GET /v1/notes", srv.listNotes)
+	srv.mux.HandleFunc("POST /v1/notes", srv.addNote)
+	srv.mux.HandleFunc("GET /v1/notes/{id}", srv.getNote)
+	srv.mux.HandleFunc("PUT /v1/notes/{id}", srv.updateNote)
+	srv.mux.HandleFunc("DELETE /v1/notes/{id}", srv.deleteNote)
+	srv.mux.HandleFunc("GET /v1/search", srv.search)
+	return srv
+}
+
+type tokenKey struct{}
+
+func withToken(ctx context.Context, t Token) context.Context {
+	return context.WithValue(ctx, tokenKey{}, t)
+}
+
+func tokenFrom(ctx context.Context) Token {
+	t, _ := ctx.Value(tokenKey{}).(Token)
+	return t
+}
+
+// statusRecorder remembers the status code for the access log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	var token Token
+	defer func() {
+		srv.logAccess(AccessRecord{
+			Time:    time.Now(),
+			TokenID: token.ID,
+			Method:  r.Method,
+			Path:    r.URL.Path,
+			Status:  rec.status,
+			Remote:  r.RemoteAddr,
+		})
+	}()
+
+	secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		writeError(rec, http.StatusUnauthorized, errors.New("missing bearer token"))
+		return
+	}
+	var err error
+	if token, err = srv.tokens.Authenticate(secret); err != nil {
+		writeError(rec, http.StatusUnauthorized, err)
+		return
+	}
+	if token.Scope.ReadOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(rec, http.StatusForbidden, errors.New("token is read-only"))
+		return
+	}
+	srv.mux.ServeHTTP(rec, r.WithContext(withToken(r.Context(), token)))
+}
+
+func (srv *Server) logAccess(rec AccessRecord) {
+	if srv.accessLog == nil {
+		return
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	srv.logMu.Lock()
+	defer srv.logMu.Unlock()
+	srv.accessLog.Write(append(data, '\n'))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+var errOutOfScope = errors.New("entry is outside the token's folders")
+
+func (srv *Server) listPasswords(w http.ResponseWriter, r *http.Request) {
+	scope := tokenFrom(r.Context()).Scope
+	passwords := srv.storage.GetPasswords()
+	if q := r.URL.Query().Get("q"); q != "" {
+		passwords = srv.storage.Search(q).Passwords
+	}
+	writeJSON(w, http.StatusOK, filterPasswords(passwords, scope))
+}
+
+func filterPasswords(passwords []models.Password, scope Scope) []models.Password {
+	visible := []models.Password{}
+	for _, p := range passwords {
+		if scope.AllowsFolder(p.Folder) {
+			visible = append(visible, p)
+		}
+	}
+	return visible
+}
+
+// findPassword returns the entry with the ID in the request path, treating
+// entries outside the token's folders as missing.
+func (srv *Server) findPassword(r *http.Request) (models.Password, bool) {
+	scope := tokenFrom(r.Context()).Scope
+	for _, p := range srv.storage.GetPasswords() {
+		if p.ID == r.PathValue("id") && scope.AllowsFolder(p.Folder) {
+			return p, true
+		}
+	}
+	return models.Password{}, false
+}
+
+func (srv *Server) getPassword(w http.ResponseWriter, r *http.Request) {
+	p, ok := srv.findPassword(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("password not found"))
+		return
+	}
+	p, err := srv.storage.RevealPassword(p.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (srv *Server) addPassword(w http.ResponseWriter, r *http.Request) {
+	var p models.Password
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !tokenFrom(r.Context()).Scope.AllowsFolder(p.Folder) {
+		writeError(w, http.StatusForbidden, errOutOfScope)
+		return
+	}
+	now := time.Now()
+	p.ID = uuid.New().String()
+	p.CreatedAt, p.UpdatedAt = now, now
+	if err := srv.storage.AddPassword(p); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, p)
+}
+
+func (srv *Server) updatePassword(w http.ResponseWriter, r *http.Request) {
+	existing, ok := srv.findPassword(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("password not found"))
+		return
+	}
+	var p models.Password
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !tokenFrom(r.Context()).Scope.AllowsFolder(p.Folder) {
+		writeError(w, http.StatusForbidden, errOutOfScope)
+		return
+	}
+	p.ID, p.CreatedAt, p.UpdatedAt = existing.ID, existing.CreatedAt, time.Now()
+	if err := srv.storage.UpdatePassword(p); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (srv *Server) deletePassword(w http.ResponseWriter, r *http.Request) {
+	p, ok := srv.findPassword(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("password not found"))
+		return
+	}
+	if err := srv.storage.DeletePassword(p.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notesAllowed rejects folder-restricted tokens, since notes have no folder.
+func notesAllowed(w http.ResponseWriter, r *http.Request) bool {
+	if len(tokenFrom(r.Context()).Scope.Folders) > 0 {
+		writeError(w, http.StatusForbidden, errors.New("folder-restricted tokens cannot access notes"))
+		return false
+	}
+	return true
+}
+
+func (srv *Server) listNotes(w http.ResponseWriter, r *http.Request) {
+	if !notesAllowed(w, r) {
+		return
+	}
+	notes := srv.storage.GetNotes()
+	if q := r.URL.Query().Get("q"); q != "" {
+		notes = srv.storage.Search(q).Notes
+	}
+	writeJSON(w, http.StatusOK, append([]models.Note{}, notes...))
+}
+
+func (srv *Server) findNote(r *http.Request) (models.Note, bool) {
+	for _, n := range srv.storage.GetNotes() {
+		if n.ID == r.PathValue("id") {
+			return n, true
+		}
+	}
+	return models.Note{}, false
+}
+
+func (srv *Server) getNote(w http.ResponseWriter, r *http.Request) {
+	if !notesAllowed(w, r) {
+		return
+	}
+	n, ok := srv.findNote(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("note not found"))
+		return
+	}
+	n, err := srv.storage.RevealNote(n.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, n)
+}
+
+func (srv *Server) addNote(w http.ResponseWriter, r *http.Request) {
+	if !notesAllowed(w, r) {
+		return
+	}
+	var n models.Note
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	now := time.Now()
+	n.ID = uuid.New().String()
+	n.CreatedAt, n.UpdatedAt = now, now
+	if err := srv.storage.AddNote(n); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, n)
+}
+
+func (srv *Server) updateNote(w http.ResponseWriter, r *http.Request) {
+	if !notesAllowed(w, r) {
+		return
+	}
+	existing, ok := srv.findNote(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("note not found"))
+		return
+	}
+	var n models.Note
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	n.ID, n.CreatedAt, n.UpdatedAt = existing.ID, existing.CreatedAt, time.Now()
+	if err := srv.storage.UpdateNote(n); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, n)
+}
+
+func (srv *Server) deleteNote(w http.ResponseWriter, r *http.Request) {
+	if !notesAllowed(w, r) {
+		return
+	}
+	n, ok := srv.findNote(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("note not found"))
+		return
+	}
+	if err := srv.storage.DeleteNote(n.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (srv *Server) search(w http.ResponseWriter, r *http.Request) {
+	scope := tokenFrom(r.Context()).Scope
+	result := srv.storage.Search(r.URL.Query().Get("q"))
+	notes := []models.Note{}
+	if len(scope.Folders) == 0 {
+		notes = append(notes, result.Notes...)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"passwords": filterPasswords(result.Passwords, scope),
+		"notes":     notes,
+	})
+}
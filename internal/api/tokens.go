@@ -0,0 +1,173 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// tokenPrefix makes leaked tokens easy to recognise in logs and scanners.
+const tokenPrefix = "gpt_"
+
+var ErrInvalidToken = errors.New("invalid or revoked token")
+
+// Scope limits what a token may do. The zero Scope allows everything.
+type Scope struct {
+	ReadOnly bool `json:"read_only"`
+	// Folders restricts the token to password entries in these folders and
+	// their subfolders. Notes have no folder and are out of reach of
+	// folder-restricted tokens.
+	Folders   []string  `json:"folders,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// AllowsFolder reports whether an entry in folder is within the scope.
+func (s Scope) AllowsFolder(folder string) bool {
+	if len(s.Folders) == 0 {
+		return true
+	}
+	for _, f := range s.Folders {
+		f = strings.Trim(f, "/")
+		if folder == f || strings.HasPrefix(folder, f+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Token is a stored API token. Only a hash of the secret is kept.
+type Token struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	Scope     Scope     `json:"scope"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (t Token) Expired(now time.Time) bool {
+	return !t.Scope.ExpiresAt.IsZero() && !now.Before(t.Scope.ExpiresAt)
+}
+
+// TokenStore keeps tokens in a JSON file. The file is read on every lookup,
+// so revoking a token takes effect in a running server immediately.
+type TokenStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewTokenStore(path string) *TokenStore {
+	return &TokenStore{path: path}
+}
+
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func (ts *TokenStore) load() ([]Token, error) {
+	data, err := os.ReadFile(ts.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tokens []Token
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (ts *TokenStore) save(tokens []Token) error {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ts.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(ts.path, data, 0600)
+}
+
+// Create stores a new token and returns its secret, which is not kept and
+// cannot be shown again.
+func (ts *TokenStore) Create(name string, scope Scope) (string, Token, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", Token{}, err
+	}
+	secret := tokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	t := Token{
+		ID:        uuid.New().String()[:8],
+		Name:      name,
+		Hash:      hashToken(secret),
+		Scope:     scope,
+		CreatedAt: time.Now(),
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	tokens, err := ts.load()
+	if err != nil {
+		return "", Token{}, err
+	}
+	if err := ts.save(append(tokens, t)); err != nil {
+		return "", Token{}, err
+	}
+	return secret, t, nil
+}
+
+func (ts *TokenStore) List() ([]Token, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.load()
+}
+
+// Revoke deletes the token with the given ID.
+func (ts *TokenStore) Revoke(id string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	tokens, err := ts.load()
+	if err != nil {
+		return err
+	}
+	for i, t := range tokens {
+		if t.ID == id {
+			return ts.save(append(tokens[:i], tokens[i+1:]...))
+		}
+	}
+	return errors.New("token not found")
+}
+
+// Authenticate returns the token matching secret if it exists and has not
+// expired.
+func (ts *TokenStore) Authenticate(secret string) (Token, error) {
+	if !strings.HasPrefix(secret, tokenPrefix) {
+		return Token{}, ErrInvalidToken
+	}
+	tokens, err := ts.List()
+	if err != nil {
+		return Token{}, err
+	}
+	hash := hashToken(secret)
+	for _, t := range tokens {
+		if t.Hash == hash {
+			if t.Expired(time.Now()) {
+				return Token{}, errors.New("token expired")
+			}
+			return t, nil
+		}
+	}
+	return Token{}, ErrInvalidToken
+}
@@ -0,0 +1,373 @@
+// Package audit keeps an encrypted, hash-chained record of what was done
+// with a vault. Each line of the log is one sealed entry naming the hash
+// of the entry before it, so entries that are changed, removed or
+// reordered break the chain.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+	"gopass/internal/events"
+)
+
+var ErrTampered = errors.New("audit log has been tampered with")
+
+type Action string
+
+const (
+	Unlock       Action = "unlock"
+	FailedUnlock Action = "failed_unlock"
+	View         Action = "view"
+	Copy         Action = "copy"
+	Export       Action = "export"
+	Import       Action = "import"
+	Add          Action = "add"
+	Update       Action = "update"
+	Delete       Action = "delete"
+	Sync         Action = "sync"
+)
+
+// Actions lists every action, for filters to choose from.
+var Actions = []Action{Unlock, FailedUnlock, View, Copy, Export, Import, Add, Update, Delete, Sync}
+
+// Entry is one thing done with the vault. Kind and ID are empty for
+// vault-wide actions.
+type Entry struct {
+	Seq    int         `json:"seq"`
+	Time   time.Time   `json:"time"`
+	Action Action      `json:"action"`
+	Kind   events.Kind `json:"kind,omitempty"`
+	ID     string      `json:"id,omitempty"`
+	Client string      `json:"client"`
+	Prev   string      `json:"prev"`
+	Hash   string      `json:"hash"`
+}
+
+func (e Entry) hash() (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log appends to the audit log at path. Lines sealed with another key are
+// skipped: both vaults of a duress pair share the file, and each sees only
+// its own chain.
+type Log struct {
+	path   string
+	client string
+	gcm    cipher.AEAD
+	// slot is the line of the head file that is ours, see OpenSlot.
+	slot int
+
+	mu   sync.Mutex
+	size int64
+	seq  int
+	head string
+}
+
+// Open opens the log at path for the vault whose key is given. Entries it
+// records name client. Failed unlocks noted since the last Open are moved
+// into the log.
+func Open(path string, key []byte, client string) (*Log, error) {
+	return OpenSlot(path, key, 0, client)
+}
+
+// OpenSlot is Open for the vault in slot of a duress pair, which keeps its
+// head in that line of the head file.
+func OpenSlot(path string, key []byte, slot int, client string) (*Log, error) {
+	if slot < 0 || slot >= headLines {
+		return nil, errSlot
+	}
+	l, err := newLog(path, key, client)
+	if err != nil {
+		return nil, err
+	}
+	l.slot = slot
+	if _, err := l.Entries(); err != nil && !errors.Is(err, ErrTampered) {
+		return nil, err
+	}
+	return l, l.takeFailures()
+}
+
+func newLog(path string, key []byte, client string) (*Log, error) {
+	logKey := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte("gopass audit log")), logKey); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(logKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{path: path, client: client, gcm: gcm}, nil
+}
+
+// Rekey re-seals the entries and head at path that open with from under
+// to, for a vault moving to a new key. Lines of the other vault of a
+// duress pair are left as they are.
+func Rekey(path string, from, to []byte) error {
+	l, err := newLog(path, from, "")
+	if err != nil {
+		return err
+	}
+	return l.Rekey(to)
+}
+
+// Rekey moves the log to key, re-sealing its entries and head. Logs kept
+// open by others go on with key once they call Rekey too.
+func (l *Log) Rekey(key []byte) error {
+	next, err := newLog(l.path, key, l.client)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	unlock, err := l.lockFile()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	for _, p := range []string{l.path, headPath(l.path)} {
+		data, err := os.ReadFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+		for i, line := range lines {
+			if plain, ok := l.unseal(string(line)); ok {
+				if lines[i], err = next.seal(plain); err != nil {
+					return err
+				}
+			}
+		}
+		tmp := p + ".tmp"
+		if err := os.WriteFile(tmp, append(bytes.Join(lines, []byte("\n")), '\n'), 0600); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, p); err != nil {
+			return err
+		}
+	}
+	l.gcm = next.gcm
+	if _, err := l.scan(); err != nil && !errors.Is(err, ErrTampered) {
+		return err
+	}
+	return nil
+}
+
+// Record appends an entry for action on the entry of kind with id.
+func (l *Log) Record(action Action, kind events.Kind, id string) error {
+	return l.append(Entry{Time: time.Now(), Action: action, Kind: kind, ID: id, Client: l.client})
+}
+
+var eventActions = map[events.Type]Action{
+	events.Revealed: View,
+	events.Added:    Add,
+	events.Updated:  Update,
+	events.Deleted:  Delete,
+	events.Imported: Import,
+	events.Exported: Export,
+	events.Synced:   Sync,
+}
+
+// Watch records the changes and reveals published on bus.
+func (l *Log) Watch(bus *events.Bus) func() {
+	return bus.Subscribe(func(e events.Event) {
+		action, ok := eventActions[e.Type]
+		if !ok {
+			return
+		}
+		// The bus has no way to report errors; a failed write shows as a
+		// gap when the chain is next checked
+		l.append(Entry{Time: e.Time, Action: action, Kind: e.Kind, ID: e.ID, Client: l.client})
+	})
+}
+
+func (l *Log) append(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	unlock, err := l.lockFile()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return l.appendLocked(e)
+}
+
+// lockFile keeps other processes from appending until unlock is called.
+func (l *Log) lockFile() (unlock func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(l.path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := flock(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	// Closing the file releases the lock
+	return func() { f.Close() }, nil
+}
+
+// appendLocked appends e while l.mu and the file lock are held.
+func (l *Log) appendLocked(e Entry) error {
+	// Pick up entries other processes appended since we last looked
+	if info, err := os.Stat(l.path); err == nil && info.Size() != l.size {
+		if _, err := l.scan(); err != nil && !errors.Is(err, ErrTampered) {
+			return err
+		}
+	}
+
+	e.Seq, e.Prev = l.seq+1, l.head
+	var err error
+	if e.Hash, err = e.hash(); err != nil {
+		return err
+	}
+	plain, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	sealed, err := l.seal(plain)
+	if err != nil {
+		return err
+	}
+	line := string(sealed) + "\n"
+
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(line)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	l.seq, l.head = e.Seq, e.Hash
+	l.size += int64(len(line))
+	return l.writeHead(head{Seq: e.Seq, Hash: e.Hash})
+}
+
+// seal encrypts one line of the log or its head.
+func (l *Log) seal(plain []byte) ([]byte, error) {
+	nonce := make([]byte, l.gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := l.gcm.Seal(nonce, nonce, plain, nil)
+	out := make([]byte, base64.StdEncoding.EncodedLen(len(sealed)))
+	base64.StdEncoding.Encode(out, sealed)
+	return out, nil
+}
+
+// Entries reads the whole log, oldest first. When the chain is broken the
+// entries are returned all the same, with an error wrapping ErrTampered
+// that names the first break.
+func (l *Log) Entries() ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.scan()
+}
+
+func (l *Log) scan() ([]Entry, error) {
+	recorded, _, ours, err := l.readHead()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		l.size, l.seq, l.head = 0, 0, ""
+		if ours >= 0 && recorded.Seq > 0 {
+			// Later entries carry on from the head, keeping the gap in view
+			l.seq, l.head = recorded.Seq, recorded.Hash
+			return nil, fmt.Errorf("%w: the log is missing", ErrTampered)
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	var broken error
+	var size int64
+	seq, head := 0, ""
+	lines := bufio.NewScanner(f)
+	lines.Buffer(nil, 1<<20)
+	for lines.Scan() {
+		size += int64(len(lines.Bytes())) + 1
+		e, ok := l.open(lines.Text())
+		if !ok {
+			continue
+		}
+		if broken == nil {
+			if hash, err := e.hash(); err != nil || hash != e.Hash {
+				broken = fmt.Errorf("%w: entry %d does not match its hash", ErrTampered, e.Seq)
+			} else if e.Prev != head || e.Seq != seq+1 {
+				broken = fmt.Errorf("%w: entries missing or reordered before entry %d", ErrTampered, e.Seq)
+			}
+		}
+		entries = append(entries, e)
+		seq, head = e.Seq, e.Hash
+	}
+	if err := lines.Err(); err != nil {
+		return entries, err
+	}
+	if ours >= 0 && recorded.Seq > seq {
+		if broken == nil {
+			broken = fmt.Errorf("%w: the log ends at entry %d, before its recorded head %d", ErrTampered, seq, recorded.Seq)
+		}
+		seq, head = recorded.Seq, recorded.Hash
+	}
+	l.size, l.seq, l.head = size, seq, head
+	return entries, broken
+}
+
+func (l *Log) open(line string) (Entry, bool) {
+	var e Entry
+	plain, ok := l.unseal(line)
+	return e, ok && json.Unmarshal(plain, &e) == nil
+}
+
+// unseal decrypts one line of the log or its head, if it is sealed with
+// l's key.
+func (l *Log) unseal(line string) ([]byte, bool) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(line))
+	if err != nil || len(sealed) < l.gcm.NonceSize() {
+		return nil, false
+	}
+	n := l.gcm.NonceSize()
+	plain, err := l.gcm.Open(nil, sealed[:n], sealed[n:], nil)
+	return plain, err == nil
+}
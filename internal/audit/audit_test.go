@@ -0,0 +1,171 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopass/internal/events"
+)
+
+func testKey(b byte) []byte {
+	key := make([]byte, 32)
+	key[0] = b
+	return key
+}
+
+func TestLogRecordsEventsAndFailures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	require.NoError(t, RecordFailure(path, "cli show"))
+
+	l, err := Open(path, testKey(1), "gui")
+	require.NoError(t, err)
+	require.NoError(t, l.Record(Unlock, "", ""))
+	bus := events.NewBus()
+	l.Watch(bus)
+	bus.Publish(events.Event{Type: events.Revealed, Kind: events.KindPassword, ID: "p1"})
+	bus.Publish(events.Event{Type: events.Reloaded})
+
+	entries, err := l.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, FailedUnlock, entries[0].Action)
+	assert.Equal(t, "cli show", entries[0].Client)
+	assert.Equal(t, View, entries[2].Action)
+	assert.Equal(t, "p1", entries[2].ID)
+	assert.Equal(t, 3, entries[2].Seq)
+
+	_, err = os.Stat(failuresPath(path))
+	assert.True(t, os.IsNotExist(err))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), `"p1"`)
+
+	views := Select(entries, Filter{Action: View})
+	assert.Len(t, views, 1)
+	assert.Len(t, Select(entries, Filter{Text: "CLI"}), 1)
+}
+
+func TestLogDetectsRemovedEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path, testKey(1), "gui")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, l.Record(View, events.KindNote, "n1"))
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(string(data), "\n")
+	require.NoError(t, os.WriteFile(path, []byte(lines[0]+lines[2]), 0600))
+
+	entries, err := l.Entries()
+	assert.ErrorIs(t, err, ErrTampered)
+	assert.Len(t, entries, 2)
+}
+
+func TestLogsOfDifferentKeysShareAFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	a, err := Open(path, testKey(1), "gui")
+	require.NoError(t, err)
+	b, err := Open(path, testKey(2), "gui")
+	require.NoError(t, err)
+	require.NoError(t, a.Record(Unlock, "", ""))
+	require.NoError(t, b.Record(Unlock, "", ""))
+	require.NoError(t, a.Record(Copy, events.KindPassword, "p1"))
+
+	entries, err := a.Entries()
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+	entries, err = b.Entries()
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestRekeyKeepsTheChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	a, err := Open(path, testKey(1), "gui")
+	require.NoError(t, err)
+	b, err := Open(path, testKey(2), "gui")
+	require.NoError(t, err)
+	require.NoError(t, a.Record(Unlock, "", ""))
+	require.NoError(t, b.Record(Unlock, "", ""))
+	require.NoError(t, a.Record(View, events.KindNote, "n1"))
+
+	require.NoError(t, Rekey(path, testKey(1), testKey(3)))
+	moved, err := Open(path, testKey(3), "gui")
+	require.NoError(t, err)
+	entries, err := moved.Entries()
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+	entries, err = a.Entries()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// A log kept open follows once it is moved too
+	require.NoError(t, a.Rekey(testKey(3)))
+	require.NoError(t, a.Record(Copy, events.KindNote, "n1"))
+	entries, err = moved.Entries()
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+	entries, err = b.Entries()
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestLogDetectsTruncationAndRemoval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path, testKey(1), "gui")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, l.Record(View, events.KindNote, "n1"))
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(string(data), "\n")
+	require.NoError(t, os.WriteFile(path, []byte(lines[0]+lines[1]), 0600))
+	entries, err := l.Entries()
+	assert.ErrorIs(t, err, ErrTampered)
+	assert.Len(t, entries, 2)
+
+	// Appending does not paper over the gap
+	require.NoError(t, l.Record(Unlock, "", ""))
+	entries, err = l.Entries()
+	assert.ErrorIs(t, err, ErrTampered)
+	assert.Equal(t, 4, entries[2].Seq)
+
+	require.NoError(t, os.Remove(path))
+	l, err = Open(path, testKey(1), "gui")
+	require.NoError(t, err)
+	_, err = l.Entries()
+	assert.ErrorIs(t, err, ErrTampered)
+}
+
+func TestConcurrentAppendsKeepTheChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		// Separate logs stand in for separate processes
+		l, err := Open(path, testKey(1), "gui")
+		require.NoError(t, err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				assert.NoError(t, l.Record(View, events.KindNote, "n1"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	l, err := Open(path, testKey(1), "gui")
+	require.NoError(t, err)
+	entries, err := l.Entries()
+	require.NoError(t, err)
+	assert.Len(t, entries, 40)
+}
@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Failed unlocks happen without the key, so they are noted in the clear
+// next to the log, with nothing but the time and client, and sealed into
+// the log by the next Open.
+
+type failure struct {
+	Time   time.Time `json:"time"`
+	Client string    `json:"client"`
+}
+
+func failuresPath(path string) string {
+	return path + ".failed"
+}
+
+// RecordFailure notes a failed unlock of the vault whose log is at path.
+func RecordFailure(path, client string) error {
+	line, err := json.Marshal(failure{Time: time.Now(), Client: client})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(failuresPath(path), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (l *Log) takeFailures() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	unlock, err := l.lockFile()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := os.Open(failuresPath(l.path))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		var fail failure
+		if json.Unmarshal(lines.Bytes(), &fail) != nil {
+			continue
+		}
+		if err := l.appendLocked(Entry{Time: fail.Time, Action: FailedUnlock, Client: fail.Client}); err != nil {
+			return err
+		}
+	}
+	if err := lines.Err(); err != nil {
+		return err
+	}
+	return os.Remove(failuresPath(l.path))
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"strings"
+	"time"
+
+	"gopass/internal/events"
+)
+
+// Filter selects entries; zero fields match everything.
+type Filter struct {
+	Action Action
+	Kind   events.Kind
+	ID     string
+	Client string
+	Since  time.Time
+	Until  time.Time
+	// Text matches the action, kind, ID or client, ignoring case.
+	Text string
+}
+
+func (f Filter) Match(e Entry) bool {
+	switch {
+	case f.Action != "" && e.Action != f.Action,
+		f.Kind != "" && e.Kind != f.Kind,
+		f.ID != "" && e.ID != f.ID,
+		f.Client != "" && e.Client != f.Client,
+		!f.Since.IsZero() && e.Time.Before(f.Since),
+		!f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	}
+	if f.Text == "" {
+		return true
+	}
+	text := strings.ToLower(f.Text)
+	for _, field := range []string{string(e.Action), string(e.Kind), e.ID, e.Client} {
+		if strings.Contains(strings.ToLower(field), text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns the entries f matches.
+func Select(entries []Entry, f Filter) []Entry {
+	var out []Entry
+	for _, e := range entries {
+		if f.Match(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
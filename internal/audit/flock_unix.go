@@ -0,0 +1,13 @@
+//go:build unix
+
+package audit
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func flock(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_EX)
+}
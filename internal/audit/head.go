@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// The hash chain only shows changes inside the log. The head of the chain
+// is also kept sealed next to it, so a log cut short or removed shows as
+// ending before the head. Like the log, the file holds a line for each
+// vault of a duress pair. It always has both lines, the other one random
+// until a second vault writes it, so it shows nothing of a decoy.
+
+// headLines is how many lines the head file has.
+const headLines = 2
+
+var errSlot = errors.New("no such audit log slot")
+
+type head struct {
+	Seq  int    `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+func headPath(path string) string {
+	return path + ".head"
+}
+
+// readHead returns the recorded head, and the lines of the file with the
+// index of ours, or -1.
+func (l *Log) readHead() (head, [][]byte, int, error) {
+	var h head
+	f, err := os.Open(headPath(l.path))
+	if os.IsNotExist(err) {
+		return h, nil, -1, nil
+	}
+	if err != nil {
+		return h, nil, -1, err
+	}
+	defer f.Close()
+
+	var lines [][]byte
+	ours := -1
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := append([]byte{}, scanner.Bytes()...)
+		lines = append(lines, line)
+		if plain, ok := l.unseal(string(line)); ok && json.Unmarshal(plain, &h) == nil {
+			ours = len(lines) - 1
+		}
+	}
+	return h, lines, ours, scanner.Err()
+}
+
+func (l *Log) writeHead(h head) error {
+	_, lines, ours, err := l.readHead()
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	sealed, err := l.seal(plain)
+	if err != nil {
+		return err
+	}
+	for len(lines) < headLines {
+		random := make([]byte, l.gcm.NonceSize()+len(plain)+l.gcm.Overhead())
+		if _, err := rand.Read(random); err != nil {
+			return err
+		}
+		lines = append(lines, []byte(base64.StdEncoding.EncodeToString(random)))
+	}
+	if ours >= 0 && ours != l.slot {
+		// Moved to another slot; the line there belongs to the other vault
+		lines[ours] = lines[l.slot]
+	}
+	lines[l.slot] = sealed
+	tmp := headPath(l.path) + ".tmp"
+	if err := os.WriteFile(tmp, append(bytes.Join(lines, []byte("\n")), '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, headPath(l.path))
+}
+
+// MoveHead puts the head of the log at path sealed with key into slot, for
+// a vault moving to another slot of its duress pair.
+func MoveHead(path string, key []byte, slot int) error {
+	if slot < 0 || slot >= headLines {
+		return errSlot
+	}
+	l, err := newLog(path, key, "")
+	if err != nil {
+		return err
+	}
+	l.slot = slot
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	unlock, err := l.lockFile()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	h, _, ours, err := l.readHead()
+	if err != nil || ours < 0 || ours == l.slot {
+		return err
+	}
+	return l.writeHead(h)
+}
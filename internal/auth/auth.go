@@ -1,72 +1,203 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+// Derive stretches a PIN the way the vault derives its key, so checking a
+// guess against pin.hash costs as much as trying it on the vault.
+type Derive func(pin string) ([]byte, error)
+
+// Auth checks PINs against the verifiers in pin.hash, one per line. A
+// verifier is a random salt and a MAC of it keyed with the stretched PIN.
+// The file always holds Slots verifiers, unused ones random, so it does
+// not tell whether a duress PIN is set. The slot of the PIN that matched
+// picks the vault file to open.
 type Auth struct {
-	pinHash     string
-	currentPIN  string
+	verifiers []string
+	slot      int
+	dir       string
+	derive    Derive
 }
 
+// Slots is the number of PINs pin.hash has room for.
+const Slots = 2
+
 func NewAuth() *Auth {
 	return &Auth{}
 }
 
-func (a *Auth) SetPIN(pin string) error {
-	hash := sha256.Sum256([]byte(pin))
-	a.pinHash = hex.EncodeToString(hash[:])
-	a.currentPIN = pin
-	
+// NewAuthAt keeps the verifiers in dir instead of the default config
+// directory, stretching PINs with derive.
+func NewAuthAt(dir string, derive Derive) *Auth {
+	return &Auth{dir: dir, derive: derive}
+}
+
+func (a *Auth) appDir() (string, error) {
+	if a.dir != "" {
+		return a.dir, nil
+	}
 	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "gopass"), nil
+}
+
+func (a *Auth) stretch(pin string) ([]byte, error) {
+	if a.derive == nil {
+		sum := sha256.Sum256([]byte(pin))
+		return sum[:], nil
+	}
+	return a.derive(pin)
+}
+
+func pinMAC(stretched, salt []byte) []byte {
+	mac := hmac.New(sha256.New, stretched)
+	mac.Write([]byte("gopass pin check"))
+	mac.Write(salt)
+	return mac.Sum(nil)
+}
+
+func newVerifier(stretched []byte) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return enc.EncodeToString(salt) + "$" + enc.EncodeToString(pinMAC(stretched, salt)), nil
+}
+
+// matches checks pin against one verifier. Verifiers written before they
+// were salted are the plain SHA-256 of the PIN.
+func matches(verifier, pin string, stretched []byte) bool {
+	salt, mac, ok := strings.Cut(verifier, "$")
+	if !ok {
+		sum := sha256.Sum256([]byte(pin))
+		return subtle.ConstantTimeCompare([]byte(verifier), []byte(hex.EncodeToString(sum[:]))) == 1
+	}
+	saltBytes, err1 := base64.RawStdEncoding.DecodeString(salt)
+	macBytes, err2 := base64.RawStdEncoding.DecodeString(mac)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	return hmac.Equal(macBytes, pinMAC(stretched, saltBytes))
+}
+
+// SetPIN changes the PIN of the slot last unlocked, keeping the others.
+func (a *Auth) SetPIN(pin string) error {
+	return a.SetSlotPIN(a.slot, pin)
+}
+
+// SetSlotPIN sets the PIN of slot.
+func (a *Auth) SetSlotPIN(slot int, pin string) error {
+	if slot < 0 || slot >= Slots {
+		return errors.New("no such PIN slot")
+	}
+	stretched, err := a.stretch(pin)
+	if err != nil {
+		return err
+	}
+	verifier, err := newVerifier(stretched)
+	if err != nil {
+		return err
+	}
+	if err := a.fillSlots(); err != nil {
+		return err
+	}
+	a.verifiers[slot] = verifier
+	a.slot = slot
+	return a.save()
+}
+
+// fillSlots gives every unused slot a verifier no PIN matches.
+func (a *Auth) fillSlots() error {
+	for len(a.verifiers) < Slots {
+		random := make([]byte, sha256.Size)
+		if _, err := rand.Read(random); err != nil {
+			return err
+		}
+		verifier, err := newVerifier(random)
+		if err != nil {
+			return err
+		}
+		a.verifiers = append(a.verifiers, verifier)
+	}
+	return nil
+}
+
+func (a *Auth) save() error {
+	if err := a.fillSlots(); err != nil {
+		return err
+	}
+	appDir, err := a.appDir()
 	if err != nil {
 		return err
 	}
-	
-	appDir := filepath.Join(configDir, "gopass")
 	if err := os.MkdirAll(appDir, 0700); err != nil {
 		return err
 	}
-	
-	return os.WriteFile(filepath.Join(appDir, "pin.hash"), []byte(a.pinHash), 0600)
+	return os.WriteFile(filepath.Join(appDir, "pin.hash"), []byte(strings.Join(a.verifiers, "\n")), 0600)
 }
 
 func (a *Auth) ValidatePIN(pin string) bool {
-	hash := sha256.Sum256([]byte(pin))
-	inputHash := hex.EncodeToString(hash[:])
-	if a.pinHash == inputHash {
-		a.currentPIN = pin
-		return true
+	stretched, err := a.stretch(pin)
+	if err != nil {
+		return false
+	}
+	// Check every slot so the time taken does not tell them apart
+	match := -1
+	for i, v := range a.verifiers {
+		if matches(v, pin, stretched) {
+			match = i
+		}
+	}
+	if match < 0 {
+		return false
+	}
+	a.slot = match
+	if !strings.Contains(a.verifiers[match], "$") {
+		// Best effort: replace the unsalted hash now that the PIN is known
+		if verifier, err := newVerifier(stretched); err == nil {
+			a.verifiers[match] = verifier
+			a.save()
+		}
 	}
-	return false
+	return true
+}
+
+// Slot is the slot of the PIN last validated or set.
+func (a *Auth) Slot() int {
+	return a.slot
 }
 
 func (a *Auth) LoadPINHash() error {
-	configDir, err := os.UserConfigDir()
+	appDir, err := a.appDir()
 	if err != nil {
 		return err
 	}
-	
-	pinHash, err := os.ReadFile(filepath.Join(configDir, "gopass", "pin.hash"))
+
+	data, err := os.ReadFile(filepath.Join(appDir, "pin.hash"))
 	if err != nil {
 		if os.IsNotExist(err) {
 			return errors.New("PIN not set")
 		}
 		return err
 	}
-	
-	a.pinHash = string(pinHash)
+
+	a.verifiers = strings.Fields(string(data))
 	return nil
 }
 
 func (a *Auth) IsPINSet() bool {
-	return a.pinHash != ""
-}
-
-func (a *Auth) GetCurrentPIN() string {
-	return a.currentPIN
+	return len(a.verifiers) > 0
 }
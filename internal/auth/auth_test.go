@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPINHashIsSaltedAndStretched(t *testing.T) {
+	dir := t.TempDir()
+	stretched := 0
+	derive := func(pin string) ([]byte, error) {
+		stretched++
+		sum := sha256.Sum256([]byte("slow " + pin))
+		return sum[:], nil
+	}
+	require.NoError(t, NewAuthAt(dir, derive).SetPIN("1234"))
+	require.NoError(t, NewAuthAt(dir, derive).SetPIN("1234"))
+
+	data, err := os.ReadFile(filepath.Join(dir, "pin.hash"))
+	require.NoError(t, err)
+	sum := sha256.Sum256([]byte("1234"))
+	assert.NotContains(t, string(data), hex.EncodeToString(sum[:]))
+
+	first := string(data)
+	require.NoError(t, NewAuthAt(dir, derive).SetPIN("1234"))
+	data, err = os.ReadFile(filepath.Join(dir, "pin.hash"))
+	require.NoError(t, err)
+	assert.NotEqual(t, first, string(data), "each verifier has its own salt")
+
+	a := NewAuthAt(dir, derive)
+	require.NoError(t, a.LoadPINHash())
+	stretched = 0
+	assert.True(t, a.ValidatePIN("1234"))
+	assert.False(t, a.ValidatePIN("4321"))
+	assert.Equal(t, 2, stretched)
+}
+
+func TestUnsaltedPINHashIsReplaced(t *testing.T) {
+	dir := t.TempDir()
+	sum := sha256.Sum256([]byte("1234"))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pin.hash"), []byte(hex.EncodeToString(sum[:])), 0600))
+
+	a := NewAuthAt(dir, nil)
+	require.NoError(t, a.LoadPINHash())
+	assert.False(t, a.ValidatePIN("0000"))
+	assert.True(t, a.ValidatePIN("1234"))
+
+	data, err := os.ReadFile(filepath.Join(dir, "pin.hash"))
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(string(data), "$"))
+	a = NewAuthAt(dir, nil)
+	require.NoError(t, a.LoadPINHash())
+	assert.True(t, a.ValidatePIN("1234"))
+}
+
+func TestPINHashAlwaysHasEverySlot(t *testing.T) {
+	dir := t.TempDir()
+	lines := func() int {
+		data, err := os.ReadFile(filepath.Join(dir, "pin.hash"))
+		require.NoError(t, err)
+		return len(strings.Fields(string(data)))
+	}
+	a := NewAuthAt(dir, nil)
+	require.NoError(t, a.SetPIN("1234"))
+	assert.Equal(t, Slots, lines())
+	require.NoError(t, a.SetSlotPIN(1, "9999"))
+	assert.Equal(t, Slots, lines())
+	assert.Error(t, a.SetSlotPIN(Slots, "5555"))
+
+	a = NewAuthAt(dir, nil)
+	require.NoError(t, a.LoadPINHash())
+	require.True(t, a.ValidatePIN("9999"))
+	assert.Equal(t, 1, a.Slot())
+	require.True(t, a.ValidatePIN("1234"))
+	assert.Equal(t, 0, a.Slot())
+}
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gopass/internal/agent"
+	"gopass/internal/models"
+	"gopass/internal/sshagent"
+)
+
+func init() {
+	register("agent", "keep the vault unlocked for other commands: agent [--idle 15m] [--no-confirm] [--ssh] | agent stop", runAgent)
+}
+
+func runAgent(c *CLI, args []string) error {
+	if len(args) > 0 && args[0] == "stop" {
+		return stopAgent(c)
+	}
+
+	fs := flag.NewFlagSet("agent", flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	idle := fs.Duration("idle", 15*time.Minute, "lock the vault and exit after this long without requests; 0 disables")
+	noConfirm := fs.Bool("no-confirm", false, "serve every client of this user without asking")
+	socket := fs.String("socket", "", "socket path instead of the per-vault default")
+	withSSH := fs.Bool("ssh", false, "also serve the vault's ssh keys as an ssh agent")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	v, err := c.registry.Current(c.vaultName)
+	if err != nil {
+		return err
+	}
+	path := *socket
+	if path == "" {
+		if path, err = agent.SocketPath(v.Name); err != nil {
+			return err
+		}
+	}
+
+	var prompt *prompter
+	if _, ok := c.terminal(); ok {
+		prompt = &prompter{in: bufio.NewReader(c.Stdin), out: c.Stderr}
+	}
+	approve := agent.AlwaysApprove
+	if !*noConfirm {
+		if prompt == nil {
+			return errors.New("approving clients needs a terminal; use --no-confirm to skip approval")
+		}
+		approve = func(peer agent.Peer, req agent.Request) bool {
+			return prompt.ask(fmt.Sprintf("Allow %s to use vault %s (first request: %s)?", peer, v.Name, req.Op))
+		}
+	}
+
+	// Take the lock so the GUI cannot overwrite changes made through the agent
+	s, err := c.openVault(v.Name, true)
+	if err != nil {
+		return err
+	}
+	srv := agent.NewServer(s, v.Name, approve, *idle)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+			srv.Stop()
+		case <-srv.Done():
+		}
+	}()
+
+	fmt.Fprintf(c.Stdout, "GOPASS_AGENT_SOCK=%s; export GOPASS_AGENT_SOCK\n", path)
+	if *withSSH {
+		sshPath := filepath.Join(filepath.Dir(path), "ssh-"+v.Name+".sock")
+		sshSrv := sshagent.NewServer(sshagent.New(s, func(k models.SSHKey) bool {
+			// Keys that need confirmation are refused when nobody can confirm
+			return prompt != nil && prompt.ask(fmt.Sprintf("Allow use of ssh key %s?", k.Name))
+		}))
+		go func() {
+			if err := sshSrv.ListenAndServe(sshPath); err != nil {
+				fmt.Fprintln(c.Stderr, "gopass: ssh agent:", err)
+			}
+		}()
+		// The keys go away together with the unlocked vault
+		go func() {
+			<-srv.Done()
+			sshSrv.Close()
+		}()
+		fmt.Fprintf(c.Stdout, "SSH_AUTH_SOCK=%s; export SSH_AUTH_SOCK\n", sshPath)
+	}
+	return srv.ListenAndServe(path)
+}
+
+// prompter asks yes/no questions on the terminal, one at a time, for
+// clients that may connect concurrently.
+type prompter struct {
+	mu  sync.Mutex
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (p *prompter) ask(question string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.out, "%s [y/N] ", question)
+	answer, err := p.in.ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func stopAgent(c *CLI) error {
+	v, err := c.registry.Current(c.vaultName)
+	if err != nil {
+		return err
+	}
+	path, err := agent.SocketPath(v.Name)
+	if err != nil {
+		return err
+	}
+	client, err := agent.Dial(path)
+	if err != nil {
+		return fmt.Errorf("no agent running for vault %s", v.Name)
+	}
+	defer client.Close()
+	return client.Stop()
+}
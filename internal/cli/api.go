@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"gopass/internal/api"
+)
+
+func init() {
+	register("api", "local REST API: api serve [--listen 127.0.0.1:8377 | --socket PATH] | api token create|list|revoke", runAPI)
+}
+
+func runAPI(c *CLI, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: gopass api serve|token")
+	}
+	switch args[0] {
+	case "serve":
+		return serveAPI(c, args[1:])
+	case "token":
+		return runAPIToken(c, args[1:])
+	default:
+		return fmt.Errorf("unknown api command %q", args[0])
+	}
+}
+
+// apiFiles returns where the selected vault keeps its API tokens and access
+// log.
+func (c *CLI) apiFiles() (tokens, accessLog string, err error) {
+	v, err := c.registry.Current(c.vaultName)
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Join(v.LocalPath(), "api-tokens.json"), filepath.Join(v.LocalPath(), "api-access.log"), nil
+}
+
+func serveAPI(c *CLI, args []string) error {
+	fs := flag.NewFlagSet("api serve", flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	listen := fs.String("listen", "127.0.0.1:8377", "loopback address to listen on")
+	socket := fs.String("socket", "", "Unix socket to listen on instead of a TCP address")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var l net.Listener
+	var err error
+	if *socket != "" {
+		os.Remove(*socket)
+		if l, err = net.Listen("unix", *socket); err == nil {
+			defer os.Remove(*socket)
+			err = os.Chmod(*socket, 0600)
+		}
+	} else {
+		if err := checkLoopback(*listen); err != nil {
+			return err
+		}
+		l, err = net.Listen("tcp", *listen)
+	}
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	tokensPath, logPath, err := c.apiFiles()
+	if err != nil {
+		return err
+	}
+	accessLog, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer accessLog.Close()
+
+	s, err := c.openVault(c.vaultName, true)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	srv := &http.Server{
+		Handler:           api.NewServer(s, api.NewTokenStore(tokensPath), accessLog),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		srv.Close()
+	}()
+
+	fmt.Fprintf(c.Stderr, "Serving the vault API on %s\n", l.Addr())
+	if err := srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// checkLoopback refuses addresses reachable from other machines.
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("%s is not a loopback address", addr)
+}
+
+func runAPIToken(c *CLI, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: gopass api token create|list|revoke")
+	}
+	tokensPath, _, err := c.apiFiles()
+	if err != nil {
+		return err
+	}
+	tokens := api.NewTokenStore(tokensPath)
+
+	switch args[0] {
+	case "create":
+		return createAPIToken(c, tokens, args[1:])
+	case "list":
+		list, err := tokens.List()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, t := range list {
+			fmt.Fprintf(c.Stdout, "%s  %-20s %s\n", t.ID, t.Name, describeScope(t, now))
+		}
+		return nil
+	case "revoke":
+		if len(args) != 2 {
+			return errors.New("usage: gopass api token revoke ID")
+		}
+		return tokens.Revoke(args[1])
+	default:
+		return fmt.Errorf("unknown token command %q", args[0])
+	}
+}
+
+func createAPIToken(c *CLI, tokens *api.TokenStore, args []string) error {
+	fs := flag.NewFlagSet("api token create", flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	readOnly := fs.Bool("read-only", false, "only allow reading entries")
+	var folders []string
+	fs.Func("folder", "restrict the token to password entries in this folder; repeatable", func(f string) error {
+		folders = append(folders, f)
+		return nil
+	})
+	ttl := fs.Duration("ttl", 0, "expire the token after this long; 0 never expires")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: gopass api token create [--read-only] [--folder F]... [--ttl 24h] NAME")
+	}
+
+	scope := api.Scope{ReadOnly: *readOnly, Folders: folders}
+	if *ttl > 0 {
+		scope.ExpiresAt = time.Now().Add(*ttl)
+	}
+	secret, t, err := tokens.Create(fs.Arg(0), scope)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Stderr, "Created token %s; it is shown only once.\n", t.ID)
+	fmt.Fprintln(c.Stdout, secret)
+	return nil
+}
+
+func describeScope(t api.Token, now time.Time) string {
+	var parts []string
+	if t.Scope.ReadOnly {
+		parts = append(parts, "read-only")
+	} else {
+		parts = append(parts, "read-write")
+	}
+	if len(t.Scope.Folders) > 0 {
+		parts = append(parts, "folders="+strings.Join(t.Scope.Folders, ","))
+	}
+	switch {
+	case t.Scope.ExpiresAt.IsZero():
+	case t.Expired(now):
+		parts = append(parts, "expired")
+	default:
+		parts = append(parts, "expires "+t.Scope.ExpiresAt.Format(time.RFC3339))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"gopass/internal/audit"
+	"gopass/internal/events"
+)
+
+func init() {
+	register("audit", "print or export the vault's audit log: audit [--action A] [--kind K] [--id ID] [--client C] [--since T] [--until T] [--format text|json|csv] [--out FILE]", runAudit)
+}
+
+func runAudit(c *CLI, args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	action := fs.String("action", "", "only entries with this action")
+	kind := fs.String("kind", "", "only entries of this kind: password, note or ssh_key")
+	id := fs.String("id", "", "only entries about the entry with this ID")
+	client := fs.String("client", "", "only entries from this client")
+	since := fs.String("since", "", "only entries from this time on: RFC 3339, a date, or a duration ago such as 24h")
+	until := fs.String("until", "", "only entries before this time")
+	format := fs.String("format", "text", "output format: text, json or csv")
+	out := fs.String("out", "", "write to this file instead of standard output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: gopass audit [--action A] [--kind K] [--id ID] [--client C] [--since T] [--until T] [--format text|json|csv] [--out FILE]")
+	}
+	f := audit.Filter{Action: audit.Action(*action), Kind: events.Kind(*kind), ID: *id, Client: *client}
+	var err error
+	if f.Since, err = parseAuditTime(*since); err != nil {
+		return err
+	}
+	if f.Until, err = parseAuditTime(*until); err != nil {
+		return err
+	}
+
+	v, err := c.registry.Current(c.vaultName)
+	if err != nil {
+		return err
+	}
+	v, pin, err := c.unlock(v)
+	if err != nil {
+		return err
+	}
+	log, err := v.OpenAudit(pin, c.client)
+	if err != nil {
+		return err
+	}
+	entries, chainErr := log.Entries()
+	if chainErr != nil && !errors.Is(chainErr, audit.ErrTampered) {
+		return chainErr
+	}
+	entries = audit.Select(entries, f)
+
+	w := c.Stdout
+	if *out != "" {
+		file, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := writeAudit(w, *format, entries); err != nil {
+		return err
+	}
+	if *out != "" {
+		fmt.Fprintf(c.Stdout, "Wrote %d audit entries to %s\n", len(entries), *out)
+	}
+	// Report a broken chain last, so it is not lost above the entries
+	return chainErr
+}
+
+func writeAudit(w io.Writer, format string, entries []audit.Entry) error {
+	switch format {
+	case "text":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, e := range entries {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", e.Seq, e.Time.Local().Format(time.DateTime), e.Action, e.Kind, e.ID, e.Client)
+		}
+		return tw.Flush()
+	case "json":
+		if entries == nil {
+			entries = []audit.Entry{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"seq", "time", "action", "kind", "id", "client", "prev", "hash"})
+		for _, e := range entries {
+			cw.Write([]string{strconv.Itoa(e.Seq), e.Time.Format(time.RFC3339Nano), string(e.Action), string(e.Kind), e.ID, e.Client, e.Prev, e.Hash})
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+func parseAuditTime(text string) (time.Time, error) {
+	if text == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(text); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, text); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, text, time.Local)
+	if err != nil {
+		return t, fmt.Errorf("cannot parse time %q", text)
+	}
+	return t, nil
+}
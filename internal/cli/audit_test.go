@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopass/internal/audit"
+	"gopass/internal/models"
+)
+
+func TestAuditLogsViewsAndFailedUnlocks(t *testing.T) {
+	testEnv(t)
+	addPassword(t, models.Password{ID: "1", Name: "db", Password: "pw"})
+	run(t, "", "show", "db")
+
+	t.Setenv("GOPASS_PIN", "0000")
+	c := &CLI{Stdin: strings.NewReader(""), Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}}
+	assert.Equal(t, 1, c.Run([]string{"list"}))
+	t.Setenv("GOPASS_PIN", "1234")
+
+	var entries []audit.Entry
+	require.NoError(t, json.Unmarshal([]byte(run(t, "", "audit", "--format", "json")), &entries))
+	var actions []audit.Action
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+	}
+	assert.Contains(t, actions, audit.View)
+	assert.Contains(t, actions, audit.Add)
+	assert.Contains(t, actions, audit.FailedUnlock)
+
+	out := run(t, "", "audit", "--action", "view", "--id", "1")
+	assert.Contains(t, out, "cli show")
+	assert.NotContains(t, out, "failed_unlock")
+}
@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopass/internal/models"
+	"gopass/internal/nativemsg"
+	"gopass/internal/urlmatch"
+)
+
+// nativeHostName is the name browsers know the messaging host by.
+const nativeHostName = "com.gopass.native"
+
+func init() {
+	register("browser", "browser autofill: browser host | pending | approve CODE | forget ORIGIN | rule NAME "+strings.Join(urlmatch.Rules, "|")+" | manifest --extension-id ID [--firefox]", runBrowser)
+}
+
+func runBrowser(c *CLI, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: gopass browser host|pending|approve|forget|rule|manifest")
+	}
+	switch args[0] {
+	case "host":
+		return runBrowserHost(c)
+	case "pending":
+		return listPendingApprovals(c)
+	case "approve":
+		if len(args) != 2 {
+			return errors.New("usage: gopass browser approve CODE")
+		}
+		approvals, err := c.browserApprovals()
+		if err != nil {
+			return err
+		}
+		p, err := approvals.Approve(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.Stdout, "%s may now fill %s\n", p.Origin, p.EntryName)
+		return nil
+	case "forget":
+		if len(args) != 2 {
+			return errors.New("usage: gopass browser forget ORIGIN")
+		}
+		approvals, err := c.browserApprovals()
+		if err != nil {
+			return err
+		}
+		n, err := approvals.Forget(strings.TrimSuffix(strings.ToLower(args[1]), "/"))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.Stdout, "Removed %d approvals\n", n)
+		return nil
+	case "rule":
+		return setURLMatchRule(c, args[1:])
+	case "manifest":
+		return printNativeManifest(c, args[1:])
+	default:
+		return fmt.Errorf("unknown browser command %q", args[0])
+	}
+}
+
+func (c *CLI) browserApprovals() (*nativemsg.Approvals, error) {
+	v, err := c.registry.Current(c.vaultName)
+	if err != nil {
+		return nil, err
+	}
+	return nativemsg.NewApprovals(filepath.Join(v.LocalPath(), "browser-approvals.json")), nil
+}
+
+// runBrowserHost speaks the native-messaging protocol on Stdin and Stdout.
+// The browser starts it without a terminal, so the vault has to be served
+// by a running agent (or GOPASS_PIN must be set).
+func runBrowserHost(c *CLI) error {
+	approvals, err := c.browserApprovals()
+	if err != nil {
+		return err
+	}
+	host := nativemsg.NewHost(func() ([]models.Password, error) {
+		s, err := c.session(false)
+		if err != nil {
+			return nil, err
+		}
+		defer s.Close()
+		return s.Passwords()
+	}, func(id string) (models.Password, error) {
+		s, err := c.session(false)
+		if err != nil {
+			return models.Password{}, err
+		}
+		defer s.Close()
+		return s.RevealPassword(id)
+	}, approvals)
+	return host.Serve(c.Stdin, c.Stdout)
+}
+
+func listPendingApprovals(c *CLI) error {
+	approvals, err := c.browserApprovals()
+	if err != nil {
+		return err
+	}
+	pending, err := approvals.Pending()
+	if err != nil {
+		return err
+	}
+	for _, p := range pending {
+		fmt.Fprintf(c.Stdout, "%s  %-24s %s (%s ago)\n", p.Code, p.EntryName, p.Origin,
+			time.Since(p.RequestedAt).Round(time.Second))
+	}
+	return nil
+}
+
+func setURLMatchRule(c *CLI, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: gopass browser rule NAME " + strings.Join(urlmatch.Rules, "|"))
+	}
+	rule := args[1]
+	if !urlmatch.ValidRule(rule) {
+		return fmt.Errorf("unknown rule %q", rule)
+	}
+
+	s, err := c.session(true)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	passwords, err := s.Passwords()
+	if err != nil {
+		return err
+	}
+	p, err := findPassword(passwords, args[0])
+	if err != nil {
+		return err
+	}
+	p.URLMatch = rule
+	p.UpdatedAt = time.Now()
+	return s.UpdatePassword(p)
+}
+
+// printNativeManifest prints the host manifest to install in the browser's
+// NativeMessagingHosts directory.
+func printNativeManifest(c *CLI, args []string) error {
+	fs := flag.NewFlagSet("browser manifest", flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	extensionID := fs.String("extension-id", "", "ID of the gopass extension")
+	firefox := fs.Bool("firefox", false, "write a Firefox manifest instead of a Chromium one")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *extensionID == "" {
+		return errors.New("usage: gopass browser manifest --extension-id ID [--firefox]")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	manifest := map[string]any{
+		"name":        nativeHostName,
+		"description": "gopass autofill",
+		"path":        exe,
+		"type":        "stdio",
+	}
+	if *firefox {
+		manifest["allowed_extensions"] = []string{*extensionID}
+	} else {
+		manifest["allowed_origins"] = []string{"chrome-extension://" + *extensionID + "/"}
+	}
+	enc := json.NewEncoder(c.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(manifest)
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"gopass/internal/storage"
+	"gopass/internal/vault"
+)
+
+// runCipher shows the cipher suite of the current vault, or re-encrypts
+// the vault file with another one. Entry secrets stay sealed with
+// AES-256-GCM, see storage.Suite.
+func runCipher(c *CLI, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: gopass vault cipher [%s] (the vault file only; entry secrets stay %s)", suiteNames(), storage.AES256GCM)
+	}
+	if len(args) == 0 {
+		s, err := c.openVault(c.vaultName, false)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+		fmt.Fprintln(c.Stdout, s.Suite())
+		return nil
+	}
+	suite, err := storage.ParseSuite(args[0])
+	if err != nil {
+		return err
+	}
+	s, err := c.openVault(c.vaultName, true)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return c.setSuite(s, suite)
+}
+
+func (c *CLI) setSuite(s *storage.Storage, suite storage.Suite) error {
+	if err := s.SetSuite(suite); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Stdout, "Vault file encrypted with %s; entry secrets stay sealed with %s\n", suite, storage.AES256GCM)
+	return nil
+}
+
+// createSuite writes the new vault v with suite, unless it is the default.
+func (c *CLI) createSuite(v vault.Vault, pin string, suite storage.Suite) error {
+	if suite == storage.AES256GCM {
+		return nil
+	}
+	s, err := v.NewStorage(pin)
+	if err != nil {
+		return err
+	}
+	if s, err = c.loadStorage(s, true); err != nil {
+		return err
+	}
+	defer s.Close()
+	return c.setSuite(s, suite)
+}
+
+func suiteNames() string {
+	names := make([]string, len(storage.Suites))
+	for i, s := range storage.Suites {
+		names[i] = s.String()
+	}
+	return strings.Join(names, "|")
+}
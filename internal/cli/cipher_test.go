@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopass/internal/models"
+)
+
+func TestCipherReencryptsVault(t *testing.T) {
+	testEnv(t)
+	addPassword(t, models.Password{ID: "1", Name: "db", Password: "pw"})
+	assert.Equal(t, "aes-256-gcm\n", run(t, "", "vault", "cipher"))
+
+	out := run(t, "", "vault", "cipher", "xchacha20-poly1305")
+	assert.Contains(t, out, "encrypted with xchacha20-poly1305")
+	assert.Contains(t, out, "entry secrets stay sealed with aes-256-gcm")
+	assert.Equal(t, "xchacha20-poly1305\n", run(t, "", "vault", "cipher"))
+	assert.Contains(t, run(t, "", "show", "db"), "pw")
+}
@@ -0,0 +1,269 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"golang.org/x/term"
+	"gopass/internal/audit"
+	"gopass/internal/config"
+	"gopass/internal/secmem"
+	"gopass/internal/storage"
+	"gopass/internal/vault"
+)
+
+// exitStatus ends the command with a specific exit code and no message,
+// such as the status of a child process.
+type exitStatus int
+
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// errReported is returned by commands that already told the caller about a
+// failure in their own protocol.
+var errReported error = exitStatus(1)
+
+type command struct {
+	usage string
+	run   func(c *CLI, args []string) error
+}
+
+var commands = map[string]command{}
+
+func register(name, usage string, run func(c *CLI, args []string) error) {
+	commands[name] = command{usage: usage, run: run}
+}
+
+// CLI runs one gopass command line. The streams are fields so tests and
+// helper protocols can replace them.
+type CLI struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	vaultName string
+	keyFile   string
+	config    *config.Config
+	registry  *vault.Registry
+	// client names the command in the audit log.
+	client string
+}
+
+// Run executes the command in args (without the program name) and returns
+// the process exit code.
+func Run(args []string) int {
+	c := &CLI{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+	return c.Run(args)
+}
+
+func (c *CLI) Run(args []string) int {
+	fs := flag.NewFlagSet("gopass", flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	fs.StringVar(&c.vaultName, "vault", "", "name of the vault to use instead of the default one")
+	fs.StringVar(&c.keyFile, "keyfile", os.Getenv("GOPASS_KEYFILE"), "key file of a vault that needs one besides the PIN")
+	fs.Usage = func() { c.usage(fs) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	rest := fs.Args()
+	if len(rest) == 0 {
+		c.usage(fs)
+		return 2
+	}
+	cmd, ok := commands[rest[0]]
+	if !ok {
+		fmt.Fprintf(c.Stderr, "gopass: unknown command %q\n", rest[0])
+		c.usage(fs)
+		return 2
+	}
+
+	c.client = "cli " + rest[0]
+	if err := c.loadRegistry(); err != nil {
+		fmt.Fprintln(c.Stderr, "gopass:", err)
+		return 1
+	}
+	if err := cmd.run(c, rest[1:]); err != nil {
+		var status exitStatus
+		if errors.As(err, &status) {
+			return int(status)
+		}
+		fmt.Fprintln(c.Stderr, "gopass:", err)
+		return 1
+	}
+	return 0
+}
+
+func (c *CLI) usage(fs *flag.FlagSet) {
+	fmt.Fprintln(c.Stderr, "Usage: gopass [--vault NAME] [--keyfile FILE] <command> [arguments]")
+	fmt.Fprintln(c.Stderr, "\nRun without arguments to start the graphical interface.")
+	fmt.Fprintln(c.Stderr, "\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(c.Stderr, "  %-14s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintln(c.Stderr, "\nGlobal flags:")
+	fs.PrintDefaults()
+}
+
+func (c *CLI) loadRegistry() error {
+	cfg, err := config.LoadEffective()
+	if err != nil {
+		return err
+	}
+	c.config = cfg
+	if c.vaultName == "" {
+		c.vaultName = cfg.Vault.Default
+	}
+
+	dir, err := cfg.VaultDir()
+	if err != nil {
+		return err
+	}
+	c.registry, err = vault.LoadRegistry(dir)
+	return err
+}
+
+// terminal returns the descriptor of Stdin when it is an interactive terminal.
+func (c *CLI) terminal() (int, bool) {
+	if f, ok := c.Stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		return int(f.Fd()), true
+	}
+	return 0, false
+}
+
+// readPIN prompts on the terminal without echo. When no terminal is
+// attached the PIN is taken from the GOPASS_PIN environment variable.
+func (c *CLI) readPIN(prompt string) (string, error) {
+	return c.readSecret(prompt, "GOPASS_PIN")
+}
+
+// readSecret prompts on the terminal without echo, falling back to the
+// environment variable env when Stdin is not a terminal. Helpers that speak
+// a protocol on Stdin, such as git's, still reach the controlling terminal.
+func (c *CLI) readSecret(prompt, env string) (string, error) {
+	if fd, ok := c.terminal(); ok {
+		fmt.Fprint(c.Stderr, prompt)
+		secret, err := term.ReadPassword(fd)
+		fmt.Fprintln(c.Stderr)
+		return string(secret), err
+	}
+	if secret, ok := os.LookupEnv(env); ok {
+		return secret, nil
+	}
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		defer tty.Close()
+		fmt.Fprint(tty, prompt)
+		secret, err := term.ReadPassword(int(tty.Fd()))
+		fmt.Fprintln(tty)
+		return string(secret), err
+	}
+	return "", fmt.Errorf("no terminal to read from; set %s", env)
+}
+
+// openVault unlocks the named vault (the default one when name is empty).
+// Writable vaults take the vault lock; read-only ones can be opened while
+// the GUI holds it.
+func (c *CLI) openVault(name string, writable bool) (*storage.Storage, error) {
+	v, err := c.registry.Current(name)
+	if err != nil {
+		return nil, err
+	}
+	v, pin, err := c.unlock(v)
+	if err != nil {
+		return nil, err
+	}
+	s, err := v.NewStorage(pin)
+	if err != nil {
+		return nil, err
+	}
+	if s, err = c.loadStorage(s, writable); err != nil {
+		return nil, err
+	}
+	if err := c.audit(v, pin, s); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// audit records the unlock of v and everything done with s from now on.
+func (c *CLI) audit(v vault.Vault, pin string, s *storage.Storage) error {
+	log, err := v.OpenAudit(pin, c.client)
+	if err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+	log.Watch(s.Events())
+	return log.Record(audit.Unlock, "", "")
+}
+
+// unlock asks for the PIN of v and checks it, and attaches the key file
+// given with --keyfile when v needs one.
+func (c *CLI) unlock(v vault.Vault) (vault.Vault, string, error) {
+	if v.KeyFile != nil {
+		if c.keyFile == "" {
+			return v, "", fmt.Errorf("vault %s needs its key file; pass --keyfile FILE", v.Name)
+		}
+		data, err := vault.ReadKeyFile(c.keyFile)
+		if err != nil {
+			return v, "", err
+		}
+		if v, err = v.WithKeyFile(data); err != nil {
+			audit.RecordFailure(v.AuditPath(), c.client)
+			return v, "", fmt.Errorf("%s: %w", c.keyFile, err)
+		}
+	}
+	a := v.NewAuth()
+	if err := a.LoadPINHash(); err != nil {
+		return v, "", fmt.Errorf("vault %s: %w", v.Name, err)
+	}
+	pin, err := c.readPIN(fmt.Sprintf("PIN for vault %s: ", v.Name))
+	if err != nil {
+		return v, "", err
+	}
+	if !a.ValidatePIN(pin) {
+		audit.RecordFailure(v.AuditPath(), c.client)
+		return v, "", errors.New("invalid PIN")
+	}
+	// The command ends soon, and with it the unlocked vault, so core dumps
+	// are never restored
+	if _, err := secmem.DisableCoreDumps(); err != nil {
+		fmt.Fprintln(c.Stderr, "gopass: warning: cannot disable core dumps; a crash may write the unlocked vault to disk:", err)
+	}
+	return v, pin, nil
+}
+
+func (c *CLI) loadStorage(s *storage.Storage, writable bool) (*storage.Storage, error) {
+	s.SetBackupCount(c.config.Backup.Count)
+	if writable {
+		if err := s.Lock(); err != nil {
+			return nil, err
+		}
+	} else {
+		s.OpenReadOnly()
+	}
+	if err := s.Load(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	if rb, ok := s.RolledBack(); ok {
+		fmt.Fprintln(c.Stderr, "gopass: warning:", rollbackWarning(rb))
+	}
+	return s, nil
+}
+
+func rollbackWarning(rb storage.Rollback) string {
+	if rb.Err != nil {
+		return fmt.Sprintf("cannot check the vault file against the revisions seen before (%v); it may have been replaced with an older copy. Saving a change accepts this version.", rb.Err)
+	}
+	return fmt.Sprintf("the vault file is at revision %d, but revision %d was seen before; it may have been replaced with an older copy. Saving a change accepts this version.", rb.Loaded, rb.Seen)
+}
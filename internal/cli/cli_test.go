@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopass/internal/models"
+)
+
+// testEnv points configuration, vaults and the agent socket at a temporary
+// directory and creates an unlocked-by-GOPASS_PIN default vault.
+func testEnv(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("GOPASS_CONFIG", filepath.Join(dir, "config.toml"))
+	t.Setenv("GOPASS_VAULT_DIR", filepath.Join(dir, "vaults"))
+	t.Setenv("GOPASS_AGENT_SOCK", filepath.Join(dir, "agent.sock"))
+	t.Setenv("GOPASS_PIN", "1234")
+
+	run(t, "", "vault", "create", "test")
+	run(t, "", "vault", "default", "test")
+}
+
+// run executes a command line with stdin and returns what it printed.
+func run(t *testing.T, stdin string, args ...string) string {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	c := &CLI{Stdin: strings.NewReader(stdin), Stdout: &stdout, Stderr: &stderr}
+	require.Equal(t, 0, c.Run(args), stderr.String())
+	return stdout.String()
+}
+
+// addPassword stores p in the default test vault.
+func addPassword(t *testing.T, p models.Password) {
+	t.Helper()
+	c := &CLI{Stdin: strings.NewReader(""), Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}}
+	require.NoError(t, c.loadRegistry())
+	s, err := c.openVault("", true)
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.AddPassword(p))
+}
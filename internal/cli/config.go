@@ -0,0 +1,16 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+)
+
+func init() {
+	register("config", "print the effective settings, including environment overrides", runConfig)
+}
+
+func runConfig(c *CLI, args []string) error {
+	fmt.Fprintf(c.Stdout, "# %s\n", c.config.FilePath())
+	return toml.NewEncoder(c.Stdout).Encode(c.config)
+}
@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gopass/internal/credential"
+	"gopass/internal/models"
+)
+
+// dockerFolder holds the registry credentials so they stay apart from the
+// entries people manage by hand.
+const dockerFolder = "docker"
+
+// errDockerNotFound is the exact message docker's helper client looks for.
+var errDockerNotFound = errors.New("credentials not found in native keychain")
+
+func init() {
+	register("docker-credential", "docker credential helper (get|store|erase|list); also runs when installed as docker-credential-gopass", runDockerCredential)
+}
+
+type dockerCredentials struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// runDockerCredential implements docker's credential helper protocol.
+// Docker reads errors from stdout, so they are reported there.
+func runDockerCredential(c *CLI, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: gopass docker-credential get|store|erase|list")
+	}
+
+	var err error
+	switch args[0] {
+	case "get":
+		err = dockerGet(c)
+	case "store":
+		err = dockerStore(c)
+	case "erase":
+		err = dockerErase(c)
+	case "list":
+		err = dockerList(c)
+	default:
+		err = fmt.Errorf("unknown credential action %q", args[0])
+	}
+	if err != nil {
+		fmt.Fprintln(c.Stdout, err)
+		return errReported
+	}
+	return nil
+}
+
+func readServerURL(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	serverURL := strings.TrimSpace(string(data))
+	if serverURL == "" {
+		return "", errors.New("no credentials server URL")
+	}
+	return serverURL, nil
+}
+
+func dockerEntries(s session) ([]models.Password, error) {
+	passwords, err := s.Passwords()
+	if err != nil {
+		return nil, err
+	}
+	var entries []models.Password
+	for _, p := range passwords {
+		if p.Folder == dockerFolder {
+			entries = append(entries, p)
+		}
+	}
+	return entries, nil
+}
+
+// findDockerEntry prefers the exact server URL docker stored and falls back
+// to an entry for the same registry host.
+func findDockerEntry(entries []models.Password, serverURL string) (models.Password, bool) {
+	for _, p := range entries {
+		if p.URL == serverURL {
+			return p, true
+		}
+	}
+	target, ok := credential.ParseURL(serverURL)
+	if !ok {
+		return models.Password{}, false
+	}
+	target.Path = ""
+	if matches := credential.Match(entries, target); len(matches) > 0 {
+		return matches[0], true
+	}
+	return models.Password{}, false
+}
+
+func dockerGet(c *CLI) error {
+	serverURL, err := readServerURL(c.Stdin)
+	if err != nil {
+		return err
+	}
+	s, err := c.session(false)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	entries, err := dockerEntries(s)
+	if err != nil {
+		return err
+	}
+	p, ok := findDockerEntry(entries, serverURL)
+	if !ok {
+		return errDockerNotFound
+	}
+	if p, err = s.RevealPassword(p.ID); err != nil {
+		return err
+	}
+	return json.NewEncoder(c.Stdout).Encode(dockerCredentials{
+		ServerURL: serverURL,
+		Username:  p.Username,
+		Secret:    p.Password,
+	})
+}
+
+func dockerStore(c *CLI) error {
+	var creds dockerCredentials
+	if err := json.NewDecoder(c.Stdin).Decode(&creds); err != nil {
+		return err
+	}
+	if creds.ServerURL == "" {
+		return errors.New("no credentials server URL")
+	}
+	s, err := c.session(true)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	entries, err := dockerEntries(s)
+	if err != nil {
+		return err
+	}
+	for _, p := range entries {
+		if p.URL == creds.ServerURL {
+			p, err := s.RevealPassword(p.ID)
+			if err != nil {
+				return err
+			}
+			p.Username = creds.Username
+			p.Password = creds.Secret
+			p.UpdatedAt = time.Now()
+			return s.UpdatePassword(p)
+		}
+	}
+
+	p := models.NewPassword()
+	p.ID = uuid.New().String()
+	p.Name = creds.ServerURL
+	if target, ok := credential.ParseURL(creds.ServerURL); ok {
+		p.Name = target.Host
+	}
+	p.Folder = dockerFolder
+	p.URL = creds.ServerURL
+	p.Username = creds.Username
+	p.Password = creds.Secret
+	return s.AddPassword(*p)
+}
+
+func dockerErase(c *CLI) error {
+	serverURL, err := readServerURL(c.Stdin)
+	if err != nil {
+		return err
+	}
+	s, err := c.session(true)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	entries, err := dockerEntries(s)
+	if err != nil {
+		return err
+	}
+	for _, p := range entries {
+		if p.URL == serverURL {
+			return s.DeletePassword(p.ID)
+		}
+	}
+	return errDockerNotFound
+}
+
+func dockerList(c *CLI) error {
+	s, err := c.session(false)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	entries, err := dockerEntries(s)
+	if err != nil {
+		return err
+	}
+	list := make(map[string]string, len(entries))
+	for _, p := range entries {
+		list[p.URL] = p.Username
+	}
+	return json.NewEncoder(c.Stdout).Encode(list)
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDockerCredentialRoundTrip(t *testing.T) {
+	testEnv(t)
+
+	run(t, `{"ServerURL":"https://registry.example.com","Username":"ci","Secret":"tok1"}`, "docker-credential", "store")
+	run(t, `{"ServerURL":"https://index.docker.io/v1/","Username":"hub","Secret":"tok2"}`, "docker-credential", "store")
+
+	assert.JSONEq(t, `{"ServerURL":"https://registry.example.com","Username":"ci","Secret":"tok1"}`,
+		run(t, "https://registry.example.com\n", "docker-credential", "get"))
+	assert.JSONEq(t, `{"ServerURL":"registry.example.com","Username":"ci","Secret":"tok1"}`,
+		run(t, "registry.example.com", "docker-credential", "get"))
+	assert.JSONEq(t, `{"https://registry.example.com":"ci","https://index.docker.io/v1/":"hub"}`,
+		run(t, "", "docker-credential", "list"))
+	assert.Contains(t, run(t, "", "list"), "docker/registry.example.com")
+
+	run(t, "https://registry.example.com", "docker-credential", "erase")
+	var stdout bytes.Buffer
+	c := &CLI{Stdin: strings.NewReader("https://registry.example.com"), Stdout: &stdout, Stderr: &bytes.Buffer{}}
+	assert.Equal(t, 1, c.Run([]string{"docker-credential", "get"}))
+	assert.Equal(t, "credentials not found in native keychain\n", stdout.String())
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+)
+
+// runDuress adds a duress PIN to a vault. It opens a decoy vault, which
+// should then be filled with a few plausible entries.
+func runDuress(c *CLI, args []string) error {
+	if len(args) > 1 {
+		return errors.New("usage: gopass vault duress [NAME]")
+	}
+	name := c.vaultName
+	if len(args) == 1 {
+		name = args[0]
+	}
+	v, err := c.registry.Current(name)
+	if err != nil {
+		return err
+	}
+	v, pin, err := c.unlock(v)
+	if err != nil {
+		return err
+	}
+	duress, err := c.readSecret("Duress PIN: ", "GOPASS_DURESS_PIN")
+	if err != nil {
+		return err
+	}
+	if len(duress) < c.config.Security.MinPINLength {
+		return fmt.Errorf("PIN must be at least %d characters", c.config.Security.MinPINLength)
+	}
+	if _, ok := c.terminal(); ok {
+		confirm, err := c.readSecret("Confirm duress PIN: ", "GOPASS_DURESS_PIN")
+		if err != nil {
+			return err
+		}
+		if confirm != duress {
+			return errors.New("PINs do not match")
+		}
+	}
+	if err := v.SetDuressPIN(pin, duress); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Stdout, "Duress PIN set for vault %s; unlock with it to fill the decoy vault\n", v.Name)
+	return nil
+}
@@ -0,0 +1,212 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"gopass/internal/models"
+)
+
+func init() {
+	register("list", "list the passwords and notes in the vault", runList)
+	register("search", "search entries by name, username or content: search QUERY", runSearch)
+	register("show", "print an entry including its secret: show [--note] NAME", runShow)
+	register("copy", "copy an entry into another vault: copy [--note] --to VAULT NAME", func(c *CLI, args []string) error {
+		return runTransfer(c, "copy", args)
+	})
+	register("move", "move an entry into another vault: move [--note] --to VAULT NAME", func(c *CLI, args []string) error {
+		return runTransfer(c, "move", args)
+	})
+}
+
+func runList(c *CLI, args []string) error {
+	s, err := c.session(false)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	passwords, err := s.Passwords()
+	if err != nil {
+		return err
+	}
+	notes, err := s.Notes()
+	if err != nil {
+		return err
+	}
+	printEntries(c, passwords, notes)
+	return nil
+}
+
+func runSearch(c *CLI, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: gopass search QUERY")
+	}
+	s, err := c.session(false)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	result, err := s.Search(args[0])
+	if err != nil {
+		return err
+	}
+	printEntries(c, result.Passwords, result.Notes)
+	return nil
+}
+
+func printEntries(c *CLI, passwords []models.Password, notes []models.Note) {
+	for _, p := range passwords {
+		fmt.Fprintf(c.Stdout, "password  %-24s %-24s %s\n", p.Path(), p.Username, p.URL)
+	}
+	for _, n := range notes {
+		fmt.Fprintf(c.Stdout, "note      %s\n", n.Title)
+	}
+}
+
+func runShow(c *CLI, args []string) error {
+	fs := flag.NewFlagSet("show", flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	isNote := fs.Bool("note", false, "the entry is a note rather than a password")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: gopass show [--note] NAME")
+	}
+
+	s, err := c.session(false)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if *isNote {
+		n, err := lookupNote(s, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.Stdout, "Title: %s\n", n.Title)
+		printFields(c, n.Fields)
+		fmt.Fprintf(c.Stdout, "\n%s\n", n.Content)
+		return nil
+	}
+
+	p, err := lookupPassword(s, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Stdout, "Name: %s\nURL: %s\nUsername: %s\nPassword: %s\nNote: %s\n",
+		p.Name, p.URL, p.Username, p.Password, p.Note)
+	printFields(c, p.Fields)
+	return nil
+}
+
+func runTransfer(c *CLI, action string, args []string) error {
+	fs := flag.NewFlagSet(action, flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	to := fs.String("to", "", "name of the vault to "+action+" the entry into")
+	isNote := fs.Bool("note", false, "the entry is a note rather than a password")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *to == "" || fs.NArg() != 1 {
+		return fmt.Errorf("usage: gopass %s [--note] --to VAULT NAME", action)
+	}
+	name := fs.Arg(0)
+	move := action == "move"
+
+	src, err := c.openVault(c.vaultName, move)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := c.openVault(*to, true)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	if *isNote {
+		n, err := findNote(src.GetNotes(), name)
+		if err != nil {
+			return err
+		}
+		return src.TransferNote(n.ID, dst, move)
+	}
+	p, err := findPassword(src.GetPasswords(), name)
+	if err != nil {
+		return err
+	}
+	return src.TransferPassword(p.ID, dst, move)
+}
+
+// findPassword looks an entry up by ID or, failing that, by its exact name
+// or folder/name path.
+func findPassword(passwords []models.Password, name string) (models.Password, error) {
+	var matches []models.Password
+	for _, p := range passwords {
+		if p.ID == name {
+			return p, nil
+		}
+		if p.Name == name || p.Path() == name {
+			matches = append(matches, p)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return models.Password{}, errors.New("password not found")
+	case 1:
+		return matches[0], nil
+	default:
+		return models.Password{}, fmt.Errorf("%d passwords are named %q; use the entry ID", len(matches), name)
+	}
+}
+
+// lookupPassword finds a password like findPassword and reveals it.
+func lookupPassword(s session, name string) (models.Password, error) {
+	passwords, err := s.Passwords()
+	if err != nil {
+		return models.Password{}, err
+	}
+	p, err := findPassword(passwords, name)
+	if err != nil {
+		return p, err
+	}
+	return s.RevealPassword(p.ID)
+}
+
+func lookupNote(s session, title string) (models.Note, error) {
+	notes, err := s.Notes()
+	if err != nil {
+		return models.Note{}, err
+	}
+	n, err := findNote(notes, title)
+	if err != nil {
+		return n, err
+	}
+	return s.RevealNote(n.ID)
+}
+
+func findNote(notes []models.Note, title string) (models.Note, error) {
+	var matches []models.Note
+	for _, n := range notes {
+		if n.ID == title {
+			return n, nil
+		}
+		if n.Title == title {
+			matches = append(matches, n)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return models.Note{}, errors.New("note not found")
+	case 1:
+		return matches[0], nil
+	default:
+		return models.Note{}, fmt.Errorf("%d notes are titled %q; use the entry ID", len(matches), title)
+	}
+}
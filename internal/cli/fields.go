@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"gopass/internal/models"
+)
+
+func init() {
+	register("field", "set or remove a custom field: field set [--note] NAME FIELD [VALUE] | field remove [--note] NAME FIELD", runField)
+}
+
+func runField(c *CLI, args []string) error {
+	if len(args) == 0 || (args[0] != "set" && args[0] != "remove") {
+		return errors.New("usage: gopass field set|remove [--note] NAME FIELD [VALUE]")
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("field "+action, flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	isNote := fs.Bool("note", false, "the entry is a note rather than a password")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var value string
+	switch {
+	case action == "set" && fs.NArg() == 3:
+		value = fs.Arg(2)
+	case action == "set" && fs.NArg() == 2:
+		// Keep secret values out of the shell history
+		v, err := c.readSecret(fmt.Sprintf("Value for %s: ", fs.Arg(1)), "GOPASS_FIELD_VALUE")
+		if err != nil {
+			return err
+		}
+		value = v
+	case action == "remove" && fs.NArg() == 2:
+	default:
+		return fmt.Errorf("usage: gopass field %s [--note] NAME FIELD", action)
+	}
+	name, field := fs.Arg(0), fs.Arg(1)
+	if field == "" {
+		return errors.New("field name must not be empty")
+	}
+
+	s, err := c.session(true)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if *isNote {
+		n, err := lookupNote(s, name)
+		if err != nil {
+			return err
+		}
+		if n.Fields, err = editFields(n.Fields, action, field, value); err != nil {
+			return err
+		}
+		n.UpdatedAt = time.Now()
+		return s.UpdateNote(n)
+	}
+
+	p, err := lookupPassword(s, name)
+	if err != nil {
+		return err
+	}
+	if p.Fields, err = editFields(p.Fields, action, field, value); err != nil {
+		return err
+	}
+	p.UpdatedAt = time.Now()
+	return s.UpdatePassword(p)
+}
+
+func editFields(fields []models.Field, action, name, value string) ([]models.Field, error) {
+	fields = append([]models.Field{}, fields...)
+	for i, f := range fields {
+		if f.Name != name {
+			continue
+		}
+		if action == "remove" {
+			return append(fields[:i], fields[i+1:]...), nil
+		}
+		fields[i].Value = value
+		return fields, nil
+	}
+	if action == "remove" {
+		return nil, fmt.Errorf("entry has no field %q", name)
+	}
+	return append(fields, models.Field{Name: name, Value: value}), nil
+}
+
+func printFields(c *CLI, fields []models.Field) {
+	for _, f := range fields {
+		fmt.Fprintf(c.Stdout, "%s: %s\n", f.Name, f.Value)
+	}
+}
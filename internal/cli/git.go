@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+
+	"gopass/internal/gitvault"
+	"gopass/internal/storage"
+	"gopass/internal/vault"
+)
+
+func init() {
+	register("git", "git vault history and sync: git log [-n N] | remote [add NAME URL] | pull [REMOTE] | push [REMOTE] | sync [REMOTE]", runGit)
+}
+
+func runGit(c *CLI, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: gopass git log|remote|pull|push|sync")
+	}
+	v, err := c.registry.Current(c.vaultName)
+	if err != nil {
+		return err
+	}
+	if v.Backend != vault.BackendGit {
+		return fmt.Errorf("vault %s is not a git vault", v.Name)
+	}
+
+	remote := gitvault.DefaultRemote
+	if len(args) == 2 && (args[0] == "pull" || args[0] == "push" || args[0] == "sync") {
+		remote = args[1]
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "log":
+		return gitLog(c, v, args[1:])
+	case "remote":
+		return gitRemote(c, v, args[1:])
+	case "pull":
+		return c.withGitVault(v, func(s *storage.Storage, repo *gitvault.Repo) error {
+			return gitPull(ctx, c, s, repo, remote)
+		})
+	case "push":
+		return c.withGitVault(v, func(s *storage.Storage, repo *gitvault.Repo) error {
+			return repo.Push(ctx, remote)
+		})
+	case "sync":
+		return c.withGitVault(v, func(s *storage.Storage, repo *gitvault.Repo) error {
+			if err := gitPull(ctx, c, s, repo, remote); err != nil {
+				return err
+			}
+			return repo.Push(ctx, remote)
+		})
+	default:
+		return fmt.Errorf("unknown git action %q", args[0])
+	}
+}
+
+// withGitVault unlocks the vault for pulling and pushing, which need the key
+// to merge entries.
+func (c *CLI) withGitVault(v vault.Vault, fn func(*storage.Storage, *gitvault.Repo) error) error {
+	s, err := c.openVault(v.Name, true)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	repo, ok := s.Backend().(*gitvault.Repo)
+	if !ok {
+		return fmt.Errorf("vault %s is not a git vault", v.Name)
+	}
+	return fn(s, repo)
+}
+
+func gitPull(ctx context.Context, c *CLI, s *storage.Storage, repo *gitvault.Repo, remote string) error {
+	res, err := repo.Pull(ctx, s, remote)
+	if err != nil {
+		return err
+	}
+	if !res.Changed {
+		fmt.Fprintln(c.Stdout, "Already up to date")
+		return nil
+	}
+	if err := s.Replace(res.Data); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Stdout, "Pulled changes from %s\n", remote)
+	printConflicts(c, res.Conflicts)
+	return nil
+}
+
+func gitLog(c *CLI, v vault.Vault, args []string) error {
+	fs := flag.NewFlagSet("git log", flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	n := fs.Int("n", 20, "number of commits to show")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	// The history needs no key: entry names are not in commit messages
+	repo, err := gitvault.Open(v.RepoPath(), nil, nil)
+	if err != nil {
+		return err
+	}
+	commits, err := repo.Log(*n)
+	if err != nil {
+		return err
+	}
+	for _, commit := range commits {
+		fmt.Fprintf(c.Stdout, "%s %s %-16s %s\n", commit.Hash[:8], commit.When.Format("2006-01-02 15:04"), commit.Author, commit.Message)
+	}
+	return nil
+}
+
+func gitRemote(c *CLI, v vault.Vault, args []string) error {
+	repo, err := gitvault.Open(v.RepoPath(), nil, nil)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(args) == 0:
+		remotes, err := repo.Remotes()
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(remotes))
+		for name := range remotes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(c.Stdout, "%-16s %s\n", name, remotes[name])
+		}
+		return nil
+	case len(args) == 3 && args[0] == "add":
+		return repo.AddRemote(args[1], args[2])
+	default:
+		return errors.New("usage: gopass git remote [add NAME URL]")
+	}
+}
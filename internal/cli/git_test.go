@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopass/internal/models"
+)
+
+func TestGitVaultCloneAndSync(t *testing.T) {
+	testEnv(t)
+	bare := filepath.Join(t.TempDir(), "vault.git")
+	_, err := git.PlainInit(bare, true)
+	require.NoError(t, err)
+
+	run(t, "", "vault", "create", "--backend", "git", "work")
+	run(t, "", "vault", "default", "work")
+	addPassword(t, models.Password{ID: "1", Name: "jira", Password: "pw"})
+	assert.Contains(t, run(t, "", "git", "log"), "Add password 1")
+
+	run(t, "", "git", "remote", "add", "origin", bare)
+	assert.Contains(t, run(t, "", "git", "remote"), bare)
+	run(t, "", "git", "push")
+
+	run(t, "", "vault", "clone", "laptop", bare)
+	assert.Contains(t, run(t, "", "--vault", "laptop", "list"), "jira")
+
+	addPassword(t, models.Password{ID: "2", Name: "wiki", Password: "pw"})
+	run(t, "", "git", "push")
+	assert.Equal(t, "Pulled changes from origin\n", run(t, "", "--vault", "laptop", "git", "sync"))
+	assert.Contains(t, run(t, "", "--vault", "laptop", "list"), "wiki")
+}
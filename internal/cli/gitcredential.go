@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gopass/internal/credential"
+	"gopass/internal/models"
+)
+
+func init() {
+	register("git-credential", "git credential helper: git config credential.helper '!gopass git-credential [--store]'", runGitCredential)
+}
+
+// runGitCredential implements git's credential helper protocol. Store and
+// erase only touch the vault with --store; otherwise the vault stays the
+// single place credentials are managed.
+func runGitCredential(c *CLI, args []string) error {
+	fs := flag.NewFlagSet("git-credential", flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	store := fs.Bool("store", false, "save credentials git reports as working and remove rejected ones")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: gopass git-credential [--store] get|store|erase")
+	}
+
+	attrs, err := readCredentialAttrs(c.Stdin)
+	if err != nil {
+		return err
+	}
+	target, err := credentialTarget(attrs)
+	if err != nil {
+		return err
+	}
+
+	switch fs.Arg(0) {
+	case "get":
+		return gitCredentialGet(c, target)
+	case "store":
+		if !*store {
+			return nil
+		}
+		return gitCredentialStore(c, target, attrs["password"])
+	case "erase":
+		if !*store {
+			return nil
+		}
+		return gitCredentialErase(c, target, attrs["password"])
+	default:
+		// Git ignores helpers that do not understand an action
+		return nil
+	}
+}
+
+// readCredentialAttrs reads key=value lines up to a blank line or EOF.
+func readCredentialAttrs(r io.Reader) (map[string]string, error) {
+	attrs := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed credential line %q", line)
+		}
+		attrs[key] = value
+	}
+	return attrs, scanner.Err()
+}
+
+func credentialTarget(attrs map[string]string) (credential.Target, error) {
+	if raw, ok := attrs["url"]; ok {
+		t, ok := credential.ParseURL(raw)
+		if !ok {
+			return t, fmt.Errorf("invalid url %q", raw)
+		}
+		if u, ok := attrs["username"]; ok {
+			t.Username = u
+		}
+		return t, nil
+	}
+	t := credential.Target{
+		Protocol: attrs["protocol"],
+		Host:     attrs["host"],
+		Path:     attrs["path"],
+		Username: attrs["username"],
+	}
+	if t.Host == "" {
+		return t, errors.New("credential request has no host")
+	}
+	return t, nil
+}
+
+func gitCredentialGet(c *CLI, target credential.Target) error {
+	s, err := c.session(false)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	passwords, err := s.Passwords()
+	if err != nil {
+		return err
+	}
+	matches := credential.Match(passwords, target)
+	if len(matches) == 0 {
+		// Empty output lets git fall through to the next helper or a prompt
+		return nil
+	}
+	secret, err := s.PasswordSecret(matches[0].ID)
+	if err != nil {
+		return err
+	}
+	defer secret.Destroy()
+	// A line break would let the value add attributes of its own
+	if strings.ContainsAny(matches[0].Username, "\n\x00") || bytes.ContainsAny(secret.Bytes(), "\n\x00") {
+		return errors.New("the matching entry's username or password contains a line break or NUL")
+	}
+	fmt.Fprintf(c.Stdout, "username=%s\npassword=", matches[0].Username)
+	c.Stdout.Write(secret.Bytes())
+	fmt.Fprintln(c.Stdout)
+	return nil
+}
+
+func gitCredentialStore(c *CLI, target credential.Target, password string) error {
+	if target.Username == "" || password == "" {
+		return nil
+	}
+	s, err := c.session(true)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	passwords, err := s.Passwords()
+	if err != nil {
+		return err
+	}
+	if matches := credential.Match(passwords, target); len(matches) > 0 {
+		p, err := s.RevealPassword(matches[0].ID)
+		if err != nil {
+			return err
+		}
+		if p.Password == password {
+			return nil
+		}
+		p.Password = password
+		p.UpdatedAt = time.Now()
+		return s.UpdatePassword(p)
+	}
+
+	p := models.NewPassword()
+	p.ID = uuid.New().String()
+	p.Name = target.Host
+	p.URL = target.URL()
+	p.Username = target.Username
+	p.Password = password
+	return s.AddPassword(*p)
+}
+
+// gitCredentialErase removes the entry git rejected, but only when it still
+// holds the password that failed, so a freshly fixed entry survives.
+func gitCredentialErase(c *CLI, target credential.Target, password string) error {
+	s, err := c.session(true)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	passwords, err := s.Passwords()
+	if err != nil {
+		return err
+	}
+	for _, match := range credential.Match(passwords, target) {
+		p, err := s.RevealPassword(match.ID)
+		if err != nil {
+			return err
+		}
+		if password != "" && p.Password == password {
+			return s.DeletePassword(p.ID)
+		}
+	}
+	return nil
+}
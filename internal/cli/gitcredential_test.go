@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopass/internal/models"
+)
+
+func TestGitCredentialRoundTrip(t *testing.T) {
+	testEnv(t)
+
+	request := "protocol=https\nhost=git.example.com\npath=team/app.git\n\n"
+	assert.Empty(t, run(t, request, "git-credential", "get"))
+
+	// Without --store the vault is left alone
+	run(t, "protocol=https\nhost=git.example.com\nusername=ci\npassword=s3cret\n", "git-credential", "store")
+	assert.Empty(t, run(t, request, "git-credential", "get"))
+
+	run(t, "protocol=https\nhost=git.example.com\nusername=ci\npassword=s3cret\n", "git-credential", "--store", "store")
+	assert.Equal(t, "username=ci\npassword=s3cret\n", run(t, request, "git-credential", "get"))
+	assert.Empty(t, run(t, "protocol=https\nhost=other.example.com\n", "git-credential", "get"))
+	assert.Empty(t, run(t, "protocol=https\nhost=git.example.com\nusername=admin\n", "git-credential", "get"))
+
+	// A rejected password is erased only while the entry still holds it
+	run(t, "protocol=https\nhost=git.example.com\nusername=ci\npassword=old\n", "git-credential", "--store", "erase")
+	assert.Contains(t, run(t, request, "git-credential", "get"), "s3cret")
+	run(t, "protocol=https\nhost=git.example.com\nusername=ci\npassword=s3cret\n", "git-credential", "--store", "erase")
+	assert.Empty(t, run(t, request, "git-credential", "get"))
+}
+
+func TestGitCredentialRefusesLineBreaks(t *testing.T) {
+	testEnv(t)
+	addPassword(t, models.Password{ID: "p1", URL: "https://git.example.com", Username: "ci", Password: "pw\nhost=evil.example.com"})
+
+	var stdout bytes.Buffer
+	c := &CLI{Stdin: strings.NewReader("protocol=https\nhost=git.example.com\n\n"), Stdout: &stdout, Stderr: &bytes.Buffer{}}
+	assert.Equal(t, 1, c.Run([]string{"git-credential", "get"}))
+	assert.Empty(t, stdout.String())
+}
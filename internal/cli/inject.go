@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+func init() {
+	register("inject", "render {{ gopass://[@VAULT/]PATH[#FIELD] }} references in a template: inject [-i TEMPLATE] [-o OUTPUT]", runInject)
+}
+
+func runInject(c *CLI, args []string) error {
+	fs := flag.NewFlagSet("inject", flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	in := fs.String("i", "", "template to read instead of stdin")
+	out := fs.String("o", "", "file to write, readable by the owner only, instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: gopass inject [-i TEMPLATE] [-o OUTPUT]")
+	}
+
+	var tpl []byte
+	var err error
+	if *in == "" {
+		tpl, err = io.ReadAll(c.Stdin)
+	} else {
+		tpl, err = os.ReadFile(*in)
+	}
+	if err != nil {
+		return err
+	}
+
+	res, done := c.resolver()
+	rendered, err := res.Render(tpl)
+	done()
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = c.Stdout.Write(rendered)
+		return err
+	}
+	return writeSecretFile(*out, rendered)
+}
+
+// writeSecretFile replaces path with data. The temporary file is created
+// with mode 0600, so the secrets are never readable by others even briefly,
+// and an existing file's looser mode is not inherited.
+func writeSecretFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopass/internal/models"
+)
+
+func TestInjectRendersTemplate(t *testing.T) {
+	testEnv(t)
+	addPassword(t, models.Password{ID: "1", Folder: "infra", Name: "db", Username: "app", Password: "hunter2"})
+	run(t, "", "field", "set", "infra/db", "port", "5432")
+	assert.Contains(t, run(t, "", "show", "infra/db"), "port: 5432")
+
+	dir := t.TempDir()
+	tpl := filepath.Join(dir, "config.tpl")
+	out := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(tpl, []byte("db: {{ gopass://infra/db#username }}:{{ gopass://infra/db }}@localhost:{{ gopass://infra/db#port }}\n"), 0644))
+	require.NoError(t, os.WriteFile(out, []byte("old"), 0644))
+
+	run(t, "", "inject", "-i", tpl, "-o", out)
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "db: app:hunter2@localhost:5432\n", string(data))
+	info, err := os.Stat(out)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	var stderr bytes.Buffer
+	c := &CLI{Stdin: strings.NewReader("{{ gopass://infra/db#missing }}"), Stdout: &bytes.Buffer{}, Stderr: &stderr}
+	assert.Equal(t, 1, c.Run([]string{"inject", "-o", out}))
+	assert.Contains(t, stderr.String(), `gopass://infra/db#missing: entry has no field "missing"`)
+	data, err = os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "db: app:hunter2@localhost:5432\n", string(data), "a failed render leaves the output alone")
+}
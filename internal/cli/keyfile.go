@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"gopass/internal/vault"
+)
+
+func runKeyFile(c *CLI, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: gopass vault keyfile generate FILE | set FILE | remove")
+	}
+	switch args[0] {
+	case "generate":
+		if len(args) != 2 {
+			return errors.New("usage: gopass vault keyfile generate FILE")
+		}
+		if _, err := vault.GenerateKeyFile(args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.Stdout, "Wrote key file %s\n", args[1])
+		return nil
+	case "set":
+		if len(args) != 2 {
+			return errors.New("usage: gopass vault keyfile set FILE")
+		}
+		v, pin, err := c.unlockCurrent()
+		if err != nil {
+			return err
+		}
+		_, err = c.requireKeyFile(v, pin, args[1])
+		return err
+	case "remove":
+		v, pin, err := c.unlockCurrent()
+		if err != nil {
+			return err
+		}
+		if _, err := c.registry.SetKeyFile(v, pin, nil, nil); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.Stdout, "Vault %s opens with its PIN alone\n", v.Name)
+		return nil
+	default:
+		return fmt.Errorf("unknown keyfile action %q", args[0])
+	}
+}
+
+func (c *CLI) unlockCurrent() (vault.Vault, string, error) {
+	v, err := c.registry.Current(c.vaultName)
+	if err != nil {
+		return v, "", err
+	}
+	return c.unlock(v)
+}
+
+// requireKeyFile makes v need the key file at path, generating it first
+// when it does not exist. The vault is re-encrypted under a new key.
+func (c *CLI) requireKeyFile(v vault.Vault, pin, path string) (vault.Vault, error) {
+	data, err := vault.ReadKeyFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if data, err = vault.GenerateKeyFile(path); err == nil {
+			fmt.Fprintf(c.Stdout, "Wrote key file %s\n", path)
+		}
+	}
+	if err != nil {
+		return v, err
+	}
+	s, err := v.NewStorage(pin)
+	if err != nil {
+		return v, err
+	}
+	if s, err = c.loadStorage(s, true); err != nil {
+		return v, err
+	}
+	defer s.Close()
+	hadRecovery := v.HasRecovery()
+	if v, err = c.registry.SetKeyFile(v, pin, data, s.Rekey); err != nil {
+		return v, err
+	}
+	fmt.Fprintf(c.Stdout, "Vault %s now needs %s besides its PIN; keep a copy somewhere safe\n", v.Name, path)
+	if hadRecovery {
+		fmt.Fprintln(c.Stdout, "The recovery secret no longer works; set up recovery again with gopass vault recovery")
+	}
+	return v, nil
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopass/internal/models"
+)
+
+func TestKeyFileUnlocksVault(t *testing.T) {
+	testEnv(t)
+	addPassword(t, models.Password{ID: "1", Name: "db", Password: "pw"})
+	keyFile := filepath.Join(t.TempDir(), "vault.key")
+	other := filepath.Join(t.TempDir(), "other.key")
+	require.NoError(t, os.WriteFile(other, []byte("not the key"), 0600))
+
+	assert.Contains(t, run(t, "", "vault", "keyfile", "set", keyFile), "Wrote key file")
+
+	fails := func(args ...string) string {
+		var stderr bytes.Buffer
+		c := &CLI{Stdin: strings.NewReader(""), Stdout: &bytes.Buffer{}, Stderr: &stderr}
+		assert.Equal(t, 1, c.Run(args))
+		return stderr.String()
+	}
+	assert.Contains(t, fails("show", "db"), "needs its key file")
+	assert.Contains(t, fails("--keyfile", other, "show", "db"), "wrong key file")
+	assert.Contains(t, run(t, "", "--keyfile", keyFile, "show", "db"), "pw")
+
+	run(t, "", "--keyfile", keyFile, "vault", "keyfile", "remove")
+	assert.Contains(t, run(t, "", "show", "db"), "pw")
+}
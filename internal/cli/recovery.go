@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+
+	"gopass/internal/recovery"
+	"gopass/internal/vault"
+)
+
+// recoveryFlags are the options of vault create and vault recovery that
+// choose how a vault can be recovered without its PIN.
+type recoveryFlags struct {
+	key       *bool
+	shares    *int
+	threshold *int
+}
+
+func addRecoveryFlags(fs *flag.FlagSet) recoveryFlags {
+	return recoveryFlags{
+		key:       fs.Bool("recovery-key", false, "print a recovery key"),
+		shares:    fs.Int("shares", 0, "split the recovery secret into this many shares"),
+		threshold: fs.Int("threshold", 0, "number of shares needed to recover"),
+	}
+}
+
+func (f recoveryFlags) wanted() bool {
+	return *f.key || *f.shares > 0
+}
+
+func (f recoveryFlags) check() error {
+	if *f.shares == 0 && *f.threshold == 0 {
+		return nil
+	}
+	if *f.threshold < 2 || *f.threshold > *f.shares || *f.shares > 255 {
+		return errors.New("--shares and --threshold need 2 <= threshold <= shares <= 255")
+	}
+	return nil
+}
+
+// setUpRecovery seals the vault key with a new recovery secret and prints
+// the secret as f asks.
+func (c *CLI) setUpRecovery(v vault.Vault, pin string, f recoveryFlags) error {
+	secret, err := v.EnableRecovery(pin)
+	if err != nil {
+		return err
+	}
+	if *f.key {
+		fmt.Fprintf(c.Stdout, "Recovery key for vault %s. Print it and keep it somewhere safe:\n\n  %s\n\n",
+			v.Name, recovery.FormatKey(secret))
+	}
+	if *f.shares > 0 {
+		shares, err := recovery.Split(secret, *f.shares, *f.threshold)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.Stdout, "Recovery shares for vault %s. Any %d of them recover it; give one to each person:\n\n",
+			v.Name, *f.threshold)
+		for i, s := range shares {
+			fmt.Fprintf(c.Stdout, "  %d/%d  %s\n", i+1, len(shares), s)
+		}
+		fmt.Fprintln(c.Stdout)
+	}
+	return nil
+}
+
+// runRecovery replaces the recovery secret of an existing vault.
+func runRecovery(c *CLI, args []string) error {
+	fs := flag.NewFlagSet("vault recovery", flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	f := addRecoveryFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 || !f.wanted() {
+		return errors.New("usage: gopass vault recovery [--recovery-key] [--shares N --threshold K] [NAME]")
+	}
+	if err := f.check(); err != nil {
+		return err
+	}
+	v, err := c.registry.Current(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	v, pin, err := c.unlock(v)
+	if err != nil {
+		return err
+	}
+	return c.setUpRecovery(v, pin, f)
+}
+
+// recoverVault unlocks a vault whose PIN was forgotten with its recovery
+// key or enough shares, and sets a new PIN.
+func (c *CLI) recoverVault(name string) error {
+	v, err := c.registry.Current(name)
+	if err != nil {
+		return err
+	}
+	if !v.HasRecovery() {
+		return fmt.Errorf("vault %s has no recovery set up", v.Name)
+	}
+	secret, err := c.readRecoverySecret()
+	if err != nil {
+		return err
+	}
+	pin, err := c.newPIN()
+	if err != nil {
+		return err
+	}
+	if _, err := c.registry.Recover(v.Name, secret, pin); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Stdout, "Vault %s recovered with a new PIN\n", v.Name)
+	return nil
+}
+
+// readRecoverySecret asks for a recovery key, or for shares until there
+// are enough. Without a terminal they are read from Stdin, one per line.
+func (c *CLI) readRecoverySecret() ([]byte, error) {
+	var lines *bufio.Scanner
+	if _, ok := c.terminal(); !ok {
+		lines = bufio.NewScanner(c.Stdin)
+	}
+	read := func(prompt string) (string, error) {
+		if lines == nil {
+			return c.readSecret(prompt, "GOPASS_RECOVERY")
+		}
+		if !lines.Scan() {
+			return "", errors.New("not enough recovery shares given")
+		}
+		return lines.Text(), nil
+	}
+
+	text, err := read("Recovery key or share: ")
+	if err != nil {
+		return nil, err
+	}
+	if recovery.IsKey(text) {
+		return recovery.ParseKey(text)
+	}
+	share, err := recovery.ParseShare(text)
+	if err != nil {
+		return nil, err
+	}
+	shares := []recovery.Share{share}
+	for len(shares) < share.Threshold {
+		text, err := read(fmt.Sprintf("Share %d of %d: ", len(shares)+1, share.Threshold))
+		if err != nil {
+			return nil, err
+		}
+		s, err := recovery.ParseShare(text)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, s)
+	}
+	return recovery.Combine(shares)
+}
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopass/internal/models"
+)
+
+func TestRecoverVaultWithShares(t *testing.T) {
+	testEnv(t)
+	addPassword(t, models.Password{ID: "1", Name: "db", Password: "pw"})
+
+	out := run(t, "", "vault", "recovery", "--recovery-key", "--shares", "3", "--threshold", "2")
+	texts := regexp.MustCompile(`[A-Z2-7]{4}(?:-[A-Z2-7]{1,4})+`).FindAllString(out, -1)
+	require.Len(t, texts, 4, out)
+	key, shares := texts[0], texts[1:]
+
+	t.Setenv("GOPASS_PIN", "5678")
+	assert.Contains(t, run(t, shares[2]+"\n"+shares[0]+"\n", "vault", "recover"), "recovered")
+	assert.Contains(t, run(t, "", "show", "db"), "pw")
+
+	t.Setenv("GOPASS_PIN", "9012")
+	run(t, strings.ToLower(key)+"\n", "vault", "recover")
+	assert.Contains(t, run(t, "", "show", "db"), "pw")
+}
@@ -0,0 +1,266 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"gopass/internal/secretref"
+)
+
+func init() {
+	register("run", "run a command with secrets in its environment: run [--env NAME=gopass://[@VAULT/]PATH[#FIELD]]... [--env-file FILE] [--mask] -- COMMAND [ARGS]", runRun)
+}
+
+// envFlag collects repeated --env NAME=VALUE flags.
+type envFlag []string
+
+func (e *envFlag) String() string { return strings.Join(*e, ",") }
+
+func (e *envFlag) Set(value string) error {
+	if name, _, ok := strings.Cut(value, "="); !ok || name == "" {
+		return fmt.Errorf("%q is not NAME=VALUE", value)
+	}
+	*e = append(*e, value)
+	return nil
+}
+
+func runRun(c *CLI, args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	var envs envFlag
+	fs.Var(&envs, "env", "set NAME to a literal value or a gopass:// reference; repeatable")
+	envFile := fs.String("env-file", "", "read NAME=VALUE lines, values may be gopass:// references")
+	mask := fs.Bool("mask", false, "replace injected secrets in the command's output with *****")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: gopass run [--env NAME=REF]... [--env-file FILE] [--mask] -- COMMAND [ARGS]")
+	}
+
+	var vars []string
+	if *envFile != "" {
+		fileVars, err := readEnvFile(*envFile)
+		if err != nil {
+			return err
+		}
+		vars = append(vars, fileVars...)
+	}
+	// Flags come last so they override the file
+	vars = append(vars, envs...)
+
+	env, secrets, err := c.resolveEnv(vars)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(fs.Arg(0), fs.Args()[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = c.Stdin
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+	if *mask {
+		stdout := newMaskWriter(c.Stdout, secrets)
+		stderr := newMaskWriter(c.Stderr, secrets)
+		defer stdout.Flush()
+		defer stderr.Flush()
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+	}
+	return runChild(cmd)
+}
+
+// resolveEnv turns NAME=VALUE pairs into environment entries, resolving
+// references against the vault. Vaults are only opened when a reference
+// needs them and are closed again before the command starts.
+func (c *CLI) resolveEnv(vars []string) (env, secrets []string, err error) {
+	res, done := c.resolver()
+	defer done()
+	for _, v := range vars {
+		name, value, _ := strings.Cut(v, "=")
+		if !secretref.IsRef(value) {
+			env = append(env, name+"="+value)
+			continue
+		}
+		ref, err := secretref.Parse(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		secret, err := res.Resolve(ref)
+		if err != nil {
+			return nil, nil, err
+		}
+		env = append(env, name+"="+secret)
+		secrets = append(secrets, secret)
+	}
+	return env, secrets, nil
+}
+
+// resolver resolves references against the vaults they name, the selected
+// vault when they name none. Vaults stay open to reveal the entries that
+// references match until the returned func is called.
+func (c *CLI) resolver() (*secretref.Resolver, func()) {
+	var sessions []session
+	res := secretref.NewResolver(func(vaultName string) (secretref.Entries, error) {
+		if vaultName == "" {
+			vaultName = c.vaultName
+		}
+		s, err := c.sessionFor(vaultName, false)
+		if err != nil {
+			return secretref.Entries{}, err
+		}
+		sessions = append(sessions, s)
+
+		passwords, err := s.Passwords()
+		if err != nil {
+			return secretref.Entries{}, err
+		}
+		notes, err := s.Notes()
+		if err != nil {
+			return secretref.Entries{}, err
+		}
+		return secretref.Entries{
+			Passwords:      passwords,
+			Notes:          notes,
+			RevealPassword: s.RevealPassword,
+			RevealNote:     s.RevealNote,
+		}, nil
+	})
+	return res, func() {
+		for _, s := range sessions {
+			s.Close()
+		}
+	}
+}
+
+// readEnvFile reads a .env style file: NAME=VALUE lines, optionally
+// prefixed with "export", with blank lines and # comments ignored.
+func readEnvFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var vars []string
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%s:%d: expected NAME=VALUE", path, lineNo)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		vars = append(vars, name+"="+value)
+	}
+	return vars, scanner.Err()
+}
+
+// runChild runs cmd, passing interrupts on to it, and exits with its status.
+func runChild(cmd *exec.Cmd) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer func() {
+		signal.Stop(signals)
+		close(signals)
+	}()
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		for sig := range signals {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitStatus(exitErr.ExitCode())
+	}
+	return err
+}
+
+const maskText = "*****"
+
+// maskWriter replaces secrets in a stream. Output that could be the start of
+// a secret is held back until the next write shows whether it is one.
+type maskWriter struct {
+	w       io.Writer
+	secrets [][]byte
+	buf     []byte
+}
+
+func newMaskWriter(w io.Writer, secrets []string) *maskWriter {
+	m := &maskWriter{w: w}
+	for _, s := range secrets {
+		if s != "" {
+			m.secrets = append(m.secrets, []byte(s))
+		}
+	}
+	// Longest first so a secret containing another is masked as a whole
+	sort.Slice(m.secrets, func(i, j int) bool { return len(m.secrets[i]) > len(m.secrets[j]) })
+	return m
+}
+
+func (m *maskWriter) Write(p []byte) (int, error) {
+	m.buf = append(m.buf, p...)
+	if err := m.emit(false); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes whatever is still held back.
+func (m *maskWriter) Flush() error {
+	return m.emit(true)
+}
+
+func (m *maskWriter) emit(final bool) error {
+	var out []byte
+	i := 0
+scan:
+	for i < len(m.buf) {
+		rest := m.buf[i:]
+		if !final {
+			for _, s := range m.secrets {
+				if len(rest) < len(s) && bytes.HasPrefix(s, rest) {
+					break scan
+				}
+			}
+		}
+		for _, s := range m.secrets {
+			if bytes.HasPrefix(rest, s) {
+				out = append(out, maskText...)
+				i += len(s)
+				continue scan
+			}
+		}
+		out = append(out, m.buf[i])
+		i++
+	}
+	m.buf = append(m.buf[:0], m.buf[i:]...)
+	if len(out) == 0 {
+		return nil
+	}
+	_, err := m.w.Write(out)
+	return err
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopass/internal/models"
+)
+
+func TestRunInjectsAndMasksSecrets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	testEnv(t)
+	addPassword(t, models.Password{ID: "1", Folder: "infra", Name: "db", Username: "app", Password: "hunter2"})
+
+	envFile := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(envFile, []byte("# database\nexport DB_USER=gopass://infra/db#username\nMODE=\"prod\"\n"), 0600))
+
+	script := `echo "$DB_USER:$DB_PASS:$MODE"`
+	out := run(t, "", "run", "--env-file", envFile, "--env", "DB_PASS=gopass://infra/db#password", "--", "sh", "-c", script)
+	assert.Equal(t, "app:hunter2:prod\n", out)
+
+	out = run(t, "", "run", "--mask", "--env", "DB_PASS=gopass://infra/db", "--", "sh", "-c", `echo "pass=$DB_PASS"`)
+	assert.Equal(t, "pass=*****\n", out)
+	assert.NotContains(t, os.Getenv("DB_PASS"), "hunter2")
+
+	c := &CLI{Stdin: strings.NewReader(""), Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}}
+	assert.Equal(t, 3, c.Run([]string{"run", "--", "sh", "-c", "exit 3"}))
+	assert.Equal(t, 1, c.Run([]string{"run", "--env", "X=gopass://infra/missing", "--", "true"}))
+}
+
+func TestMaskWriterHandlesSplitSecrets(t *testing.T) {
+	var out bytes.Buffer
+	m := newMaskWriter(&out, []string{"secret", "secret-long", ""})
+	for _, chunk := range []string{"a sec", "ret and secret-lo", "ng, then sec"} {
+		_, err := m.Write([]byte(chunk))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, "a ***** and *****, then ", out.String())
+	require.NoError(t, m.Flush())
+	assert.Equal(t, "a ***** and *****, then sec", out.String())
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"gopass/internal/agent"
+	"gopass/internal/models"
+	"gopass/internal/secmem"
+	"gopass/internal/storage"
+)
+
+// session is an unlocked vault, either opened in this process or served by
+// a running agent.
+type session interface {
+	// Entries are listed without their secrets; Reveal fetches them.
+	Passwords() ([]models.Password, error)
+	Notes() ([]models.Note, error)
+	RevealPassword(id string) (models.Password, error)
+	RevealNote(id string) (models.Note, error)
+	RevealSSHKey(id string) (models.SSHKey, error)
+	// PasswordSecret returns just the password, in secure memory when the
+	// vault is open in this process.
+	PasswordSecret(id string) (*secmem.Buffer, error)
+	Search(query string) (models.SearchResult, error)
+	AddPassword(p models.Password) error
+	UpdatePassword(p models.Password) error
+	DeletePassword(id string) error
+	AddNote(n models.Note) error
+	UpdateNote(n models.Note) error
+	DeleteNote(id string) error
+	SSHKeys() ([]models.SSHKey, error)
+	AddSSHKey(k models.SSHKey) error
+	UpdateSSHKey(k models.SSHKey) error
+	DeleteSSHKey(id string) error
+	Close() error
+}
+
+type localSession struct {
+	*storage.Storage
+}
+
+func (l localSession) Passwords() ([]models.Password, error) {
+	return l.GetPasswords(), nil
+}
+
+func (l localSession) Notes() ([]models.Note, error) {
+	return l.GetNotes(), nil
+}
+
+func (l localSession) SSHKeys() ([]models.SSHKey, error) {
+	return l.GetSSHKeys(), nil
+}
+
+func (l localSession) Search(query string) (models.SearchResult, error) {
+	return l.Storage.Search(query), nil
+}
+
+// session connects to the agent serving the selected vault and only unlocks
+// the vault itself, asking for the PIN, when no agent is running.
+func (c *CLI) session(writable bool) (session, error) {
+	return c.sessionFor(c.vaultName, writable)
+}
+
+// sessionFor is session for the named vault; the empty name is the default.
+func (c *CLI) sessionFor(name string, writable bool) (session, error) {
+	v, err := c.registry.Current(name)
+	if err != nil {
+		return nil, err
+	}
+	if path, err := agent.SocketPath(v.Name); err == nil {
+		if client, err := agent.Dial(path); err == nil {
+			// GOPASS_AGENT_SOCK may point at an agent for another vault
+			if client.Vault() == v.Name {
+				return client, nil
+			}
+			client.Close()
+		}
+	}
+
+	s, err := c.openVault(v.Name, writable)
+	if err != nil {
+		return nil, err
+	}
+	return localSession{s}, nil
+}
@@ -0,0 +1,275 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"gopass/internal/events"
+	"gopass/internal/share"
+	"gopass/internal/storage"
+)
+
+func init() {
+	register("share", "share entries with teammates: share id | contact list|add NAME KEY|remove NAME | password|note --to CONTACT [--expires D] [--out FILE] NAME | import [FILE] | list | revoke ID | prune", runShare)
+}
+
+func runShare(c *CLI, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: gopass share id|contact|password|note|import|list|revoke|prune")
+	}
+	switch args[0] {
+	case "id":
+		return c.withKeyring(false, func(s *storage.Storage, k *share.Keyring) error {
+			key, err := k.PublicKey()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(c.Stdout, key)
+			fmt.Fprintf(c.Stdout, "fingerprint %s\n", key.Fingerprint())
+			return nil
+		})
+	case "contact":
+		return shareContact(c, args[1:])
+	case "password", "note":
+		return shareEntry(c, args[0], args[1:])
+	case "import":
+		return shareImport(c, args[1:])
+	case "list":
+		return c.withKeyring(false, func(s *storage.Storage, k *share.Keyring) error {
+			for _, r := range k.Sent() {
+				printShare(c, "to", r)
+			}
+			for _, r := range k.ReceivedShares() {
+				printShare(c, "from", r)
+			}
+			return nil
+		})
+	case "revoke":
+		if len(args) != 2 {
+			return errors.New("usage: gopass share revoke ID")
+		}
+		return c.withKeyring(false, func(s *storage.Storage, k *share.Keyring) error {
+			blob, err := k.Revoke(args[1])
+			if err != nil {
+				return err
+			}
+			_, err = c.Stdout.Write(blob)
+			return err
+		})
+	case "prune":
+		return c.withKeyring(true, func(s *storage.Storage, k *share.Keyring) error {
+			now := time.Now()
+			for _, r := range k.ReceivedShares() {
+				if r.ExpiresAt.IsZero() || now.Before(r.ExpiresAt) {
+					continue
+				}
+				if err := dropShared(s, k, r.ID, ""); err != nil {
+					return err
+				}
+				fmt.Fprintf(c.Stdout, "Removed expired %s %s from %s\n", r.Kind, r.EntryName, r.Peer)
+			}
+			return nil
+		})
+	default:
+		return fmt.Errorf("unknown share command %q", args[0])
+	}
+}
+
+// withKeyring unlocks the vault and its sharing keyring. Importing and
+// pruning change the vault and need it writable.
+func (c *CLI) withKeyring(writable bool, fn func(*storage.Storage, *share.Keyring) error) error {
+	v, err := c.registry.Current(c.vaultName)
+	if err != nil {
+		return err
+	}
+	s, err := c.openVault(v.Name, writable)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	k, err := share.OpenKeyring(v.SharingPath(), s.Encrypt, s.Decrypt)
+	if err != nil {
+		return err
+	}
+	return fn(s, k)
+}
+
+func printShare(c *CLI, direction string, r share.Record) {
+	state := ""
+	switch {
+	case r.Revoked:
+		state = " (revoked)"
+	case !r.ExpiresAt.IsZero():
+		state = " (expires " + r.ExpiresAt.Format(time.DateTime) + ")"
+	}
+	fmt.Fprintf(c.Stdout, "%s  %-8s %-24s %s %s%s\n", r.ID, r.Kind, r.EntryName, direction, r.Peer, state)
+}
+
+func shareContact(c *CLI, args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	return c.withKeyring(false, func(s *storage.Storage, k *share.Keyring) error {
+		switch args[0] {
+		case "list":
+			for _, contact := range k.Contacts() {
+				key, err := share.ParsePublicKey(contact.PublicKey)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(c.Stdout, "%-16s %s\n", contact.Name, key.Fingerprint())
+			}
+			return nil
+		case "add":
+			if len(args) != 3 {
+				return errors.New("usage: gopass share contact add NAME KEY")
+			}
+			contact, err := k.AddContact(args[1], args[2])
+			if err != nil {
+				return err
+			}
+			key, _ := share.ParsePublicKey(contact.PublicKey)
+			fmt.Fprintf(c.Stdout, "Added %s with fingerprint %s; compare it with theirs\n", contact.Name, key.Fingerprint())
+			return nil
+		case "remove":
+			if len(args) != 2 {
+				return errors.New("usage: gopass share contact remove NAME")
+			}
+			return k.RemoveContact(args[1])
+		default:
+			return fmt.Errorf("unknown contact command %q", args[0])
+		}
+	})
+}
+
+func shareEntry(c *CLI, kind string, args []string) error {
+	fs := flag.NewFlagSet("share "+kind, flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	to := fs.String("to", "", "contact to share with")
+	expires := fs.Duration("expires", 0, "ask the recipient to drop the entry after this long; 0 never expires")
+	out := fs.String("out", "", "write the share to this file instead of standard output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *to == "" {
+		return fmt.Errorf("usage: gopass share %s --to CONTACT [--expires D] [--out FILE] NAME", kind)
+	}
+
+	return c.withKeyring(false, func(s *storage.Storage, k *share.Keyring) error {
+		var p share.Payload
+		if kind == "note" {
+			n, err := findNote(s.GetNotes(), fs.Arg(0))
+			if err == nil {
+				n, err = s.RevealNote(n.ID)
+			}
+			if err != nil {
+				return err
+			}
+			p.Note = &n
+		} else {
+			pw, err := findPassword(s.GetPasswords(), fs.Arg(0))
+			if err == nil {
+				pw, err = s.RevealPassword(pw.ID)
+			}
+			if err != nil {
+				return err
+			}
+			p.Password = &pw
+		}
+		var expiresAt time.Time
+		if *expires > 0 {
+			expiresAt = time.Now().Add(*expires)
+		}
+
+		blob, err := k.Share(*to, p, expiresAt)
+		if err != nil {
+			return err
+		}
+		if *out != "" {
+			return os.WriteFile(*out, blob, 0600)
+		}
+		_, err = c.Stdout.Write(blob)
+		return err
+	})
+}
+
+// shareImport stores the entry of a share blob read from a file or standard
+// input, or applies a revocation.
+func shareImport(c *CLI, args []string) error {
+	var blob []byte
+	var err error
+	switch len(args) {
+	case 0:
+		blob, err = io.ReadAll(c.Stdin)
+	case 1:
+		blob, err = os.ReadFile(args[0])
+	default:
+		return errors.New("usage: gopass share import [FILE]")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.withKeyring(true, func(s *storage.Storage, k *share.Keyring) error {
+		p, from, err := k.Open(blob)
+		if err != nil {
+			return err
+		}
+		if p.Revoked {
+			if err := dropShared(s, k, p.ID, from.Name); err != nil {
+				return err
+			}
+			fmt.Fprintf(c.Stdout, "%s revoked share %s\n", from.Name, p.ID)
+			return nil
+		}
+
+		// Shared entries get IDs of our own so they never collide with ours
+		now := time.Now()
+		id := uuid.New().String()
+		var name string
+		switch {
+		case p.Password != nil:
+			pw := *p.Password
+			pw.ID, pw.CreatedAt, pw.UpdatedAt = id, now, now
+			name = pw.Path()
+			err = s.AddPassword(pw)
+		case p.Note != nil:
+			n := *p.Note
+			n.ID, n.CreatedAt, n.UpdatedAt = id, now, now
+			name = n.Title
+			err = s.AddNote(n)
+		default:
+			return errors.New("share carries no entry")
+		}
+		if err != nil {
+			return err
+		}
+		if err := k.Received(p, from, id); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.Stdout, "Imported %s from %s\n", name, from.Name)
+		return nil
+	})
+}
+
+// dropShared deletes the entry a received share was stored as and forgets
+// the share. Entries the user has since deleted are fine to miss.
+func dropShared(s *storage.Storage, k *share.Keyring, id, from string) error {
+	r, err := k.Forget(id, from)
+	if err != nil {
+		return err
+	}
+	if r.Kind == events.KindNote {
+		if _, err := findNote(s.GetNotes(), r.EntryID); err == nil {
+			return s.DeleteNote(r.EntryID)
+		}
+	} else if _, err := findPassword(s.GetPasswords(), r.EntryID); err == nil {
+		return s.DeletePassword(r.EntryID)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopass/internal/models"
+)
+
+func TestShareBetweenVaults(t *testing.T) {
+	testEnv(t)
+	run(t, "", "vault", "create", "bob")
+	addPassword(t, models.Password{ID: "1", Name: "deploy", Username: "ci", Password: "s3cret"})
+
+	aliceKey := strings.SplitN(run(t, "", "share", "id"), "\n", 2)[0]
+	bobKey := strings.SplitN(run(t, "", "--vault", "bob", "share", "id"), "\n", 2)[0]
+	run(t, "", "share", "contact", "add", "bob", bobKey)
+	run(t, "", "--vault", "bob", "share", "contact", "add", "alice", aliceKey)
+
+	blob := run(t, "", "share", "password", "--to", "bob", "deploy")
+	assert.Contains(t, blob, "BEGIN GOPASS SHARE")
+	assert.Equal(t, "Imported deploy from alice\n", run(t, blob, "--vault", "bob", "share", "import"))
+	assert.Contains(t, run(t, "", "--vault", "bob", "show", "deploy"), "s3cret")
+
+	id := strings.Fields(run(t, "", "share", "list"))[0]
+	revocation := run(t, "", "share", "revoke", id)
+	assert.Contains(t, run(t, revocation, "--vault", "bob", "share", "import"), "revoked")
+	assert.NotContains(t, run(t, "", "--vault", "bob", "list"), "deploy")
+}
@@ -0,0 +1,202 @@
+package cli
+
+import (
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+	"gopass/internal/models"
+	"gopass/internal/sshagent"
+)
+
+func init() {
+	register("ssh-key", "manage keys for the ssh agent: ssh-key list | add [--confirm] [--lifetime 1h] [--comment TEXT] NAME FILE | public NAME | remove NAME", runSSHKey)
+}
+
+func runSSHKey(c *CLI, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: gopass ssh-key list|add|public|remove")
+	}
+	switch args[0] {
+	case "list":
+		return listSSHKeys(c)
+	case "add":
+		return addSSHKey(c, args[1:])
+	case "public":
+		return showPublicKey(c, args[1:])
+	case "remove":
+		return removeSSHKey(c, args[1:])
+	default:
+		return fmt.Errorf("unknown ssh-key command %q", args[0])
+	}
+}
+
+func listSSHKeys(c *CLI) error {
+	s, err := c.session(false)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	keys, err := s.SSHKeys()
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		fingerprint := "unusable key"
+		if signer, err := sshagent.ParseKey(k); err == nil {
+			fingerprint = ssh.FingerprintSHA256(signer.PublicKey())
+		}
+		var constraints string
+		if k.Confirm {
+			constraints += " confirm"
+		}
+		if k.Lifetime > 0 {
+			constraints += " lifetime=" + k.Lifetime.String()
+		}
+		fmt.Fprintf(c.Stdout, "%-24s %s%s\n", k.Name, fingerprint, constraints)
+	}
+	return nil
+}
+
+func addSSHKey(c *CLI, args []string) error {
+	fs := flag.NewFlagSet("ssh-key add", flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	confirm := fs.Bool("confirm", false, "ask before every use of the key")
+	lifetime := fs.Duration("lifetime", 0, "stop offering the key this long after the agent first offers it; 0 means while unlocked")
+	comment := fs.String("comment", "", "comment shown by ssh-add -l; defaults to the key name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: gopass ssh-key add [--confirm] [--lifetime 1h] [--comment TEXT] NAME FILE")
+	}
+
+	data, err := os.ReadFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	privateKey, err := c.decryptSSHKey(data)
+	if err != nil {
+		return err
+	}
+
+	k := models.NewSSHKey()
+	k.ID = uuid.New().String()
+	k.Name = fs.Arg(0)
+	k.PrivateKey = privateKey
+	k.Comment = *comment
+	k.Confirm = *confirm
+	k.Lifetime = *lifetime
+	if _, err := sshagent.ParseKey(*k); err != nil {
+		return err
+	}
+
+	s, err := c.session(true)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	keys, err := s.SSHKeys()
+	if err != nil {
+		return err
+	}
+	if _, err := findSSHKey(keys, k.Name); err == nil {
+		return fmt.Errorf("an ssh key named %q already exists", k.Name)
+	}
+	return s.AddSSHKey(*k)
+}
+
+// decryptSSHKey returns the key file as unencrypted PEM, asking for the
+// passphrase when the file is protected by one. The vault encrypts it
+// instead, and the agent has no way to ask.
+func (c *CLI) decryptSSHKey(data []byte) (string, error) {
+	if _, err := ssh.ParseRawPrivateKey(data); err == nil {
+		return string(data), nil
+	} else if _, ok := err.(*ssh.PassphraseMissingError); !ok {
+		return "", err
+	}
+
+	passphrase, err := c.readSecret("Passphrase for the key file: ", "GOPASS_SSH_PASSPHRASE")
+	if err != nil {
+		return "", err
+	}
+	raw, err := ssh.ParseRawPrivateKeyWithPassphrase(data, []byte(passphrase))
+	if err != nil {
+		return "", err
+	}
+	block, err := ssh.MarshalPrivateKey(raw, "")
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(block)), nil
+}
+
+func showPublicKey(c *CLI, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: gopass ssh-key public NAME")
+	}
+	s, err := c.session(false)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	keys, err := s.SSHKeys()
+	if err != nil {
+		return err
+	}
+	k, err := findSSHKey(keys, args[0])
+	if err == nil {
+		k, err = s.RevealSSHKey(k.ID)
+	}
+	if err != nil {
+		return err
+	}
+	signer, err := sshagent.ParseKey(k)
+	if err != nil {
+		return err
+	}
+	comment := k.Comment
+	if comment == "" {
+		comment = k.Name
+	}
+	line := ssh.MarshalAuthorizedKey(signer.PublicKey())
+	fmt.Fprintf(c.Stdout, "%s %s\n", line[:len(line)-1], comment)
+	return nil
+}
+
+func removeSSHKey(c *CLI, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: gopass ssh-key remove NAME")
+	}
+	s, err := c.session(true)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	keys, err := s.SSHKeys()
+	if err != nil {
+		return err
+	}
+	k, err := findSSHKey(keys, args[0])
+	if err != nil {
+		return err
+	}
+	return s.DeleteSSHKey(k.ID)
+}
+
+func findSSHKey(keys []models.SSHKey, name string) (models.SSHKey, error) {
+	for _, k := range keys {
+		if k.ID == name || k.Name == name {
+			return k, nil
+		}
+	}
+	return models.SSHKey{}, errors.New("ssh key not found")
+}
@@ -0,0 +1,314 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"gopass/internal/remote"
+	"gopass/internal/secretref"
+	"gopass/internal/storage"
+	"gopass/internal/vault"
+	"gopass/internal/vaultsync"
+)
+
+func init() {
+	register("remote", "manage sync remotes: remote list | add [--type webdav|s3] [--user USER] [--password-ref REF] [--endpoint URL] [--region REGION] NAME URL | remove NAME | versions NAME | restore NAME VERSION", runRemote)
+	register("sync", "sync the vault with its remotes: sync [REMOTE...]", runSync)
+}
+
+func runRemote(c *CLI, args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	v, err := c.registry.Current(c.vaultName)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		for _, r := range v.Remotes {
+			fmt.Fprintf(c.Stdout, "%-16s %-6s %s\n", r.Name, r.Type, r.URL)
+		}
+		return nil
+	case "add":
+		fs := flag.NewFlagSet("remote add", flag.ContinueOnError)
+		fs.SetOutput(c.Stderr)
+		cfg := addRemoteFlags(fs)
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 2 {
+			return errors.New("usage: gopass remote add [--type webdav|s3] [--user USER] [--password-ref REF] [--endpoint URL] [--region REGION] NAME URL")
+		}
+		cfg.Name, cfg.URL = fs.Arg(0), fs.Arg(1)
+		if err := checkPasswordRef(*cfg); err != nil {
+			return err
+		}
+		return c.registry.AddRemote(v.Name, *cfg)
+	case "remove":
+		if len(args) != 2 {
+			return errors.New("usage: gopass remote remove NAME")
+		}
+		return c.registry.RemoveRemote(v.Name, args[1])
+	case "versions":
+		if len(args) != 2 {
+			return errors.New("usage: gopass remote versions NAME")
+		}
+		return c.withVersionedRemote(v, args[1], func(s *storage.Storage, r remote.Versioned) error {
+			versions, err := r.Versions(context.Background())
+			if err != nil {
+				return err
+			}
+			for _, version := range versions {
+				marker := " "
+				if version.Latest {
+					marker = "*"
+				}
+				fmt.Fprintf(c.Stdout, "%s %-34s %s %8d bytes\n", marker, version.ID, version.Modified.Local().Format("2006-01-02 15:04:05"), version.Size)
+			}
+			return nil
+		})
+	case "restore":
+		if len(args) != 3 {
+			return errors.New("usage: gopass remote restore NAME VERSION")
+		}
+		return c.withVersionedRemote(v, args[1], func(s *storage.Storage, r remote.Versioned) error {
+			if err := vaultsync.Restore(context.Background(), s, r, args[2], v.BasePath(args[1])); err != nil {
+				return err
+			}
+			fmt.Fprintf(c.Stdout, "Restored version %s from %s\n", args[2], args[1])
+			return nil
+		})
+	default:
+		return fmt.Errorf("unknown remote action %q", args[0])
+	}
+}
+
+// addRemoteFlags defines the flags describing a remote on fs. Name and
+// URL are left to the caller.
+func addRemoteFlags(fs *flag.FlagSet) *remote.Config {
+	cfg := &remote.Config{}
+	fs.StringVar(&cfg.Type, "type", remote.TypeWebDAV, "kind of remote: webdav, or s3 for s3://BUCKET/KEY URLs")
+	fs.StringVar(&cfg.Username, "user", "", "user name on the server, or S3 access key ID")
+	fs.StringVar(&cfg.PasswordRef, "password-ref", "", "gopass:// reference to the entry holding the server password or S3 secret key")
+	fs.StringVar(&cfg.Endpoint, "endpoint", "", "S3 endpoint, e.g. a MinIO server; defaults to AWS")
+	fs.StringVar(&cfg.Region, "region", "", "S3 region (default "+remote.DefaultS3Region+")")
+	return cfg
+}
+
+func checkPasswordRef(cfg remote.Config) error {
+	if cfg.PasswordRef == "" {
+		return nil
+	}
+	_, err := secretref.Parse(cfg.PasswordRef)
+	return err
+}
+
+func runSync(c *CLI, args []string) error {
+	v, err := c.registry.Current(c.vaultName)
+	if err != nil {
+		return err
+	}
+	remotes := v.Remotes
+	if len(args) > 0 {
+		remotes = nil
+		for _, name := range args {
+			r, err := v.Remote(name)
+			if err != nil {
+				return err
+			}
+			remotes = append(remotes, r)
+		}
+	}
+	if len(remotes) == 0 {
+		return fmt.Errorf("vault %s has no remotes; add one with gopass remote add", v.Name)
+	}
+
+	s, err := c.openVault(v.Name, true)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	for _, cfg := range remotes {
+		if err := c.syncRemote(s, v, cfg); err != nil {
+			return fmt.Errorf("remote %s: %w", cfg.Name, err)
+		}
+	}
+	return nil
+}
+
+// withVersionedRemote unlocks the vault, whose key decrypts the versions,
+// and connects to the named remote if it keeps versions.
+func (c *CLI) withVersionedRemote(v vault.Vault, name string, fn func(*storage.Storage, remote.Versioned) error) error {
+	cfg, err := v.Remote(name)
+	if err != nil {
+		return err
+	}
+	s, err := c.openVault(v.Name, true)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	password, err := c.remotePassword(s, v, cfg)
+	if err != nil {
+		return err
+	}
+	r, err := remote.Open(cfg, password)
+	if err != nil {
+		return err
+	}
+	versioned, ok := r.(remote.Versioned)
+	if !ok {
+		return fmt.Errorf("remote %s does not keep versions", name)
+	}
+	return fn(s, versioned)
+}
+
+func (c *CLI) syncRemote(s *storage.Storage, v vault.Vault, cfg remote.Config) error {
+	password, err := c.remotePassword(s, v, cfg)
+	if err != nil {
+		return err
+	}
+	r, err := remote.Open(cfg, password)
+	if err != nil {
+		return err
+	}
+	meta, err := v.RemoteMeta()
+	if err != nil {
+		return err
+	}
+	res, err := vaultsync.Sync(context.Background(), s, r, v.BasePath(cfg.Name), meta)
+	if err != nil {
+		return err
+	}
+	printSyncResult(c, cfg.Name, res)
+	return nil
+}
+
+func printSyncResult(c *CLI, name string, res vaultsync.Result) {
+
+	switch {
+	case res.Pulled && res.Pushed:
+		fmt.Fprintf(c.Stdout, "%s: merged changes from both sides\n", name)
+	case res.Pulled:
+		fmt.Fprintf(c.Stdout, "%s: pulled changes\n", name)
+	case res.Pushed:
+		fmt.Fprintf(c.Stdout, "%s: pushed changes\n", name)
+	default:
+		fmt.Fprintf(c.Stdout, "%s: up to date\n", name)
+	}
+	printConflicts(c, res.Conflicts)
+}
+
+// cloneRemote registers the vault on the remote cfg under name and pulls
+// its entries. The PIN and key file are the ones the vault already has,
+// since its key is derived from them with the settings the remote
+// publishes.
+func (c *CLI) cloneRemote(name, dir string, cfg remote.Config) error {
+	if err := checkPasswordRef(cfg); err != nil {
+		return err
+	}
+	password := ""
+	if cfg.Username != "" {
+		var err error
+		if password, err = c.readSecret(fmt.Sprintf("Password for %s on %s: ", cfg.Username, cfg.URL), "GOPASS_REMOTE_PASSWORD"); err != nil {
+			return err
+		}
+	}
+	r, err := remote.Open(cfg, password)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	meta, err := vaultsync.Meta(ctx, r)
+	if err != nil {
+		return err
+	}
+	v, err := c.registry.CloneRemote(name, dir, cfg, meta)
+	if err != nil {
+		return err
+	}
+	if err := c.pullClone(ctx, v, r, meta); err != nil {
+		c.registry.Remove(v.Name)
+		os.RemoveAll(v.Path)
+		return err
+	}
+	fmt.Fprintf(c.Stdout, "Cloned vault %s into %s\n", v.Name, v.Path)
+	return nil
+}
+
+func (c *CLI) pullClone(ctx context.Context, v vault.Vault, r remote.Remote, meta []byte) error {
+	if v.KeyFile != nil {
+		if c.keyFile == "" {
+			return fmt.Errorf("vault %s needs its key file; pass --keyfile FILE", v.Name)
+		}
+		data, err := vault.ReadKeyFile(c.keyFile)
+		if err != nil {
+			return err
+		}
+		if v, err = v.WithKeyFile(data); err != nil {
+			return fmt.Errorf("%s: %w", c.keyFile, err)
+		}
+	}
+	pin, err := c.readPIN(fmt.Sprintf("PIN of vault %s: ", v.Name))
+	if err != nil {
+		return err
+	}
+	s, err := v.NewStorage(pin)
+	if err != nil {
+		return fmt.Errorf("cannot open the cloned vault, is the PIN right? %w", err)
+	}
+	if s, err = c.loadStorage(s, true); err != nil {
+		return err
+	}
+	defer s.Close()
+	if _, err := vaultsync.Sync(ctx, s, r, v.BasePath(v.Remotes[0].Name), meta); err != nil {
+		return err
+	}
+	return v.NewAuth().SetPIN(pin)
+}
+
+func printConflicts(c *CLI, conflicts []vaultsync.Conflict) {
+	for _, conflict := range conflicts {
+		if len(conflict.Fields) == 0 {
+			fmt.Fprintf(c.Stderr, "conflict: %s %q was deleted on one side and changed on the other; kept the changed one\n",
+				conflict.Kind, conflict.Name)
+			continue
+		}
+		fmt.Fprintf(c.Stderr, "conflict: %s %q changed on both sides (%v); the other version was saved as %q\n",
+			conflict.Kind, conflict.Name, conflict.Fields, conflict.Name+vaultsync.ConflictSuffix)
+	}
+}
+
+// remotePassword resolves the remote's password reference in the open vault
+// or, for references to other vaults, through their sessions. Remotes
+// without a reference ask for the password when they have a user name.
+func (c *CLI) remotePassword(s *storage.Storage, v vault.Vault, cfg remote.Config) (string, error) {
+	if cfg.PasswordRef == "" {
+		if cfg.Username == "" {
+			return "", nil
+		}
+		return c.readSecret(fmt.Sprintf("Password for %s on %s: ", cfg.Username, cfg.Name), "GOPASS_REMOTE_PASSWORD")
+	}
+	ref, err := secretref.Parse(cfg.PasswordRef)
+	if err != nil {
+		return "", err
+	}
+	if ref.Vault == "" || ref.Vault == v.Name {
+		return secretref.Entries{
+			Passwords:      s.GetPasswords(),
+			Notes:          s.GetNotes(),
+			RevealPassword: s.RevealPassword,
+			RevealNote:     s.RevealNote,
+		}.Resolve(ref)
+	}
+	res, done := c.resolver()
+	defer done()
+	return res.Resolve(ref)
+}
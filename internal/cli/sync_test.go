@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+	"gopass/internal/models"
+)
+
+// newWebDAVServer serves an in-memory WebDAV tree to alice.
+func newWebDAVServer(t *testing.T) *httptest.Server {
+	dav := &webdav.Handler{FileSystem: webdav.NewMemFS(), LockSystem: webdav.NewMemLS()}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "alice" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		dav.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newS3Server serves one bucket of unversioned objects, honouring the
+// conditional writes the S3 remote relies on.
+func newS3Server(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	etag := func(data []byte) string {
+		sum := md5.Sum(data)
+		return `"` + hex.EncodeToString(sum[:]) + `"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		data, exists := objects[r.URL.Path]
+		switch r.Method {
+		case http.MethodGet:
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><Message>no such key</Message></Error>")
+				return
+			}
+			w.Header().Set("ETag", etag(data))
+			w.Write(data)
+		case http.MethodPut:
+			match := r.Header.Get("If-Match")
+			if (match != "" && (!exists || match != etag(data))) || (r.Header.Get("If-None-Match") == "*" && exists) {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			data, _ = io.ReadAll(r.Body)
+			objects[r.URL.Path] = data
+			w.Header().Set("ETag", etag(data))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSyncWithWebDAVRemote(t *testing.T) {
+	testEnv(t)
+	srv := newWebDAVServer(t)
+
+	addPassword(t, models.Password{ID: "1", Name: "nas", Password: "secret"})
+	run(t, "", "remote", "add", "--user", "alice", "--password-ref", "gopass://nas", "nas", srv.URL+"/gopass/test.vault")
+	assert.Contains(t, run(t, "", "remote", "list"), "nas")
+
+	assert.Equal(t, "nas: pushed changes\n", run(t, "", "sync"))
+	assert.Equal(t, "nas: up to date\n", run(t, "", "sync", "nas"))
+
+	run(t, "", "remote", "remove", "nas")
+	assert.Empty(t, run(t, "", "remote", "list"))
+}
+
+func TestSyncTwoRegistriesThroughWebDAV(t *testing.T) {
+	testEnv(t)
+	srv := newWebDAVServer(t)
+	url := srv.URL + "/gopass/test.vault"
+	addPassword(t, models.Password{ID: "1", Name: "nas", Password: "secret"})
+	run(t, "", "remote", "add", "--user", "alice", "--password-ref", "gopass://nas", "nas", url)
+	run(t, "", "sync")
+	laptop := os.Getenv("GOPASS_VAULT_DIR")
+
+	// A second machine with a registry, and so key settings, of its own
+	t.Setenv("GOPASS_VAULT_DIR", filepath.Join(t.TempDir(), "vaults"))
+	t.Setenv("GOPASS_REMOTE_PASSWORD", "secret")
+	t.Setenv("GOPASS_PIN", "0000")
+	c := &CLI{Stdin: strings.NewReader(""), Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}}
+	assert.Equal(t, 1, c.Run([]string{"vault", "clone", "--remote", "--user", "alice", "test", url}))
+	assert.NotContains(t, run(t, "", "vault", "list"), "test", "a failed clone is forgotten")
+
+	t.Setenv("GOPASS_PIN", "1234")
+	run(t, "", "vault", "clone", "--remote", "--user", "alice", "--password-ref", "gopass://nas", "--remote-name", "nas", "test", url)
+	run(t, "", "vault", "default", "test")
+	assert.Contains(t, run(t, "", "list"), "nas")
+	addPassword(t, models.Password{ID: "2", Name: "wiki", Password: "pw"})
+	assert.Equal(t, "nas: pushed changes\n", run(t, "", "sync"))
+
+	t.Setenv("GOPASS_VAULT_DIR", laptop)
+	assert.Equal(t, "nas: pulled changes\n", run(t, "", "sync"))
+	assert.Contains(t, run(t, "", "list"), "wiki")
+}
+
+func TestSyncTwoRegistriesThroughS3(t *testing.T) {
+	testEnv(t)
+	srv := newS3Server(t)
+	t.Setenv("GOPASS_REMOTE_PASSWORD", "secret")
+	s3 := []string{"--type", "s3", "--endpoint", srv.URL, "--user", "AKID"}
+	addPassword(t, models.Password{ID: "1", Name: "aws", Password: "secret"})
+	run(t, "", append(append([]string{"remote", "add"}, s3...), "bucket", "s3://vaults/test.vault")...)
+	assert.Equal(t, "bucket: pushed changes\n", run(t, "", "sync"))
+	laptop := os.Getenv("GOPASS_VAULT_DIR")
+
+	t.Setenv("GOPASS_VAULT_DIR", filepath.Join(t.TempDir(), "vaults"))
+	run(t, "", append(append([]string{"vault", "clone", "--remote", "--remote-name", "bucket"}, s3...), "test", "s3://vaults/test.vault")...)
+	run(t, "", "vault", "default", "test")
+	assert.Contains(t, run(t, "", "list"), "aws")
+	addPassword(t, models.Password{ID: "2", Name: "ci", Password: "pw"})
+	assert.Equal(t, "bucket: pushed changes\n", run(t, "", "sync"))
+
+	t.Setenv("GOPASS_VAULT_DIR", laptop)
+	assert.Equal(t, "bucket: pulled changes\n", run(t, "", "sync"))
+	assert.Contains(t, run(t, "", "list"), "ci")
+}
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"gopass/internal/share"
+	"gopass/internal/storage"
+	"gopass/internal/team"
+)
+
+func init() {
+	register("team", "team vaults: team create --member NAME VAULT DIR | join --member NAME VAULT DIR | members | add [--role owner|editor|viewer] NAME KEY | role NAME ROLE | remove NAME", runTeam)
+}
+
+func runTeam(c *CLI, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: gopass team create|join|members|add|role|remove")
+	}
+	switch args[0] {
+	case "create", "join":
+		return teamSetup(c, args[0], args[1:])
+	case "members":
+		return c.withTeam(false, func(s *storage.Storage, t *team.Team) error {
+			me := t.Member().Name
+			for _, m := range t.Members() {
+				marker := " "
+				if m.Name == me {
+					marker = "*"
+				}
+				key, err := share.ParsePublicKey(m.PublicKey)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(c.Stdout, "%s %-16s %-7s %s\n", marker, m.Name, m.Role, key.Fingerprint())
+			}
+			return nil
+		})
+	case "add":
+		fs := flag.NewFlagSet("team add", flag.ContinueOnError)
+		fs.SetOutput(c.Stderr)
+		role := fs.String("role", string(storage.RoleEditor), "what the member may do: owner, editor or viewer")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 2 {
+			return errors.New("usage: gopass team add [--role owner|editor|viewer] NAME KEY")
+		}
+		return c.withTeam(false, func(s *storage.Storage, t *team.Team) error {
+			return t.Add(fs.Arg(0), fs.Arg(1), storage.Role(*role))
+		})
+	case "role":
+		if len(args) != 3 {
+			return errors.New("usage: gopass team role NAME owner|editor|viewer")
+		}
+		return c.withTeam(false, func(s *storage.Storage, t *team.Team) error {
+			return t.SetRole(args[1], storage.Role(args[2]))
+		})
+	case "remove":
+		if len(args) != 2 {
+			return errors.New("usage: gopass team remove NAME")
+		}
+		return c.withTeam(true, func(s *storage.Storage, t *team.Team) error {
+			if err := t.Remove(args[1], s.Rekey); err != nil {
+				return err
+			}
+			fmt.Fprintf(c.Stdout, "Removed %s and rotated the vault key\n", args[1])
+			return nil
+		})
+	default:
+		return fmt.Errorf("unknown team command %q", args[0])
+	}
+}
+
+func teamSetup(c *CLI, action string, args []string) error {
+	fs := flag.NewFlagSet("team "+action, flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	member := fs.String("member", "", "your name in the team")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 || *member == "" {
+		return fmt.Errorf("usage: gopass team %s --member NAME VAULT DIR", action)
+	}
+	pin, err := c.newPIN()
+	if err != nil {
+		return err
+	}
+
+	if action == "create" {
+		v, err := c.registry.CreateTeam(fs.Arg(0), fs.Arg(1), *member, pin)
+		if err != nil {
+			return err
+		}
+		if err := v.NewAuth().SetPIN(pin); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.Stdout, "Created team vault %s in %s\n", v.Name, v.Path)
+		return nil
+	}
+
+	v, key, err := c.registry.JoinTeam(fs.Arg(0), fs.Arg(1), *member, pin)
+	if err != nil {
+		return err
+	}
+	if err := v.NewAuth().SetPIN(pin); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Stdout, "Ask an owner of the team vault to run:\n  gopass --vault VAULT team add %s %s\n", v.Team.Member, key)
+	return nil
+}
+
+// withTeam unlocks the current team vault. Removing members re-encrypts the
+// entries, which needs the vault writable.
+func (c *CLI) withTeam(writable bool, fn func(*storage.Storage, *team.Team) error) error {
+	v, err := c.registry.Current(c.vaultName)
+	if err != nil {
+		return err
+	}
+	if v.Team == nil {
+		return fmt.Errorf("vault %s is not a team vault", v.Name)
+	}
+	v, pin, err := c.unlock(v)
+	if err != nil {
+		return err
+	}
+	t, err := v.OpenTeam(pin)
+	if err != nil {
+		return err
+	}
+	s, err := c.loadStorage(v.TeamStorage(t), writable)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if err := c.audit(v, pin, s); err != nil {
+		return err
+	}
+	return fn(s, t)
+}
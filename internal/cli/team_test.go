@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopass/internal/models"
+)
+
+func TestTeamVaultMembership(t *testing.T) {
+	testEnv(t)
+	shared := filepath.Join(t.TempDir(), "shared")
+
+	run(t, "", "team", "create", "--member", "alice", "ops", shared)
+	run(t, "", "vault", "default", "ops")
+	addPassword(t, models.Password{ID: "1", Name: "db", Password: "pw"})
+
+	joined := run(t, "", "team", "join", "--member", "bob", "ops-bob", shared)
+	fields := strings.Fields(joined)
+	key := fields[len(fields)-1]
+	run(t, "", "team", "add", "--role", "viewer", "bob", key)
+	assert.Contains(t, run(t, "", "team", "members"), "bob")
+
+	assert.Contains(t, run(t, "", "--vault", "ops-bob", "list"), "db")
+	var stderr bytes.Buffer
+	c := &CLI{Stdin: strings.NewReader(""), Stdout: &bytes.Buffer{}, Stderr: &stderr}
+	assert.Equal(t, 1, c.Run([]string{"--vault", "ops-bob", "field", "set", "db", "env", "prod"}))
+	assert.Contains(t, stderr.String(), "role does not allow")
+
+	assert.Contains(t, run(t, "", "team", "remove", "bob"), "rotated")
+	assert.Contains(t, run(t, "", "list"), "db")
+	stderr.Reset()
+	assert.Equal(t, 1, c.Run([]string{"--vault", "ops-bob", "list"}))
+	assert.Contains(t, stderr.String(), "not a member")
+}
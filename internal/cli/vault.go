@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"gopass/internal/storage"
+	"gopass/internal/vault"
+)
+
+func init() {
+	register("vault", "manage vaults: list | create [--backend file|git] [--cipher SUITE] [--keyfile FILE] [--recovery-key] [--shares N --threshold K] NAME [DIR] | clone [--remote [--type webdav|s3] [--user USER] [--password-ref REF] [--endpoint URL] [--region REGION] [--remote-name NAME]] NAME URL [DIR] | remove NAME | default NAME | recovery [--recovery-key] [--shares N --threshold K] [NAME] | recover [NAME] | keyfile generate FILE | keyfile set FILE | keyfile remove | duress [NAME] | cipher [SUITE] (vault file only; entry secrets stay aes-256-gcm)", runVault)
+}
+
+func runVault(c *CLI, args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		for _, v := range c.registry.Vaults {
+			marker := " "
+			if v.Name == c.registry.Default {
+				marker = "*"
+			}
+			fmt.Fprintf(c.Stdout, "%s %-16s %-6s %s\n", marker, v.Name, v.Backend, v.Path)
+		}
+		return nil
+	case "create":
+		fs := flag.NewFlagSet("vault create", flag.ContinueOnError)
+		fs.SetOutput(c.Stderr)
+		backend := fs.String("backend", vault.BackendFile, "where entries are kept: file or git")
+		cipher := fs.String("cipher", storage.AES256GCM.String(), "cipher suite of the vault file, entry secrets stay "+storage.AES256GCM.String()+": "+suiteNames())
+		keyFile := fs.String("keyfile", "", "key file needed besides the PIN, generated when it does not exist")
+		opts := addRecoveryFlags(fs)
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() < 1 || fs.NArg() > 2 {
+			return errors.New("usage: gopass vault create [--backend file|git] [--cipher SUITE] [--keyfile FILE] [--recovery-key] [--shares N --threshold K] NAME [DIR]")
+		}
+		if err := opts.check(); err != nil {
+			return err
+		}
+		suite, err := storage.ParseSuite(*cipher)
+		if err != nil {
+			return err
+		}
+		if suite != storage.AES256GCM && *backend != vault.BackendFile {
+			return errors.New("only file vaults can change their cipher")
+		}
+		return c.createVault(fs.Arg(0), fs.Arg(1), *backend, suite, *keyFile, opts)
+	case "clone":
+		fs := flag.NewFlagSet("vault clone", flag.ContinueOnError)
+		fs.SetOutput(c.Stderr)
+		fromRemote := fs.Bool("remote", false, "clone a vault from a sync remote instead of a git repository")
+		remoteName := fs.String("remote-name", "origin", "name of the sync remote in the cloned vault")
+		cfg := addRemoteFlags(fs)
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() < 2 || fs.NArg() > 3 {
+			return errors.New("usage: gopass vault clone [--remote [--type webdav|s3] [--user USER] [--password-ref REF] [--endpoint URL] [--region REGION] [--remote-name NAME]] NAME URL [DIR]")
+		}
+		if *fromRemote {
+			cfg.Name, cfg.URL = *remoteName, fs.Arg(1)
+			return c.cloneRemote(fs.Arg(0), fs.Arg(2), *cfg)
+		}
+		return c.cloneVault(fs.Arg(0), fs.Arg(1), fs.Arg(2))
+	case "remove":
+		if len(args) != 2 {
+			return errors.New("usage: gopass vault remove NAME")
+		}
+		return c.registry.Remove(args[1])
+	case "default":
+		if len(args) != 2 {
+			return errors.New("usage: gopass vault default NAME")
+		}
+		return c.registry.SetDefault(args[1])
+	case "recovery":
+		return runRecovery(c, args[1:])
+	case "keyfile":
+		return runKeyFile(c, args[1:])
+	case "duress":
+		return runDuress(c, args[1:])
+	case "cipher":
+		return runCipher(c, args[1:])
+	case "recover":
+		if len(args) > 2 {
+			return errors.New("usage: gopass vault recover [NAME]")
+		}
+		name := ""
+		if len(args) == 2 {
+			name = args[1]
+		}
+		return c.recoverVault(name)
+	default:
+		return fmt.Errorf("unknown vault action %q", args[0])
+	}
+}
+
+func (c *CLI) createVault(name, dir, backend string, suite storage.Suite, keyFile string, opts recoveryFlags) error {
+	pin, err := c.newPIN()
+	if err != nil {
+		return err
+	}
+
+	v, err := c.registry.CreateWithBackend(name, dir, backend)
+	if err != nil {
+		return err
+	}
+	if err := v.NewAuth().SetPIN(pin); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Stdout, "Created vault %s in %s\n", v.Name, v.Path)
+	if keyFile != "" {
+		if v, err = c.requireKeyFile(v, pin, keyFile); err != nil {
+			return err
+		}
+	}
+	if err := c.createSuite(v, pin, suite); err != nil {
+		return err
+	}
+	if opts.wanted() {
+		return c.setUpRecovery(v, pin, opts)
+	}
+	return nil
+}
+
+// cloneVault registers a clone of a git vault. The PIN is the one the vault
+// was created with, since the key is derived from it.
+func (c *CLI) cloneVault(name, url, dir string) error {
+	v, err := c.registry.Clone(context.Background(), name, url, dir)
+	if err != nil {
+		return err
+	}
+	pin, err := c.readPIN(fmt.Sprintf("PIN of vault %s: ", v.Name))
+	if err != nil {
+		return err
+	}
+	s, err := v.NewStorage(pin)
+	if err == nil {
+		err = s.Load()
+	}
+	if err != nil {
+		c.registry.Remove(v.Name)
+		os.RemoveAll(v.RepoPath())
+		return fmt.Errorf("cannot open the cloned vault, is the PIN right? %w", err)
+	}
+	if err := v.NewAuth().SetPIN(pin); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Stdout, "Cloned vault %s into %s\n", v.Name, v.Path)
+	return nil
+}
+
+// newPIN asks for a PIN for a new vault, twice on a terminal.
+func (c *CLI) newPIN() (string, error) {
+	pin, err := c.readPIN("Create PIN: ")
+	if err != nil {
+		return "", err
+	}
+	if len(pin) < c.config.Security.MinPINLength {
+		return "", fmt.Errorf("PIN must be at least %d characters", c.config.Security.MinPINLength)
+	}
+	if _, ok := c.terminal(); ok {
+		confirm, err := c.readPIN("Confirm PIN: ")
+		if err != nil {
+			return "", err
+		}
+		if confirm != pin {
+			return "", errors.New("PINs do not match")
+		}
+	}
+	return pin, nil
+}
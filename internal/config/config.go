@@ -0,0 +1,226 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	ThemeSystem = "system"
+	ThemeLight  = "light"
+	ThemeDark   = "dark"
+)
+
+type Config struct {
+	Vault     VaultConfig     `toml:"vault"`
+	Security  SecurityConfig  `toml:"security"`
+	Generator GeneratorConfig `toml:"generator"`
+	Backup    BackupConfig    `toml:"backup"`
+	UI        UIConfig        `toml:"ui"`
+
+	path string
+}
+
+type VaultConfig struct {
+	// Location holds vaults.json and the original vault. Empty means the
+	// gopass directory under the user config directory.
+	Location string `toml:"location"`
+	// Default overrides the default vault recorded in the registry.
+	Default string `toml:"default"`
+}
+
+type SecurityConfig struct {
+	AutoLock       time.Duration `toml:"auto_lock"`
+	ClipboardClear time.Duration `toml:"clipboard_clear"`
+	MinPINLength   int           `toml:"min_pin_length"`
+}
+
+type GeneratorConfig struct {
+	Length    int  `toml:"length"`
+	Uppercase bool `toml:"uppercase"`
+	Lowercase bool `toml:"lowercase"`
+	Digits    bool `toml:"digits"`
+	Symbols   bool `toml:"symbols"`
+}
+
+type BackupConfig struct {
+	// Count is how many previous versions of each vault file are kept.
+	Count int `toml:"count"`
+}
+
+type UIConfig struct {
+	Theme        string `toml:"theme"`
+	WindowWidth  int    `toml:"window_width"`
+	WindowHeight int    `toml:"window_height"`
+}
+
+func Default() *Config {
+	return &Config{
+		Security: SecurityConfig{
+			AutoLock:       5 * time.Minute,
+			ClipboardClear: 30 * time.Second,
+			MinPINLength:   4,
+		},
+		Generator: GeneratorConfig{
+			Length:    20,
+			Uppercase: true,
+			Lowercase: true,
+			Digits:    true,
+			Symbols:   true,
+		},
+		Backup: BackupConfig{Count: 3},
+		UI: UIConfig{
+			Theme:        ThemeSystem,
+			WindowWidth:  800,
+			WindowHeight: 600,
+		},
+	}
+}
+
+// Path is the config file in use: $GOPASS_CONFIG or config.toml in the gopass
+// config directory.
+func Path() (string, error) {
+	if path := os.Getenv("GOPASS_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gopass", "config.toml"), nil
+}
+
+// Load reads the config file at path on top of the defaults. A missing file
+// is not an error.
+func Load(path string) (*Config, error) {
+	c := Default()
+	c.path = path
+
+	if _, err := toml.DecodeFile(path, c); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return c, c.Validate()
+}
+
+// LoadEffective loads the config file and applies environment overrides.
+// This is what the app runs with; the Settings screen edits the file alone.
+func LoadEffective() (*Config, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	return c, c.Validate()
+}
+
+func (c *Config) Save() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(c); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, buf.Bytes(), 0600)
+}
+
+func (c *Config) FilePath() string {
+	return c.path
+}
+
+func (c *Config) Validate() error {
+	switch {
+	case c.Security.AutoLock < 0:
+		return fmt.Errorf("auto_lock must not be negative")
+	case c.Security.ClipboardClear < 0:
+		return fmt.Errorf("clipboard_clear must not be negative")
+	case c.Security.MinPINLength < 4:
+		return fmt.Errorf("min_pin_length must be at least 4")
+	case c.Generator.Length < 4 || c.Generator.Length > 256:
+		return fmt.Errorf("generator length must be between 4 and 256")
+	case !c.Generator.Uppercase && !c.Generator.Lowercase && !c.Generator.Digits && !c.Generator.Symbols:
+		return fmt.Errorf("generator needs at least one character class")
+	case c.Backup.Count < 0:
+		return fmt.Errorf("backup count must not be negative")
+	case c.UI.Theme != ThemeSystem && c.UI.Theme != ThemeLight && c.UI.Theme != ThemeDark:
+		return fmt.Errorf("unknown theme %q", c.UI.Theme)
+	case c.UI.WindowWidth <= 0 || c.UI.WindowHeight <= 0:
+		return fmt.Errorf("window size must be positive")
+	}
+	return nil
+}
+
+// VaultDir is where the vault registry and the original vault live.
+func (c *Config) VaultDir() (string, error) {
+	if c.Vault.Location != "" {
+		return c.Vault.Location, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gopass"), nil
+}
+
+// ApplyEnv overrides settings from GOPASS_* environment variables, so CI can
+// run without a config file.
+func (c *Config) ApplyEnv() error {
+	str := func(name string, dst *string) error {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+		return nil
+	}
+	integer := func(name string, dst *int) error {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*dst = n
+		}
+		return nil
+	}
+	duration := func(name string, dst *time.Duration) error {
+		if v, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*dst = d
+		}
+		return nil
+	}
+
+	for _, apply := range []func() error{
+		func() error { return str("GOPASS_VAULT_DIR", &c.Vault.Location) },
+		func() error { return str("GOPASS_DEFAULT_VAULT", &c.Vault.Default) },
+		func() error { return duration("GOPASS_AUTO_LOCK", &c.Security.AutoLock) },
+		func() error { return duration("GOPASS_CLIPBOARD_CLEAR", &c.Security.ClipboardClear) },
+		func() error { return integer("GOPASS_MIN_PIN_LENGTH", &c.Security.MinPINLength) },
+		func() error { return integer("GOPASS_GENERATOR_LENGTH", &c.Generator.Length) },
+		func() error { return integer("GOPASS_BACKUP_COUNT", &c.Backup.Count) },
+		func() error { return str("GOPASS_THEME", &c.UI.Theme) },
+	} {
+		if err := apply(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMissingFileUsesDefaults(t *testing.T) {
+	c, err := Load(filepath.Join(t.TempDir(), "config.toml"))
+	require.NoError(t, err)
+	assert.Equal(t, Default().Security, c.Security)
+	assert.Equal(t, 800, c.UI.WindowWidth)
+}
+
+func TestSaveAndLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gopass", "config.toml")
+	c, err := Load(path)
+	require.NoError(t, err)
+
+	c.Vault.Location = "/srv/vaults"
+	c.Security.AutoLock = 90 * time.Second
+	c.Generator.Symbols = false
+	c.UI.Theme = ThemeDark
+	require.NoError(t, c.Save())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `auto_lock = "1m30s"`)
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, c.Vault, loaded.Vault)
+	assert.Equal(t, c.Security, loaded.Security)
+	assert.Equal(t, c.Generator, loaded.Generator)
+	assert.Equal(t, ThemeDark, loaded.UI.Theme)
+}
+
+func TestPartialFileKeepsOtherDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte("[backup]\ncount = 10\n"), 0600))
+
+	c, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, 10, c.Backup.Count)
+	assert.Equal(t, 20, c.Generator.Length)
+}
+
+func TestInvalidFileIsRejected(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte("[ui]\ntheme = \"neon\"\n"), 0600))
+
+	_, err := Load(path)
+	assert.Error(t, err)
+}
+
+func TestEnvironmentOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte("[security]\nauto_lock = \"10m\"\n"), 0600))
+	t.Setenv("GOPASS_CONFIG", path)
+	t.Setenv("GOPASS_AUTO_LOCK", "0s")
+	t.Setenv("GOPASS_VAULT_DIR", "/tmp/ci-vaults")
+	t.Setenv("GOPASS_BACKUP_COUNT", "0")
+
+	c, err := LoadEffective()
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), c.Security.AutoLock)
+	assert.Equal(t, "/tmp/ci-vaults", c.Vault.Location)
+	assert.Equal(t, 0, c.Backup.Count)
+
+	t.Setenv("GOPASS_BACKUP_COUNT", "many")
+	_, err = LoadEffective()
+	assert.Error(t, err)
+}
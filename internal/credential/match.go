@@ -0,0 +1,127 @@
+// Package credential finds the password entries that belong to a remote
+// service, for helpers such as git's and docker's credential stores.
+package credential
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"gopass/internal/models"
+)
+
+// Target is the service a client wants credentials for. Empty fields match
+// anything.
+type Target struct {
+	Protocol string
+	Host     string
+	Path     string
+	Username string
+}
+
+// ParseURL turns an entry URL into a Target. URLs without a scheme, such as
+// "github.com/org", are accepted as well.
+func ParseURL(raw string) (Target, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return Target{}, false
+	}
+	hasScheme := strings.Contains(raw, "://")
+	if !hasScheme {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return Target{}, false
+	}
+
+	t := Target{
+		Host: strings.ToLower(u.Host),
+		Path: strings.Trim(u.Path, "/"),
+	}
+	if hasScheme {
+		t.Protocol = strings.ToLower(u.Scheme)
+	}
+	if u.User != nil {
+		t.Username = u.User.Username()
+	}
+	return t, true
+}
+
+// URL formats t the way it is stored in new entries.
+func (t Target) URL() string {
+	u := url.URL{Scheme: t.Protocol, Host: t.Host, Path: "/" + t.Path}
+	if u.Scheme == "" {
+		u.Scheme = "https"
+	}
+	if t.Path == "" {
+		u.Path = ""
+	}
+	return u.String()
+}
+
+// Match returns the entries whose URL and username fit want, the most
+// specific first: a longer matching path wins, then the most recently
+// updated entry.
+func Match(passwords []models.Password, want Target) []models.Password {
+	want.Protocol = strings.ToLower(want.Protocol)
+	want.Host = strings.ToLower(want.Host)
+	want.Path = strings.Trim(want.Path, "/")
+
+	type scored struct {
+		p     models.Password
+		score int
+	}
+	var matches []scored
+	for _, p := range passwords {
+		have, ok := ParseURL(p.URL)
+		if !ok {
+			continue
+		}
+		if score, ok := fits(have, p.Username, want); ok {
+			matches = append(matches, scored{p, score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].p.UpdatedAt.After(matches[j].p.UpdatedAt)
+	})
+	result := make([]models.Password, len(matches))
+	for i, m := range matches {
+		result[i] = m.p
+	}
+	return result
+}
+
+func fits(have Target, username string, want Target) (int, bool) {
+	if want.Host == "" || have.Host != want.Host {
+		return 0, false
+	}
+	if have.Protocol != "" && want.Protocol != "" && have.Protocol != want.Protocol {
+		return 0, false
+	}
+	// Entries without a scheme are only sent over https, never in the clear
+	if have.Protocol == "" && want.Protocol != "" && want.Protocol != "https" {
+		return 0, false
+	}
+	if want.Username != "" && username != want.Username {
+		return 0, false
+	}
+	if have.Username != "" && want.Username != "" && have.Username != want.Username {
+		return 0, false
+	}
+
+	// An entry for a path covers everything below it; entries without a path
+	// cover the whole host. Git only sends a path with credential.useHttpPath,
+	// so without one every entry for the host fits.
+	if have.Path == "" || want.Path == "" {
+		return 0, true
+	}
+	if want.Path != have.Path && !strings.HasPrefix(want.Path, have.Path+"/") {
+		return 0, false
+	}
+	return len(have.Path), true
+}
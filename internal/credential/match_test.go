@@ -0,0 +1,48 @@
+package credential
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopass/internal/models"
+)
+
+func TestMatchPrefersMostSpecificEntry(t *testing.T) {
+	now := time.Now()
+	passwords := []models.Password{
+		{ID: "host", URL: "https://git.example.com", Username: "ci", UpdatedAt: now},
+		{ID: "org", URL: "git.example.com/team", Username: "ci", UpdatedAt: now.Add(-time.Hour)},
+		{ID: "http", URL: "http://git.example.com", Username: "ci"},
+		{ID: "other", URL: "https://example.com", Username: "ci"},
+		{ID: "admin", URL: "https://git.example.com/team/app.git", Username: "admin"},
+	}
+
+	ids := func(ps []models.Password) []string {
+		var out []string
+		for _, p := range ps {
+			out = append(out, p.ID)
+		}
+		return out
+	}
+
+	got := Match(passwords, Target{Protocol: "https", Host: "git.example.com", Path: "team/app.git", Username: "ci"})
+	assert.Equal(t, []string{"org", "host"}, ids(got))
+
+	got = Match(passwords, Target{Protocol: "https", Host: "GIT.example.com", Path: "other/repo"})
+	assert.Equal(t, []string{"host"}, ids(got))
+
+	// Only an entry saved for http fits a request over it
+	got = Match(passwords, Target{Protocol: "http", Host: "git.example.com"})
+	assert.Equal(t, []string{"http"}, ids(got))
+}
+
+func TestParseURL(t *testing.T) {
+	target, ok := ParseURL("https://deploy@git.example.com:8443/team/")
+	assert.True(t, ok)
+	assert.Equal(t, Target{Protocol: "https", Host: "git.example.com:8443", Path: "team", Username: "deploy"}, target)
+	assert.Equal(t, "https://git.example.com:8443/team", target.URL())
+
+	_, ok = ParseURL("")
+	assert.False(t, ok)
+}
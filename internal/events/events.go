@@ -0,0 +1,81 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+type Type string
+
+const (
+	Added    Type = "added"
+	Updated  Type = "updated"
+	Deleted  Type = "deleted"
+	Reloaded Type = "reloaded"
+	Imported Type = "imported"
+	Synced   Type = "synced"
+	// Revealed and Exported report secrets being read; they change nothing.
+	Revealed Type = "revealed"
+	Exported Type = "exported"
+)
+
+type Kind string
+
+const (
+	KindPassword Kind = "password"
+	KindNote     Kind = "note"
+	KindSSHKey   Kind = "ssh_key"
+)
+
+// Event describes a change to a vault. Kind and ID are empty for vault-wide
+// events such as Reloaded, Imported and Synced.
+type Event struct {
+	Type Type      `json:"type"`
+	Kind Kind      `json:"kind,omitempty"`
+	ID   string    `json:"id,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// Bus delivers events to every subscriber synchronously, in the publisher's
+// goroutine. Handlers must not block and must not publish on the same bus.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[int]func(Event)
+	next int
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[int]func(Event))}
+}
+
+// Subscribe registers fn and returns a function that removes it again.
+func (b *Bus) Subscribe(fn func(Event)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.next
+	b.next++
+	b.subs[id] = fn
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, id)
+	}
+}
+
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	handlers := make([]func(Event), 0, len(b.subs))
+	for _, fn := range b.subs {
+		handlers = append(handlers, fn)
+	}
+	b.mu.RUnlock()
+
+	for _, fn := range handlers {
+		fn(e)
+	}
+}
@@ -0,0 +1,83 @@
+package generator
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+)
+
+const (
+	upperChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	lowerChars  = "abcdefghijklmnopqrstuvwxyz"
+	digitChars  = "0123456789"
+	symbolChars = "!@#$%^&*()-_=+[]{};:,.<>/?"
+)
+
+type Options struct {
+	Length    int
+	Uppercase bool
+	Lowercase bool
+	Digits    bool
+	Symbols   bool
+}
+
+// Generate returns a random password containing at least one character
+// from every enabled class.
+func Generate(opts Options) (string, error) {
+	var classes []string
+	if opts.Uppercase {
+		classes = append(classes, upperChars)
+	}
+	if opts.Lowercase {
+		classes = append(classes, lowerChars)
+	}
+	if opts.Digits {
+		classes = append(classes, digitChars)
+	}
+	if opts.Symbols {
+		classes = append(classes, symbolChars)
+	}
+	if len(classes) == 0 {
+		return "", errors.New("no character classes selected")
+	}
+	if opts.Length < len(classes) {
+		return "", errors.New("password length is shorter than the number of character classes")
+	}
+
+	all := ""
+	for _, class := range classes {
+		all += class
+	}
+
+	password := make([]byte, opts.Length)
+	for i := range password {
+		// The first characters guarantee one of each class; the shuffle
+		// below hides where they ended up
+		set := all
+		if i < len(classes) {
+			set = classes[i]
+		}
+		c, err := randomIndex(len(set))
+		if err != nil {
+			return "", err
+		}
+		password[i] = set[c]
+	}
+
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomIndex(i + 1)
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password), nil
+}
+
+func randomIndex(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
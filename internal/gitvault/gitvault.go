@@ -0,0 +1,379 @@
+// Package gitvault keeps a vault in a git repository as one encrypted file
+// per entry, committing every change so the vault has a history and can be
+// pushed to and pulled from other machines.
+package gitvault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"gopass/internal/events"
+	"gopass/internal/models"
+)
+
+func init() {
+	client.InstallProtocol("file", newLocalTransport())
+}
+
+const (
+	branch = plumbing.ReferenceName("refs/heads/main")
+	// MetaFile holds the vault settings another machine needs to open a
+	// clone, such as the key derivation salt. It is not encrypted.
+	MetaFile = "vault.json"
+)
+
+// Folders in the repository, one per kind of entry.
+var kindDirs = map[events.Kind]string{
+	events.KindPassword: "passwords",
+	events.KindNote:     "notes",
+	events.KindSSHKey:   "ssh_keys",
+}
+
+var ErrPullFirst = errors.New("the remote has changes that are not pulled yet")
+
+// file is one encrypted entry file with its decrypted content.
+type file struct {
+	plain  []byte
+	sealed []byte
+}
+
+// Repo is a vault's git repository. It implements storage.Backend.
+type Repo struct {
+	dir     string
+	repo    *git.Repository
+	encrypt func([]byte) ([]byte, error)
+	decrypt func([]byte) ([]byte, error)
+
+	mu sync.Mutex
+	// files are the entry files at HEAD by path
+	files map[string]file
+}
+
+// Init creates a repository in dir whose first commit holds meta.
+func Init(dir string, meta []byte) error {
+	repo, err := git.PlainInitWithOptions(dir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: branch},
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, MetaFile), meta, 0600); err != nil {
+		return err
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Add(MetaFile); err != nil {
+		return err
+	}
+	_, err = w.Commit("Create vault", &git.CommitOptions{Author: signature(repo)})
+	return err
+}
+
+// Clone copies the repository at url into dir.
+func Clone(ctx context.Context, url, dir string) error {
+	_, err := git.PlainCloneContext(ctx, dir, false, &git.CloneOptions{
+		URL:           url,
+		ReferenceName: branch,
+		SingleBranch:  true,
+	})
+	return err
+}
+
+// ReadMeta returns the settings stored by Init in the repository at dir.
+func ReadMeta(dir string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(dir, MetaFile))
+}
+
+// Open opens the repository in dir. Entries are encrypted and decrypted with
+// the given functions, normally the vault storage's.
+func Open(dir string, encrypt, decrypt func([]byte) ([]byte, error)) (*Repo, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return nil, fmt.Errorf("open git vault %s: %w", dir, err)
+	}
+	return &Repo{dir: dir, repo: repo, encrypt: encrypt, decrypt: decrypt}, nil
+}
+
+// signature is the author of commits: the user from git's configuration
+// when there is one.
+func signature(repo *git.Repository) *object.Signature {
+	sig := &object.Signature{Name: "gopass", Email: "gopass@localhost", When: time.Now()}
+	if cfg, err := repo.ConfigScoped(config.GlobalScope); err == nil {
+		if cfg.User.Name != "" {
+			sig.Name = cfg.User.Name
+		}
+		if cfg.User.Email != "" {
+			sig.Email = cfg.User.Email
+		}
+	}
+	return sig
+}
+
+func entryPath(kind events.Kind, id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return "", fmt.Errorf("invalid entry ID %q", id)
+	}
+	return path.Join(kindDirs[kind], id+".enc"), nil
+}
+
+// isEntryPath reports whether p is an entry file and of which kind.
+func isEntryPath(p string) (events.Kind, bool) {
+	dir, name := path.Split(p)
+	for kind, d := range kindDirs {
+		if dir == d+"/" && strings.HasSuffix(name, ".enc") {
+			return kind, true
+		}
+	}
+	return "", false
+}
+
+// Load reads the entries in the worktree.
+func (r *Repo) Load() (models.ExportData, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	files := make(map[string]file)
+	for _, dir := range kindDirs {
+		err := filepath.WalkDir(filepath.Join(r.dir, dir), func(p string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel := path.Join(dir, d.Name())
+			if _, ok := isEntryPath(rel); !ok {
+				return nil
+			}
+			sealed, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			f, err := r.open(rel, sealed)
+			files[rel] = f
+			return err
+		})
+		if err != nil {
+			return models.ExportData{}, err
+		}
+	}
+
+	data, err := decode(files)
+	if err != nil {
+		return data, err
+	}
+	r.files = files
+	return data, nil
+}
+
+func (r *Repo) open(p string, sealed []byte) (file, error) {
+	plain, err := r.decrypt(sealed)
+	if err != nil {
+		return file{}, fmt.Errorf("%s: %w", p, err)
+	}
+	return file{plain: plain, sealed: sealed}, nil
+}
+
+// decode turns entry files into vault contents, oldest entries first.
+func decode(files map[string]file) (models.ExportData, error) {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var data models.ExportData
+	for _, p := range paths {
+		kind, _ := isEntryPath(p)
+		var err error
+		switch kind {
+		case events.KindPassword:
+			var e models.Password
+			err = json.Unmarshal(files[p].plain, &e)
+			data.Passwords = append(data.Passwords, e)
+		case events.KindNote:
+			var e models.Note
+			err = json.Unmarshal(files[p].plain, &e)
+			data.Notes = append(data.Notes, e)
+		case events.KindSSHKey:
+			var e models.SSHKey
+			err = json.Unmarshal(files[p].plain, &e)
+			data.SSHKeys = append(data.SSHKeys, e)
+		}
+		if err != nil {
+			return data, fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	sort.SliceStable(data.Passwords, func(i, j int) bool { return data.Passwords[i].CreatedAt.Before(data.Passwords[j].CreatedAt) })
+	sort.SliceStable(data.Notes, func(i, j int) bool { return data.Notes[i].CreatedAt.Before(data.Notes[j].CreatedAt) })
+	sort.SliceStable(data.SSHKeys, func(i, j int) bool { return data.SSHKeys[i].CreatedAt.Before(data.SSHKeys[j].CreatedAt) })
+	return data, nil
+}
+
+// encode turns vault contents into entry files. Entries whose content is
+// unchanged in one of reuse keep their ciphertext, so only entries that
+// really changed show up in a commit.
+func (r *Repo) encode(data models.ExportData, reuse ...map[string]file) (map[string]file, error) {
+	files := make(map[string]file)
+	add := func(kind events.Kind, id string, entry any) error {
+		p, err := entryPath(kind, id)
+		if err != nil {
+			return err
+		}
+		plain, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		for _, prev := range reuse {
+			if f, ok := prev[p]; ok && bytes.Equal(f.plain, plain) {
+				files[p] = f
+				return nil
+			}
+		}
+		sealed, err := r.encrypt(plain)
+		files[p] = file{plain: plain, sealed: sealed}
+		return err
+	}
+
+	for _, e := range data.Passwords {
+		if err := add(events.KindPassword, e.ID, e); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range data.Notes {
+		if err := add(events.KindNote, e.ID, e); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range data.SSHKeys {
+		if err := add(events.KindSSHKey, e.ID, e); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Save writes the entries that changed and commits them.
+func (r *Repo) Save(data models.ExportData, change events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	files, err := r.encode(data, r.files)
+	if err != nil {
+		return err
+	}
+	message := describe(change, r.files, files)
+	if _, err := r.commit(files, message); err != nil {
+		return err
+	}
+	return nil
+}
+
+// commit writes files to the worktree and commits them on top of HEAD and
+// any extra parents. It does nothing when nothing changed and there are no
+// extra parents.
+func (r *Repo) commit(files map[string]file, message string, parents ...plumbing.Hash) (bool, error) {
+	w, err := r.repo.Worktree()
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	for p, f := range files {
+		if old, ok := r.files[p]; ok && bytes.Equal(old.sealed, f.sealed) {
+			continue
+		}
+		full := filepath.Join(r.dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0700); err != nil {
+			return false, err
+		}
+		if err := os.WriteFile(full, f.sealed, 0600); err != nil {
+			return false, err
+		}
+		if _, err := w.Add(p); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	for p := range r.files {
+		if _, ok := files[p]; ok {
+			continue
+		}
+		if _, err := w.Remove(p); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	if !changed && len(parents) == 0 {
+		return false, nil
+	}
+
+	opts := &git.CommitOptions{Author: signature(r.repo), AllowEmptyCommits: true}
+	if len(parents) > 0 {
+		head, err := r.repo.Head()
+		if err != nil {
+			return false, err
+		}
+		opts.Parents = append([]plumbing.Hash{head.Hash()}, parents...)
+	}
+	if _, err := w.Commit(message, opts); err != nil {
+		return false, err
+	}
+	r.files = files
+	return true, nil
+}
+
+// describe writes the commit message for going from before to after.
+func describe(change events.Event, before, after map[string]file) string {
+	var added, updated, deleted []string
+	for p, f := range after {
+		if old, ok := before[p]; !ok {
+			added = append(added, p)
+		} else if !bytes.Equal(old.plain, f.plain) {
+			updated = append(updated, p)
+		}
+	}
+	for p := range before {
+		if _, ok := after[p]; !ok {
+			deleted = append(deleted, p)
+		}
+	}
+
+	switch n := len(added) + len(updated) + len(deleted); {
+	case n == 1 && len(added) == 1:
+		return "Add " + entryName(added[0])
+	case n == 1 && len(updated) == 1:
+		return "Update " + entryName(updated[0])
+	case n == 1 && len(deleted) == 1:
+		return "Delete " + entryName(deleted[0])
+	case change.Type == events.Imported:
+		return fmt.Sprintf("Import %d entries", len(added))
+	default:
+		return fmt.Sprintf("Update vault: %d added, %d changed, %d deleted", len(added), len(updated), len(deleted))
+	}
+}
+
+// entryName describes the entry file at p by kind and ID. Names stay out of
+// commit messages, which are not encrypted.
+func entryName(p string) string {
+	kind, _ := isEntryPath(p)
+	return strings.ReplaceAll(string(kind), "_", " ") + " " + strings.TrimSuffix(path.Base(p), ".enc")
+}
@@ -0,0 +1,154 @@
+package gitvault
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopass/internal/models"
+	"gopass/internal/storage"
+)
+
+var key = []byte("0123456789abcdef0123456789abcdef")
+
+func openVault(t *testing.T, dir string) (*storage.Storage, *Repo) {
+	t.Helper()
+	s := storage.NewStorageWithKey(filepath.Join(t.TempDir(), "data.enc"), key)
+	repo, err := Open(dir, s.Encrypt, s.Decrypt)
+	require.NoError(t, err)
+	s.SetBackend(repo)
+	require.NoError(t, s.Load())
+	return s, repo
+}
+
+// newPair returns a vault pushed to a bare repository and a clone of it,
+// as on two machines.
+func newPair(t *testing.T) (laptop, desktop *storage.Storage, laptopRepo, desktopRepo *Repo) {
+	t.Helper()
+	ctx := context.Background()
+	bare := filepath.Join(t.TempDir(), "vault.git")
+	_, err := git.PlainInit(bare, true)
+	require.NoError(t, err)
+
+	laptopDir := filepath.Join(t.TempDir(), "laptop")
+	require.NoError(t, Init(laptopDir, []byte(`{"kdf":"test"}`)))
+	laptop, laptopRepo = openVault(t, laptopDir)
+	require.NoError(t, laptopRepo.AddRemote(DefaultRemote, bare))
+	require.NoError(t, laptopRepo.Push(ctx, DefaultRemote))
+
+	desktopDir := filepath.Join(t.TempDir(), "desktop")
+	require.NoError(t, Clone(ctx, bare, desktopDir))
+	meta, err := ReadMeta(desktopDir)
+	require.NoError(t, err)
+	assert.Equal(t, `{"kdf":"test"}`, string(meta))
+	desktop, desktopRepo = openVault(t, desktopDir)
+	return
+}
+
+func TestEveryChangeIsCommittedEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, Init(dir, []byte("{}")))
+	s, repo := openVault(t, dir)
+
+	p := models.Password{ID: "p1", Name: "GitHub", Password: "hunter2", CreatedAt: time.Now()}
+	require.NoError(t, s.AddPassword(p))
+	p.Username = "me"
+	require.NoError(t, s.UpdatePassword(p))
+	require.NoError(t, s.AddNote(models.Note{ID: "n1", Title: "Wifi"}))
+	require.NoError(t, s.DeleteNote("n1"))
+	require.NoError(t, s.Save(), "saving without changes commits nothing")
+
+	log, err := repo.Log(10)
+	require.NoError(t, err)
+	var messages []string
+	for _, c := range log {
+		messages = append(messages, c.Message)
+	}
+	assert.Equal(t, []string{"Delete note n1", "Add note n1", "Update password p1", "Add password p1", "Create vault"}, messages)
+
+	sealed, err := os.ReadFile(filepath.Join(dir, "passwords", "p1.enc"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "hunter2")
+	assert.NotContains(t, string(sealed), "GitHub")
+
+	reopened, _ := openVault(t, dir)
+	assert.Equal(t, s.GetPasswords()[0].Username, reopened.GetPasswords()[0].Username)
+}
+
+func TestPullMergesIndependentChanges(t *testing.T) {
+	ctx := context.Background()
+	laptop, desktop, laptopRepo, desktopRepo := newPair(t)
+
+	github := models.Password{ID: "p1", Name: "GitHub", Password: "pw", CreatedAt: time.Now()}
+	require.NoError(t, laptop.AddPassword(github))
+	require.NoError(t, laptopRepo.Push(ctx, DefaultRemote))
+
+	res, err := desktopRepo.Pull(ctx, desktop, DefaultRemote)
+	require.NoError(t, err)
+	assert.True(t, res.Changed, "fast-forward")
+	require.NoError(t, desktop.Replace(res.Data))
+	require.Len(t, desktop.GetPasswords(), 1)
+
+	// Both sides change different entries
+	github.Username = "me"
+	require.NoError(t, desktop.UpdatePassword(github))
+	require.NoError(t, laptop.AddNote(models.Note{ID: "n1", Title: "Wifi"}))
+	require.NoError(t, laptopRepo.Push(ctx, DefaultRemote))
+	assert.ErrorIs(t, desktopRepo.Push(ctx, DefaultRemote), ErrPullFirst)
+
+	res, err = desktopRepo.Pull(ctx, desktop, DefaultRemote)
+	require.NoError(t, err)
+	assert.Empty(t, res.Conflicts)
+	require.NoError(t, desktop.Replace(res.Data))
+	require.NoError(t, desktopRepo.Push(ctx, DefaultRemote))
+	log, err := desktopRepo.Log(1)
+	require.NoError(t, err)
+	assert.Equal(t, "Merge origin", log[0].Message)
+
+	res, err = laptopRepo.Pull(ctx, laptop, DefaultRemote)
+	require.NoError(t, err)
+	require.NoError(t, laptop.Replace(res.Data))
+	assert.Equal(t, "me", laptop.GetPasswords()[0].Username)
+	assert.Len(t, laptop.GetNotes(), 1)
+	assert.Equal(t, desktop.Snapshot(), laptop.Snapshot())
+}
+
+func TestPullReportsConflicts(t *testing.T) {
+	ctx := context.Background()
+	laptop, desktop, laptopRepo, desktopRepo := newPair(t)
+
+	p := models.Password{ID: "p1", Name: "Bank", Password: "old", CreatedAt: time.Now()}
+	require.NoError(t, laptop.AddPassword(p))
+	require.NoError(t, laptopRepo.Push(ctx, DefaultRemote))
+	res, err := desktopRepo.Pull(ctx, desktop, DefaultRemote)
+	require.NoError(t, err)
+	require.NoError(t, desktop.Replace(res.Data))
+
+	p.Password, p.UpdatedAt = "laptop", time.Now()
+	require.NoError(t, laptop.UpdatePassword(p))
+	require.NoError(t, laptopRepo.Push(ctx, DefaultRemote))
+	p.Password, p.UpdatedAt = "desktop", time.Now().Add(time.Minute)
+	require.NoError(t, desktop.UpdatePassword(p))
+
+	res, err = desktopRepo.Pull(ctx, desktop, DefaultRemote)
+	require.NoError(t, err)
+	require.Len(t, res.Conflicts, 1)
+	// Secrets are compared in the open, field by field
+	assert.Equal(t, []string{"Password"}, res.Conflicts[0].Fields)
+	require.NoError(t, desktop.Replace(res.Data))
+
+	var passwords []string
+	for _, p := range desktop.GetPasswords() {
+		p, err := desktop.RevealPassword(p.ID)
+		require.NoError(t, err)
+		passwords = append(passwords, p.Name+"="+p.Password)
+	}
+	assert.ElementsMatch(t, []string{"Bank=desktop", "Bank (conflicted copy)=laptop"}, passwords)
+	assert.False(t, strings.Contains(res.Conflicts[0].CopyID, "/"))
+}
@@ -0,0 +1,231 @@
+package gitvault
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"gopass/internal/models"
+	"gopass/internal/vaultsync"
+)
+
+// DefaultRemote is the remote pull and push use when none is named.
+const DefaultRemote = "origin"
+
+func (r *Repo) AddRemote(name, url string) error {
+	_, err := r.repo.CreateRemote(&config.RemoteConfig{Name: name, URLs: []string{url}})
+	return err
+}
+
+// Remotes returns the configured remotes by name with their URL.
+func (r *Repo) Remotes() (map[string]string, error) {
+	remotes, err := r.repo.Remotes()
+	if err != nil {
+		return nil, err
+	}
+	urls := make(map[string]string, len(remotes))
+	for _, rm := range remotes {
+		urls[rm.Config().Name] = strings.Join(rm.Config().URLs, " ")
+	}
+	return urls, nil
+}
+
+// Commit is one change in the vault's history.
+type Commit struct {
+	Hash    string
+	Message string
+	Author  string
+	When    time.Time
+}
+
+// Log returns up to n commits reachable from HEAD, newest first.
+func (r *Repo) Log(n int) ([]Commit, error) {
+	iter, err := r.repo.Log(&git.LogOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var commits []Commit
+	for len(commits) < n {
+		c, err := iter.Next()
+		if err != nil {
+			break
+		}
+		commits = append(commits, Commit{
+			Hash:    c.Hash.String(),
+			Message: strings.TrimSpace(c.Message),
+			Author:  c.Author.Name,
+			When:    c.Author.When,
+		})
+	}
+	return commits, nil
+}
+
+// PullResult describes what a pull did.
+type PullResult struct {
+	// Data is the vault after the pull.
+	Data models.ExportData
+	// Changed is set when the pull brought in changes.
+	Changed   bool
+	Conflicts []vaultsync.Conflict
+}
+
+// Pull fetches the remote's branch and merges it: fast-forward when only the
+// remote moved, otherwise a three-way merge of the entries committed as a
+// merge commit. Entries both sides changed are resolved as vaultsync.Merge
+// does and reported as conflicts; sec opens their secrets for the merge.
+func (r *Repo) Pull(ctx context.Context, sec vaultsync.Secrets, remote string) (PullResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var res PullResult
+	tracking := plumbing.NewRemoteReferenceName(remote, branch.Short())
+	err := r.repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: remote,
+		RefSpecs:   []config.RefSpec{config.RefSpec("+" + branch + ":" + tracking)},
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) && !errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return res, err
+	}
+	if res.Data, err = decode(r.files); err != nil {
+		return res, err
+	}
+
+	ref, err := r.repo.Reference(tracking, true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return res, nil
+	}
+	if err != nil {
+		return res, err
+	}
+	head, err := r.repo.Head()
+	if err != nil {
+		return res, err
+	}
+	ours, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return res, err
+	}
+	theirs, err := r.repo.CommitObject(ref.Hash())
+	if err != nil {
+		return res, err
+	}
+
+	if behind, err := theirs.IsAncestor(ours); err != nil || behind || theirs.Hash == ours.Hash {
+		return res, err
+	}
+	if ahead, err := ours.IsAncestor(theirs); err != nil {
+		return res, err
+	} else if ahead {
+		return r.fastForward(theirs)
+	}
+	return r.merge(sec, remote, ours, theirs)
+}
+
+func (r *Repo) fastForward(to *object.Commit) (PullResult, error) {
+	var res PullResult
+	w, err := r.repo.Worktree()
+	if err != nil {
+		return res, err
+	}
+	if err := w.Reset(&git.ResetOptions{Commit: to.Hash, Mode: git.HardReset}); err != nil {
+		return res, err
+	}
+	files, err := r.treeFiles(to)
+	if err != nil {
+		return res, err
+	}
+	r.files = files
+	res.Data, err = decode(files)
+	res.Changed = true
+	return res, err
+}
+
+func (r *Repo) merge(sec vaultsync.Secrets, remote string, ours, theirs *object.Commit) (PullResult, error) {
+	var res PullResult
+	var base models.ExportData
+	if bases, err := ours.MergeBase(theirs); err != nil {
+		return res, err
+	} else if len(bases) > 0 {
+		files, err := r.treeFiles(bases[0])
+		if err != nil {
+			return res, err
+		}
+		if base, err = decode(files); err != nil {
+			return res, err
+		}
+	}
+
+	theirFiles, err := r.treeFiles(theirs)
+	if err != nil {
+		return res, err
+	}
+	theirData, err := decode(theirFiles)
+	if err != nil {
+		return res, err
+	}
+	ourData, err := decode(r.files)
+	if err != nil {
+		return res, err
+	}
+
+	if res.Data, res.Conflicts, err = vaultsync.MergeSealed(sec, base, ourData, theirData); err != nil {
+		return res, err
+	}
+	files, err := r.encode(res.Data, r.files, theirFiles)
+	if err != nil {
+		return res, err
+	}
+	if _, err := r.commit(files, "Merge "+remote, theirs.Hash); err != nil {
+		return res, err
+	}
+	res.Changed = !vaultsync.Equal(res.Data, ourData)
+	return res, nil
+}
+
+// treeFiles reads and decrypts the entry files of a commit.
+func (r *Repo) treeFiles(c *object.Commit) (map[string]file, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]file)
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if _, ok := isEntryPath(f.Name); !ok {
+			return nil
+		}
+		contents, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		files[f.Name], err = r.open(f.Name, []byte(contents))
+		return err
+	})
+	return files, err
+}
+
+// Push sends the vault's branch to the remote. It fails with ErrPullFirst
+// when the remote has commits that have not been pulled.
+func (r *Repo) Push(ctx context.Context, remote string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: remote,
+		RefSpecs:   []config.RefSpec{config.RefSpec(branch + ":" + branch)},
+	})
+	switch {
+	case errors.Is(err, git.NoErrAlreadyUpToDate):
+		return nil
+	case errors.Is(err, git.ErrForceNeeded), err != nil && strings.Contains(err.Error(), "non-fast-forward"):
+		return ErrPullFirst
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package gitvault
+
+import (
+	"context"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+)
+
+// localTransport serves file:// and plain path remotes in process instead of
+// running git-upload-pack, so no git installation is needed.
+type localTransport struct {
+	transport.Transport
+	loader server.Loader
+}
+
+func newLocalTransport() transport.Transport {
+	return localTransport{Transport: server.NewClient(server.DefaultLoader), loader: server.DefaultLoader}
+}
+
+func (t localTransport) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	sess, err := t.Transport.NewUploadPackSession(ep, auth)
+	if err != nil {
+		return nil, err
+	}
+	sto, err := t.loader.Load(ep)
+	if err != nil {
+		sess.Close()
+		return nil, err
+	}
+	return uploadPack{UploadPackSession: sess, sto: sto}, nil
+}
+
+// uploadPack drops the commits the client has that the repository does not
+// from fetch requests: go-git's server fails on them instead of ignoring
+// them, which breaks every fetch after a local commit.
+type uploadPack struct {
+	transport.UploadPackSession
+	sto storer.Storer
+}
+
+func (u uploadPack) UploadPack(ctx context.Context, req *packp.UploadPackRequest) (*packp.UploadPackResponse, error) {
+	var haves []plumbing.Hash
+	for _, h := range req.Haves {
+		if u.sto.HasEncodedObject(h) == nil {
+			haves = append(haves, h)
+		}
+	}
+	req.Haves = haves
+	return u.UploadPackSession.UploadPack(ctx, req)
+}
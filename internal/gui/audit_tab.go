@@ -0,0 +1,97 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"gopass/internal/audit"
+)
+
+// AuditTab shows the current vault's audit log, newest first, and whether
+// its hash chain is intact.
+type AuditTab struct {
+	log     *audit.Log
+	list    *widget.List
+	status  *widget.Label
+	entries []audit.Entry
+	shown   []audit.Entry
+	filter  audit.Filter
+}
+
+func NewAuditTab(log *audit.Log) *AuditTab {
+	return &AuditTab{log: log}
+}
+
+func (t *AuditTab) createContent() fyne.CanvasObject {
+	t.status = widget.NewLabel("")
+	t.list = widget.NewList(
+		func() int {
+			return len(t.shown)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("Template")
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			e := t.shown[i]
+			text := fmt.Sprintf("%s  %s", e.Time.Local().Format(time.DateTime), e.Action)
+			if e.ID != "" {
+				text += fmt.Sprintf("  %s %s", e.Kind, e.ID)
+			}
+			o.(*widget.Label).SetText(text + "  via " + e.Client)
+		},
+	)
+
+	search := widget.NewEntry()
+	search.SetPlaceHolder("Filter by entry ID, kind or client")
+	search.OnChanged = func(text string) {
+		t.filter.Text = text
+		t.apply()
+	}
+	actions := []string{"All actions"}
+	for _, a := range audit.Actions {
+		actions = append(actions, string(a))
+	}
+	actionSelect := widget.NewSelect(actions, func(selected string) {
+		t.filter.Action = ""
+		if selected != actions[0] {
+			t.filter.Action = audit.Action(selected)
+		}
+		t.apply()
+	})
+	actionSelect.SetSelected(actions[0])
+
+	t.refresh()
+	header := container.NewBorder(nil, nil, nil,
+		container.NewHBox(actionSelect, widget.NewButton("Refresh", t.refresh)), search)
+	return container.NewBorder(container.NewVBox(header, t.status), nil, nil, nil, t.list)
+}
+
+func (t *AuditTab) refresh() {
+	entries, err := t.log.Entries()
+	switch {
+	case errors.Is(err, audit.ErrTampered):
+		t.status.SetText("Warning: " + err.Error())
+	case err != nil:
+		t.status.SetText("Error reading the audit log: " + err.Error())
+	default:
+		t.status.SetText(fmt.Sprintf("%d entries; the log is intact.", len(entries)))
+	}
+	t.entries = entries
+	t.apply()
+}
+
+func (t *AuditTab) apply() {
+	t.shown = t.shown[:0]
+	for i := len(t.entries) - 1; i >= 0; i-- {
+		if t.filter.Match(t.entries[i]) {
+			t.shown = append(t.shown, t.entries[i])
+		}
+	}
+	if t.list != nil {
+		t.list.Refresh()
+	}
+}
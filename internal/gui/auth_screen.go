@@ -1,23 +1,56 @@
 package gui
 
 import (
+	"fmt"
+	"io"
+	"strconv"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
 	"gopass/internal/auth"
+	"gopass/internal/vault"
 )
 
+// AuthHooks connect the auth screen to the vault's recovery secret, key
+// file and audit log.
+type AuthHooks struct {
+	// RecoveryAvailable reports whether the vault can be recovered without
+	// its PIN.
+	RecoveryAvailable func() bool
+	// SetUpRecovery creates a recovery key and/or shares after a PIN was set.
+	SetUpRecovery func(pin string, key bool, shares, threshold int) error
+	// Recover unlocks the vault with the secret and sets newPIN.
+	Recover func(secret []byte, newPIN string) error
+	// NeedsKeyFile reports whether the vault needs a key file besides the PIN.
+	NeedsKeyFile func() bool
+	// UseKeyFile checks the chosen key file and keeps it for unlocking. The
+	// PIN is then checked with the returned store.
+	UseKeyFile func(data []byte) (*auth.Auth, error)
+	// RequireKeyFile makes the vault need data besides pin from now on.
+	RequireKeyFile func(pin string, data []byte) error
+	// Failed notes a failed unlock in the audit log.
+	Failed func()
+}
+
 type AuthScreen struct {
-	window fyne.Window
-	auth   *auth.Auth
-	onAuth func()
+	window       fyne.Window
+	auth         *auth.Auth
+	minPINLength int
+	vaultBar     fyne.CanvasObject
+	onAuth       func(pin string)
+	hooks        AuthHooks
 }
 
-func NewAuthScreen(window fyne.Window, auth *auth.Auth, onAuth func()) *AuthScreen {
+func NewAuthScreen(window fyne.Window, auth *auth.Auth, minPINLength int, vaultBar fyne.CanvasObject, onAuth func(pin string), hooks AuthHooks) *AuthScreen {
 	return &AuthScreen{
-		window: window,
-		auth:   auth,
-		onAuth: onAuth,
+		window:       window,
+		auth:         auth,
+		minPINLength: minPINLength,
+		vaultBar:     vaultBar,
+		onAuth:       onAuth,
+		hooks:        hooks,
 	}
 }
 
@@ -33,24 +66,72 @@ func (a *AuthScreen) Load() {
 	a.window.SetContent(content)
 }
 
+// checkNewPIN returns why pin cannot be set, or "" when it can.
+func (a *AuthScreen) checkNewPIN(pin, confirm string) string {
+	if pin != confirm {
+		return "PINs do not match"
+	}
+	if len(pin) < a.minPINLength {
+		return fmt.Sprintf("PIN must be at least %d characters", a.minPINLength)
+	}
+	return ""
+}
+
 func (a *AuthScreen) createSetPINScreen() fyne.CanvasObject {
 	pinEntry := widget.NewPasswordEntry()
 	confirmEntry := widget.NewPasswordEntry()
 	message := widget.NewLabel("")
 
+	recoveryKey := widget.NewCheck("Print a recovery key", nil)
+	sharesEntry := widget.NewEntry()
+	sharesEntry.SetPlaceHolder("e.g. 5, or empty for none")
+	thresholdEntry := widget.NewEntry()
+	thresholdEntry.SetPlaceHolder("e.g. 3")
+
+	var keyFile []byte
+	keyFileLabel := widget.NewLabel("None")
+	keyFileBtn := widget.NewButton("Create Key File...", func() {
+		dialog.ShowFileSave(func(w fyne.URIWriteCloser, err error) {
+			if err != nil || w == nil {
+				return
+			}
+			defer w.Close()
+			data, err := vault.NewKeyFile()
+			if err == nil {
+				_, err = w.Write(data)
+			}
+			if err != nil {
+				message.SetText("Error writing key file: " + err.Error())
+				return
+			}
+			keyFile = data
+			keyFileLabel.SetText(w.URI().Name())
+		}, a.window)
+	})
+
 	form := &widget.Form{
 		Items: []*widget.FormItem{
 			{Text: "Create PIN", Widget: pinEntry},
 			{Text: "Confirm PIN", Widget: confirmEntry},
+			{Text: "Key File", Widget: container.NewHBox(keyFileLabel, keyFileBtn)},
+			{Text: "Recovery", Widget: recoveryKey},
+			{Text: "Recovery Shares", Widget: sharesEntry},
+			{Text: "Shares Needed", Widget: thresholdEntry},
 		},
 		OnSubmit: func() {
-			if pinEntry.Text != confirmEntry.Text {
-				message.SetText("PINs do not match")
+			if problem := a.checkNewPIN(pinEntry.Text, confirmEntry.Text); problem != "" {
+				message.SetText(problem)
 				return
 			}
-			if len(pinEntry.Text) < 4 {
-				message.SetText("PIN must be at least 4 characters")
-				return
+			var shares, threshold int
+			if sharesEntry.Text != "" {
+				var err1, err2 error
+				shares, err1 = strconv.Atoi(sharesEntry.Text)
+				threshold, err2 = strconv.Atoi(thresholdEntry.Text)
+				if err1 != nil || err2 != nil || threshold < 2 || threshold > shares || shares > 255 {
+					message.SetText("Shares needed must be between 2 and the number of shares")
+					return
+				}
 			}
 
 			err := a.auth.SetPIN(pinEntry.Text)
@@ -59,11 +140,23 @@ func (a *AuthScreen) createSetPINScreen() fyne.CanvasObject {
 				return
 			}
 
-			a.onAuth()
+			a.onAuth(pinEntry.Text)
+			if keyFile != nil {
+				if err := a.hooks.RequireKeyFile(pinEntry.Text, keyFile); err != nil {
+					message.SetText("Error setting key file: " + err.Error())
+					return
+				}
+			}
+			if recoveryKey.Checked || shares > 0 {
+				if err := a.hooks.SetUpRecovery(pinEntry.Text, recoveryKey.Checked, shares, threshold); err != nil {
+					message.SetText("Error setting up recovery: " + err.Error())
+				}
+			}
 		},
 	}
 
 	return container.NewVBox(
+		a.vaultBar,
 		widget.NewLabel("Welcome to GoPass"),
 		widget.NewLabel("Please set a PIN to secure your data"),
 		form,
@@ -79,18 +172,105 @@ func (a *AuthScreen) createLoginScreen() fyne.CanvasObject {
 		Items: []*widget.FormItem{
 			{Text: "Enter PIN", Widget: pinEntry},
 		},
+	}
+
+	needsKeyFile := a.hooks.NeedsKeyFile()
+	var keyFile []byte
+	if needsKeyFile {
+		keyFileLabel := widget.NewLabel("Not chosen")
+		form.Append("Key File", container.NewHBox(keyFileLabel, widget.NewButton("Choose...", func() {
+			dialog.ShowFileOpen(func(r fyne.URIReadCloser, err error) {
+				if err != nil || r == nil {
+					return
+				}
+				defer r.Close()
+				data, err := io.ReadAll(r)
+				if err != nil {
+					message.SetText("Error reading key file: " + err.Error())
+					return
+				}
+				keyFile = data
+				keyFileLabel.SetText(r.URI().Name())
+			}, a.window)
+		})))
+	}
+
+	form.OnSubmit = func() {
+		if needsKeyFile {
+			if keyFile == nil {
+				message.SetText("Choose the key file of this vault")
+				return
+			}
+			checker, err := a.hooks.UseKeyFile(keyFile)
+			if err != nil {
+				a.hooks.Failed()
+				message.SetText("This is not the key file of this vault")
+				return
+			}
+			a.auth = checker
+		}
+		if a.auth.ValidatePIN(pinEntry.Text) {
+			a.onAuth(pinEntry.Text)
+			pinEntry.SetText("")
+		} else {
+			a.hooks.Failed()
+			message.SetText("Invalid PIN")
+			pinEntry.SetText("")
+		}
+	}
+
+	box := container.NewVBox(
+		a.vaultBar,
+		widget.NewLabel("Welcome back to GoPass"),
+		form,
+		message,
+	)
+	if a.hooks.RecoveryAvailable() {
+		box.Add(widget.NewButton("Forgot PIN?", func() {
+			a.window.SetContent(a.createRecoveryScreen())
+		}))
+	}
+	return box
+}
+
+// createRecoveryScreen takes the recovery key or enough shares and makes
+// the user choose a new PIN before the vault opens.
+func (a *AuthScreen) createRecoveryScreen() fyne.CanvasObject {
+	secretEntry := widget.NewMultiLineEntry()
+	secretEntry.SetPlaceHolder("Recovery key, or one share per line")
+	secretEntry.SetMinRowsVisible(5)
+	pinEntry := widget.NewPasswordEntry()
+	confirmEntry := widget.NewPasswordEntry()
+	message := widget.NewLabel("")
+
+	form := &widget.Form{
+		Items: []*widget.FormItem{
+			{Text: "Recovery", Widget: secretEntry},
+			{Text: "New PIN", Widget: pinEntry},
+			{Text: "Confirm PIN", Widget: confirmEntry},
+		},
 		OnSubmit: func() {
-			if a.auth.ValidatePIN(pinEntry.Text) {
-				a.onAuth()
-			} else {
-				message.SetText("Invalid PIN")
-				pinEntry.SetText("")
+			secret, err := parseRecoveryText(secretEntry.Text)
+			if err != nil {
+				message.SetText(err.Error())
+				return
+			}
+			if problem := a.checkNewPIN(pinEntry.Text, confirmEntry.Text); problem != "" {
+				message.SetText(problem)
+				return
 			}
+			if err := a.hooks.Recover(secret, pinEntry.Text); err != nil {
+				message.SetText("Recovery failed: " + err.Error())
+			}
+		},
+		OnCancel: func() {
+			a.Load()
 		},
 	}
 
 	return container.NewVBox(
-		widget.NewLabel("Welcome back to GoPass"),
+		a.vaultBar,
+		widget.NewLabel("Recover the vault and choose a new PIN"),
 		form,
 		message,
 	)
@@ -0,0 +1,48 @@
+package gui
+
+import "time"
+
+// touch records user activity and restarts the auto-lock countdown.
+func (m *MainApp) touch() {
+	m.lockMu.Lock()
+	defer m.lockMu.Unlock()
+
+	if m.lockTimer != nil {
+		m.lockTimer.Stop()
+		m.lockTimer = nil
+	}
+	if d := m.config.Security.AutoLock; d > 0 && len(m.open) > 0 {
+		m.lockTimer = time.AfterFunc(d, func() {
+			m.inUI(func() {
+				m.lockVaults()
+				m.logOutput("Vaults locked after inactivity.")
+			})
+		})
+	}
+}
+
+// lockVaults closes every unlocked vault and returns to the PIN screen.
+func (m *MainApp) lockVaults() {
+	m.lockMu.Lock()
+	if m.lockTimer != nil {
+		m.lockTimer.Stop()
+		m.lockTimer = nil
+	}
+	m.lockMu.Unlock()
+
+	for name, s := range m.open {
+		s.Close()
+		delete(m.open, name)
+		delete(m.teams, name)
+		delete(m.audits, name)
+	}
+	m.storage = nil
+	if m.restoreDumps != nil {
+		m.restoreDumps()
+		m.restoreDumps = nil
+	}
+	m.forgetKeyFile()
+	m.auth = m.vault.NewAuth()
+	m.authScreen = m.newAuthScreen()
+	m.LoadAuth()
+}
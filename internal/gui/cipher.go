@@ -0,0 +1,38 @@
+package gui
+
+import (
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"gopass/internal/storage"
+)
+
+// showCipherDialog re-encrypts the open vault with the chosen cipher suite.
+func (m *MainApp) showCipherDialog() {
+	if m.storage == nil {
+		return
+	}
+	var names []string
+	for _, s := range storage.Suites {
+		names = append(names, s.String())
+	}
+	current := m.storage.Suite()
+	suiteSelect := widget.NewSelect(names, nil)
+	suiteSelect.SetSelected(current.String())
+	items := []*widget.FormItem{
+		{Text: "Cipher", Widget: suiteSelect, HintText: "applies to the vault file; entry secrets stay aes-256-gcm"},
+	}
+	dialog.ShowForm("Vault Cipher", "Re-encrypt", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		suite, err := storage.ParseSuite(suiteSelect.Selected)
+		if err == nil && suite != current {
+			err = m.storage.SetSuite(suite)
+		}
+		if err != nil {
+			dialog.ShowError(err, m.window)
+			return
+		}
+		m.logOutput("Vault " + m.vault.Name + " encrypted with " + suite.String() + ".")
+	}, m.window)
+}
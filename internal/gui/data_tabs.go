@@ -42,21 +42,10 @@ func (d *DataTabs) createExportTab() fyne.CanvasObject {
 			// Handle export in goroutine
 			go func() {
 				data, err := d.mainApp.storage.Export()
-				if err != nil {
-					d.window.Canvas().Refresh(d.window.Content())
-					dialog.ShowError(err, d.window)
-					return
+				if err == nil {
+					_, err = writer.Write(data)
 				}
-
-				_, err = writer.Write(data)
-				if err != nil {
-					d.window.Canvas().Refresh(d.window.Content())
-					dialog.ShowError(err, d.window)
-					return
-				}
-
-				d.window.Canvas().Refresh(d.window.Content())
-				d.mainApp.logOutput(fmt.Sprintf("Data exported successfully to %s", writer.URI().Path()))
+				d.finish(err, fmt.Sprintf("Data exported successfully to %s", writer.URI().Path()))
 			}()
 		}, d.window)
 	})
@@ -85,21 +74,10 @@ func (d *DataTabs) createImportTab() fyne.CanvasObject {
 			// Handle import in goroutine
 			go func() {
 				data, err := os.ReadFile(reader.URI().Path())
-				if err != nil {
-					d.window.Canvas().Refresh(d.window.Content())
-					dialog.ShowError(err, d.window)
-					return
-				}
-
-				err = d.mainApp.storage.Import(data)
-				if err != nil {
-					d.window.Canvas().Refresh(d.window.Content())
-					dialog.ShowError(err, d.window)
-					return
+				if err == nil {
+					err = d.mainApp.storage.Import(data)
 				}
-
-				d.window.Canvas().Refresh(d.window.Content())
-				d.mainApp.logOutput(fmt.Sprintf("Data imported successfully from %s", filepath.Base(reader.URI().Path())))
+				d.finish(err, fmt.Sprintf("Data imported successfully from %s", filepath.Base(reader.URI().Path())))
 			}()
 		}, d.window)
 
@@ -112,3 +90,15 @@ func (d *DataTabs) createImportTab() fyne.CanvasObject {
 		importBtn,
 	)
 }
+
+// finish reports how an export or import running in the background went,
+// through the UI goroutine.
+func (d *DataTabs) finish(err error, message string) {
+	d.mainApp.inUI(func() {
+		if err != nil {
+			dialog.ShowError(err, d.window)
+			return
+		}
+		d.mainApp.logOutput(message)
+	})
+}
@@ -0,0 +1,50 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showDuressDialog adds a duress PIN to the current vault. The vault is
+// locked first, since its files are rewritten, and the decoy is filled by
+// unlocking it with the duress PIN.
+func (m *MainApp) showDuressDialog() {
+	pinEntry := widget.NewPasswordEntry()
+	duressEntry := widget.NewPasswordEntry()
+	confirmEntry := widget.NewPasswordEntry()
+	items := []*widget.FormItem{
+		{Text: "Current PIN", Widget: pinEntry},
+		{Text: "Duress PIN", Widget: duressEntry, HintText: "opens a decoy vault instead"},
+		{Text: "Confirm Duress PIN", Widget: confirmEntry},
+	}
+	dialog.ShowForm("Set Duress PIN", "Set", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		if err := m.setDuressPIN(pinEntry.Text, duressEntry.Text, confirmEntry.Text); err != nil {
+			dialog.ShowError(err, m.window)
+			return
+		}
+		dialog.ShowInformation("Duress PIN Set",
+			"Unlock with the duress PIN and add a few plausible entries to the decoy vault.", m.window)
+	}, m.window)
+}
+
+func (m *MainApp) setDuressPIN(pin, duress, confirm string) error {
+	if len(duress) < m.config.Security.MinPINLength {
+		return fmt.Errorf("PIN must be at least %d characters", m.config.Security.MinPINLength)
+	}
+	if duress != confirm {
+		return errors.New("PINs do not match")
+	}
+	v := m.vault
+	m.lockVaults()
+	if err := v.SetDuressPIN(pin, duress); err != nil {
+		return err
+	}
+	m.logOutput("Duress PIN set for vault " + v.Name + ".")
+	return nil
+}
@@ -20,7 +20,7 @@ func setupTestApp(t *testing.T) (fyne.App, *MainApp) {
 	assert.NoError(t, err, "Should set PIN without error")
 	
 	// Trigger auth success to show main UI
-	mainApp.onAuthSuccess()
+	mainApp.onAuthSuccess("123456")
 	
 	mainWindow.Resize(fyne.NewSize(800, 600))
 	return app, mainApp
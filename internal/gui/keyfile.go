@@ -0,0 +1,72 @@
+package gui
+
+import (
+	"errors"
+
+	"gopass/internal/auth"
+	"gopass/internal/secmem"
+)
+
+func (m *MainApp) authHooks() AuthHooks {
+	return AuthHooks{
+		RecoveryAvailable: m.vault.HasRecovery,
+		SetUpRecovery:     m.setUpRecovery,
+		Recover:           m.recoverVault,
+		NeedsKeyFile:      func() bool { return m.vault.KeyFile != nil },
+		UseKeyFile:        m.useKeyFile,
+		RequireKeyFile:    m.requireKeyFile,
+		Failed:            m.recordFailure,
+	}
+}
+
+// useKeyFile keeps the key file the user picked for unlocking the current
+// vault, after checking it is the right one, and returns the PIN store
+// that checks the PIN together with it.
+func (m *MainApp) useKeyFile(data []byte) (*auth.Auth, error) {
+	v, err := m.vault.WithKeyFile(data)
+	if err != nil {
+		return nil, err
+	}
+	a := v.NewAuth()
+	if err := a.LoadPINHash(); err != nil {
+		return nil, err
+	}
+	m.vault, m.auth = v, a
+	return a, nil
+}
+
+// requireKeyFile re-encrypts the open vault under a new key that needs
+// data besides pin.
+func (m *MainApp) requireKeyFile(pin string, data []byte) error {
+	if m.storage == nil {
+		return errors.New("the vault is not open")
+	}
+	v, err := m.registry.SetKeyFile(m.vault, pin, data, m.storage.Rekey)
+	if err != nil {
+		return err
+	}
+	m.vault, m.auth = v, v.NewAuth()
+	if err := m.auth.LoadPINHash(); err != nil {
+		return err
+	}
+	m.auth.ValidatePIN(pin)
+	if log, ok := m.audits[v.Name]; ok {
+		key, err := v.Key(pin)
+		if err != nil {
+			return err
+		}
+		defer secmem.Wipe(key)
+		if err := log.Rekey(key); err != nil {
+			return err
+		}
+	}
+	m.logOutput("Vault " + v.Name + " now needs its key file besides the PIN.")
+	return nil
+}
+
+// forgetKeyFile drops the key file of a locked vault from memory.
+func (m *MainApp) forgetKeyFile() {
+	if v, err := m.registry.Get(m.vault.Name); err == nil {
+		m.vault = v
+	}
+}
@@ -1,15 +1,33 @@
 package gui
 
 import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
+	"gopass/internal/audit"
 	"gopass/internal/auth"
+	"gopass/internal/config"
+	"gopass/internal/events"
+	"gopass/internal/secmem"
 	"gopass/internal/storage"
+	"gopass/internal/team"
+	"gopass/internal/vault"
 )
 
 type MainApp struct {
 	window     fyne.Window
+	config     *config.Config
+	registry   *vault.Registry
+	vault      vault.Vault
+	open       map[string]*storage.Storage
+	teams      map[string]*team.Team
+	audits     map[string]*audit.Log
 	auth       *auth.Auth
 	storage    *storage.Storage
 	authScreen *AuthScreen
@@ -17,22 +35,75 @@ type MainApp struct {
 	passwordTab *PasswordTab
 	notesTab    *NotesTab
 	dataTabs    *DataTabs
+	settingsTab *SettingsTab
+	lockMu      sync.Mutex
+	lockTimer   *time.Timer
+	// ui queues the updates of background goroutines, see runUI.
+	ui       chan func()
+	outputMu sync.Mutex
+	// restoreDumps allows core dumps again once every vault is locked.
+	restoreDumps func()
 }
 
 func NewMainApp(window fyne.Window) *MainApp {
 	app := &MainApp{
 		window: window,
-		auth:   auth.NewAuth(),
+		open:   make(map[string]*storage.Storage),
+		teams:  make(map[string]*team.Team),
+		audits: make(map[string]*audit.Log),
 		output: widget.NewTextGrid(),
+		ui:     make(chan func(), 64),
 	}
+	go app.runUI()
 
-	app.authScreen = NewAuthScreen(window, app.auth, app.onAuthSuccess)
+	app.loadConfig()
+	app.loadRegistry()
+	app.auth = app.vault.NewAuth()
+	app.authScreen = app.newAuthScreen()
 	app.passwordTab = NewPasswordTab(window, app)
 	app.notesTab = NewNotesTab(window, app)
 	app.dataTabs = NewDataTabs(window, app)
+	app.settingsTab = NewSettingsTab(window, app)
+	app.applySettings()
+
+	window.SetOnClosed(func() {
+		for _, s := range app.open {
+			s.Close()
+		}
+	})
 	return app
 }
 
+func (m *MainApp) loadConfig() {
+	cfg, err := config.LoadEffective()
+	if err != nil {
+		m.logOutput("Error loading settings, using defaults: " + err.Error())
+		cfg = config.Default()
+	}
+	m.config = cfg
+}
+
+func (m *MainApp) loadRegistry() {
+	dir, err := m.config.VaultDir()
+	if err == nil {
+		m.registry, err = vault.LoadRegistry(dir)
+	}
+	if err != nil {
+		m.logOutput("Error loading vault registry: " + err.Error())
+		m.registry = vault.NewRegistry(dir)
+	}
+
+	m.vault, err = m.registry.Current(m.config.Vault.Default)
+	if err != nil {
+		m.logOutput("Error selecting vault: " + err.Error())
+		m.vault = m.registry.Vaults[0]
+	}
+}
+
+func (m *MainApp) newAuthScreen() *AuthScreen {
+	return NewAuthScreen(m.window, m.auth, m.config.Security.MinPINLength, m.createVaultBar(), m.onAuthSuccess, m.authHooks())
+}
+
 func (m *MainApp) LoadAuth() {
 	err := m.auth.LoadPINHash()
 	if err != nil && err.Error() != "PIN not set" {
@@ -41,22 +112,97 @@ func (m *MainApp) LoadAuth() {
 	m.authScreen.Load()
 }
 
-func (m *MainApp) onAuthSuccess() {
-	// Initialize storage with PIN
-	m.storage = storage.NewStorage(m.auth.GetCurrentPIN())
-	if err := m.storage.Load(); err != nil {
+// onAuthSuccess opens the vault pin unlocks. The PIN is not kept.
+func (m *MainApp) onAuthSuccess(pin string) {
+	if m.restoreDumps == nil {
+		restore, err := secmem.DisableCoreDumps()
+		if err != nil {
+			m.logOutput("Could not disable core dumps: " + err.Error())
+			dialog.ShowInformation("Core Dumps Enabled",
+				"Core dumps could not be turned off, so a crash may write the unlocked vault to disk.", m.window)
+		}
+		m.restoreDumps = restore
+	}
+	s, err := m.openStorage(pin)
+	if err != nil {
+		dialog.ShowError(err, m.window)
+		return
+	}
+	lockErr := s.Lock()
+	if lockErr != nil {
+		s.OpenReadOnly()
+	}
+	s.SetBackupCount(m.config.Backup.Count)
+	if err := s.Load(); err != nil {
 		m.logOutput("Error loading data: " + err.Error())
 	}
+	m.warnRollback(s)
+	s.Events().Subscribe(func(e events.Event) {
+		// Events come from the watcher and syncs as well as from the UI
+		m.inUI(func() {
+			// Vaults left open in the background must not repaint the tabs
+			if s == m.storage {
+				m.onStorageEvent(e)
+			}
+		})
+	})
+	if err := s.Watch(); err != nil {
+		m.logOutput("Error watching for external changes: " + err.Error())
+	}
+	if log, err := m.vault.OpenAudit(pin, "gui"); err != nil {
+		m.logOutput("Error opening the audit log: " + err.Error())
+	} else {
+		log.Watch(s.Events())
+		log.Record(audit.Unlock, "", "")
+		m.audits[m.vault.Name] = log
+	}
+
+	m.storage = s
+	m.open[m.vault.Name] = s
+	m.showMain()
+	m.touch()
+	m.logOutput("Successfully authenticated.")
+
+	if lockErr != nil {
+		if errors.Is(lockErr, storage.ErrVaultInUse) {
+			dialog.ShowInformation("Vault In Use",
+				lockErr.Error()+"\nThe vault has been opened read-only.", m.window)
+		}
+		m.logOutput("Vault opened read-only: " + lockErr.Error())
+	}
+}
 
+// openStorage returns the current vault's storage. Team vaults are opened
+// through their roster, which the members tab needs as well.
+func (m *MainApp) openStorage(pin string) (*storage.Storage, error) {
+	if m.vault.Team == nil {
+		return m.vault.NewStorage(pin)
+	}
+	t, err := m.vault.OpenTeam(pin)
+	if err != nil {
+		return nil, err
+	}
+	m.teams[m.vault.Name] = t
+	return m.vault.TeamStorage(t), nil
+}
+
+func (m *MainApp) showMain() {
 	tabs := container.NewAppTabs(
 		container.NewTabItem("Passwords", m.createPasswordsTab()),
 		container.NewTabItem("Notes", m.createNotesTab()),
 		container.NewTabItem("Export Data", m.createExportTab()),
 		container.NewTabItem("Import Data", m.createImportTab()),
+		container.NewTabItem("Settings", m.settingsTab.createContent()),
 	)
+	if log, ok := m.audits[m.vault.Name]; ok {
+		tabs.Append(container.NewTabItem("Audit Log", NewAuditTab(log).createContent()))
+	}
+	if t, ok := m.teams[m.vault.Name]; ok {
+		tabs.Append(container.NewTabItem("Members", NewMembersTab(m.window, m, t).createContent()))
+	}
 
 	content := container.NewBorder(
-		nil,
+		m.createVaultBar(),
 		container.NewVBox(
 			widget.NewLabel("System Output:"),
 			m.output,
@@ -67,7 +213,52 @@ func (m *MainApp) onAuthSuccess() {
 	)
 
 	m.window.SetContent(content)
-	m.logOutput("Successfully authenticated.")
+}
+
+// onStorageEvent keeps the tabs in sync with the vault, whichever part of
+// the app (or another process) changed it. It runs on the UI goroutine.
+func (m *MainApp) onStorageEvent(e events.Event) {
+	m.touch()
+	if e.Type == events.Revealed || e.Type == events.Exported {
+		return
+	}
+	switch e.Kind {
+	case events.KindPassword:
+		m.passwordTab.refresh()
+	case events.KindNote:
+		m.notesTab.refresh()
+	default:
+		m.passwordTab.refresh()
+		m.notesTab.refresh()
+	}
+
+	switch e.Type {
+	case events.Reloaded:
+		m.logOutput("Vault reloaded after an external change.")
+		m.warnRollback(m.storage)
+	case events.Synced:
+		m.logOutput("Vault updated from a sync remote.")
+	}
+}
+
+// warnRollback tells the user when the vault file is older than one seen
+// before on this machine.
+func (m *MainApp) warnRollback(s *storage.Storage) {
+	rb, ok := s.RolledBack()
+	if !ok {
+		return
+	}
+	if rb.Err != nil {
+		msg := fmt.Sprintf("Cannot check the vault file against the revisions seen before: %v.\n"+
+			"It may have been replaced with an older copy. Saving a change accepts this version.", rb.Err)
+		dialog.ShowInformation("Possible Rollback", msg, m.window)
+		m.logOutput(fmt.Sprintf("Warning: cannot check vault revision: %v", rb.Err))
+		return
+	}
+	msg := fmt.Sprintf("The vault file is at revision %d, but revision %d was seen before.\n"+
+		"It may have been replaced with an older copy. Saving a change accepts this version.", rb.Loaded, rb.Seen)
+	dialog.ShowInformation("Possible Rollback", msg, m.window)
+	m.logOutput(fmt.Sprintf("Warning: vault rolled back from revision %d to %d.", rb.Seen, rb.Loaded))
 }
 
 func (m *MainApp) createPasswordsTab() fyne.CanvasObject {
@@ -86,9 +277,53 @@ func (m *MainApp) createImportTab() fyne.CanvasObject {
 	return m.dataTabs.createImportTab()
 }
 
+// copySecret puts secret on the clipboard and clears it again after the
+// configured timeout, unless something else has been copied meanwhile.
+func (m *MainApp) copySecret(secret string) {
+	m.touch()
+	clipboard := m.window.Clipboard()
+	clipboard.SetContent(secret)
+
+	if d := m.config.Security.ClipboardClear; d > 0 {
+		time.AfterFunc(d, func() {
+			if clipboard.Content() == secret {
+				clipboard.SetContent("")
+			}
+		})
+	}
+}
+
+// record adds an entry to the current vault's audit log, for actions the
+// vault does not see, such as copying to the clipboard.
+func (m *MainApp) record(action audit.Action, kind events.Kind, id string) {
+	if log, ok := m.audits[m.vault.Name]; ok {
+		if err := log.Record(action, kind, id); err != nil {
+			m.logOutput("Error writing the audit log: " + err.Error())
+		}
+	}
+}
+
+// recordFailure notes a failed unlock of the current vault.
+func (m *MainApp) recordFailure() {
+	audit.RecordFailure(m.vault.AuditPath(), "gui")
+}
+
 func (m *MainApp) logOutput(message string) {
-	// Ensure UI updates happen on main thread
-	m.window.Canvas().Refresh(m.output)
-	currentText := m.output.Text()
-	m.output.SetText(currentText + "\n" + message)
+	m.outputMu.Lock()
+	defer m.outputMu.Unlock()
+	m.output.SetText(m.output.Text() + "\n" + message)
+}
+
+// runUI runs the UI updates of background goroutines one at a time. Fyne
+// 2.5 has no way to hand work to its own thread, so the watcher, syncs and
+// timers queue their updates here with inUI instead of racing each other.
+func (m *MainApp) runUI() {
+	for fn := range m.ui {
+		fn()
+	}
+}
+
+// inUI queues fn for runUI. fn must not queue more work itself.
+func (m *MainApp) inUI(fn func()) {
+	m.ui <- fn
 }
@@ -0,0 +1,149 @@
+package gui
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"gopass/internal/share"
+	"gopass/internal/storage"
+	"gopass/internal/team"
+)
+
+// MembersTab manages who can open a team vault. Only owners can change
+// anything; everyone else sees the list.
+type MembersTab struct {
+	window   fyne.Window
+	mainApp  *MainApp
+	team     *team.Team
+	list     *widget.List
+	members  []team.Member
+	selected int
+}
+
+func NewMembersTab(window fyne.Window, mainApp *MainApp, t *team.Team) *MembersTab {
+	return &MembersTab{
+		window:   window,
+		mainApp:  mainApp,
+		team:     t,
+		selected: -1,
+	}
+}
+
+func roleNames() []string {
+	names := make([]string, len(storage.Roles))
+	for i, r := range storage.Roles {
+		names[i] = string(r)
+	}
+	return names
+}
+
+func (t *MembersTab) createContent() fyne.CanvasObject {
+	t.members = t.team.Members()
+	t.list = widget.NewList(
+		func() int {
+			return len(t.members)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("Template")
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			m := t.members[i]
+			fingerprint := ""
+			if key, err := share.ParsePublicKey(m.PublicKey); err == nil {
+				fingerprint = key.Fingerprint()
+			}
+			o.(*widget.Label).SetText(fmt.Sprintf("%s (%s)  %s", m.Name, m.Role, fingerprint))
+		},
+	)
+	t.list.OnSelected = func(id widget.ListItemID) {
+		t.selected = id
+	}
+
+	me := t.team.Member()
+	header := widget.NewLabel(fmt.Sprintf("You are %s, with the %s role.", me.Name, me.Role))
+	if me.Role != storage.RoleOwner {
+		return container.NewBorder(header, nil, nil, nil, t.list)
+	}
+
+	addBtn := widget.NewButton("Add Member", t.showAddDialog)
+	roleBtn := widget.NewButton("Change Role", func() {
+		if t.selected < 0 {
+			dialog.ShowInformation("Select Member", "Please select a member", t.window)
+			return
+		}
+		t.showRoleDialog(t.members[t.selected])
+	})
+	removeBtn := widget.NewButton("Remove", func() {
+		if t.selected < 0 {
+			dialog.ShowInformation("Select Member", "Please select a member to remove", t.window)
+			return
+		}
+		name := t.members[t.selected].Name
+		dialog.ShowConfirm("Remove Member",
+			fmt.Sprintf("Remove %s? The vault key is rotated so their copy of it stops working.", name),
+			func(ok bool) {
+				if !ok {
+					return
+				}
+				if err := t.team.Remove(name, t.mainApp.storage.Rekey); err != nil {
+					dialog.ShowError(err, t.window)
+					return
+				}
+				t.refresh()
+				t.mainApp.logOutput(fmt.Sprintf("Removed %s and rotated the vault key.", name))
+			}, t.window)
+	})
+
+	return container.NewBorder(header, container.NewHBox(addBtn, roleBtn, removeBtn), nil, nil, t.list)
+}
+
+func (t *MembersTab) refresh() {
+	t.members = t.team.Members()
+	t.selected = -1
+	t.list.UnselectAll()
+	t.list.Refresh()
+}
+
+func (t *MembersTab) showAddDialog() {
+	nameEntry := widget.NewEntry()
+	keyEntry := widget.NewEntry()
+	keyEntry.SetPlaceHolder("gpk1...")
+	roleSelect := widget.NewSelect(roleNames(), nil)
+	roleSelect.SetSelected(string(storage.RoleEditor))
+
+	items := []*widget.FormItem{
+		{Text: "Name", Widget: nameEntry},
+		{Text: "Public Key", Widget: keyEntry, HintText: "printed by gopass team join"},
+		{Text: "Role", Widget: roleSelect},
+	}
+	dialog.ShowForm("Add Member", "Add", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		if err := t.team.Add(nameEntry.Text, keyEntry.Text, storage.Role(roleSelect.Selected)); err != nil {
+			dialog.ShowError(err, t.window)
+			return
+		}
+		t.refresh()
+		t.mainApp.logOutput(fmt.Sprintf("Added %s as %s.", nameEntry.Text, roleSelect.Selected))
+	}, t.window)
+}
+
+func (t *MembersTab) showRoleDialog(m team.Member) {
+	roleSelect := widget.NewSelect(roleNames(), nil)
+	roleSelect.SetSelected(string(m.Role))
+	items := []*widget.FormItem{{Text: "Role", Widget: roleSelect}}
+	dialog.ShowForm("Role of "+m.Name, "Save", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		if err := t.team.SetRole(m.Name, storage.Role(roleSelect.Selected)); err != nil {
+			dialog.ShowError(err, t.window)
+			return
+		}
+		t.refresh()
+	}, t.window)
+}
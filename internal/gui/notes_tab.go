@@ -10,12 +10,14 @@ import (
 	"fyne.io/fyne/v2/widget"
 	"github.com/google/uuid"
 	"gopass/internal/models"
+	"gopass/internal/storage"
 )
 
 type NotesTab struct {
 	window      fyne.Window
 	mainApp     *MainApp
 	table       *widget.Table
+	count       *widget.Label
 	notes       []models.Note
 	selectedRow int
 }
@@ -33,6 +35,7 @@ func (n *NotesTab) createContent() fyne.CanvasObject {
 	searchEntry := widget.NewEntry()
 	searchEntry.SetPlaceHolder("Search notes...")
 	searchEntry.OnChanged = func(text string) {
+		n.mainApp.touch()
 		results := n.mainApp.storage.Search(text)
 		n.notes = results.Notes
 		n.table.Refresh()
@@ -54,12 +57,8 @@ func (n *NotesTab) createContent() fyne.CanvasObject {
 			case 0:
 				label.SetText(note.Title)
 			case 1:
-				// Show preview of content
-				if len(note.Content) > 50 {
-					label.SetText(note.Content[:47] + "...")
-				} else {
-					label.SetText(note.Content)
-				}
+				// Content stays sealed until the note is opened
+				label.SetText("********")
 			}
 		},
 	)
@@ -83,7 +82,12 @@ func (n *NotesTab) createContent() fyne.CanvasObject {
 			dialog.ShowInformation("Select Entry", "Please select a note to edit", n.window)
 			return
 		}
-		n.showNoteDialog(&n.notes[n.selectedRow])
+		note, err := n.mainApp.storage.RevealNote(n.notes[n.selectedRow].ID)
+		if err != nil {
+			dialog.ShowError(err, n.window)
+			return
+		}
+		n.showNoteDialog(&note)
 	})
 
 	// Delete button
@@ -103,11 +107,6 @@ func (n *NotesTab) createContent() fyne.CanvasObject {
 						dialog.ShowError(err, n.window)
 						return
 					}
-					// Reset selection and refresh table
-					n.selectedRow = -1
-					n.notes = n.mainApp.storage.GetNotes()
-					n.table.UnselectAll()
-					n.table.Refresh()
 					n.mainApp.logOutput("Note deleted successfully")
 				}
 			}, n.window)
@@ -122,17 +121,37 @@ func (n *NotesTab) createContent() fyne.CanvasObject {
 			dialog.ShowInformation("Select Entry", "Please select a note to view", n.window)
 			return
 		}
-		note := n.notes[n.selectedRow]
+		n.mainApp.touch()
+		note, err := n.mainApp.storage.RevealNote(n.notes[n.selectedRow].ID)
+		if err != nil {
+			dialog.ShowError(err, n.window)
+			return
+		}
 		content := widget.NewTextGrid()
 		content.SetText(fmt.Sprintf("Title: %s\n\n%s", note.Title, note.Content))
 		dialog.ShowCustom("Note Details", "Close", content, n.window)
 	})
 
-	buttons := container.NewHBox(addBtn, editBtn, deleteBtn, viewBtn)
-	count := widget.NewLabel(fmt.Sprintf("Total Notes: %d", len(n.notes)))
+	// Copy or move button
+	transferBtn := widget.NewButton("Copy/Move", func() {
+		if len(n.notes) == 0 {
+			return
+		}
+		if n.selectedRow < 0 {
+			dialog.ShowInformation("Select Entry", "Please select a note to copy or move", n.window)
+			return
+		}
+		id := n.notes[n.selectedRow].ID
+		n.mainApp.showTransferDialog("Note", func(dst *storage.Storage, move bool) error {
+			return n.mainApp.storage.TransferNote(id, dst, move)
+		})
+	})
+
+	buttons := container.NewHBox(addBtn, editBtn, deleteBtn, viewBtn, transferBtn)
+	n.count = widget.NewLabel(fmt.Sprintf("Total Notes: %d", len(n.notes)))
 
 	return container.NewBorder(
-		container.NewVBox(searchEntry, buttons, count),
+		container.NewVBox(searchEntry, buttons, n.count),
 		nil, nil, nil,
 		n.table,
 	)
@@ -187,29 +206,24 @@ func (n *NotesTab) showNoteDialog(note *models.Note) {
 				return
 			}
 
-			// Do storage operations in goroutine
-			go func() {
-				// Log the operation
-				action := "added"
-				if !isNew {
-					action = "updated"
-				}
-				
-				// Get updated notes in background
-				updatedNotes := n.mainApp.storage.GetNotes()
-				
-				// Update UI state
-				n.selectedRow = -1
-				n.notes = updatedNotes
-				
-				// Ensure table refresh happens on main thread
-				if canvas := fyne.CurrentApp().Driver().CanvasForObject(n.table); canvas != nil {
-					canvas.Refresh(n.table)
-				}
-				
-				// Log output (already handles main thread updates)
-				n.mainApp.logOutput(fmt.Sprintf("Note %s successfully", action))
-			}()
+			// The table refreshes itself from the storage event
+			action := "added"
+			if !isNew {
+				action = "updated"
+			}
+			n.mainApp.logOutput(fmt.Sprintf("Note %s successfully", action))
 		}, n.window)
 	d.Show()
 }
+
+// refresh reloads the notes from storage after it changed outside this tab.
+func (n *NotesTab) refresh() {
+	if n.table == nil {
+		return
+	}
+	n.selectedRow = -1
+	n.notes = n.mainApp.storage.GetNotes()
+	n.table.UnselectAll()
+	n.table.Refresh()
+	n.count.SetText(fmt.Sprintf("Total Notes: %d", len(n.notes)))
+}
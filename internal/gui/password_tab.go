@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"time"
 
+	"gopass/internal/audit"
+	"gopass/internal/events"
 	"gopass/internal/models"
+	"gopass/internal/storage"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -17,6 +20,7 @@ type PasswordTab struct {
 	window      fyne.Window
 	mainApp     *MainApp
 	table       *widget.Table
+	count       *widget.Label
 	passwords   []models.Password
 	selectedRow int
 }
@@ -34,6 +38,7 @@ func (p *PasswordTab) createContent() fyne.CanvasObject {
 	searchEntry := widget.NewEntry()
 	searchEntry.SetPlaceHolder("Search passwords...")
 	searchEntry.OnChanged = func(text string) {
+		p.mainApp.touch()
 		results := p.mainApp.storage.Search(text)
 		p.passwords = results.Passwords
 		p.table.Refresh()
@@ -83,7 +88,12 @@ func (p *PasswordTab) createContent() fyne.CanvasObject {
 			dialog.ShowInformation("Select Entry", "Please select a password entry to edit", p.window)
 			return
 		}
-		p.showPasswordDialog(&p.passwords[p.selectedRow])
+		pass, err := p.mainApp.storage.RevealPassword(p.passwords[p.selectedRow].ID)
+		if err != nil {
+			dialog.ShowError(err, p.window)
+			return
+		}
+		p.showPasswordDialog(&pass)
 	})
 
 	// Delete button
@@ -103,11 +113,6 @@ func (p *PasswordTab) createContent() fyne.CanvasObject {
 						dialog.ShowError(err, p.window)
 						return
 					}
-					// Reset selection and refresh table
-					p.selectedRow = -1
-					p.passwords = p.mainApp.storage.GetPasswords()
-					p.table.UnselectAll()
-					p.table.Refresh()
 					p.mainApp.logOutput("Password deleted successfully")
 				}
 			}, p.window)
@@ -122,18 +127,57 @@ func (p *PasswordTab) createContent() fyne.CanvasObject {
 			dialog.ShowInformation("Select Entry", "Please select a password entry to view", p.window)
 			return
 		}
-		pass := p.passwords[p.selectedRow]
+		p.mainApp.touch()
+		pass, err := p.mainApp.storage.RevealPassword(p.passwords[p.selectedRow].ID)
+		if err != nil {
+			dialog.ShowError(err, p.window)
+			return
+		}
 		content := widget.NewTextGrid()
 		content.SetText(fmt.Sprintf("Name: %s\nURL: %s\nUsername: %s\nPassword: %s\nNote: %s",
 			pass.Name, pass.URL, pass.Username, pass.Password, pass.Note))
 		dialog.ShowCustom("Password Details", "Close", content, p.window)
 	})
 
-	buttons := container.NewHBox(addBtn, editBtn, deleteBtn, viewBtn)
-	count := widget.NewLabel(fmt.Sprintf("Total Passwords: %d", len(p.passwords)))
+	// Copy password button
+	copyBtn := widget.NewButton("Copy Password", func() {
+		if len(p.passwords) == 0 {
+			return
+		}
+		if p.selectedRow < 0 {
+			dialog.ShowInformation("Select Entry", "Please select a password entry to copy", p.window)
+			return
+		}
+		pass, err := p.mainApp.storage.RevealPassword(p.passwords[p.selectedRow].ID)
+		if err != nil {
+			dialog.ShowError(err, p.window)
+			return
+		}
+		p.mainApp.copySecret(pass.Password)
+		p.mainApp.record(audit.Copy, events.KindPassword, pass.ID)
+		p.mainApp.logOutput("Password copied to clipboard.")
+	})
+
+	// Copy or move button
+	transferBtn := widget.NewButton("Copy/Move", func() {
+		if len(p.passwords) == 0 {
+			return
+		}
+		if p.selectedRow < 0 {
+			dialog.ShowInformation("Select Entry", "Please select a password entry to copy or move", p.window)
+			return
+		}
+		id := p.passwords[p.selectedRow].ID
+		p.mainApp.showTransferDialog("Password", func(dst *storage.Storage, move bool) error {
+			return p.mainApp.storage.TransferPassword(id, dst, move)
+		})
+	})
+
+	buttons := container.NewHBox(addBtn, editBtn, deleteBtn, viewBtn, copyBtn, transferBtn)
+	p.count = widget.NewLabel(fmt.Sprintf("Total Passwords: %d", len(p.passwords)))
 
 	return container.NewBorder(
-		container.NewVBox(searchEntry, buttons, count),
+		container.NewVBox(searchEntry, buttons, p.count),
 		nil, nil, nil,
 		p.table,
 	)
@@ -166,6 +210,14 @@ func (p *PasswordTab) showPasswordDialog(password *models.Password) {
 		{Text: "Username", Widget: usernameEntry},
 		{Text: "Password", Widget: passwordEntry},
 		{Text: "Note", Widget: noteEntry},
+		{Text: "", Widget: widget.NewButton("Generate Password", func() {
+			generated, err := p.mainApp.generatePassword()
+			if err != nil {
+				dialog.ShowError(err, p.window)
+				return
+			}
+			passwordEntry.SetText(generated)
+		})},
 	}
 
 	var formTxt string
@@ -200,28 +252,23 @@ func (p *PasswordTab) showPasswordDialog(password *models.Password) {
 				return
 			}
 
-			// Do storage operations in goroutine
-			go func() {
-				// Log the operation
-				action := "added"
-				if !isNew {
-					action = "updated"
-				}
-				
-				// Get updated passwords in background
-				updatedPasswords := p.mainApp.storage.GetPasswords()
-				
-				// Update UI state
-				p.selectedRow = -1
-				p.passwords = updatedPasswords
-				
-				// Ensure table refresh happens on main thread
-				if canvas := fyne.CurrentApp().Driver().CanvasForObject(p.table); canvas != nil {
-					canvas.Refresh(p.table)
-				}
-				
-				// Log output (already handles main thread updates)
-				p.mainApp.logOutput(fmt.Sprintf("Password %s successfully.", action))
-			}()
+			// The table refreshes itself from the storage event
+			action := "added"
+			if !isNew {
+				action = "updated"
+			}
+			p.mainApp.logOutput(fmt.Sprintf("Password %s successfully.", action))
 		}, p.window)
 }
+
+// refresh reloads the passwords from storage after it changed outside this tab.
+func (p *PasswordTab) refresh() {
+	if p.table == nil {
+		return
+	}
+	p.selectedRow = -1
+	p.passwords = p.mainApp.storage.GetPasswords()
+	p.table.UnselectAll()
+	p.table.Refresh()
+	p.count.SetText(fmt.Sprintf("Total Passwords: %d", len(p.passwords)))
+}
@@ -0,0 +1,85 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"gopass/internal/recovery"
+)
+
+// setUpRecovery creates the recovery secret of the current vault and shows
+// it for printing.
+func (m *MainApp) setUpRecovery(pin string, key bool, shares, threshold int) error {
+	secret, err := m.vault.EnableRecovery(pin)
+	if err != nil {
+		return err
+	}
+	var text strings.Builder
+	if key {
+		fmt.Fprintf(&text, "Recovery key:\n\n%s\n\n", recovery.FormatKey(secret))
+	}
+	if shares > 0 {
+		split, err := recovery.Split(secret, shares, threshold)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&text, "Any %d of these shares recover the vault. Give one to each person:\n\n", threshold)
+		for i, s := range split {
+			fmt.Fprintf(&text, "%d/%d  %s\n", i+1, len(split), s)
+		}
+	}
+
+	content := widget.NewMultiLineEntry()
+	content.SetText(text.String())
+	content.SetMinRowsVisible(8)
+	dialog.ShowCustom("Print and Keep Safe", "Done", content, m.window)
+	m.logOutput("Recovery set up for vault " + m.vault.Name + ".")
+	return nil
+}
+
+// recoverVault sets a new PIN on the current vault with its recovery
+// secret and opens it.
+func (m *MainApp) recoverVault(secret []byte, newPIN string) error {
+	v, err := m.registry.Recover(m.vault.Name, secret, newPIN)
+	if err != nil {
+		return err
+	}
+	m.vault = v
+	m.auth = v.NewAuth()
+	if err := m.auth.LoadPINHash(); err != nil {
+		return err
+	}
+	m.auth.ValidatePIN(newPIN)
+	m.authScreen = m.newAuthScreen()
+	m.onAuthSuccess(newPIN)
+	m.logOutput("Vault recovered with a new PIN.")
+	return nil
+}
+
+// parseRecoveryText reads a recovery key, or shares one per line.
+func parseRecoveryText(text string) ([]byte, error) {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("enter the recovery key or shares")
+	}
+	if len(lines) == 1 && recovery.IsKey(lines[0]) {
+		return recovery.ParseKey(lines[0])
+	}
+	var shares []recovery.Share
+	for i, line := range lines {
+		s, err := recovery.ParseShare(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		shares = append(shares, s)
+	}
+	return recovery.Combine(shares)
+}
@@ -0,0 +1,180 @@
+package gui
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"gopass/internal/config"
+	"gopass/internal/generator"
+)
+
+type SettingsTab struct {
+	window  fyne.Window
+	mainApp *MainApp
+}
+
+func NewSettingsTab(window fyne.Window, mainApp *MainApp) *SettingsTab {
+	return &SettingsTab{
+		window:  window,
+		mainApp: mainApp,
+	}
+}
+
+func (s *SettingsTab) createContent() fyne.CanvasObject {
+	// Edit the file itself so environment overrides are never written back
+	path, err := config.Path()
+	if err != nil {
+		return widget.NewLabel("Settings unavailable: " + err.Error())
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return widget.NewLabel("Settings unavailable: " + err.Error())
+	}
+
+	locationEntry := widget.NewEntry()
+	locationEntry.SetText(cfg.Vault.Location)
+	locationEntry.SetPlaceHolder("Default config directory")
+	defaultVault := widget.NewSelect(append([]string{""}, s.mainApp.registry.Names()...), nil)
+	defaultVault.SetSelected(cfg.Vault.Default)
+
+	autoLockEntry := widget.NewEntry()
+	autoLockEntry.SetText(cfg.Security.AutoLock.String())
+	clipboardEntry := widget.NewEntry()
+	clipboardEntry.SetText(cfg.Security.ClipboardClear.String())
+	minPINEntry := widget.NewEntry()
+	minPINEntry.SetText(strconv.Itoa(cfg.Security.MinPINLength))
+
+	lengthEntry := widget.NewEntry()
+	lengthEntry.SetText(strconv.Itoa(cfg.Generator.Length))
+	upperCheck := widget.NewCheck("A-Z", nil)
+	upperCheck.SetChecked(cfg.Generator.Uppercase)
+	lowerCheck := widget.NewCheck("a-z", nil)
+	lowerCheck.SetChecked(cfg.Generator.Lowercase)
+	digitsCheck := widget.NewCheck("0-9", nil)
+	digitsCheck.SetChecked(cfg.Generator.Digits)
+	symbolsCheck := widget.NewCheck("Symbols", nil)
+	symbolsCheck.SetChecked(cfg.Generator.Symbols)
+
+	backupEntry := widget.NewEntry()
+	backupEntry.SetText(strconv.Itoa(cfg.Backup.Count))
+	themeSelect := widget.NewSelect([]string{config.ThemeSystem, config.ThemeLight, config.ThemeDark}, nil)
+	themeSelect.SetSelected(cfg.UI.Theme)
+	widthEntry := widget.NewEntry()
+	widthEntry.SetText(strconv.Itoa(cfg.UI.WindowWidth))
+	heightEntry := widget.NewEntry()
+	heightEntry.SetText(strconv.Itoa(cfg.UI.WindowHeight))
+
+	form := &widget.Form{
+		Items: []*widget.FormItem{
+			{Text: "Vault Location", Widget: locationEntry},
+			{Text: "Default Vault", Widget: defaultVault},
+			{Text: "Auto-Lock After", Widget: autoLockEntry, HintText: "e.g. 5m; 0 disables"},
+			{Text: "Clear Clipboard After", Widget: clipboardEntry, HintText: "e.g. 30s; 0 disables"},
+			{Text: "Minimum PIN Length", Widget: minPINEntry},
+			{Text: "Generated Length", Widget: lengthEntry},
+			{Text: "Generated Characters", Widget: container.NewHBox(upperCheck, lowerCheck, digitsCheck, symbolsCheck)},
+			{Text: "Backups Kept", Widget: backupEntry},
+			{Text: "Theme", Widget: themeSelect},
+			{Text: "Window Width", Widget: widthEntry},
+			{Text: "Window Height", Widget: heightEntry},
+		},
+		SubmitText: "Save Settings",
+		OnSubmit: func() {
+			previousVault := cfg.Vault
+			var err error
+			parseDuration := func(text string) time.Duration {
+				d, perr := time.ParseDuration(strings.TrimSpace(text))
+				if perr != nil && err == nil {
+					err = perr
+				}
+				return d
+			}
+			parseInt := func(text string) int {
+				n, perr := strconv.Atoi(strings.TrimSpace(text))
+				if perr != nil && err == nil {
+					err = perr
+				}
+				return n
+			}
+
+			cfg.Vault.Location = strings.TrimSpace(locationEntry.Text)
+			cfg.Vault.Default = defaultVault.Selected
+			cfg.Security.AutoLock = parseDuration(autoLockEntry.Text)
+			cfg.Security.ClipboardClear = parseDuration(clipboardEntry.Text)
+			cfg.Security.MinPINLength = parseInt(minPINEntry.Text)
+			cfg.Generator.Length = parseInt(lengthEntry.Text)
+			cfg.Generator.Uppercase = upperCheck.Checked
+			cfg.Generator.Lowercase = lowerCheck.Checked
+			cfg.Generator.Digits = digitsCheck.Checked
+			cfg.Generator.Symbols = symbolsCheck.Checked
+			cfg.Backup.Count = parseInt(backupEntry.Text)
+			cfg.UI.Theme = themeSelect.Selected
+			cfg.UI.WindowWidth = parseInt(widthEntry.Text)
+			cfg.UI.WindowHeight = parseInt(heightEntry.Text)
+
+			if err == nil {
+				err = cfg.Save()
+			}
+			if err != nil {
+				dialog.ShowError(err, s.window)
+				return
+			}
+
+			s.mainApp.loadConfig()
+			s.mainApp.applySettings()
+			s.mainApp.logOutput("Settings saved to " + cfg.FilePath())
+			if cfg.Vault != previousVault {
+				s.mainApp.logOutput("Vault location changes take effect after a restart.")
+			}
+		},
+	}
+
+	content := []fyne.CanvasObject{form,
+		widget.NewButton("Set Duress PIN...", s.mainApp.showDuressDialog),
+		widget.NewButton("Change Cipher...", s.mainApp.showCipherDialog),
+	}
+	if overrides := envOverrides(); len(overrides) > 0 {
+		content = append(content, widget.NewLabel(
+			"Overridden by the environment for this session: "+strings.Join(overrides, ", ")))
+	}
+	return container.NewVScroll(container.NewVBox(content...))
+}
+
+func envOverrides() []string {
+	var set []string
+	for _, env := range os.Environ() {
+		name, _, _ := strings.Cut(env, "=")
+		if strings.HasPrefix(name, "GOPASS_") && name != "GOPASS_PIN" && name != "GOPASS_AGENT_SOCK" {
+			set = append(set, name)
+		}
+	}
+	return set
+}
+
+func (m *MainApp) applySettings() {
+	if app := fyne.CurrentApp(); app != nil {
+		app.Settings().SetTheme(themeFor(m.config.UI.Theme))
+	}
+	m.window.Resize(fyne.NewSize(float32(m.config.UI.WindowWidth), float32(m.config.UI.WindowHeight)))
+	for _, s := range m.open {
+		s.SetBackupCount(m.config.Backup.Count)
+	}
+	m.touch()
+}
+
+func (m *MainApp) generatePassword() (string, error) {
+	g := m.config.Generator
+	return generator.Generate(generator.Options{
+		Length:    g.Length,
+		Uppercase: g.Uppercase,
+		Lowercase: g.Lowercase,
+		Digits:    g.Digits,
+		Symbols:   g.Symbols,
+	})
+}
@@ -0,0 +1,31 @@
+package gui
+
+import (
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+	"gopass/internal/config"
+)
+
+// variantTheme forces the default theme into a light or dark variant,
+// regardless of the system preference.
+type variantTheme struct {
+	fyne.Theme
+	variant fyne.ThemeVariant
+}
+
+func (t variantTheme) Color(name fyne.ThemeColorName, _ fyne.ThemeVariant) color.Color {
+	return t.Theme.Color(name, t.variant)
+}
+
+func themeFor(name string) fyne.Theme {
+	switch name {
+	case config.ThemeLight:
+		return variantTheme{Theme: theme.DefaultTheme(), variant: theme.VariantLight}
+	case config.ThemeDark:
+		return variantTheme{Theme: theme.DefaultTheme(), variant: theme.VariantDark}
+	default:
+		return theme.DefaultTheme()
+	}
+}
@@ -0,0 +1,122 @@
+package gui
+
+import (
+	"fmt"
+	"sort"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"gopass/internal/storage"
+)
+
+func (m *MainApp) createVaultBar() fyne.CanvasObject {
+	selector := widget.NewSelect(m.registry.Names(), nil)
+	selector.SetSelected(m.vault.Name)
+	selector.OnChanged = func(name string) {
+		if name != m.vault.Name {
+			m.switchVault(name)
+		}
+	}
+
+	newBtn := widget.NewButton("New Vault", m.showNewVaultDialog)
+	lockBtn := widget.NewButton("Lock", m.lockVaults)
+
+	return container.NewHBox(widget.NewLabel("Vault:"), selector, newBtn, lockBtn)
+}
+
+// switchVault makes name the current vault. Vaults unlocked earlier in this
+// session stay open and are shown again without asking for their PIN.
+func (m *MainApp) switchVault(name string) {
+	v, err := m.registry.Get(name)
+	if err != nil {
+		dialog.ShowError(err, m.window)
+		return
+	}
+	m.vault = v
+
+	if s, ok := m.open[name]; ok {
+		m.storage = s
+		m.showMain()
+		m.logOutput(fmt.Sprintf("Switched to vault %s.", name))
+		return
+	}
+
+	m.auth = v.NewAuth()
+	m.authScreen = m.newAuthScreen()
+	m.LoadAuth()
+}
+
+func (m *MainApp) showNewVaultDialog() {
+	nameEntry := widget.NewEntry()
+	dirEntry := widget.NewEntry()
+	dirEntry.SetPlaceHolder("Leave empty to keep it with the other vaults")
+
+	items := []*widget.FormItem{
+		{Text: "Name", Widget: nameEntry},
+		{Text: "Directory", Widget: dirEntry},
+	}
+
+	dialog.ShowForm("New Vault", "Create", "Cancel", items,
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			v, err := m.registry.Create(nameEntry.Text, dirEntry.Text)
+			if err != nil {
+				dialog.ShowError(err, m.window)
+				return
+			}
+			m.logOutput(fmt.Sprintf("Vault %s created in %s.", v.Name, v.Path))
+			m.switchVault(v.Name)
+		}, m.window)
+}
+
+// otherOpenVaults lists the unlocked vaults entries can be copied into.
+func (m *MainApp) otherOpenVaults() []string {
+	var names []string
+	for name, s := range m.open {
+		if s != m.storage {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// showTransferDialog asks for a target vault and whether to copy or move,
+// then hands the choice to transfer.
+func (m *MainApp) showTransferDialog(entry string, transfer func(dst *storage.Storage, move bool) error) {
+	targets := m.otherOpenVaults()
+	if len(targets) == 0 {
+		dialog.ShowInformation("Copy or Move",
+			"Unlock another vault first to copy or move entries into it", m.window)
+		return
+	}
+
+	target := widget.NewSelect(targets, nil)
+	target.SetSelected(targets[0])
+	move := widget.NewCheck("Remove from this vault", nil)
+
+	items := []*widget.FormItem{
+		{Text: "Target Vault", Widget: target},
+		{Text: "Move", Widget: move},
+	}
+
+	dialog.ShowForm("Copy or Move "+entry, "OK", "Cancel", items,
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := transfer(m.open[target.Selected], move.Checked); err != nil {
+				dialog.ShowError(err, m.window)
+				return
+			}
+			action := "copied"
+			if move.Checked {
+				action = "moved"
+			}
+			m.logOutput(fmt.Sprintf("%s %s to vault %s.", entry, action, target.Selected))
+		}, m.window)
+}
@@ -8,25 +8,77 @@ import (
 type Password struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
+	Folder    string    `json:"folder,omitempty"`
 	URL       string    `json:"url"`
 	Username  string    `json:"username"`
 	Password  string    `json:"password"`
 	Note      string    `json:"note"`
+	URLMatch  string    `json:"url_match,omitempty"`
+	Fields    []Field   `json:"fields,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// Sealed holds Password, Note and Fields while the entry is stored; they
+	// are empty until the entry is revealed.
+	Sealed *Sealed `json:"sealed,omitempty"`
+}
+
+// Sealed is an entry's secret fields encrypted with a data key of the
+// entry's own, which is itself encrypted with the vault key.
+type Sealed struct {
+	Key  []byte `json:"key"`
+	Data []byte `json:"data"`
+}
+
+// Path is the entry's folder and name joined by a slash, or just the name
+// outside any folder.
+func (p Password) Path() string {
+	if p.Folder == "" {
+		return p.Name
+	}
+	return p.Folder + "/" + p.Name
 }
 
 type Note struct {
 	ID        string    `json:"id"`
 	Title     string    `json:"title"`
 	Content   string    `json:"content"`
+	Fields    []Field   `json:"fields,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// Sealed holds Content and Fields, like Password.Sealed.
+	Sealed *Sealed `json:"sealed,omitempty"`
+}
+
+// Field is a custom named value on an entry, such as an API key next to a
+// login.
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SSHKey is a private key the SSH agent offers to clients. Confirm asks
+// before every signature; a non-zero Lifetime limits how long after unlocking
+// the key is offered.
+type SSHKey struct {
+	ID         string        `json:"id"`
+	Name       string        `json:"name"`
+	PrivateKey string        `json:"private_key"`
+	Comment    string        `json:"comment"`
+	Confirm    bool          `json:"confirm"`
+	Lifetime   time.Duration `json:"lifetime,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+	// PublicKey is the public half of PrivateKey in authorized_keys format.
+	// It stays unsealed, so keys can be listed without revealing them.
+	PublicKey string `json:"public_key,omitempty"`
+	// Sealed holds PrivateKey, like Password.Sealed.
+	Sealed *Sealed `json:"sealed,omitempty"`
 }
 
 type ExportData struct {
 	Passwords []Password `json:"passwords"`
 	Notes     []Note     `json:"notes"`
+	SSHKeys   []SSHKey   `json:"ssh_keys,omitempty"`
 }
 
 func (e *ExportData) ToJSON() ([]byte, error) {
@@ -57,3 +109,11 @@ func NewNote() *Note {
 		UpdatedAt: now,
 	}
 }
+
+func NewSSHKey() *SSHKey {
+	now := time.Now()
+	return &SSHKey{
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
@@ -0,0 +1,174 @@
+package nativemsg
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Grant lets one origin receive one entry's secret.
+type Grant struct {
+	EntryID   string    `json:"entry_id"`
+	Origin    string    `json:"origin"`
+	GrantedAt time.Time `json:"granted_at"`
+}
+
+// Pending is a request waiting for the user to approve it by its code.
+type Pending struct {
+	Code        string    `json:"code"`
+	EntryID     string    `json:"entry_id"`
+	EntryName   string    `json:"entry_name"`
+	Origin      string    `json:"origin"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// pendingTTL bounds how long an unanswered request can be approved.
+const pendingTTL = 10 * time.Minute
+
+type approvalFile struct {
+	Grants  []Grant   `json:"grants"`
+	Pending []Pending `json:"pending"`
+}
+
+// Approvals remembers which origins the user allowed to receive which
+// entries. It lives in a file shared by the host, which files requests, and
+// the command line, where the user approves them.
+type Approvals struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewApprovals(path string) *Approvals {
+	return &Approvals{path: path}
+}
+
+func (a *Approvals) load() (approvalFile, error) {
+	var f approvalFile
+	data, err := os.ReadFile(a.path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return f, err
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return f, err
+	}
+
+	// Drop requests nobody answered in time
+	kept := f.Pending[:0]
+	for _, p := range f.Pending {
+		if time.Since(p.RequestedAt) < pendingTTL {
+			kept = append(kept, p)
+		}
+	}
+	f.Pending = kept
+	return f, nil
+}
+
+func (a *Approvals) save(f approvalFile) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(a.path, data, 0600)
+}
+
+func (a *Approvals) Allowed(entryID, origin string) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := a.load()
+	if err != nil {
+		return false, err
+	}
+	for _, g := range f.Grants {
+		if g.EntryID == entryID && g.Origin == origin {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Request files a pending approval and returns the code the user approves
+// it with. Asking again for the same entry and origin returns the same code.
+func (a *Approvals) Request(entryID, entryName, origin string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := a.load()
+	if err != nil {
+		return "", err
+	}
+	for _, p := range f.Pending {
+		if p.EntryID == entryID && p.Origin == origin {
+			return p.Code, nil
+		}
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+	f.Pending = append(f.Pending, Pending{
+		Code:        code,
+		EntryID:     entryID,
+		EntryName:   entryName,
+		Origin:      origin,
+		RequestedAt: time.Now(),
+	})
+	return code, a.save(f)
+}
+
+func (a *Approvals) Pending() ([]Pending, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := a.load()
+	return f.Pending, err
+}
+
+// Approve turns the pending request with code into a grant.
+func (a *Approvals) Approve(code string) (Pending, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := a.load()
+	if err != nil {
+		return Pending{}, err
+	}
+	for i, p := range f.Pending {
+		if p.Code != code {
+			continue
+		}
+		f.Pending = append(f.Pending[:i], f.Pending[i+1:]...)
+		f.Grants = append(f.Grants, Grant{EntryID: p.EntryID, Origin: p.Origin, GrantedAt: time.Now()})
+		return p, a.save(f)
+	}
+	return Pending{}, errors.New("no pending request with that code")
+}
+
+// Forget removes every grant for origin and returns how many there were.
+func (a *Approvals) Forget(origin string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := a.load()
+	if err != nil {
+		return 0, err
+	}
+	kept := f.Grants[:0]
+	for _, g := range f.Grants {
+		if g.Origin != origin {
+			kept = append(kept, g)
+		}
+	}
+	removed := len(f.Grants) - len(kept)
+	f.Grants = kept
+	return removed, a.save(f)
+}
@@ -0,0 +1,144 @@
+package nativemsg
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+
+	"gopass/internal/models"
+	"gopass/internal/urlmatch"
+)
+
+const (
+	TypeLookup = "lookup"
+	TypeGet    = "get"
+)
+
+// Request is a message from the extension. ID is echoed back so the
+// extension can pair answers with questions.
+type Request struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Origin  string `json:"origin"`
+	EntryID string `json:"entry_id,omitempty"`
+}
+
+// Entry describes a match without its secret.
+type Entry struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	URL      string `json:"url"`
+}
+
+type Response struct {
+	ID       string  `json:"id,omitempty"`
+	Type     string  `json:"type"`
+	Error    string  `json:"error,omitempty"`
+	Entries  []Entry `json:"entries,omitempty"`
+	Username string  `json:"username,omitempty"`
+	Password string  `json:"password,omitempty"`
+	// Approval is the code to approve with `gopass browser approve` when a
+	// secret is withheld until the user agrees.
+	Approval string `json:"approval,omitempty"`
+}
+
+// ErrApprovalRequired is reported while a secret waits for approval.
+var ErrApprovalRequired = errors.New("approval required")
+
+// Host answers extension requests from the entries passwords returns. Those
+// carry no secrets; reveal fetches one entry's password once it is released.
+type Host struct {
+	passwords func() ([]models.Password, error)
+	reveal    func(id string) (models.Password, error)
+	approvals *Approvals
+}
+
+func NewHost(passwords func() ([]models.Password, error), reveal func(id string) (models.Password, error), approvals *Approvals) *Host {
+	return &Host{passwords: passwords, reveal: reveal, approvals: approvals}
+}
+
+// Serve answers messages from r on w until r is closed.
+func (h *Host) Serve(r io.Reader, w io.Writer) error {
+	for {
+		var req Request
+		if err := ReadMessage(r, &req); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		resp := h.Handle(req)
+		if err := WriteMessage(w, resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (h *Host) Handle(req Request) Response {
+	resp, err := h.handle(req)
+	resp.ID, resp.Type = req.ID, req.Type
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return resp
+}
+
+// originOf reduces a page URL to scheme://host[:port], the unit approvals are
+// granted for.
+func originOf(page string) (string, error) {
+	u, err := url.Parse(page)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", errors.New("origin must be an http or https URL")
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
+
+func (h *Host) handle(req Request) (Response, error) {
+	var resp Response
+	origin, err := originOf(req.Origin)
+	if err != nil {
+		return resp, err
+	}
+	passwords, err := h.passwords()
+	if err != nil {
+		return resp, err
+	}
+	matches := urlmatch.Find(passwords, req.Origin)
+
+	switch req.Type {
+	case TypeLookup:
+		for _, p := range matches {
+			resp.Entries = append(resp.Entries, Entry{ID: p.ID, Name: p.Name, Username: p.Username, URL: p.URL})
+		}
+		return resp, nil
+	case TypeGet:
+		// Only entries that match the page are ever released to it
+		for _, p := range matches {
+			if p.ID != req.EntryID {
+				continue
+			}
+			allowed, err := h.approvals.Allowed(p.ID, origin)
+			if err != nil {
+				return resp, err
+			}
+			if !allowed {
+				resp.Approval, err = h.approvals.Request(p.ID, p.Name, origin)
+				if err != nil {
+					return resp, err
+				}
+				return resp, ErrApprovalRequired
+			}
+			revealed, err := h.reveal(p.ID)
+			if err != nil {
+				return resp, err
+			}
+			resp.Username, resp.Password = revealed.Username, revealed.Password
+			return resp, nil
+		}
+		return resp, errors.New("no matching entry for this page")
+	default:
+		return resp, errors.New("unknown request type")
+	}
+}
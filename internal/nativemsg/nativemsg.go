@@ -0,0 +1,49 @@
+// Package nativemsg implements the browser native-messaging host that the
+// gopass extension talks to for autofill.
+package nativemsg
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Browsers refuse messages from a host larger than 1 MiB. Incoming messages
+// may be larger in theory, but nothing the extension sends comes close.
+const (
+	maxOutgoing = 1 << 20
+	maxIncoming = 4 << 20
+)
+
+// ReadMessage reads one message: a 32-bit length in native byte order
+// followed by that much JSON.
+func ReadMessage(r io.Reader, v any) error {
+	var size uint32
+	if err := binary.Read(r, binary.NativeEndian, &size); err != nil {
+		return err
+	}
+	if size > maxIncoming {
+		return fmt.Errorf("message of %d bytes is too large", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func WriteMessage(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxOutgoing {
+		return fmt.Errorf("message of %d bytes is too large for the browser", len(data))
+	}
+	if err := binary.Write(w, binary.NativeEndian, uint32(len(data))); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,84 @@
+package nativemsg
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopass/internal/models"
+)
+
+func newTestHost(passwords []models.Password, approvals *Approvals) *Host {
+	list := func() ([]models.Password, error) {
+		listed := make([]models.Password, len(passwords))
+		for i, p := range passwords {
+			p.Password = ""
+			listed[i] = p
+		}
+		return listed, nil
+	}
+	reveal := func(id string) (models.Password, error) {
+		for _, p := range passwords {
+			if p.ID == id {
+				return p, nil
+			}
+		}
+		return models.Password{}, errors.New("not found")
+	}
+	return NewHost(list, reveal, approvals)
+}
+
+func TestServeSpeaksLengthPrefixedJSON(t *testing.T) {
+	passwords := []models.Password{
+		{ID: "gh", Name: "GitHub", URL: "https://github.com", Username: "me", Password: "pw"},
+		{ID: "bank", Name: "Bank", URL: "https://bank.example", Username: "acct", Password: "pin"},
+	}
+	host := newTestHost(passwords, NewApprovals(filepath.Join(t.TempDir(), "approvals.json")))
+
+	var in bytes.Buffer
+	require.NoError(t, WriteMessage(&in, Request{ID: "1", Type: TypeLookup, Origin: "https://github.com/login"}))
+	require.NoError(t, WriteMessage(&in, Request{ID: "2", Type: TypeGet, Origin: "https://github.com/login", EntryID: "gh"}))
+	var out bytes.Buffer
+	require.NoError(t, host.Serve(&in, &out))
+
+	var lookup, get Response
+	require.NoError(t, ReadMessage(&out, &lookup))
+	require.NoError(t, ReadMessage(&out, &get))
+	assert.Equal(t, []Entry{{ID: "gh", Name: "GitHub", Username: "me", URL: "https://github.com"}}, lookup.Entries)
+	assert.Equal(t, "2", get.ID)
+	assert.Equal(t, ErrApprovalRequired.Error(), get.Error)
+	assert.Empty(t, get.Password)
+	assert.Len(t, get.Approval, 6)
+}
+
+func TestSecretsNeedApprovalPerOrigin(t *testing.T) {
+	passwords := []models.Password{{ID: "gh", Name: "GitHub", URL: "https://github.com", Username: "me", Password: "pw"}}
+	approvals := NewApprovals(filepath.Join(t.TempDir(), "approvals.json"))
+	host := newTestHost(passwords, approvals)
+	get := Request{Type: TypeGet, Origin: "https://github.com/session", EntryID: "gh"}
+
+	first := host.Handle(get)
+	require.NotEmpty(t, first.Approval)
+	assert.Equal(t, first.Approval, host.Handle(get).Approval, "asking again reuses the pending request")
+
+	pending, err := approvals.Approve(first.Approval)
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com", pending.Origin)
+
+	resp := host.Handle(get)
+	assert.Empty(t, resp.Error)
+	assert.Equal(t, "pw", resp.Password)
+
+	// Entries are never released to pages they do not match
+	resp = host.Handle(Request{Type: TypeGet, Origin: "https://evil.example", EntryID: "gh"})
+	assert.NotEmpty(t, resp.Error)
+	assert.Empty(t, resp.Password)
+
+	n, err := approvals.Forget("https://github.com")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, ErrApprovalRequired.Error(), host.Handle(get).Error)
+}
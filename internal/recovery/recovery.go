@@ -0,0 +1,196 @@
+// Package recovery lets a vault be opened without its PIN, through a
+// printable recovery key or through Shamir shares handed to colleagues.
+//
+// Both stand for one random secret. The vault key is sealed with it when
+// recovery is set up, and unsealed again when the PIN is forgotten.
+package recovery
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// SecretSize is the length of a recovery secret in bytes.
+const SecretSize = 32
+
+const (
+	kindKey   = 'K'
+	kindShare = 'S'
+	setSize   = 4
+)
+
+var (
+	ErrMistyped    = errors.New("recovery text is mistyped")
+	ErrWrongSecret = errors.New("recovery secret does not open this vault")
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func NewSecret() ([]byte, error) {
+	secret := make([]byte, SecretSize)
+	_, err := rand.Read(secret)
+	return secret, err
+}
+
+// encode writes kind and data in groups of four base32 characters, with a
+// checksum so typing mistakes are caught before anything is decrypted.
+func encode(kind byte, data []byte) string {
+	payload := append([]byte{kind}, data...)
+	sum := sha256.Sum256(payload)
+	text := encoding.EncodeToString(append(payload, sum[:2]...))
+	var groups []string
+	for len(text) > 4 {
+		groups = append(groups, text[:4])
+		text = text[4:]
+	}
+	return strings.Join(append(groups, text), "-")
+}
+
+func decode(kind byte, text string) ([]byte, error) {
+	text = strings.ToUpper(strings.NewReplacer("-", "", " ", "", "\n", "", "\t", "").Replace(text))
+	raw, err := encoding.DecodeString(text)
+	if err != nil || len(raw) < 3 {
+		return nil, ErrMistyped
+	}
+	payload, check := raw[:len(raw)-2], raw[len(raw)-2:]
+	sum := sha256.Sum256(payload)
+	if !bytes.Equal(sum[:2], check) {
+		return nil, ErrMistyped
+	}
+	if payload[0] != kind {
+		if kind == kindKey {
+			return nil, errors.New("this is a recovery share, not a recovery key")
+		}
+		return nil, errors.New("this is a recovery key, not a recovery share")
+	}
+	return payload[1:], nil
+}
+
+// FormatKey returns secret as a recovery key to print.
+func FormatKey(secret []byte) string {
+	return encode(kindKey, secret)
+}
+
+func ParseKey(text string) ([]byte, error) {
+	secret, err := decode(kindKey, text)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) != SecretSize {
+		return nil, ErrMistyped
+	}
+	return secret, nil
+}
+
+// IsKey reports whether text looks like a recovery key rather than a share.
+func IsKey(text string) bool {
+	_, err := ParseKey(text)
+	return err == nil
+}
+
+// Share is one parsed piece of a split secret.
+type Share struct {
+	set       []byte
+	Threshold int
+	data      []byte
+}
+
+// Split cuts secret into n shares to print, any threshold of which recover
+// it.
+func Split(secret []byte, n, threshold int) ([]string, error) {
+	shares, err := split(secret, n, threshold)
+	if err != nil {
+		return nil, err
+	}
+	set := make([]byte, setSize)
+	if _, err := rand.Read(set); err != nil {
+		return nil, err
+	}
+	texts := make([]string, n)
+	for i, s := range shares {
+		data := append(append(append([]byte{}, set...), byte(threshold)), s...)
+		texts[i] = encode(kindShare, data)
+	}
+	return texts, nil
+}
+
+func ParseShare(text string) (Share, error) {
+	data, err := decode(kindShare, text)
+	if err != nil {
+		return Share{}, err
+	}
+	if len(data) != setSize+2+SecretSize {
+		return Share{}, ErrMistyped
+	}
+	return Share{set: data[:setSize], Threshold: int(data[setSize]), data: data[setSize+1:]}, nil
+}
+
+// Combine recovers the secret from at least a threshold of shares.
+func Combine(shares []Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no shares")
+	}
+	threshold := shares[0].Threshold
+	var raw [][]byte
+	for _, s := range shares {
+		if !bytes.Equal(s.set, shares[0].set) {
+			return nil, errors.New("the shares come from different recovery sets")
+		}
+		raw = append(raw, s.data)
+	}
+	if len(shares) < threshold {
+		return nil, fmt.Errorf("need %d shares, have %d", threshold, len(shares))
+	}
+	return combine(raw)
+}
+
+func sealKey(secret []byte) (cipher.AEAD, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, nil, []byte("gopass recovery")), key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal encrypts the vault key with a key derived from secret.
+func Seal(secret, vaultKey []byte) ([]byte, error) {
+	gcm, err := sealKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, vaultKey, nil), nil
+}
+
+// Open returns the vault key sealed with secret.
+func Open(secret, sealed []byte) ([]byte, error) {
+	gcm, err := sealKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("recovery file too short")
+	}
+	key, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrWrongSecret
+	}
+	return key, nil
+}
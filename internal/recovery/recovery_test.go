@@ -0,0 +1,88 @@
+package recovery
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnyThresholdOfSharesRecoversTheSecret(t *testing.T) {
+	secret, err := NewSecret()
+	require.NoError(t, err)
+	texts, err := Split(secret, 5, 3)
+	require.NoError(t, err)
+	require.Len(t, texts, 5)
+
+	var shares []Share
+	for _, text := range texts {
+		s, err := ParseShare(text)
+		require.NoError(t, err)
+		assert.Equal(t, 3, s.Threshold)
+		shares = append(shares, s)
+	}
+
+	for _, pick := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		var chosen []Share
+		for _, i := range pick {
+			chosen = append(chosen, shares[i])
+		}
+		got, err := Combine(chosen)
+		require.NoError(t, err)
+		assert.Equal(t, secret, got, "shares %v", pick)
+	}
+
+	_, err = Combine(shares[:2])
+	assert.ErrorContains(t, err, "need 3 shares")
+	_, err = Combine([]Share{shares[0], shares[0], shares[1]})
+	assert.Error(t, err)
+
+	other, err := Split(secret, 3, 2)
+	require.NoError(t, err)
+	stranger, err := ParseShare(other[0])
+	require.NoError(t, err)
+	_, err = Combine([]Share{shares[0], shares[1], stranger})
+	assert.ErrorContains(t, err, "different recovery sets")
+}
+
+func TestRecoveryKeyRoundTripsAndCatchesTypos(t *testing.T) {
+	secret, err := NewSecret()
+	require.NoError(t, err)
+	key := FormatKey(secret)
+
+	got, err := ParseKey(strings.ToLower(strings.ReplaceAll(key, "-", " ")))
+	require.NoError(t, err)
+	assert.Equal(t, secret, got)
+	assert.True(t, IsKey(key))
+
+	typo := []byte(key)
+	if typo[0] == 'A' {
+		typo[0] = 'B'
+	} else {
+		typo[0] = 'A'
+	}
+	_, err = ParseKey(string(typo))
+	assert.ErrorIs(t, err, ErrMistyped)
+
+	shares, err := Split(secret, 2, 2)
+	require.NoError(t, err)
+	_, err = ParseKey(shares[0])
+	assert.ErrorContains(t, err, "recovery share")
+	assert.False(t, IsKey(shares[0]))
+}
+
+func TestSealOpensOnlyWithTheSameSecret(t *testing.T) {
+	secret, _ := NewSecret()
+	other, _ := NewSecret()
+	vaultKey := []byte("0123456789abcdef0123456789abcdef")
+
+	sealed, err := Seal(secret, vaultKey)
+	require.NoError(t, err)
+	got, err := Open(secret, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, vaultKey, got)
+
+	_, err = Open(other, sealed)
+	assert.ErrorIs(t, err, ErrWrongSecret)
+}
@@ -0,0 +1,104 @@
+package recovery
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+// Shamir's secret sharing over GF(2^8), one polynomial per secret byte.
+// Share x-coordinates run from 1 to n; the secret is the value at 0.
+
+var expTable, logTable [256]byte
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		expTable[i] = x
+		logTable[x] = byte(i)
+		// Multiply by the generator 3 modulo x^8+x^4+x^3+x+1
+		hi := x & 0x80
+		x2 := x << 1
+		if hi != 0 {
+			x2 ^= 0x1b
+		}
+		x ^= x2
+	}
+	expTable[255] = expTable[0]
+}
+
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])+int(logTable[b]))%255]
+}
+
+func div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])-int(logTable[b])+255)%255]
+}
+
+// split returns n shares of secret, any threshold of which recover it. Each
+// share is its x-coordinate followed by one y value per secret byte.
+func split(secret []byte, n, threshold int) ([][]byte, error) {
+	if threshold < 2 || threshold > n || n > 255 {
+		return nil, errors.New("shares need 2 <= threshold <= count <= 255")
+	}
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, 1+len(secret))
+		shares[i][0] = byte(i + 1)
+	}
+	coeffs := make([]byte, threshold)
+	for j, b := range secret {
+		coeffs[0] = b
+		if _, err := rand.Read(coeffs[1:]); err != nil {
+			return nil, err
+		}
+		for _, share := range shares {
+			// Horner's rule at x = share[0]
+			var y byte
+			for k := threshold - 1; k >= 0; k-- {
+				y = mul(y, share[0]) ^ coeffs[k]
+			}
+			share[1+j] = y
+		}
+	}
+	return shares, nil
+}
+
+// combine interpolates the shares at 0. It cannot tell whether enough
+// shares were given; too few yield a wrong secret.
+func combine(shares [][]byte) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no shares")
+	}
+	size := len(shares[0]) - 1
+	seen := map[byte]bool{}
+	for _, s := range shares {
+		if len(s) != size+1 || s[0] == 0 {
+			return nil, errors.New("shares do not belong together")
+		}
+		if seen[s[0]] {
+			return nil, errors.New("the same share was given twice")
+		}
+		seen[s[0]] = true
+	}
+
+	secret := make([]byte, size)
+	for i, si := range shares {
+		// Lagrange basis polynomial for share i evaluated at 0
+		basis := byte(1)
+		for j, sj := range shares {
+			if i != j {
+				basis = mul(basis, div(sj[0], sj[0]^si[0]))
+			}
+		}
+		for k := range secret {
+			secret[k] ^= mul(basis, si[1+k])
+		}
+	}
+	return secret, nil
+}
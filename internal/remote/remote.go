@@ -0,0 +1,107 @@
+// Package remote keeps a copy of a vault's encrypted file on another
+// machine, so several devices can sync the same vault through it.
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrNotFound = errors.New("remote vault does not exist yet")
+	// ErrModified is returned by Put when someone else wrote the remote
+	// since it was read.
+	ErrModified = errors.New("remote vault changed since it was read")
+)
+
+// Remote stores one encrypted vault file. Every version carries a tag, and
+// writes only succeed against the version they were based on.
+type Remote interface {
+	// Get returns the stored file and the tag of its version.
+	Get(ctx context.Context) (data []byte, tag string, err error)
+	// Put replaces the version tag with data and returns the new tag. The
+	// empty tag means the file must not exist yet.
+	Put(ctx context.Context, data []byte, tag string) (string, error)
+}
+
+const (
+	TypeWebDAV = "webdav"
+	// TypeS3 remotes have URLs of the form s3://BUCKET/KEY.
+	TypeS3 = "s3"
+)
+
+var Types = []string{TypeWebDAV, TypeS3}
+
+// DefaultS3Region is used for S3 remotes that do not name a region.
+const DefaultS3Region = "us-east-1"
+
+// Config is a remote as recorded in the vault registry.
+type Config struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	URL  string `json:"url"`
+	// Username is the access key ID for S3 remotes.
+	Username string `json:"username,omitempty"`
+	// PasswordRef is a gopass:// reference to the entry holding the
+	// password (or S3 secret key) for the remote, resolved once the vault is
+	// unlocked.
+	PasswordRef string `json:"password_ref,omitempty"`
+	// Endpoint and Region locate S3 remotes. The endpoint defaults to AWS
+	// in the region, e.g. https://s3.us-east-1.amazonaws.com.
+	Endpoint string `json:"endpoint,omitempty"`
+	Region   string `json:"region,omitempty"`
+}
+
+func (c Config) Validate() error {
+	if c.Name == "" || strings.ContainsAny(c.Name, `/\`) {
+		return fmt.Errorf("invalid remote name %q", c.Name)
+	}
+	switch c.Type {
+	case TypeWebDAV:
+		u, err := url.Parse(c.URL)
+		if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+			return fmt.Errorf("remote %s: %q is not an http or https URL", c.Name, c.URL)
+		}
+		if strings.HasSuffix(u.Path, "/") {
+			return fmt.Errorf("remote %s: URL must name the vault file, not a folder", c.Name)
+		}
+		return nil
+	case TypeS3:
+		u, err := url.Parse(c.URL)
+		if err != nil || u.Scheme != "s3" || u.Host == "" || strings.Trim(u.Path, "/") == "" || strings.HasSuffix(u.Path, "/") {
+			return fmt.Errorf("remote %s: %q is not of the form s3://BUCKET/KEY", c.Name, c.URL)
+		}
+		if c.Endpoint != "" {
+			if e, err := url.Parse(c.Endpoint); err != nil || e.Host == "" {
+				return fmt.Errorf("remote %s: invalid endpoint %q", c.Name, c.Endpoint)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("remote %s: unknown type %q", c.Name, c.Type)
+	}
+}
+
+// Open connects to the remote described by c.
+func Open(c Config, password string) (Remote, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	if c.Type == TypeWebDAV {
+		return NewWebDAV(c.URL, c.Username, password), nil
+	}
+
+	u, _ := url.Parse(c.URL)
+	region := c.Region
+	if region == "" {
+		region = DefaultS3Region
+	}
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	return NewS3(endpoint, region, u.Host, u.Path, c.Username, password)
+}
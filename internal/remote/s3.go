@@ -0,0 +1,289 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version is an earlier state of a remote vault.
+type Version struct {
+	ID       string
+	Modified time.Time
+	Size     int64
+	Latest   bool
+}
+
+// Versioned is a Remote that keeps the versions it replaced, such as a
+// bucket with versioning enabled, so the vault can be restored from them.
+type Versioned interface {
+	Remote
+	// Versions lists the stored versions, newest first.
+	Versions(ctx context.Context) ([]Version, error)
+	GetVersion(ctx context.Context, id string) ([]byte, error)
+}
+
+// S3 stores the vault as one object in an S3-compatible bucket. Writes are
+// conditional on the object's ETag, which AWS S3, MinIO and most other
+// implementations support.
+type S3 struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	key       string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+// NewS3 returns a remote for the object key in bucket. Requests use
+// path-style addressing against endpoint, e.g. https://s3.eu-west-1.amazonaws.com
+// or a MinIO server.
+func NewS3(endpoint, region, bucket, key, accessKey, secretKey string) (*S3, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+	return &S3{
+		endpoint:  u,
+		region:    region,
+		bucket:    bucket,
+		key:       strings.TrimPrefix(key, "/"),
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    http.DefaultClient,
+	}, nil
+}
+
+func (s *S3) do(ctx context.Context, method string, query url.Values, body []byte, header http.Header, object bool) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket
+	if object {
+		u.Path += "/" + s.key
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	sum := sha256.Sum256(body)
+	signV4(req, hex.EncodeToString(sum[:]), s.accessKey, s.secretKey, s.region, time.Now())
+	return s.client.Do(req)
+}
+
+// s3Error is the error document S3 answers failed requests with.
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func readS3Error(resp *http.Response) s3Error {
+	var e s3Error
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	xml.Unmarshal(body, &e)
+	return e
+}
+
+func s3StatusError(op string, resp *http.Response) error {
+	if e := readS3Error(resp); e.Code != "" {
+		return fmt.Errorf("s3 %s: %s: %s", op, e.Code, e.Message)
+	}
+	return fmt.Errorf("s3 %s: %s", op, resp.Status)
+}
+
+func (s *S3) Get(ctx context.Context) ([]byte, string, error) {
+	resp, err := s.do(ctx, http.MethodGet, nil, nil, nil, true)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		// A missing bucket is a configuration error, not a new vault
+		if e := readS3Error(resp); e.Code != "" && e.Code != "NoSuchKey" {
+			return nil, "", fmt.Errorf("s3 GET: %s: %s", e.Code, e.Message)
+		}
+		return nil, "", ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", s3StatusError("GET", resp)
+	}
+	data, err := io.ReadAll(resp.Body)
+	return data, resp.Header.Get("ETag"), err
+}
+
+func (s *S3) Put(ctx context.Context, data []byte, tag string) (string, error) {
+	header := http.Header{}
+	if tag == "" {
+		header.Set("If-None-Match", "*")
+	} else {
+		header.Set("If-Match", tag)
+	}
+	resp, err := s.do(ctx, http.MethodPut, nil, data, header, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	switch {
+	// 409 is returned when a concurrent conditional write won the race
+	case resp.StatusCode == http.StatusPreconditionFailed, resp.StatusCode == http.StatusConflict:
+		return "", ErrModified
+	case resp.StatusCode != http.StatusOK:
+		return "", s3StatusError("PUT", resp)
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+type listVersionsResult struct {
+	IsTruncated         bool   `xml:"IsTruncated"`
+	NextKeyMarker       string `xml:"NextKeyMarker"`
+	NextVersionIDMarker string `xml:"NextVersionIdMarker"`
+	Versions            []struct {
+		Key          string    `xml:"Key"`
+		VersionID    string    `xml:"VersionId"`
+		IsLatest     bool      `xml:"IsLatest"`
+		LastModified time.Time `xml:"LastModified"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Version"`
+}
+
+func (s *S3) Versions(ctx context.Context) ([]Version, error) {
+	var versions []Version
+	query := url.Values{"versions": {""}, "prefix": {s.key}}
+	for {
+		resp, err := s.do(ctx, http.MethodGet, query, nil, nil, false)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			return nil, s3StatusError("list versions", resp)
+		}
+		var page listVersionsResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range page.Versions {
+			// The prefix also matches longer keys
+			if v.Key == s.key {
+				versions = append(versions, Version{ID: v.VersionID, Modified: v.LastModified, Size: v.Size, Latest: v.IsLatest})
+			}
+		}
+		if !page.IsTruncated {
+			break
+		}
+		query.Set("key-marker", page.NextKeyMarker)
+		query.Set("version-id-marker", page.NextVersionIDMarker)
+	}
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].Modified.After(versions[j].Modified) })
+	return versions, nil
+}
+
+func (s *S3) GetVersion(ctx context.Context, id string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, url.Values{"versionId": {id}}, nil, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3StatusError("GET version "+id, resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// signV4 adds an AWS Signature Version 4 Authorization header to req,
+// signing the host and every header already set on it.
+func signV4(req *http.Request, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsEscape(req.URL.Path, true),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func canonicalQuery(q url.Values) string {
+	var pairs []string
+	for k, vs := range q {
+		for _, v := range vs {
+			pairs = append(pairs, awsEscape(k, false)+"="+awsEscape(v, false))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape percent-encodes everything but unreserved characters, and
+// slashes when keepSlash is set, as SigV4 requires.
+func awsEscape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', keepSlash && c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,143 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+)
+
+// WebDAV stores the vault as a single file on a WebDAV server, using ETags
+// for optimistic concurrency.
+type WebDAV struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewWebDAV returns a remote for the file at fileURL.
+func NewWebDAV(fileURL, username, password string) *WebDAV {
+	return &WebDAV{url: fileURL, username: username, password: password, client: http.DefaultClient}
+}
+
+func (w *WebDAV) do(ctx context.Context, method, target string, body []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if w.username != "" || w.password != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+	return w.client.Do(req)
+}
+
+func statusError(method string, resp *http.Response) error {
+	return fmt.Errorf("webdav %s: %s", method, resp.Status)
+}
+
+func (w *WebDAV) Get(ctx context.Context) ([]byte, string, error) {
+	resp, err := w.do(ctx, http.MethodGet, w.url, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, "", ErrNotFound
+	default:
+		return nil, "", statusError("GET", resp)
+	}
+	tag := resp.Header.Get("ETag")
+	if tag == "" {
+		return nil, "", errors.New("webdav server does not send ETags")
+	}
+	data, err := io.ReadAll(resp.Body)
+	return data, tag, err
+}
+
+func (w *WebDAV) Put(ctx context.Context, data []byte, tag string) (string, error) {
+	header := http.Header{}
+	if tag == "" {
+		header.Set("If-None-Match", "*")
+	} else {
+		header.Set("If-Match", tag)
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := w.do(ctx, http.MethodPut, w.url, data, header)
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusPreconditionFailed:
+			return "", ErrModified
+		// The folder holding the file does not exist yet. RFC 4918 answers
+		// 409, some servers 404.
+		case (resp.StatusCode == http.StatusConflict || resp.StatusCode == http.StatusNotFound) && attempt == 0:
+			if err := w.mkdirAll(ctx); err != nil {
+				return "", err
+			}
+			continue
+		case resp.StatusCode < 200 || resp.StatusCode > 299:
+			return "", statusError("PUT", resp)
+		}
+		if tag := resp.Header.Get("ETag"); tag != "" {
+			return tag, nil
+		}
+		return w.head(ctx)
+	}
+}
+
+// head returns the current ETag for servers that do not send one on PUT.
+func (w *WebDAV) head(ctx context.Context) (string, error) {
+	resp, err := w.do(ctx, http.MethodHead, w.url, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError("HEAD", resp)
+	}
+	tag := resp.Header.Get("ETag")
+	if tag == "" {
+		return "", errors.New("webdav server does not send ETags")
+	}
+	return tag, nil
+}
+
+// mkdirAll creates the collections above the vault file.
+func (w *WebDAV) mkdirAll(ctx context.Context) error {
+	u, err := url.Parse(w.url)
+	if err != nil {
+		return err
+	}
+	var dirs []string
+	for dir := path.Dir(u.Path); dir != "/" && dir != "."; dir = path.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+	}
+	for _, dir := range dirs {
+		u.Path = dir + "/"
+		resp, err := w.do(ctx, "MKCOL", u.String(), nil, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		// 405 means the collection already exists
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusMethodNotAllowed {
+			return statusError("MKCOL "+dir, resp)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package remote
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+)
+
+// newWebDAVServer serves an in-memory WebDAV tree. x/net/webdav ignores
+// If-Match and If-None-Match on PUT, so they are checked here the way
+// Nextcloud and Apache do.
+func newWebDAVServer(t *testing.T) *httptest.Server {
+	dav := &webdav.Handler{FileSystem: webdav.NewMemFS(), LockSystem: webdav.NewMemLS()}
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "alice" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodPut {
+			head := httptest.NewRecorder()
+			dav.ServeHTTP(head, httptest.NewRequest(http.MethodHead, r.URL.Path, nil))
+			current := head.Header().Get("ETag")
+			if match := r.Header.Get("If-Match"); match != "" && match != current {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			if r.Header.Get("If-None-Match") == "*" && current != "" {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+		}
+		dav.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWebDAVOptimisticConcurrency(t *testing.T) {
+	ctx := context.Background()
+	srv := newWebDAVServer(t)
+	laptop := NewWebDAV(srv.URL+"/gopass/vaults/team.vault", "alice", "secret")
+	desktop := NewWebDAV(srv.URL+"/gopass/vaults/team.vault", "alice", "secret")
+
+	_, _, err := laptop.Get(ctx)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	tag, err := laptop.Put(ctx, []byte("v1"), "")
+	require.NoError(t, err, "missing folders are created")
+	require.NotEmpty(t, tag)
+
+	_, err = desktop.Put(ctx, []byte("other v1"), "")
+	assert.ErrorIs(t, err, ErrModified, "creating twice must fail")
+
+	data, seen, err := desktop.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", string(data))
+	assert.Equal(t, tag, seen)
+
+	_, err = desktop.Put(ctx, []byte("v2"), seen)
+	require.NoError(t, err)
+	_, err = laptop.Put(ctx, []byte("stale"), tag)
+	assert.ErrorIs(t, err, ErrModified)
+
+	data, _, err = laptop.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", string(data))
+}
+
+func TestWebDAVReportsAuthFailures(t *testing.T) {
+	srv := newWebDAVServer(t)
+	_, _, err := NewWebDAV(srv.URL+"/team.vault", "alice", "wrong").Get(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{Name: "nas", Type: TypeWebDAV, URL: "https://nas.local/dav/gopass.vault"}.Validate())
+	assert.Error(t, Config{Name: "nas", Type: TypeWebDAV, URL: "https://nas.local/dav/"}.Validate())
+	assert.Error(t, Config{Name: "nas", Type: TypeWebDAV, URL: "ftp://nas.local/x"}.Validate())
+	assert.Error(t, Config{Name: "a/b", Type: TypeWebDAV, URL: "https://nas.local/x"}.Validate())
+	assert.Error(t, Config{Name: "nas", Type: "dropbox", URL: "https://nas.local/x"}.Validate())
+	assert.NoError(t, Config{Name: "s3", Type: TypeS3, URL: "s3://bucket/vaults/team.vault"}.Validate())
+	assert.Error(t, Config{Name: "s3", Type: TypeS3, URL: "s3://bucket/"}.Validate())
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package secmem
+
+// DisableCoreDumps does nothing where processes write no core dumps by
+// default; on Windows only configured error reporting does.
+func DisableCoreDumps() (restore func(), err error) {
+	return func() {}, nil
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package secmem
+
+import "golang.org/x/sys/unix"
+
+// DisableCoreDumps stops the process from writing core dumps until the
+// returned function restores the previous setting.
+func DisableCoreDumps() (restore func(), err error) {
+	var old unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_CORE, &old); err != nil {
+		return func() {}, err
+	}
+	// Only the soft limit is lowered; the hard one could not be raised again
+	off := unix.Rlimit{Cur: 0, Max: old.Max}
+	if err := unix.Setrlimit(unix.RLIMIT_CORE, &off); err != nil {
+		return func() {}, err
+	}
+	if err := setDumpable(false); err != nil {
+		unix.Setrlimit(unix.RLIMIT_CORE, &old)
+		return func() {}, err
+	}
+	return func() {
+		setDumpable(true)
+		unix.Setrlimit(unix.RLIMIT_CORE, &old)
+	}, nil
+}
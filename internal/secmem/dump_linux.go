@@ -0,0 +1,17 @@
+package secmem
+
+import "golang.org/x/sys/unix"
+
+func excludeFromDumps(b []byte) {
+	unix.Madvise(b, unix.MADV_DONTDUMP)
+}
+
+// setDumpable also keeps other processes of the user from attaching to
+// read the process memory.
+func setDumpable(dumpable bool) error {
+	flag := 0
+	if dumpable {
+		flag = 1
+	}
+	return unix.Prctl(unix.PR_SET_DUMPABLE, uintptr(flag), 0, 0, 0)
+}
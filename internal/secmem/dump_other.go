@@ -0,0 +1,7 @@
+//go:build unix && !linux
+
+package secmem
+
+func excludeFromDumps([]byte) {}
+
+func setDumpable(bool) error { return nil }
@@ -0,0 +1,11 @@
+//go:build !unix && !windows
+
+package secmem
+
+import "errors"
+
+func alloc(int) ([]byte, []byte, bool, error) {
+	return nil, nil, false, errors.New("no secure memory on this platform")
+}
+
+func free([]byte, bool) {}
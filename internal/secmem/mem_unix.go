@@ -0,0 +1,42 @@
+//go:build unix
+
+package secmem
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func alloc(size int) (mem, data []byte, locked bool, err error) {
+	page := os.Getpagesize()
+	inner := (size + page - 1) / page * page
+	mem, err = unix.Mmap(-1, 0, inner+2*page, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANON)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if err := unix.Mprotect(mem[:page], unix.PROT_NONE); err != nil {
+		unix.Munmap(mem)
+		return nil, nil, false, err
+	}
+	if err := unix.Mprotect(mem[page+inner:], unix.PROT_NONE); err != nil {
+		unix.Munmap(mem)
+		return nil, nil, false, err
+	}
+	// Locking fails past RLIMIT_MEMLOCK; the guard pages and wiping still
+	// help, so carry on unlocked
+	locked = unix.Mlock(mem[page:page+inner]) == nil
+	excludeFromDumps(mem[page : page+inner])
+	end := page + inner
+	return mem, mem[end-size : end : end], locked, nil
+}
+
+func free(mem []byte, locked bool) {
+	page := os.Getpagesize()
+	inner := mem[page : len(mem)-page]
+	clear(inner)
+	if locked {
+		unix.Munlock(inner)
+	}
+	unix.Munmap(mem)
+}
@@ -0,0 +1,45 @@
+//go:build windows
+
+package secmem
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+func alloc(size int) (mem, data []byte, locked bool, err error) {
+	page := os.Getpagesize()
+	inner := (size + page - 1) / page * page
+	total := inner + 2*page
+	addr, err := windows.VirtualAlloc(0, uintptr(total), windows.MEM_COMMIT|windows.MEM_RESERVE, windows.PAGE_READWRITE)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	mem = unsafe.Slice((*byte)(*(*unsafe.Pointer)(unsafe.Pointer(&addr))), total)
+
+	var old uint32
+	if err := windows.VirtualProtect(addr, uintptr(page), windows.PAGE_NOACCESS, &old); err != nil {
+		windows.VirtualFree(addr, 0, windows.MEM_RELEASE)
+		return nil, nil, false, err
+	}
+	if err := windows.VirtualProtect(addr+uintptr(page+inner), uintptr(page), windows.PAGE_NOACCESS, &old); err != nil {
+		windows.VirtualFree(addr, 0, windows.MEM_RELEASE)
+		return nil, nil, false, err
+	}
+	// The working set limits how much can be locked; carry on unlocked
+	locked = windows.VirtualLock(addr+uintptr(page), uintptr(inner)) == nil
+	end := page + inner
+	return mem, mem[end-size : end : end], locked, nil
+}
+
+func free(mem []byte, locked bool) {
+	page := os.Getpagesize()
+	clear(mem[page : len(mem)-page])
+	addr := uintptr(unsafe.Pointer(&mem[0]))
+	if locked {
+		windows.VirtualUnlock(addr+uintptr(page), uintptr(len(mem)-2*page))
+	}
+	windows.VirtualFree(addr, 0, windows.MEM_RELEASE)
+}
@@ -0,0 +1,64 @@
+// Package secmem keeps key material and decrypted secrets in memory that
+// is locked against swapping, left out of core dumps where the system
+// allows, fenced by guard pages and zeroed when released.
+package secmem
+
+// Buffer is a fixed-size block of secure memory. The data ends right at
+// the trailing guard page, so running past it faults instead of reading
+// whatever follows.
+type Buffer struct {
+	mem    []byte
+	data   []byte
+	locked bool
+}
+
+// New returns a zeroed buffer of size bytes. When the system refuses
+// secure memory, as on platforms without it, the buffer lives on the
+// ordinary heap and is still zeroed when destroyed.
+func New(size int) *Buffer {
+	if size <= 0 {
+		return &Buffer{data: []byte{}}
+	}
+	mem, data, locked, err := alloc(size)
+	if err != nil {
+		data = make([]byte, size)
+		return &Buffer{data: data[:size:size]}
+	}
+	return &Buffer{mem: mem, data: data, locked: locked}
+}
+
+// Copy returns a buffer holding a copy of b.
+func Copy(b []byte) *Buffer {
+	buf := New(len(b))
+	copy(buf.data, b)
+	return buf
+}
+
+// Bytes returns the buffer's memory, which is valid until Destroy. Its
+// capacity is its length, so appending moves it out of secure memory.
+func (b *Buffer) Bytes() []byte {
+	return b.data
+}
+
+// Locked reports whether the buffer is locked against swapping.
+func (b *Buffer) Locked() bool {
+	return b.locked
+}
+
+// Destroy zeroes and releases the buffer. It is safe to call more than
+// once and on nil; Bytes is empty afterwards.
+func (b *Buffer) Destroy() {
+	if b == nil {
+		return
+	}
+	Wipe(b.data)
+	if b.mem != nil {
+		free(b.mem, b.locked)
+	}
+	b.mem, b.data, b.locked = nil, nil, false
+}
+
+// Wipe zeroes b.
+func Wipe(b []byte) {
+	clear(b)
+}
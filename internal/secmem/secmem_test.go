@@ -0,0 +1,29 @@
+package secmem
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferHoldsDataUntilDestroyed(t *testing.T) {
+	b := Copy([]byte("secret"))
+	assert.Equal(t, "secret", string(b.Bytes()))
+	assert.Equal(t, len(b.Bytes()), cap(b.Bytes()))
+
+	data, heap := b.Bytes(), b.mem == nil
+	b.Destroy()
+	assert.Empty(t, b.Bytes())
+	b.Destroy()
+	if heap {
+		// Secure memory is unmapped; heap memory stays readable and must be zeroed
+		assert.Equal(t, make([]byte, 6), data)
+	}
+}
+
+func TestNewIsZeroed(t *testing.T) {
+	b := New(5000)
+	defer b.Destroy()
+	assert.Equal(t, make([]byte, 5000), b.Bytes())
+	assert.Empty(t, New(0).Bytes())
+}
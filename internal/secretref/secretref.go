@@ -0,0 +1,183 @@
+// Package secretref parses references to vault secrets such as
+// gopass://infra/db#password and resolves them against vault entries.
+//
+// A reference is gopass://[@VAULT/]PATH[#FIELD]. PATH is an entry's folder
+// and name joined by slashes, a note title or an entry ID. Without a vault
+// the default one is used; without a field the password of a password entry
+// or the content of a note is meant.
+package secretref
+
+import (
+	"fmt"
+	"strings"
+
+	"gopass/internal/models"
+)
+
+const Scheme = "gopass://"
+
+// Ref points at one field of an entry.
+type Ref struct {
+	Vault string
+	Path  string
+	Field string
+}
+
+// IsRef reports whether s looks like a reference rather than a literal value.
+func IsRef(s string) bool {
+	return strings.HasPrefix(s, Scheme)
+}
+
+func Parse(s string) (Ref, error) {
+	if !IsRef(s) {
+		return Ref{}, fmt.Errorf("%q is not a %s reference", s, Scheme)
+	}
+	path, field, _ := strings.Cut(strings.TrimPrefix(s, Scheme), "#")
+
+	var r Ref
+	if strings.HasPrefix(path, "@") {
+		vault, rest, ok := strings.Cut(path[1:], "/")
+		if !ok || vault == "" {
+			return Ref{}, fmt.Errorf("reference %q names no vault", s)
+		}
+		r.Vault, path = vault, rest
+	}
+	r.Path = strings.Trim(path, "/")
+	r.Field = field
+	if r.Path == "" {
+		return Ref{}, fmt.Errorf("reference %q names no entry", s)
+	}
+	return r, nil
+}
+
+func (r Ref) String() string {
+	s := Scheme
+	if r.Vault != "" {
+		s += "@" + r.Vault + "/"
+	}
+	s += r.Path
+	if r.Field != "" {
+		s += "#" + r.Field
+	}
+	return s
+}
+
+// Entries is a snapshot of one vault's entries.
+type Entries struct {
+	Passwords []models.Password
+	Notes     []models.Note
+	// RevealPassword and RevealNote, when set, fetch the secrets of the
+	// entry a reference matched, for entries listed without them.
+	RevealPassword func(id string) (models.Password, error)
+	RevealNote     func(id string) (models.Note, error)
+}
+
+// Resolve returns the value r points at. A reference matching more than one
+// entry is an error rather than a guess.
+func (e Entries) Resolve(r Ref) (string, error) {
+	var passwords []models.Password
+	for _, p := range e.Passwords {
+		if p.Path() == r.Path || p.ID == r.Path {
+			passwords = append(passwords, p)
+		}
+	}
+	var notes []models.Note
+	for _, n := range e.Notes {
+		if n.Title == r.Path || n.ID == r.Path {
+			notes = append(notes, n)
+		}
+	}
+
+	switch {
+	case len(passwords)+len(notes) == 0:
+		return "", fmt.Errorf("%s: no such entry", r)
+	case len(passwords)+len(notes) > 1:
+		return "", fmt.Errorf("%s: %d entries match; use the entry ID", r, len(passwords)+len(notes))
+	case len(passwords) == 1:
+		p := passwords[0]
+		if e.RevealPassword != nil {
+			var err error
+			if p, err = e.RevealPassword(p.ID); err != nil {
+				return "", fmt.Errorf("%s: %w", r, err)
+			}
+		}
+		return passwordField(p, r)
+	default:
+		n := notes[0]
+		if e.RevealNote != nil {
+			var err error
+			if n, err = e.RevealNote(n.ID); err != nil {
+				return "", fmt.Errorf("%s: %w", r, err)
+			}
+		}
+		return noteField(n, r)
+	}
+}
+
+// Built-in fields are matched case-insensitively and take precedence over
+// custom fields, which must match exactly.
+func passwordField(p models.Password, r Ref) (string, error) {
+	switch strings.ToLower(r.Field) {
+	case "", "password":
+		return p.Password, nil
+	case "username":
+		return p.Username, nil
+	case "url":
+		return p.URL, nil
+	case "note":
+		return p.Note, nil
+	case "name":
+		return p.Name, nil
+	}
+	return customField(p.Fields, r)
+}
+
+func noteField(n models.Note, r Ref) (string, error) {
+	switch strings.ToLower(r.Field) {
+	case "", "content":
+		return n.Content, nil
+	case "title":
+		return n.Title, nil
+	}
+	return customField(n.Fields, r)
+}
+
+func customField(fields []models.Field, r Ref) (string, error) {
+	for _, f := range fields {
+		if f.Name == r.Field {
+			return f.Value, nil
+		}
+	}
+	return "", fmt.Errorf("%s: entry has no field %q", r, r.Field)
+}
+
+// Resolver resolves references across vaults, loading each vault once on
+// first use.
+type Resolver struct {
+	load   func(vault string) (Entries, error)
+	vaults map[string]Entries
+	failed map[string]error
+}
+
+// NewResolver resolves references with the entries load returns for a vault
+// name; the empty name is the default vault.
+func NewResolver(load func(vault string) (Entries, error)) *Resolver {
+	return &Resolver{load: load, vaults: make(map[string]Entries), failed: make(map[string]error)}
+}
+
+func (res *Resolver) Resolve(r Ref) (string, error) {
+	if err, failed := res.failed[r.Vault]; failed {
+		return "", fmt.Errorf("%s: %w", r, err)
+	}
+	entries, ok := res.vaults[r.Vault]
+	if !ok {
+		var err error
+		if entries, err = res.load(r.Vault); err != nil {
+			// Do not ask for the PIN again for every reference
+			res.failed[r.Vault] = err
+			return "", fmt.Errorf("%s: %w", r, err)
+		}
+		res.vaults[r.Vault] = entries
+	}
+	return entries.Resolve(r)
+}
@@ -0,0 +1,89 @@
+package secretref
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopass/internal/models"
+)
+
+func TestParse(t *testing.T) {
+	r, err := Parse("gopass://infra/db#username")
+	require.NoError(t, err)
+	assert.Equal(t, Ref{Path: "infra/db", Field: "username"}, r)
+	assert.Equal(t, "gopass://infra/db#username", r.String())
+
+	r, err = Parse("gopass://@work/infra/db")
+	require.NoError(t, err)
+	assert.Equal(t, Ref{Vault: "work", Path: "infra/db"}, r)
+	assert.Equal(t, "gopass://@work/infra/db", r.String())
+
+	for _, bad := range []string{"gopass://#password", "https://infra/db", "gopass://@work", "gopass://@/db"} {
+		_, err = Parse(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	entries := Entries{
+		Passwords: []models.Password{
+			{ID: "p1", Folder: "infra", Name: "db", Username: "app", Password: "pw",
+				Fields: []models.Field{{Name: "port", Value: "5432"}, {Name: "password", Value: "shadowed"}}},
+			{ID: "p2", Name: "twice"},
+			{ID: "p3", Name: "twice"},
+		},
+		Notes: []models.Note{{ID: "n1", Title: "tls/cert", Content: "PEM", Fields: []models.Field{{Name: "expires", Value: "2030"}}}},
+	}
+
+	for ref, want := range map[string]string{
+		"gopass://infra/db":          "pw",
+		"gopass://infra/db#Password": "pw",
+		"gopass://infra/db#username": "app",
+		"gopass://infra/db#port":     "5432",
+		"gopass://tls/cert":          "PEM",
+		"gopass://tls/cert#expires":  "2030",
+		"gopass://p2#name":           "twice",
+	} {
+		r, err := Parse(ref)
+		require.NoError(t, err)
+		got, err := entries.Resolve(r)
+		require.NoError(t, err, ref)
+		assert.Equal(t, want, got, ref)
+	}
+
+	for _, ref := range []string{"gopass://db", "gopass://twice", "gopass://infra/db#Port"} {
+		r, err := Parse(ref)
+		require.NoError(t, err)
+		_, err = entries.Resolve(r)
+		assert.Error(t, err, ref)
+	}
+}
+
+func TestRenderAcrossVaults(t *testing.T) {
+	var loaded []string
+	res := NewResolver(func(vault string) (Entries, error) {
+		loaded = append(loaded, vault)
+		switch vault {
+		case "":
+			return Entries{Passwords: []models.Password{{Name: "db", Username: "app", Password: "pw"}}}, nil
+		case "team":
+			return Entries{Notes: []models.Note{{Title: "token", Content: "t0k"}}}, nil
+		default:
+			return Entries{}, errors.New("no such vault")
+		}
+	})
+
+	out, err := res.Render([]byte("user: {{gopass://db#username}}\npass: {{ gopass://db }}\ntoken: {{ gopass://@team/token }}\nhelm: {{ .Values.x }}\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "user: app\npass: pw\ntoken: t0k\nhelm: {{ .Values.x }}\n", string(out))
+	assert.Equal(t, []string{"", "team"}, loaded)
+
+	out, err = res.Render([]byte("{{ gopass://missing }} {{ gopass://@other/x }} {{ gopass://@other/y }}"))
+	assert.Nil(t, out)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "gopass://missing: no such entry")
+	assert.Contains(t, err.Error(), "gopass://@other/y: no such vault")
+	assert.Equal(t, []string{"", "team", "other"}, loaded)
+}
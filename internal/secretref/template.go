@@ -0,0 +1,33 @@
+package secretref
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// placeholder matches {{ gopass://... }}. Other {{ }} expressions are left
+// alone so templates for other tools pass through untouched.
+var placeholder = regexp.MustCompile(`\{\{\s*(gopass://[^}]*?)\s*\}\}`)
+
+// Render replaces every reference placeholder in tpl. It fails listing every
+// reference that could not be resolved, and then returns no output at all.
+func (res *Resolver) Render(tpl []byte) ([]byte, error) {
+	var errs []error
+	out := placeholder.ReplaceAllFunc(tpl, func(match []byte) []byte {
+		raw := strings.TrimSpace(string(placeholder.FindSubmatch(match)[1]))
+		r, err := Parse(raw)
+		if err == nil {
+			var value string
+			if value, err = res.Resolve(r); err == nil {
+				return []byte(value)
+			}
+		}
+		errs = append(errs, err)
+		return match
+	})
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return out, nil
+}
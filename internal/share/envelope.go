@@ -0,0 +1,191 @@
+package share
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+	"gopass/internal/models"
+)
+
+// blockType is the PEM type of share blobs, which are text so they can be
+// pasted into a chat or mail.
+const blockType = "GOPASS SHARE"
+
+var (
+	ErrExpired      = errors.New("share has expired")
+	ErrNotForUs     = errors.New("share is addressed to another vault")
+	ErrBadSignature = errors.New("share signature does not verify")
+)
+
+// Payload is what a share blob carries: one entry, or the revocation of an
+// earlier share.
+type Payload struct {
+	ID       string           `json:"id"`
+	Password *models.Password `json:"password,omitempty"`
+	Note     *models.Note     `json:"note,omitempty"`
+	// Revoked withdraws the share with this ID instead of carrying an entry.
+	Revoked   bool      `json:"revoked,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt, when set, is when the recipient should drop the entry.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (p Payload) Expired(now time.Time) bool {
+	return !p.ExpiresAt.IsZero() && now.After(p.ExpiresAt)
+}
+
+type envelope struct {
+	Sender    string `json:"sender"`
+	Recipient string `json:"recipient"`
+	Ephemeral []byte `json:"ephemeral"`
+	Sealed    []byte `json:"sealed"`
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// signedBytes is the envelope without its signature.
+func (e envelope) signedBytes() ([]byte, error) {
+	e.Signature = nil
+	return json.Marshal(e)
+}
+
+// Labels keep keys derived for different purposes apart.
+const (
+	shareInfo = "gopass share v1"
+	wrapInfo  = "gopass key wrap v1"
+)
+
+// shareKey derives the AES key from the X25519 exchange, bound to both
+// public keys involved.
+func shareKey(secret, ephemeral, recipient []byte, info string) ([]byte, error) {
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+	key := make([]byte, 32)
+	_, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key)
+	return key, err
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts p to the recipient and signs it as from.
+func seal(from Identity, to PublicKey, p Payload) ([]byte, error) {
+	sender, err := from.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := ephemeral.ECDH(to.encryption)
+	if err != nil {
+		return nil, err
+	}
+	key, err := shareKey(secret, ephemeral.PublicKey().Bytes(), to.encryption.Bytes(), shareInfo)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	env := envelope{Sender: sender.String(), Recipient: to.String(), Ephemeral: ephemeral.PublicKey().Bytes()}
+	env.Sealed = gcm.Seal(nonce, nonce, plain, []byte(env.Sender+"\n"+env.Recipient))
+	signed, err := env.signedBytes()
+	if err != nil {
+		return nil, err
+	}
+	env.Signature = ed25519.Sign(from.signingKey(), signed)
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), nil
+}
+
+// open verifies a blob sealed to id and returns its payload and sender.
+func open(id Identity, blob []byte) (Payload, PublicKey, error) {
+	var p Payload
+	block, _ := pem.Decode(blob)
+	if block == nil || block.Type != blockType {
+		return p, PublicKey{}, errors.New("not a gopass share")
+	}
+	var env envelope
+	if err := json.Unmarshal(block.Bytes, &env); err != nil {
+		return p, PublicKey{}, fmt.Errorf("malformed share: %w", err)
+	}
+
+	sender, err := ParsePublicKey(env.Sender)
+	if err != nil {
+		return p, PublicKey{}, fmt.Errorf("malformed share: %w", err)
+	}
+	signed, err := env.signedBytes()
+	if err != nil {
+		return p, sender, err
+	}
+	if !ed25519.Verify(sender.signing, signed, env.Signature) {
+		return p, sender, ErrBadSignature
+	}
+
+	me, err := id.PublicKey()
+	if err != nil {
+		return p, sender, err
+	}
+	if env.Recipient != me.String() {
+		return p, sender, ErrNotForUs
+	}
+	priv, err := id.encryptionKey()
+	if err != nil {
+		return p, sender, err
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(env.Ephemeral)
+	if err != nil {
+		return p, sender, fmt.Errorf("malformed share: %w", err)
+	}
+	secret, err := priv.ECDH(ephemeral)
+	if err != nil {
+		return p, sender, err
+	}
+	key, err := shareKey(secret, env.Ephemeral, me.encryption.Bytes(), shareInfo)
+	if err != nil {
+		return p, sender, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return p, sender, err
+	}
+	if len(env.Sealed) < gcm.NonceSize() {
+		return p, sender, errors.New("malformed share: ciphertext too short")
+	}
+	nonce, ciphertext := env.Sealed[:gcm.NonceSize()], env.Sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, []byte(env.Sender+"\n"+env.Recipient))
+	if err != nil {
+		return p, sender, err
+	}
+	return p, sender, json.Unmarshal(plain, &p)
+}
@@ -0,0 +1,93 @@
+// Package share hands single entries to teammates: every vault has an
+// identity keypair, teammates' public keys are kept as contacts, and entries
+// travel as encrypted, signed share blobs.
+package share
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// publicKeyPrefix marks public keys as ours when pasted around.
+const publicKeyPrefix = "gpk1"
+
+// Identity is a vault's keypair: X25519 to receive entries and Ed25519 to
+// sign the ones it sends.
+type Identity struct {
+	EncryptionKey []byte `json:"encryption_key"`
+	SigningKey    []byte `json:"signing_key"`
+}
+
+func NewIdentity() (Identity, error) {
+	enc, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return Identity{}, err
+	}
+	_, sig, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return Identity{}, err
+	}
+	return Identity{EncryptionKey: enc.Bytes(), SigningKey: sig.Seed()}, nil
+}
+
+func (id Identity) encryptionKey() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().NewPrivateKey(id.EncryptionKey)
+}
+
+func (id Identity) signingKey() ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(id.SigningKey)
+}
+
+// PublicKey is what teammates import to share with this vault.
+func (id Identity) PublicKey() (PublicKey, error) {
+	enc, err := id.encryptionKey()
+	if err != nil {
+		return PublicKey{}, err
+	}
+	return PublicKey{
+		encryption: enc.PublicKey(),
+		signing:    id.signingKey().Public().(ed25519.PublicKey),
+	}, nil
+}
+
+// PublicKey is the public half of an Identity.
+type PublicKey struct {
+	encryption *ecdh.PublicKey
+	signing    ed25519.PublicKey
+}
+
+var errInvalidPublicKey = errors.New("not a gopass public key")
+
+func ParsePublicKey(s string) (PublicKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(s), publicKeyPrefix))
+	if err != nil || !strings.HasPrefix(strings.TrimSpace(s), publicKeyPrefix) || len(raw) != 32+ed25519.PublicKeySize {
+		return PublicKey{}, errInvalidPublicKey
+	}
+	enc, err := ecdh.X25519().NewPublicKey(raw[:32])
+	if err != nil {
+		return PublicKey{}, errInvalidPublicKey
+	}
+	return PublicKey{encryption: enc, signing: ed25519.PublicKey(raw[32:])}, nil
+}
+
+func (k PublicKey) String() string {
+	raw := append(append([]byte{}, k.encryption.Bytes()...), k.signing...)
+	return publicKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// Fingerprint is a short form of the key for comparing it out of band.
+func (k PublicKey) Fingerprint() string {
+	sum := sha256.Sum256([]byte(k.String()))
+	h := hex.EncodeToString(sum[:8])
+	return h[0:4] + " " + h[4:8] + " " + h[8:12] + " " + h[12:16]
+}
+
+func (k PublicKey) Equal(other PublicKey) bool {
+	return k.String() == other.String()
+}
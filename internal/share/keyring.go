@@ -0,0 +1,275 @@
+package share
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gopass/internal/events"
+)
+
+// Contact is a teammate's public key under a name of our choosing.
+type Contact struct {
+	Name      string    `json:"name"`
+	PublicKey string    `json:"public_key"`
+	AddedAt   time.Time `json:"added_at"`
+}
+
+// Record remembers a share sent or received.
+type Record struct {
+	ID        string      `json:"id"`
+	Peer      string      `json:"peer"`
+	Kind      events.Kind `json:"kind"`
+	EntryID   string      `json:"entry_id"`
+	EntryName string      `json:"entry_name"`
+	CreatedAt time.Time   `json:"created_at"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	Revoked   bool        `json:"revoked,omitempty"`
+}
+
+type keyringFile struct {
+	Identity Identity  `json:"identity"`
+	Contacts []Contact `json:"contacts"`
+	Sent     []Record  `json:"sent"`
+	Received []Record  `json:"received"`
+}
+
+// Keyring is a vault's identity, contacts and share history, kept in a file
+// encrypted with the vault key.
+type Keyring struct {
+	path    string
+	encrypt func([]byte) ([]byte, error)
+	mu      sync.Mutex
+	f       keyringFile
+}
+
+// OpenKeyring reads the keyring at path, creating it with a fresh identity
+// the first time.
+func OpenKeyring(path string, encrypt, decrypt func([]byte) ([]byte, error)) (*Keyring, error) {
+	k := &Keyring{path: path, encrypt: encrypt}
+	sealed, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		if k.f.Identity, err = NewIdentity(); err != nil {
+			return nil, err
+		}
+		return k, k.save()
+	}
+	if err != nil {
+		return nil, err
+	}
+	plain, err := decrypt(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("read keyring: %w", err)
+	}
+	return k, json.Unmarshal(plain, &k.f)
+}
+
+func (k *Keyring) save() error {
+	plain, err := json.Marshal(k.f)
+	if err != nil {
+		return err
+	}
+	sealed, err := k.encrypt(plain)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(k.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(k.path, sealed, 0600)
+}
+
+func (k *Keyring) PublicKey() (PublicKey, error) {
+	return k.f.Identity.PublicKey()
+}
+
+func (k *Keyring) Contacts() []Contact {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return append([]Contact{}, k.f.Contacts...)
+}
+
+func (k *Keyring) contact(name string) (Contact, PublicKey, error) {
+	for _, c := range k.f.Contacts {
+		if c.Name == name {
+			key, err := ParsePublicKey(c.PublicKey)
+			return c, key, err
+		}
+	}
+	return Contact{}, PublicKey{}, fmt.Errorf("no contact named %q", name)
+}
+
+func (k *Keyring) AddContact(name, publicKey string) (Contact, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Contact{}, errors.New("contact name must not be empty")
+	}
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return Contact{}, err
+	}
+	if me, err := k.f.Identity.PublicKey(); err == nil && me.Equal(key) {
+		return Contact{}, errors.New("that is this vault's own key")
+	}
+	for _, c := range k.f.Contacts {
+		if c.Name == name {
+			return Contact{}, fmt.Errorf("contact %q already exists", name)
+		}
+		if c.PublicKey == key.String() {
+			return Contact{}, fmt.Errorf("key already belongs to contact %q", c.Name)
+		}
+	}
+	c := Contact{Name: name, PublicKey: key.String(), AddedAt: time.Now()}
+	k.f.Contacts = append(k.f.Contacts, c)
+	return c, k.save()
+}
+
+func (k *Keyring) RemoveContact(name string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for i, c := range k.f.Contacts {
+		if c.Name == name {
+			k.f.Contacts = append(k.f.Contacts[:i], k.f.Contacts[i+1:]...)
+			return k.save()
+		}
+	}
+	return fmt.Errorf("no contact named %q", name)
+}
+
+// Share seals the entry in p for the named contact and records the share.
+// A zero expires means the share does not expire.
+func (k *Keyring) Share(to string, p Payload, expires time.Time) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	_, key, err := k.contact(to)
+	if err != nil {
+		return nil, err
+	}
+
+	p.ID = uuid.New().String()
+	p.CreatedAt = time.Now()
+	p.ExpiresAt = expires
+	rec := Record{ID: p.ID, Peer: to, CreatedAt: p.CreatedAt, ExpiresAt: expires}
+	switch {
+	case p.Password != nil:
+		rec.Kind, rec.EntryID, rec.EntryName = events.KindPassword, p.Password.ID, p.Password.Path()
+	case p.Note != nil:
+		rec.Kind, rec.EntryID, rec.EntryName = events.KindNote, p.Note.ID, p.Note.Title
+	default:
+		return nil, errors.New("nothing to share")
+	}
+
+	blob, err := seal(k.f.Identity, key, p)
+	if err != nil {
+		return nil, err
+	}
+	k.f.Sent = append(k.f.Sent, rec)
+	return blob, k.save()
+}
+
+// Revoke marks a sent share as revoked and returns the blob telling its
+// recipient to drop the entry.
+func (k *Keyring) Revoke(id string) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for i, rec := range k.f.Sent {
+		if rec.ID != id {
+			continue
+		}
+		_, key, err := k.contact(rec.Peer)
+		if err != nil {
+			return nil, err
+		}
+		blob, err := seal(k.f.Identity, key, Payload{ID: id, Revoked: true, CreatedAt: time.Now()})
+		if err != nil {
+			return nil, err
+		}
+		k.f.Sent[i].Revoked = true
+		return blob, k.save()
+	}
+	return nil, fmt.Errorf("no share with ID %q", id)
+}
+
+// Open verifies and decrypts a blob sent to this vault. The sender must be
+// a contact; expired shares and shares already imported are refused.
+func (k *Keyring) Open(blob []byte) (Payload, Contact, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	p, sender, err := open(k.f.Identity, blob)
+	if err != nil {
+		return p, Contact{}, err
+	}
+
+	var from *Contact
+	for i, c := range k.f.Contacts {
+		if c.PublicKey == sender.String() {
+			from = &k.f.Contacts[i]
+		}
+	}
+	if from == nil {
+		return p, Contact{}, fmt.Errorf("share from unknown key %s; add the sender as a contact first", sender.Fingerprint())
+	}
+	if p.Revoked {
+		return p, *from, nil
+	}
+	if p.Expired(time.Now()) {
+		return p, *from, ErrExpired
+	}
+	for _, rec := range k.f.Received {
+		if rec.ID == p.ID {
+			return p, *from, errors.New("share was already imported")
+		}
+	}
+	return p, *from, nil
+}
+
+// Received records that the entry of p from the contact was stored as
+// entryID.
+func (k *Keyring) Received(p Payload, from Contact, entryID string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	rec := Record{ID: p.ID, Peer: from.Name, EntryID: entryID, CreatedAt: p.CreatedAt, ExpiresAt: p.ExpiresAt}
+	switch {
+	case p.Password != nil:
+		rec.Kind, rec.EntryName = events.KindPassword, p.Password.Path()
+	case p.Note != nil:
+		rec.Kind, rec.EntryName = events.KindNote, p.Note.Title
+	}
+	k.f.Received = append(k.f.Received, rec)
+	return k.save()
+}
+
+// Forget drops the record of a received share, as when it is revoked by
+// from or expired, and returns it so its entry can be deleted. An empty from
+// forgets regardless of the sender.
+func (k *Keyring) Forget(id, from string) (Record, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for i, rec := range k.f.Received {
+		if rec.ID == id && (from == "" || rec.Peer == from) {
+			k.f.Received = append(k.f.Received[:i], k.f.Received[i+1:]...)
+			return rec, k.save()
+		}
+	}
+	return Record{}, fmt.Errorf("no share with ID %q was received", id)
+}
+
+func (k *Keyring) Sent() []Record {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return append([]Record{}, k.f.Sent...)
+}
+
+func (k *Keyring) ReceivedShares() []Record {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return append([]Record{}, k.f.Received...)
+}
@@ -0,0 +1,150 @@
+package share
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopass/internal/models"
+)
+
+// plain stands in for the vault cipher; the keyring only needs a pair of
+// inverse functions.
+func plain(b []byte) ([]byte, error) { return b, nil }
+
+func newKeyring(t *testing.T, dir string) *Keyring {
+	k, err := OpenKeyring(filepath.Join(dir, "sharing.enc"), plain, plain)
+	require.NoError(t, err)
+	return k
+}
+
+// pair returns two keyrings that know each other as alice and bob.
+func pair(t *testing.T) (alice, bob *Keyring) {
+	alice, bob = newKeyring(t, t.TempDir()), newKeyring(t, t.TempDir())
+	aliceKey, err := alice.PublicKey()
+	require.NoError(t, err)
+	bobKey, err := bob.PublicKey()
+	require.NoError(t, err)
+	_, err = alice.AddContact("bob", bobKey.String())
+	require.NoError(t, err)
+	_, err = bob.AddContact("alice", aliceKey.String())
+	require.NoError(t, err)
+	return alice, bob
+}
+
+func TestPublicKeyRoundTrip(t *testing.T) {
+	id, err := NewIdentity()
+	require.NoError(t, err)
+	key, err := id.PublicKey()
+	require.NoError(t, err)
+
+	parsed, err := ParsePublicKey(key.String())
+	require.NoError(t, err)
+	assert.True(t, key.Equal(parsed))
+	assert.Equal(t, key.Fingerprint(), parsed.Fingerprint())
+
+	_, err = ParsePublicKey("gpk1" + strings.Repeat("A", 10))
+	assert.Error(t, err)
+}
+
+func TestKeyringKeepsIdentity(t *testing.T) {
+	dir := t.TempDir()
+	first, err := newKeyring(t, dir).PublicKey()
+	require.NoError(t, err)
+	second, err := newKeyring(t, dir).PublicKey()
+	require.NoError(t, err)
+	assert.True(t, first.Equal(second))
+}
+
+func TestShareAndRevoke(t *testing.T) {
+	alice, bob := pair(t)
+	pw := models.Password{ID: "p1", Name: "Deploy", Username: "ci", Password: "s3cret"}
+
+	blob, err := alice.Share("bob", Payload{Password: &pw}, time.Time{})
+	require.NoError(t, err)
+	assert.NotContains(t, string(blob), "s3cret")
+
+	p, from, err := bob.Open(blob)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", from.Name)
+	assert.Equal(t, "s3cret", p.Password.Password)
+	require.NoError(t, bob.Received(p, from, "local-id"))
+
+	_, _, err = bob.Open(blob)
+	assert.ErrorContains(t, err, "already imported")
+
+	revocation, err := alice.Revoke(p.ID)
+	require.NoError(t, err)
+	assert.True(t, alice.Sent()[0].Revoked)
+
+	r, from, err := bob.Open(revocation)
+	require.NoError(t, err)
+	assert.True(t, r.Revoked)
+	rec, err := bob.Forget(r.ID, from.Name)
+	require.NoError(t, err)
+	assert.Equal(t, "local-id", rec.EntryID)
+	assert.Empty(t, bob.ReceivedShares())
+}
+
+func TestOpenRefusals(t *testing.T) {
+	alice, bob := pair(t)
+	note := models.Note{ID: "n1", Title: "Runbook", Content: "..."}
+
+	t.Run("expired", func(t *testing.T) {
+		blob, err := alice.Share("bob", Payload{Note: &note}, time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+		_, _, err = bob.Open(blob)
+		assert.ErrorIs(t, err, ErrExpired)
+	})
+
+	t.Run("unknown sender", func(t *testing.T) {
+		require.NoError(t, bob.RemoveContact("alice"))
+		defer func() {
+			key, _ := alice.PublicKey()
+			_, err := bob.AddContact("alice", key.String())
+			require.NoError(t, err)
+		}()
+		blob, err := alice.Share("bob", Payload{Note: &note}, time.Time{})
+		require.NoError(t, err)
+		_, _, err = bob.Open(blob)
+		assert.ErrorContains(t, err, "unknown key")
+	})
+
+	t.Run("other recipient", func(t *testing.T) {
+		blob, err := alice.Share("bob", Payload{Note: &note}, time.Time{})
+		require.NoError(t, err)
+		_, _, err = alice.Open(blob)
+		assert.ErrorIs(t, err, ErrNotForUs)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		blob, err := alice.Share("bob", Payload{Note: &note}, time.Time{})
+		require.NoError(t, err)
+		lines := strings.Split(string(blob), "\n")
+		line := []byte(lines[3])
+		if line[5] == 'A' {
+			line[5] = 'B'
+		} else {
+			line[5] = 'A'
+		}
+		lines[3] = string(line)
+		_, _, err = bob.Open([]byte(strings.Join(lines, "\n")))
+		assert.Error(t, err)
+	})
+}
+
+func TestAddContactValidation(t *testing.T) {
+	alice, _ := pair(t)
+	own, err := alice.PublicKey()
+	require.NoError(t, err)
+
+	_, err = alice.AddContact("me", own.String())
+	assert.Error(t, err)
+	_, err = alice.AddContact("bob", own.String())
+	assert.Error(t, err)
+	_, err = alice.AddContact("carol", "not a key")
+	assert.Error(t, err)
+}
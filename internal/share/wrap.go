@@ -0,0 +1,77 @@
+package share
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+)
+
+// Wrap encrypts a secret, such as a vault key, so only the holder of to's
+// identity can recover it. The result is the ephemeral public key followed
+// by nonce and ciphertext.
+func Wrap(to PublicKey, secret []byte) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ephemeral.ECDH(to.encryption)
+	if err != nil {
+		return nil, err
+	}
+	key, err := shareKey(shared, ephemeral.PublicKey().Bytes(), to.encryption.Bytes(), wrapInfo)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	out := ephemeral.PublicKey().Bytes()
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, secret, nil), nil
+}
+
+// Unwrap recovers a secret wrapped for id.
+func (id Identity) Unwrap(wrapped []byte) ([]byte, error) {
+	priv, err := id.encryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < 32 {
+		return nil, errors.New("wrapped key too short")
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(wrapped[:32])
+	if err != nil {
+		return nil, err
+	}
+	shared, err := priv.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	key, err := shareKey(shared, wrapped[:32], priv.PublicKey().Bytes(), wrapInfo)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	rest := wrapped[32:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, errors.New("wrapped key too short")
+	}
+	return gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], nil)
+}
+
+func (id Identity) Sign(message []byte) []byte {
+	return ed25519.Sign(id.signingKey(), message)
+}
+
+func (k PublicKey) Verify(message, sig []byte) bool {
+	return ed25519.Verify(k.signing, message, sig)
+}
@@ -0,0 +1,127 @@
+package sshagent
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/ssh/agent"
+	gopassagent "gopass/internal/agent"
+)
+
+// Server serves an Agent on a Unix socket, suitable for SSH_AUTH_SOCK.
+type Server struct {
+	agent *Agent
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]bool
+	closed   bool
+}
+
+func NewServer(a *Agent) *Server {
+	return &Server{agent: a, conns: make(map[net.Conn]bool)}
+}
+
+// ListenAndServe creates the socket at path, usable by the current user
+// only, and serves until Close is called. Its directory is checked like
+// the gopass agent's.
+func (srv *Server) ListenAndServe(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := gopassagent.CheckSocketDir(path); err != nil {
+		return err
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("an ssh agent is already listening on %s", path)
+	}
+	os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return err
+	}
+	defer os.Remove(path)
+	return srv.Serve(l)
+}
+
+// Serve accepts connections on l until Close is called. Only processes of
+// the current user are served.
+func (srv *Server) Serve(l net.Listener) error {
+	srv.mu.Lock()
+	if srv.closed {
+		srv.mu.Unlock()
+		l.Close()
+		return nil
+	}
+	srv.listener = l
+	srv.mu.Unlock()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			srv.mu.Lock()
+			closed := srv.closed
+			srv.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		if !srv.track(conn, true) {
+			conn.Close()
+			return nil
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer srv.track(conn, false)
+			defer conn.Close()
+			if peer, err := gopassagent.PeerCredentials(conn); err != nil || peer.UID != os.Getuid() {
+				return
+			}
+			agent.ServeAgent(srv.agent, conn)
+		}()
+	}
+}
+
+func (srv *Server) track(conn net.Conn, open bool) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if !open {
+		delete(srv.conns, conn)
+		return true
+	}
+	if srv.closed {
+		return false
+	}
+	srv.conns[conn] = true
+	return true
+}
+
+// Close stops the server and forgets the keys.
+func (srv *Server) Close() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.closed {
+		return
+	}
+	srv.closed = true
+	srv.agent.Close()
+	if srv.listener != nil {
+		srv.listener.Close()
+	}
+	for conn := range srv.conns {
+		conn.Close()
+	}
+}
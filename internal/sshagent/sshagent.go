@@ -0,0 +1,269 @@
+// Package sshagent offers the SSH keys kept in an unlocked vault to ssh
+// clients over the ssh-agent protocol.
+package sshagent
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"gopass/internal/models"
+	"gopass/internal/storage"
+)
+
+var (
+	ErrManagedInVault = errors.New("keys are managed in the vault")
+	ErrLocked         = errors.New("agent is locked")
+	ErrRefused        = errors.New("signing refused")
+)
+
+// Confirmer asks whether key may sign a request. It is only consulted for
+// keys with Confirm set.
+type Confirmer func(key models.SSHKey) bool
+
+// Agent implements agent.Agent on top of the SSH keys in a storage. Keys are
+// read from the storage on every request, so changes to the vault apply
+// immediately.
+type Agent struct {
+	storage *storage.Storage
+	confirm Confirmer
+
+	mu         sync.Mutex
+	firstSeen  map[string]time.Time
+	publicKeys map[string]cachedPublicKey
+	passphrase []byte
+	locked     bool
+	closed     bool
+}
+
+var _ agent.Agent = (*Agent)(nil)
+
+func New(s *storage.Storage, confirm Confirmer) *Agent {
+	return &Agent{
+		storage:    s,
+		confirm:    confirm,
+		firstSeen:  make(map[string]time.Time),
+		publicKeys: make(map[string]cachedPublicKey),
+	}
+}
+
+// ParseKey checks that k holds an unencrypted private key the agent can use.
+func ParseKey(k models.SSHKey) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(k.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("ssh key %s: %w", k.Name, err)
+	}
+	return signer, nil
+}
+
+// offeredKey is a key on offer with its public half. The private key stays
+// sealed until a signature needs it.
+type offeredKey struct {
+	models.SSHKey
+	pub ssh.PublicKey
+}
+
+// cachedPublicKey is the public half of a key stored before keys recorded
+// it, derived once per version of the key.
+type cachedPublicKey struct {
+	updated time.Time
+	pub     ssh.PublicKey
+}
+
+// keys returns the keys currently on offer. A key's lifetime counts from
+// the first time this agent offered it.
+func (a *Agent) keys() ([]offeredKey, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return nil, errors.New("vault is locked")
+	}
+	if a.locked {
+		return nil, ErrLocked
+	}
+
+	now := time.Now()
+	var keys []offeredKey
+	for _, k := range a.storage.GetSSHKeys() {
+		seen, ok := a.firstSeen[k.ID]
+		if !ok {
+			seen = now
+			a.firstSeen[k.ID] = now
+		}
+		if k.Lifetime > 0 && now.Sub(seen) >= k.Lifetime {
+			continue
+		}
+		pub, err := a.publicKey(k)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, offeredKey{SSHKey: k, pub: pub})
+	}
+	return keys, nil
+}
+
+// publicKey parses the public half of k. Keys stored before it was
+// recorded are revealed once to derive it.
+func (a *Agent) publicKey(k models.SSHKey) (ssh.PublicKey, error) {
+	if k.PublicKey != "" {
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k.PublicKey))
+		return pub, err
+	}
+	if c, ok := a.publicKeys[k.ID]; ok && c.updated.Equal(k.UpdatedAt) {
+		return c.pub, nil
+	}
+	signer, err := a.signer(k)
+	if err != nil {
+		return nil, err
+	}
+	a.publicKeys[k.ID] = cachedPublicKey{updated: k.UpdatedAt, pub: signer.PublicKey()}
+	return signer.PublicKey(), nil
+}
+
+// signer reveals the private key of k alone.
+func (a *Agent) signer(k models.SSHKey) (ssh.Signer, error) {
+	secret, err := a.storage.SSHKeySecret(k.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer secret.Destroy()
+	signer, err := ssh.ParsePrivateKey(secret.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("ssh key %s: %w", k.Name, err)
+	}
+	return signer, nil
+}
+
+func (a *Agent) List() ([]*agent.Key, error) {
+	keys, err := a.keys()
+	if err != nil {
+		// ssh clients treat an error here as fatal; a locked agent has no keys
+		return nil, nil
+	}
+	list := make([]*agent.Key, 0, len(keys))
+	for _, k := range keys {
+		comment := k.Comment
+		if comment == "" {
+			comment = k.Name
+		}
+		list = append(list, &agent.Key{Format: k.pub.Type(), Blob: k.pub.Marshal(), Comment: comment})
+	}
+	return list, nil
+}
+
+func (a *Agent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	return a.SignWithFlags(key, data, 0)
+}
+
+func (a *Agent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	keys, err := a.keys()
+	if err != nil {
+		return nil, err
+	}
+	wanted := key.Marshal()
+	for _, k := range keys {
+		if !bytes.Equal(k.pub.Marshal(), wanted) {
+			continue
+		}
+		if k.Confirm && (a.confirm == nil || !a.confirm(k.SSHKey)) {
+			return nil, ErrRefused
+		}
+
+		var algorithm string
+		switch flags {
+		case 0:
+		case agent.SignatureFlagRsaSha256:
+			algorithm = ssh.KeyAlgoRSASHA256
+		case agent.SignatureFlagRsaSha512:
+			algorithm = ssh.KeyAlgoRSASHA512
+		default:
+			return nil, fmt.Errorf("unsupported signature flags %d", flags)
+		}
+		signer, err := a.signer(k.SSHKey)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(signer.PublicKey().Marshal(), wanted) {
+			return nil, fmt.Errorf("ssh key %s does not match its public key", k.Name)
+		}
+		if algorithm == "" {
+			return signer.Sign(rand.Reader, data)
+		}
+		algSigner, ok := signer.(ssh.AlgorithmSigner)
+		if !ok {
+			return nil, fmt.Errorf("%s keys cannot sign with %s", key.Type(), algorithm)
+		}
+		return algSigner.SignWithAlgorithm(rand.Reader, data, algorithm)
+	}
+	return nil, errors.New("key not found")
+}
+
+// Signers returns signers for the keys on offer. They bypass confirmation,
+// so they are only meant for use inside this process.
+func (a *Agent) Signers() ([]ssh.Signer, error) {
+	keys, err := a.keys()
+	if err != nil {
+		return nil, err
+	}
+	signers := make([]ssh.Signer, 0, len(keys))
+	for _, k := range keys {
+		signer, err := a.signer(k.SSHKey)
+		if err != nil {
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	return signers, nil
+}
+
+func (a *Agent) Add(agent.AddedKey) error {
+	return ErrManagedInVault
+}
+
+func (a *Agent) Remove(ssh.PublicKey) error {
+	return ErrManagedInVault
+}
+
+func (a *Agent) RemoveAll() error {
+	return ErrManagedInVault
+}
+
+// Lock hides all keys until Unlock is called with the same passphrase, as
+// ssh-add -x does.
+func (a *Agent) Lock(passphrase []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.locked {
+		return ErrLocked
+	}
+	a.locked = true
+	a.passphrase = append([]byte{}, passphrase...)
+	return nil
+}
+
+func (a *Agent) Unlock(passphrase []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.locked {
+		return errors.New("agent is not locked")
+	}
+	if subtle.ConstantTimeCompare(passphrase, a.passphrase) != 1 {
+		return errors.New("incorrect passphrase")
+	}
+	a.locked = false
+	a.passphrase = nil
+	return nil
+}
+
+// Close stops offering keys for good; it is called when the vault locks.
+func (a *Agent) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closed = true
+}
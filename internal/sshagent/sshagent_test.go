@@ -0,0 +1,179 @@
+package sshagent
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"gopass/internal/events"
+	"gopass/internal/models"
+	"gopass/internal/storage"
+)
+
+func newKey(t *testing.T, name string) (models.SSHKey, ssh.PublicKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	require.NoError(t, err)
+	sshPub, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+
+	k := models.NewSSHKey()
+	k.ID = name
+	k.Name = name
+	k.PrivateKey = string(pem.EncodeToMemory(block))
+	return *k, sshPub
+}
+
+func startServer(t *testing.T, s *storage.Storage, confirm Confirmer) (*Server, agent.ExtendedAgent) {
+	t.Helper()
+	srv := NewServer(New(s, confirm))
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "ssh.sock"))
+	require.NoError(t, err)
+	go srv.Serve(l)
+	t.Cleanup(srv.Close)
+
+	conn, err := net.Dial("unix", l.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return srv, agent.NewClient(conn)
+}
+
+func newStorage(t *testing.T) *storage.Storage {
+	s := storage.NewStorageAt(filepath.Join(t.TempDir(), "data.enc"), "1234")
+	require.NoError(t, s.Lock())
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestListAndSign(t *testing.T) {
+	s := newStorage(t)
+	k, pub := newKey(t, "deploy")
+	require.NoError(t, s.AddSSHKey(k))
+	_, client := startServer(t, s, nil)
+
+	keys, err := client.List()
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "deploy", keys[0].Comment)
+	assert.Equal(t, pub.Marshal(), keys[0].Blob)
+
+	sig, err := client.Sign(pub, []byte("challenge"))
+	require.NoError(t, err)
+	assert.NoError(t, pub.Verify([]byte("challenge"), sig))
+
+	assert.ErrorContains(t, client.RemoveAll(), "failure")
+}
+
+func TestSignRevealsOnlyItsKey(t *testing.T) {
+	s := newStorage(t)
+	deploy, pub := newKey(t, "deploy")
+	backup, _ := newKey(t, "backup")
+	require.NoError(t, s.AddSSHKey(deploy))
+	require.NoError(t, s.AddSSHKey(backup))
+	_, client := startServer(t, s, nil)
+
+	var revealed []string
+	s.Events().Subscribe(func(e events.Event) {
+		if e.Type == events.Revealed {
+			revealed = append(revealed, e.ID)
+		}
+	})
+	keys, err := client.List()
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Empty(t, revealed, "listing reads the public keys kept with the entries")
+
+	_, err = client.Sign(pub, []byte("challenge"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"deploy"}, revealed)
+}
+
+func TestConfirmBeforeUse(t *testing.T) {
+	s := newStorage(t)
+	k, pub := newKey(t, "prod")
+	k.Confirm = true
+	require.NoError(t, s.AddSSHKey(k))
+
+	allow := false
+	var asked []string
+	_, client := startServer(t, s, func(k models.SSHKey) bool {
+		asked = append(asked, k.Name)
+		return allow
+	})
+
+	_, err := client.Sign(pub, []byte("data"))
+	assert.Error(t, err)
+
+	allow = true
+	_, err = client.Sign(pub, []byte("data"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prod", "prod"}, asked)
+}
+
+func TestLifetimeExpiresKeys(t *testing.T) {
+	s := newStorage(t)
+	short, _ := newKey(t, "short")
+	short.Lifetime = 50 * time.Millisecond
+	long, _ := newKey(t, "long")
+	require.NoError(t, s.AddSSHKey(short))
+	require.NoError(t, s.AddSSHKey(long))
+	_, client := startServer(t, s, nil)
+
+	keys, err := client.List()
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+
+	time.Sleep(100 * time.Millisecond)
+	keys, err = client.List()
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "long", keys[0].Comment)
+}
+
+func TestKeysFollowVault(t *testing.T) {
+	s := newStorage(t)
+	k, pub := newKey(t, "deploy")
+	srv, client := startServer(t, s, nil)
+
+	keys, err := client.List()
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	require.NoError(t, s.AddSSHKey(k))
+	keys, err = client.List()
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+
+	require.NoError(t, client.Lock([]byte("pw")))
+	keys, err = client.List()
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+	assert.Error(t, client.Unlock([]byte("wrong")))
+	require.NoError(t, client.Unlock([]byte("pw")))
+
+	// Locking the vault stops the agent
+	srv.agent.Close()
+	_, err = srv.agent.Sign(pub, []byte("data"))
+	assert.Error(t, err)
+}
+
+func TestListenRefusesSharedSocketDir(t *testing.T) {
+	t.Setenv("GOPASS_AGENT_SOCK", "")
+	dir := filepath.Join(t.TempDir(), "shared")
+	require.NoError(t, os.Mkdir(dir, 0755))
+	require.NoError(t, os.Chmod(dir, 0755))
+
+	srv := NewServer(New(newStorage(t), nil))
+	defer srv.Close()
+	assert.Error(t, srv.ListenAndServe(filepath.Join(dir, "ssh.sock")))
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"gopass/internal/events"
+	"gopass/internal/models"
+)
+
+// Backend keeps the vault somewhere other than the single encrypted file,
+// such as a git repository. It encrypts what it stores with the storage's
+// Encrypt and Decrypt.
+type Backend interface {
+	Load() (models.ExportData, error)
+	// Save stores data after change, the mutation that led to it. Change
+	// is the zero event for a plain Save.
+	Save(data models.ExportData, change events.Event) error
+}
+
+// SetBackend makes Load and Save go through b instead of the vault file.
+func (s *Storage) SetBackend(b Backend) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backend = b
+}
+
+func (s *Storage) Backend() Backend {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.backend
+}
+
+// Encrypt seals data with the vault key.
+func (s *Storage) Encrypt(data []byte) ([]byte, error) {
+	return s.encrypt(data)
+}
+
+func (s *Storage) Decrypt(data []byte) ([]byte, error) {
+	return s.decrypt(data)
+}
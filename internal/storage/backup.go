@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+)
+
+// SetBackupCount sets how many previous versions of the vault file Save
+// keeps next to it, as data.enc.1 (newest) up to data.enc.N.
+func (s *Storage) SetBackupCount(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backups = n
+}
+
+func rotateBackups(path string, count int) error {
+	if count <= 0 {
+		return nil
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	backup := func(i int) string { return fmt.Sprintf("%s.%d", path, i) }
+	if err := os.Remove(backup(count)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := count - 1; i >= 1; i-- {
+		if err := os.Rename(backup(i), backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.WriteFile(backup(1), current, 0600)
+}
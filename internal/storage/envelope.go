@@ -0,0 +1,412 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"gopass/internal/events"
+	"gopass/internal/models"
+	"gopass/internal/secmem"
+)
+
+// Entries are kept with their secret fields sealed under a data key of
+// their own, wrapped by the vault key. Listing and searching only need the
+// metadata; secrets are decrypted when an entry is revealed.
+
+var ErrNotRevealed = errors.New("entry has sealed secrets and new ones; reveal it before changing its secrets")
+
+type passwordSecrets struct {
+	Password string         `json:"password"`
+	Note     string         `json:"note"`
+	Fields   []models.Field `json:"fields,omitempty"`
+}
+
+type noteSecrets struct {
+	Content string         `json:"content"`
+	Fields  []models.Field `json:"fields,omitempty"`
+}
+
+type sshKeySecrets struct {
+	PrivateKey string `json:"private_key"`
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealWith(key, plain []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, nil), nil
+}
+
+func openWith(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+// openSecure is openWith decrypting into secure memory, for data keys and
+// entry secrets. The caller destroys the buffer.
+func openSecure(key, data []byte) (*secmem.Buffer, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, errors.New("ciphertext too short")
+	}
+	buf := secmem.New(len(data) - gcm.NonceSize() - gcm.Overhead())
+	if _, err := gcm.Open(buf.Bytes()[:0], data[:gcm.NonceSize()], data[gcm.NonceSize():], nil); err != nil {
+		buf.Destroy()
+		return nil, err
+	}
+	return buf, nil
+}
+
+// sealSecrets encrypts secrets under the data key of existing, or a new
+// one when existing is nil.
+func sealSecrets[T any](vaultKey []byte, secrets T, existing *models.Sealed) (*models.Sealed, error) {
+	var dataKey *secmem.Buffer
+	var wrapped []byte
+	var err error
+	if existing != nil {
+		wrapped = existing.Key
+		if dataKey, err = openSecure(vaultKey, wrapped); err != nil {
+			return nil, err
+		}
+	} else {
+		dataKey = secmem.New(32)
+		if _, err := io.ReadFull(rand.Reader, dataKey.Bytes()); err != nil {
+			dataKey.Destroy()
+			return nil, err
+		}
+		if wrapped, err = sealWith(vaultKey, dataKey.Bytes()); err != nil {
+			dataKey.Destroy()
+			return nil, err
+		}
+	}
+	defer dataKey.Destroy()
+
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, err
+	}
+	defer secmem.Wipe(plain)
+	data, err := sealWith(dataKey.Bytes(), plain)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Sealed{Key: wrapped, Data: data}, nil
+}
+
+func openSecrets[T any](vaultKey []byte, sealed *models.Sealed) (T, error) {
+	var secrets T
+	dataKey, err := openSecure(vaultKey, sealed.Key)
+	if err != nil {
+		return secrets, err
+	}
+	defer dataKey.Destroy()
+	plain, err := openSecure(dataKey.Bytes(), sealed.Data)
+	if err != nil {
+		return secrets, err
+	}
+	defer plain.Destroy()
+	return secrets, json.Unmarshal(plain.Bytes(), &secrets)
+}
+
+func sealPassword(key []byte, p models.Password, existing *models.Sealed) (models.Password, error) {
+	sealed, err := sealSecrets(key, passwordSecrets{Password: p.Password, Note: p.Note, Fields: p.Fields}, existing)
+	if err != nil {
+		return p, err
+	}
+	p.Password, p.Note, p.Fields, p.Sealed = "", "", nil, sealed
+	return p, nil
+}
+
+func revealPassword(key []byte, p models.Password) (models.Password, error) {
+	if p.Sealed == nil {
+		return p, nil
+	}
+	secrets, err := openSecrets[passwordSecrets](key, p.Sealed)
+	if err != nil {
+		return p, err
+	}
+	p.Password, p.Note, p.Fields, p.Sealed = secrets.Password, secrets.Note, secrets.Fields, nil
+	return p, nil
+}
+
+// updatedPassword prepares p to replace existing. Entries that were not
+// revealed keep the stored secrets, so metadata can be changed without
+// decrypting anything.
+func updatedPassword(key []byte, p, existing models.Password) (models.Password, error) {
+	if p.Sealed == nil {
+		return sealPassword(key, p, existing.Sealed)
+	}
+	if p.Password != "" || p.Note != "" || len(p.Fields) > 0 {
+		return p, ErrNotRevealed
+	}
+	p.Sealed = existing.Sealed
+	return p, nil
+}
+
+func sealNote(key []byte, n models.Note, existing *models.Sealed) (models.Note, error) {
+	sealed, err := sealSecrets(key, noteSecrets{Content: n.Content, Fields: n.Fields}, existing)
+	if err != nil {
+		return n, err
+	}
+	n.Content, n.Fields, n.Sealed = "", nil, sealed
+	return n, nil
+}
+
+func revealNote(key []byte, n models.Note) (models.Note, error) {
+	if n.Sealed == nil {
+		return n, nil
+	}
+	secrets, err := openSecrets[noteSecrets](key, n.Sealed)
+	if err != nil {
+		return n, err
+	}
+	n.Content, n.Fields, n.Sealed = secrets.Content, secrets.Fields, nil
+	return n, nil
+}
+
+func updatedNote(key []byte, n, existing models.Note) (models.Note, error) {
+	if n.Sealed == nil {
+		return sealNote(key, n, existing.Sealed)
+	}
+	if n.Content != "" || len(n.Fields) > 0 {
+		return n, ErrNotRevealed
+	}
+	n.Sealed = existing.Sealed
+	return n, nil
+}
+
+func sealSSHKey(key []byte, k models.SSHKey, existing *models.Sealed) (models.SSHKey, error) {
+	k.PublicKey = ""
+	if signer, err := ssh.ParsePrivateKey([]byte(k.PrivateKey)); err == nil {
+		k.PublicKey = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+	}
+	sealed, err := sealSecrets(key, sshKeySecrets{PrivateKey: k.PrivateKey}, existing)
+	if err != nil {
+		return k, err
+	}
+	k.PrivateKey, k.Sealed = "", sealed
+	return k, nil
+}
+
+func revealSSHKey(key []byte, k models.SSHKey) (models.SSHKey, error) {
+	if k.Sealed == nil {
+		return k, nil
+	}
+	secrets, err := openSecrets[sshKeySecrets](key, k.Sealed)
+	if err != nil {
+		return k, err
+	}
+	k.PrivateKey, k.Sealed = secrets.PrivateKey, nil
+	return k, nil
+}
+
+func updatedSSHKey(key []byte, k, existing models.SSHKey) (models.SSHKey, error) {
+	if k.Sealed == nil {
+		return sealSSHKey(key, k, existing.Sealed)
+	}
+	if k.PrivateKey != "" {
+		return k, ErrNotRevealed
+	}
+	k.Sealed, k.PublicKey = existing.Sealed, existing.PublicKey
+	return k, nil
+}
+
+// sealAll seals entries that still carry plain secrets, as in vaults
+// written before entries were sealed or in imports.
+func sealAll(key []byte, data *models.ExportData) error {
+	var err error
+	for i, p := range data.Passwords {
+		if p.Sealed == nil {
+			if data.Passwords[i], err = sealPassword(key, p, nil); err != nil {
+				return err
+			}
+		}
+	}
+	for i, n := range data.Notes {
+		if n.Sealed == nil {
+			if data.Notes[i], err = sealNote(key, n, nil); err != nil {
+				return err
+			}
+		}
+	}
+	for i, k := range data.SSHKeys {
+		if k.Sealed == nil {
+			if data.SSHKeys[i], err = sealSSHKey(key, k, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hasSealed reports whether any entry of data is already sealed.
+func hasSealed(data *models.ExportData) bool {
+	for _, p := range data.Passwords {
+		if p.Sealed != nil {
+			return true
+		}
+	}
+	for _, n := range data.Notes {
+		if n.Sealed != nil {
+			return true
+		}
+	}
+	for _, k := range data.SSHKeys {
+		if k.Sealed != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// publishReveal reports a successful reveal once the lock is released.
+func (s *Storage) publishReveal(kind events.Kind, id string, err *error) {
+	if *err == nil {
+		s.events.Publish(events.Event{Type: events.Revealed, Kind: kind, ID: id})
+	}
+}
+
+// RevealPassword returns the password with its secrets decrypted.
+func (s *Storage) RevealPassword(id string) (_ models.Password, err error) {
+	defer s.publishReveal(events.KindPassword, id, &err)
+	var p models.Password
+	err = s.withKey(func(key []byte) (err error) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		for _, p = range s.passwords {
+			if p.ID == id {
+				p, err = revealPassword(key, p)
+				return err
+			}
+		}
+		return errors.New("password not found")
+	})
+	if err != nil {
+		return models.Password{}, err
+	}
+	return p, nil
+}
+
+func (s *Storage) RevealNote(id string) (_ models.Note, err error) {
+	defer s.publishReveal(events.KindNote, id, &err)
+	var n models.Note
+	err = s.withKey(func(key []byte) (err error) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		for _, n = range s.notes {
+			if n.ID == id {
+				n, err = revealNote(key, n)
+				return err
+			}
+		}
+		return errors.New("note not found")
+	})
+	if err != nil {
+		return models.Note{}, err
+	}
+	return n, nil
+}
+
+func (s *Storage) RevealSSHKey(id string) (_ models.SSHKey, err error) {
+	defer s.publishReveal(events.KindSSHKey, id, &err)
+	var k models.SSHKey
+	err = s.withKey(func(key []byte) (err error) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		for _, k = range s.sshKeys {
+			if k.ID == id {
+				k, err = revealSSHKey(key, k)
+				return err
+			}
+		}
+		return errors.New("ssh key not found")
+	})
+	if err != nil {
+		return models.SSHKey{}, err
+	}
+	return k, nil
+}
+
+// RekeyEntry gives the entry with id a new data key, so that copies of the
+// old one no longer open it.
+func (s *Storage) RekeyEntry(id string) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+	e, err := s.rekeyEntry(id)
+	if err != nil {
+		return err
+	}
+	return s.saveAndPublish(e)
+}
+
+func (s *Storage) rekeyEntry(id string) (e events.Event, err error) {
+	err = s.withKey(func(key []byte) error {
+		e, err = s.rekeyEntryWith(key, id)
+		return err
+	})
+	return e, err
+}
+
+func (s *Storage) rekeyEntryWith(key []byte, id string) (events.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := events.Event{Type: events.Updated, ID: id}
+	var err error
+	for i, p := range s.passwords {
+		if p.ID == id {
+			e.Kind = events.KindPassword
+			if p, err = revealPassword(key, p); err == nil {
+				s.passwords[i], err = sealPassword(key, p, nil)
+			}
+			return e, err
+		}
+	}
+	for i, n := range s.notes {
+		if n.ID == id {
+			e.Kind = events.KindNote
+			if n, err = revealNote(key, n); err == nil {
+				s.notes[i], err = sealNote(key, n, nil)
+			}
+			return e, err
+		}
+	}
+	for i, k := range s.sshKeys {
+		if k.ID == id {
+			e.Kind = events.KindSSHKey
+			if k, err = revealSSHKey(key, k); err == nil {
+				s.sshKeys[i], err = sealSSHKey(key, k, nil)
+			}
+			return e, err
+		}
+	}
+	return e, errors.New("entry not found")
+}
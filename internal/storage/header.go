@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Sealed vault files start with a header naming their format and cipher
+// suite. The header is authenticated as associated data, so it cannot be
+// changed or stripped without the file failing to open.
+const (
+	fileMagic      = "GOPASS"
+	fileVersion    = 1
+	fileHeaderSize = len(fileMagic) + 2
+)
+
+func hasHeader(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(fileMagic))
+}
+
+func fileHeader(suite Suite) []byte {
+	return append([]byte(fileMagic), fileVersion, byte(suite))
+}
+
+func sealFile(suite Suite, key, plain []byte) ([]byte, error) {
+	aead, err := suite.aead(key)
+	if err != nil {
+		return nil, err
+	}
+	header := fileHeader(suite)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out := append(header, nonce...)
+	return aead.Seal(out, nonce, plain, header), nil
+}
+
+// openFile decrypts a sealed vault file and reports the suite it was
+// sealed with.
+func openFile(key, data []byte) ([]byte, Suite, error) {
+	if !hasHeader(data) {
+		// Written before files had a header
+		plain, err := openWith(key, data)
+		return plain, AES256GCM, err
+	}
+	if len(data) < fileHeaderSize {
+		return nil, 0, errors.New("vault file too short")
+	}
+	header := data[:fileHeaderSize]
+	if v := header[len(fileMagic)]; v != fileVersion {
+		return nil, 0, fmt.Errorf("unsupported vault file version %d", v)
+	}
+	suite := Suite(header[len(fileMagic)+1])
+	aead, err := suite.aead(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	rest := data[fileHeaderSize:]
+	if len(rest) < aead.NonceSize() {
+		return nil, 0, errors.New("ciphertext too short")
+	}
+	plain, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], header)
+	return plain, suite, err
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+var (
+	ErrVaultInUse  = errors.New("vault in use")
+	errCorruptLock = errors.New("corrupt lock file")
+)
+
+// staleLockGrace is how long a lock file that cannot be read is taken to
+// be still being written. After that its owner must have crashed.
+const staleLockGrace = 10 * time.Second
+
+// LockOwner describes the process holding the advisory lock on a vault.
+type LockOwner struct {
+	PID      int       `json:"pid"`
+	Host     string    `json:"host"`
+	Acquired time.Time `json:"acquired"`
+}
+
+type VaultInUseError struct {
+	Owner LockOwner
+}
+
+func (e *VaultInUseError) Error() string {
+	return fmt.Sprintf("vault in use by PID %d on %s since %s",
+		e.Owner.PID, e.Owner.Host, e.Owner.Acquired.Format(time.RFC3339))
+}
+
+func (e *VaultInUseError) Is(target error) bool {
+	return target == ErrVaultInUse
+}
+
+type fileLock struct {
+	path  string
+	owner LockOwner
+}
+
+func acquireLock(path string) (*fileLock, error) {
+	host, _ := os.Hostname()
+	owner := LockOwner{
+		PID:      os.Getpid(),
+		Host:     host,
+		Acquired: time.Now(),
+	}
+
+	// Retry once after clearing a stale lock left by a crashed process
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			err = json.NewEncoder(f).Encode(owner)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return &fileLock{path: path, owner: owner}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		existing, err := readLockOwner(path)
+		switch {
+		case os.IsNotExist(err):
+			// Released in the meantime
+			continue
+		case errors.Is(err, errCorruptLock):
+			info, serr := os.Stat(path)
+			if serr != nil {
+				return nil, serr
+			}
+			if time.Since(info.ModTime()) < staleLockGrace {
+				return nil, fmt.Errorf("%w: %s is being written", ErrVaultInUse, path)
+			}
+		case err != nil:
+			return nil, err
+		case existing.Host != host || processAlive(existing.PID):
+			return nil, &VaultInUseError{Owner: existing}
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return nil, errors.New("could not acquire vault lock")
+}
+
+func readLockOwner(path string) (LockOwner, error) {
+	var owner LockOwner
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return owner, err
+	}
+	if err := json.Unmarshal(data, &owner); err != nil {
+		return owner, fmt.Errorf("%w %s: %v", errCorruptLock, path, err)
+	}
+	return owner, nil
+}
+
+func (l *fileLock) release() error {
+	// Never remove a lock that another process has taken over
+	owner, err := readLockOwner(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if owner.PID != l.owner.PID || owner.Host != l.owner.Host {
+		return nil
+	}
+	return os.Remove(l.path)
+}
+
+// Lock takes the advisory lock on the vault file. If another live process
+// holds it, a *VaultInUseError is returned and the caller may fall back to
+// OpenReadOnly.
+func (s *Storage) Lock() error {
+	lock, err := acquireLock(s.lockPath())
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lock = lock
+	s.readOnly = false
+	return nil
+}
+
+// OpenReadOnly marks the storage read-only; every mutation returns ErrReadOnly.
+func (s *Storage) OpenReadOnly() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readOnly = true
+}
+
+func (s *Storage) IsReadOnly() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readOnly
+}
+
+// Close stops watching for external changes, releases the vault lock and
+// wipes the key. The storage cannot be used afterwards.
+func (s *Storage) Close() error {
+	s.mu.Lock()
+	lock, watcher := s.lock, s.watcher
+	s.lock, s.watcher = nil, nil
+	s.mu.Unlock()
+	if watcher != nil {
+		watcher.Close()
+	}
+
+	// Wait for saves, reveals and reloads still using the key
+	s.keyMu.Lock()
+	s.keyBuf.Destroy()
+	s.keyBuf, s.key = nil, nil
+	s.keyMu.Unlock()
+
+	if lock != nil {
+		return lock.release()
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package storage
+
+import "syscall"
+
+const processQueryLimitedInformation = 0x1000
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	syscall.CloseHandle(h)
+	return true
+}
@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"gopass/internal/secmem"
+)
+
+// A padded vault file is a sealed length, the sealed vault and random
+// bytes up to the size of its sibling. Random bytes can be appended
+// without the key, so whichever vault of a pair is saved can keep the
+// other one the same size.
+//
+// Every padded vault has a sibling: until a decoy is saved there, it holds
+// filler in the same format, sealed with a key only this vault derives. So
+// the files show nothing of whether a duress PIN is set.
+
+const (
+	// padBlock rounds padded files up so small edits do not show.
+	padBlock   = 64 << 10
+	headerSize = 12 + 8 + 16
+)
+
+// SetPadding pairs the vault file with sibling, the other vault of a
+// duress pair or filler looking like one, keeping both the same size, age
+// and format so neither can be told from the other. Backups are kept of
+// both files at once.
+func (s *Storage) SetPadding(sibling string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sibling = sibling
+}
+
+func (s *Storage) padded() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sibling != ""
+}
+
+// lockPath is shared by both vaults of a pair, so the lock does not show
+// which one is open.
+func (s *Storage) lockPath() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.sibling != "" && s.sibling < s.path {
+		return s.sibling + ".lock"
+	}
+	return s.path + ".lock"
+}
+
+func (s *Storage) pad(sealed []byte) ([]byte, error) {
+	size := int64(headerSize + len(sealed))
+	size = (size + padBlock - 1) / padBlock * padBlock
+	if info, err := os.Stat(s.sibling); err == nil && info.Size() > size {
+		size = info.Size()
+	}
+	var out []byte
+	err := s.withKey(func(key []byte) (err error) {
+		out, err = padWith(key, sealed, size)
+		return err
+	})
+	return out, err
+}
+
+func padWith(key, sealed []byte, size int64) ([]byte, error) {
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(sealed)))
+	header, err := sealWith(key, length[:])
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, size)
+	copy(out, header)
+	copy(out[headerSize:], sealed)
+	if _, err := rand.Read(out[headerSize+len(sealed):]); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// unpad returns the sealed vault inside a padded file. Files written
+// before the vault was padded are returned as they are.
+func (s *Storage) unpad(data []byte) ([]byte, error) {
+	if len(data) < headerSize {
+		return nil, errors.New("vault file too short")
+	}
+	var length []byte
+	err := s.withKey(func(key []byte) (err error) {
+		length, err = openWith(key, data[:headerSize])
+		return err
+	})
+	if err != nil {
+		// Not ours, or not padded yet: opening the vault tells which
+		return data, nil
+	}
+	n := binary.BigEndian.Uint64(length)
+	if n > uint64(len(data)-headerSize) {
+		return nil, errors.New("vault file truncated")
+	}
+	return data[headerSize : headerSize+int(n)], nil
+}
+
+// matchSibling grows the sibling to size with random bytes and gives both
+// files the same modification time. A missing sibling is written as
+// filler.
+func (s *Storage) matchSibling(size int64) error {
+	f, err := os.OpenFile(s.sibling, os.O_WRONLY|os.O_APPEND, 0600)
+	if os.IsNotExist(err) {
+		err = s.writeFiller(size)
+		if err == nil {
+			f, err = os.OpenFile(s.sibling, os.O_WRONLY|os.O_APPEND, 0600)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err == nil && info.Size() < size {
+		_, err = io.CopyN(f, rand.Reader, size-info.Size())
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := os.Chtimes(s.path, now, now); err != nil {
+		return err
+	}
+	return os.Chtimes(s.sibling, now, now)
+}
+
+// fillerKey seals the filler of the vault keyed with key.
+func fillerKey(key []byte) []byte {
+	sum := sha256.Sum256(append([]byte("gopass filler"), key...))
+	return sum[:]
+}
+
+// writeFiller writes the sibling as an empty vault of size bytes, sealed
+// with the filler key and the vault's suite.
+func (s *Storage) writeFiller(size int64) error {
+	suite := s.Suite()
+	var out []byte
+	err := s.withKey(func(key []byte) error {
+		filler := fillerKey(key)
+		defer secmem.Wipe(filler)
+		sealed, err := sealFile(suite, filler, []byte("{}"))
+		if err != nil {
+			return err
+		}
+		out, err = padWith(filler, sealed, size)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.sibling, out, 0600)
+}
+
+// renewFiller rewrites the filler after the vault's key or suite changed.
+func (s *Storage) renewFiller() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	if err := s.writeFiller(info.Size()); err != nil {
+		return err
+	}
+	return s.matchSibling(info.Size())
+}
+
+// siblingIsFiller reports whether the sibling holds this vault's filler
+// rather than another vault.
+func (s *Storage) siblingIsFiller() bool {
+	filler := false
+	s.withKey(func(key []byte) error {
+		filler = IsFiller(s.sibling, key)
+		return nil
+	})
+	return filler
+}
+
+// IsFiller reports whether the file at path is missing or holds the filler
+// of the vault keyed with key, rather than another vault.
+func IsFiller(path string, key []byte) bool {
+	header, err := readHeader(path)
+	if os.IsNotExist(err) {
+		return true
+	}
+	filler := fillerKey(key)
+	defer secmem.Wipe(filler)
+	_, err = openWith(filler, header)
+	return err == nil
+}
+
+// OpensWith reports whether the padded vault file at path is sealed with
+// key.
+func OpensWith(path string, key []byte) bool {
+	header, err := readHeader(path)
+	if err != nil {
+		return false
+	}
+	_, err = openWith(key, header)
+	return err == nil
+}
+
+func readHeader(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	header := make([]byte, headerSize)
+	_, err = io.ReadFull(f, header)
+	return header, err
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Every save raises the vault's revision, which is sealed inside the vault
+// file. The highest revision seen is remembered in a local file, so a
+// vault file replaced with an older copy shows as a rollback on Load.
+//
+// The remembered revision is sealed too, one line per vault key: both
+// vaults of a duress pair keep theirs in the same file. A padded vault
+// always has two lines, filling the other one with random bytes, so the
+// file looks the same whether or not there is a decoy.
+
+// Rollback describes a vault file older than one seen before.
+type Rollback struct {
+	Seen   uint64
+	Loaded uint64
+	// Err is set when the revision seen before could not be read, so a
+	// rollback cannot be ruled out.
+	Err error
+}
+
+var (
+	errCorruptSeen = errors.New("the record of revisions seen is corrupt")
+	errHeaderless  = errors.New("vault file has no header, though this machine saw it with one; it may have been replaced with an older copy")
+)
+
+// sealedSeenSize is the size of a sealed revision.
+const sealedSeenSize = 12 + 8 + 16
+
+// TrackRevision remembers the highest revision seen in the file at path.
+func (s *Storage) TrackRevision(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seenPath = path
+}
+
+// Revision is the revision of the vault as last loaded or saved.
+func (s *Storage) Revision() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.revision
+}
+
+// RolledBack reports whether the last Load found an older revision than
+// seen before. Saving accepts the older vault and clears it.
+func (s *Storage) RolledBack() (Rollback, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.rollback == nil {
+		return Rollback{}, false
+	}
+	return *s.rollback, true
+}
+
+// loaded notes the revision of a freshly loaded vault file.
+func (s *Storage) loaded(revision uint64) {
+	s.withKey(func(key []byte) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.revision, s.rollback = revision, nil
+		if s.seenPath == "" {
+			return nil
+		}
+		seen, err := readSeen(s.seenPath, key)
+		switch {
+		case err != nil:
+			s.rollback = &Rollback{Loaded: revision, Err: err}
+		case revision < seen:
+			s.rollback = &Rollback{Seen: seen, Loaded: revision}
+		case revision > seen:
+			// Best effort: a read-only vault still opens
+			index, lines := s.seenLine()
+			writeSeen(s.seenPath, key, revision, index, lines)
+		}
+		return nil
+	})
+}
+
+// checkHeader refuses a vault file without a header once a revision of
+// the vault was seen, as all of those were written with one.
+func (s *Storage) checkHeader(data []byte) error {
+	if hasHeader(data) {
+		return nil
+	}
+	return s.withKey(func(key []byte) error {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		if s.seenPath == "" {
+			return nil
+		}
+		seen, err := readSeen(s.seenPath, key)
+		if err != nil || seen > 0 {
+			return errHeaderless
+		}
+		return nil
+	})
+}
+
+// nextRevision is the revision the next save writes, above anything seen.
+func (s *Storage) nextRevision() (next uint64) {
+	s.withKey(func(key []byte) error {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		next = s.revision
+		if s.seenPath != "" {
+			if seen, err := readSeen(s.seenPath, key); err == nil && seen > next {
+				next = seen
+			}
+		}
+		return nil
+	})
+	return next + 1
+}
+
+// saved notes the revision just written.
+func (s *Storage) saved(revision uint64) error {
+	return s.withKey(func(key []byte) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.revision, s.rollback = revision, nil
+		if s.seenPath == "" {
+			return nil
+		}
+		index, lines := s.seenLine()
+		return writeSeen(s.seenPath, key, revision, index, lines)
+	})
+}
+
+// seenLine is the line of the revision file the vault writes to, and how
+// many lines the file has. The two vaults of a pair take the line their
+// place in the pair gives them. The caller holds s.mu.
+func (s *Storage) seenLine() (index, lines int) {
+	switch {
+	case s.sibling == "":
+		return 0, 1
+	case s.path < s.sibling:
+		return 0, 2
+	}
+	return 1, 2
+}
+
+// seenLines returns the lines of the file at path, and the revision in
+// the one sealed with key. A missing file is a vault never seen here.
+func seenLines(path string, key []byte) (lines [][]byte, seen uint64, ours int, err error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, 0, -1, nil
+	}
+	if err != nil {
+		return nil, 0, -1, err
+	}
+	ours = -1
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := append([]byte{}, scanner.Bytes()...)
+		lines = append(lines, line)
+		sealed, err := base64.StdEncoding.DecodeString(string(line))
+		if err != nil || len(sealed) != sealedSeenSize {
+			return nil, 0, -1, errCorruptSeen
+		}
+		// Lines sealed with other keys belong to the other vault of a pair
+		if plain, err := openWith(key, sealed); err == nil && len(plain) == 8 {
+			seen, ours = binary.BigEndian.Uint64(plain), len(lines)-1
+		}
+	}
+	return lines, seen, ours, scanner.Err()
+}
+
+func readSeen(path string, key []byte) (uint64, error) {
+	_, seen, _, err := seenLines(path, key)
+	return seen, err
+}
+
+// writeSeen records revision in line index of the file at path, padding
+// it to n lines. A line of ours found elsewhere is swapped into place.
+func writeSeen(path string, key []byte, revision uint64, index, n int) error {
+	lines, _, ours, err := seenLines(path, key)
+	if errors.Is(err, errCorruptSeen) {
+		// Saving accepts the vault as it is, so start the record afresh
+		lines, ours, err = nil, -1, nil
+	}
+	if err != nil {
+		return err
+	}
+	var plain [8]byte
+	binary.BigEndian.PutUint64(plain[:], revision)
+	sealed, err := sealWith(key, plain[:])
+	if err != nil {
+		return err
+	}
+	for len(lines) < n || len(lines) <= index {
+		random := make([]byte, sealedSeenSize)
+		if _, err := rand.Read(random); err != nil {
+			return err
+		}
+		lines = append(lines, []byte(base64.StdEncoding.EncodeToString(random)))
+	}
+	if ours >= 0 && ours != index {
+		lines[ours] = lines[index]
+	}
+	lines[index] = []byte(base64.StdEncoding.EncodeToString(sealed))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(bytes.Join(lines, []byte("\n")), '\n'), 0600)
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"gopass/internal/events"
+	"gopass/internal/models"
+	"gopass/internal/secmem"
+)
+
+// Role is what a member of a team vault may do. Personal vaults have the
+// empty role, which allows everything.
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+)
+
+var Roles = []Role{RoleOwner, RoleEditor, RoleViewer}
+
+var ErrPermission = errors.New("your role does not allow this change")
+
+func ParseRole(s string) (Role, error) {
+	for _, r := range Roles {
+		if string(r) == s {
+			return r, nil
+		}
+	}
+	return "", fmt.Errorf("unknown role %q", s)
+}
+
+// CanEdit reports whether the role may change entries.
+func (r Role) CanEdit() bool {
+	return r != RoleViewer
+}
+
+// CanManage reports whether the role may change who has access.
+func (r Role) CanManage() bool {
+	return r == RoleOwner || r == ""
+}
+
+// SetRole limits what this storage allows to what role may do.
+func (s *Storage) SetRole(r Role) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.role = r
+}
+
+func (s *Storage) Role() Role {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.role
+}
+
+// writable is checked by every mutation.
+func (s *Storage) writable() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if !s.role.CanEdit() {
+		return ErrPermission
+	}
+	return nil
+}
+
+// Rekey moves the vault to key, as when a team vault rotates its key after
+// a member leaves. Every entry is re-encrypted under a new data key, so
+// data keys kept from before stop opening it. When the vault cannot be
+// saved it stays under its old key.
+func (s *Storage) Rekey(key []byte) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+	if !s.Role().CanManage() {
+		return ErrPermission
+	}
+	if s.Backend() != nil {
+		return errors.New("only file vaults can be rekeyed")
+	}
+	// Filler is sealed with a key derived from the vault key, so it moves too
+	filler := s.padded() && s.siblingIsFiller()
+	old, err := s.resealAll(key)
+	if err != nil {
+		return err
+	}
+	if err := s.save(events.Event{}); err != nil {
+		s.restore(old)
+		return err
+	}
+	old.keyBuf.Destroy()
+	if filler {
+		return s.renewFiller()
+	}
+	return nil
+}
+
+// sealedState is the vault key and entries a rekey replaced.
+type sealedState struct {
+	keyBuf    *secmem.Buffer
+	passwords []models.Password
+	notes     []models.Note
+	sshKeys   []models.SSHKey
+}
+
+// resealAll re-encrypts every entry under a new data key wrapped with key
+// and makes key the vault key. It returns what it replaced.
+func (s *Storage) resealAll(key []byte) (sealedState, error) {
+	// Nothing may use the old key while it is replaced
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+	if s.key == nil {
+		return sealedState{}, ErrClosed
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	passwords := make([]models.Password, len(s.passwords))
+	notes := make([]models.Note, len(s.notes))
+	sshKeys := make([]models.SSHKey, len(s.sshKeys))
+	for i, p := range s.passwords {
+		p, err := revealPassword(s.key, p)
+		if err == nil {
+			p, err = sealPassword(key, p, nil)
+		}
+		if err != nil {
+			return sealedState{}, err
+		}
+		passwords[i] = p
+	}
+	for i, n := range s.notes {
+		n, err := revealNote(s.key, n)
+		if err == nil {
+			n, err = sealNote(key, n, nil)
+		}
+		if err != nil {
+			return sealedState{}, err
+		}
+		notes[i] = n
+	}
+	for i, k := range s.sshKeys {
+		k, err := revealSSHKey(s.key, k)
+		if err == nil {
+			k, err = sealSSHKey(key, k, nil)
+		}
+		if err != nil {
+			return sealedState{}, err
+		}
+		sshKeys[i] = k
+	}
+	old := sealedState{keyBuf: s.keyBuf, passwords: s.passwords, notes: s.notes, sshKeys: s.sshKeys}
+	s.keyBuf = secmem.Copy(key)
+	s.passwords, s.notes, s.sshKeys, s.key = passwords, notes, sshKeys, s.keyBuf.Bytes()
+	return old, nil
+}
+
+// restore puts back what resealAll replaced.
+func (s *Storage) restore(old sealedState) {
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keyBuf.Destroy()
+	s.keyBuf = old.keyBuf
+	s.passwords, s.notes, s.sshKeys, s.key = old.passwords, old.notes, old.sshKeys, old.keyBuf.Bytes()
+}
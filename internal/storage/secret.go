@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"gopass/internal/events"
+	"gopass/internal/models"
+	"gopass/internal/secmem"
+)
+
+// The Reveal methods copy secrets into ordinary strings, which stay on the
+// heap until they are collected. Callers that only pass a secret on use
+// the Secret methods instead, which decode it straight into secure memory.
+
+var errBadSecret = errors.New("sealed secret is not a string")
+
+// secretString is a JSON string decoded into secure memory. json.Unmarshal
+// hands UnmarshalJSON a slice of its input, which is secure memory too.
+type secretString struct {
+	buf *secmem.Buffer
+}
+
+func (s *secretString) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	n, err := unquote(nil, data)
+	if err != nil {
+		return err
+	}
+	buf := secmem.New(n)
+	unquote(buf.Bytes(), data)
+	s.buf.Destroy()
+	s.buf = buf
+	return nil
+}
+
+// unquote decodes the JSON string src into dst and returns its length. A
+// nil dst only measures it.
+func unquote(dst, src []byte) (int, error) {
+	if len(src) < 2 || src[0] != '"' || src[len(src)-1] != '"' {
+		return 0, errBadSecret
+	}
+	src = src[1 : len(src)-1]
+	n := 0
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if c == '\\' {
+			if i++; i == len(src) {
+				return 0, errBadSecret
+			}
+			switch src[i] {
+			case '"', '\\', '/':
+				c = src[i]
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'u':
+				r, ok := hex4(src[i+1:])
+				if !ok {
+					return 0, errBadSecret
+				}
+				i += 4
+				if high := r; utf16.IsSurrogate(high) {
+					r = utf8.RuneError
+					if i+2 < len(src) && src[i+1] == '\\' && src[i+2] == 'u' {
+						if low, ok := hex4(src[i+3:]); ok {
+							if d := utf16.DecodeRune(high, low); d != utf8.RuneError {
+								r = d
+								i += 6
+							}
+						}
+					}
+				}
+				var enc [utf8.UTFMax]byte
+				w := utf8.EncodeRune(enc[:], r)
+				if dst != nil {
+					copy(dst[n:], enc[:w])
+				}
+				secmem.Wipe(enc[:])
+				n += w
+				continue
+			default:
+				return 0, errBadSecret
+			}
+		}
+		if dst != nil {
+			dst[n] = c
+		}
+		n++
+	}
+	return n, nil
+}
+
+func hex4(b []byte) (rune, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+	var r rune
+	for _, c := range b[:4] {
+		switch {
+		case '0' <= c && c <= '9':
+			c -= '0'
+		case 'a' <= c && c <= 'f':
+			c -= 'a' - 10
+		case 'A' <= c && c <= 'F':
+			c -= 'A' - 10
+		default:
+			return 0, false
+		}
+		r = r<<4 | rune(c)
+	}
+	return r, true
+}
+
+type passwordSecret struct {
+	Password secretString `json:"password"`
+}
+
+type sshKeySecret struct {
+	PrivateKey secretString `json:"private_key"`
+}
+
+// openSecret decrypts sealed into v, whose field is the secret wanted.
+func openSecret(vaultKey []byte, sealed *models.Sealed, v any, field *secretString) (*secmem.Buffer, error) {
+	dataKey, err := openSecure(vaultKey, sealed.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer dataKey.Destroy()
+	plain, err := openSecure(dataKey.Bytes(), sealed.Data)
+	if err != nil {
+		return nil, err
+	}
+	defer plain.Destroy()
+	if err := json.Unmarshal(plain.Bytes(), v); err != nil {
+		field.buf.Destroy()
+		return nil, err
+	}
+	if field.buf == nil {
+		return secmem.New(0), nil
+	}
+	return field.buf, nil
+}
+
+// PasswordSecret returns the password of the entry with id in secure
+// memory. The caller destroys it.
+func (s *Storage) PasswordSecret(id string) (_ *secmem.Buffer, err error) {
+	defer s.publishReveal(events.KindPassword, id, &err)
+	var buf *secmem.Buffer
+	err = s.withKey(func(key []byte) (err error) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		for _, p := range s.passwords {
+			if p.ID != id {
+				continue
+			}
+			if p.Sealed == nil {
+				buf = secmem.Copy([]byte(p.Password))
+				return nil
+			}
+			var secret passwordSecret
+			buf, err = openSecret(key, p.Sealed, &secret, &secret.Password)
+			return err
+		}
+		return errors.New("password not found")
+	})
+	return buf, err
+}
+
+// SSHKeySecret returns the private key with id in secure memory. The
+// caller destroys it.
+func (s *Storage) SSHKeySecret(id string) (_ *secmem.Buffer, err error) {
+	defer s.publishReveal(events.KindSSHKey, id, &err)
+	var buf *secmem.Buffer
+	err = s.withKey(func(key []byte) (err error) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		for _, k := range s.sshKeys {
+			if k.ID != id {
+				continue
+			}
+			if k.Sealed == nil {
+				buf = secmem.Copy([]byte(k.PrivateKey))
+				return nil
+			}
+			var secret sshKeySecret
+			buf, err = openSecret(key, k.Sealed, &secret, &secret.PrivateKey)
+			return err
+		}
+		return errors.New("ssh key not found")
+	})
+	return buf, err
+}
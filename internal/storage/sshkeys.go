@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"errors"
+
+	"gopass/internal/events"
+	"gopass/internal/models"
+)
+
+func (s *Storage) AddSSHKey(k models.SSHKey) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	err := s.withKey(func(key []byte) (err error) {
+		k, err = sealSSHKey(key, k, nil)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.sshKeys = append(s.sshKeys, k)
+	}()
+
+	return s.saveAndPublish(events.Event{Type: events.Added, Kind: events.KindSSHKey, ID: k.ID})
+}
+
+func (s *Storage) UpdateSSHKey(k models.SSHKey) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	var found bool
+	err := s.withKey(func(key []byte) (err error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, existing := range s.sshKeys {
+			if existing.ID == k.ID {
+				if k, err = updatedSSHKey(key, k, existing); err == nil {
+					s.sshKeys[i] = k
+				}
+				found = true
+				break
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("ssh key not found")
+	}
+	return s.saveAndPublish(events.Event{Type: events.Updated, Kind: events.KindSSHKey, ID: k.ID})
+}
+
+func (s *Storage) DeleteSSHKey(id string) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	var found bool
+	func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, k := range s.sshKeys {
+			if k.ID == id {
+				s.sshKeys = append(s.sshKeys[:i], s.sshKeys[i+1:]...)
+				found = true
+				break
+			}
+		}
+	}()
+
+	if !found {
+		return errors.New("ssh key not found")
+	}
+	return s.saveAndPublish(events.Event{Type: events.Deleted, Kind: events.KindSSHKey, ID: id})
+}
+
+// GetSSHKeys lists the keys without their private keys; see RevealSSHKey.
+func (s *Storage) GetSSHKeys() []models.SSHKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]models.SSHKey{}, s.sshKeys...)
+}
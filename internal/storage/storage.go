@@ -1,76 +1,134 @@
 package storage
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/json"
 	"errors"
-	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"gopass/internal/events"
 	"gopass/internal/models"
+	"gopass/internal/secmem"
+)
+
+var (
+	ErrReadOnly = errors.New("vault is open read-only")
+	ErrClosed   = errors.New("vault is closed")
+	// ErrUnsavedChanges is returned by Reload while changes made in memory
+	// are still being saved.
+	ErrUnsavedChanges = errors.New("vault has unsaved changes")
 )
 
 type Storage struct {
 	passwords []models.Password
 	notes     []models.Note
+	sshKeys   []models.SSHKey
 	key       []byte
+	keyBuf    *secmem.Buffer
+	keyMu     sync.RWMutex
+	path      string
+	readOnly  bool
+	role      Role
+	lock      *fileLock
+	watcher   *fsnotify.Watcher
+	lastHash  [32]byte
+	synced    models.ExportData
+	backups   int
+	backend   Backend
+	sibling   string
+	suite     Suite
+	revision  uint64
+	seenPath  string
+	rollback  *Rollback
+	events    *events.Bus
 	mu        sync.RWMutex
 }
 
 func NewStorage(pin string) *Storage {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		configDir = "."
+	}
+	return NewStorageAt(filepath.Join(configDir, "gopass", "data.enc"), pin)
+}
+
+// NewStorageAt opens the vault stored in the file at path.
+func NewStorageAt(path, pin string) *Storage {
 	// Use PIN to derive encryption key
 	key := sha256.Sum256([]byte(pin))
+	return NewStorageWithKey(path, key[:])
+}
+
+// NewStorageWithKey opens the vault at path with an already derived key.
+// The storage keeps a copy of key in secure memory until Close.
+func NewStorageWithKey(path string, key []byte) *Storage {
+	keyBuf := secmem.Copy(key)
 	return &Storage{
 		passwords: make([]models.Password, 0),
 		notes:     make([]models.Note, 0),
-		key:       key[:],
+		key:       keyBuf.Bytes(),
+		keyBuf:    keyBuf,
+		path:      path,
+		events:    events.NewBus(),
 	}
 }
 
-func (s *Storage) encrypt(data []byte) ([]byte, error) {
-	block, err := aes.NewCipher(s.key)
-	if err != nil {
-		return nil, err
-	}
-
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, err
-	}
-
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil, err
-	}
+func (s *Storage) Path() string {
+	return s.path
+}
 
-	return gcm.Seal(nonce, nonce, data, nil), nil
+// Events returns the bus on which every change to the vault is published.
+func (s *Storage) Events() *events.Bus {
+	return s.events
 }
 
-func (s *Storage) decrypt(data []byte) ([]byte, error) {
-	block, err := aes.NewCipher(s.key)
-	if err != nil {
-		return nil, err
+// withKey runs fn with the vault key. Close and Rekey wait for fn to
+// return before they wipe the key. fn must not call withKey again, and
+// withKey is not called with mu held.
+func (s *Storage) withKey(fn func(key []byte) error) error {
+	s.keyMu.RLock()
+	defer s.keyMu.RUnlock()
+	if s.key == nil {
+		return ErrClosed
 	}
+	return fn(s.key)
+}
 
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, err
-	}
+func (s *Storage) encrypt(data []byte) (sealed []byte, err error) {
+	suite := s.Suite()
+	err = s.withKey(func(key []byte) error {
+		sealed, err = sealFile(suite, key, data)
+		return err
+	})
+	return sealed, err
+}
 
-	nonceSize := gcm.NonceSize()
-	if len(data) < nonceSize {
-		return nil, errors.New("ciphertext too short")
-	}
+func (s *Storage) decrypt(data []byte) (plain []byte, err error) {
+	err = s.withKey(func(key []byte) error {
+		plain, _, err = openFile(key, data)
+		return err
+	})
+	return plain, err
+}
 
-	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
-	return gcm.Open(nil, nonce, ciphertext, nil)
+// vaultFile is what the vault file holds: the entries and the revision,
+// which goes up with every save.
+type vaultFile struct {
+	Revision uint64 `json:"revision,omitempty"`
+	models.ExportData
 }
 
 func (s *Storage) Save() error {
+	return s.save(events.Event{})
+}
+
+// save persists the vault after change, the zero event for a plain Save.
+func (s *Storage) save(change events.Event) error {
 	// First get a copy of the data under lock
 	var data models.ExportData
 	func() {
@@ -79,11 +137,24 @@ func (s *Storage) Save() error {
 		data = models.ExportData{
 			Passwords: append([]models.Password{}, s.passwords...),
 			Notes:     append([]models.Note{}, s.notes...),
+			SSHKeys:   append([]models.SSHKey{}, s.sshKeys...),
 		}
 	}()
 
+	if err := s.writable(); err != nil {
+		return err
+	}
+	if b := s.Backend(); b != nil {
+		if err := b.Save(data, change); err != nil {
+			return err
+		}
+		s.markSynced(data)
+		return nil
+	}
+
 	// Then do the expensive operations without holding the lock
-	jsonData, err := json.Marshal(data)
+	revision := s.nextRevision()
+	jsonData, err := json.Marshal(vaultFile{Revision: revision, ExportData: data})
 	if err != nil {
 		return err
 	}
@@ -92,25 +163,113 @@ func (s *Storage) Save() error {
 	if err != nil {
 		return err
 	}
+	padded := s.padded()
+	if padded {
+		if encrypted, err = s.pad(encrypted); err != nil {
+			return err
+		}
+	}
 
-	configDir, err := os.UserConfigDir()
-	if err != nil {
+	// Remember what we wrote so the watcher can ignore our own changes
+	s.mu.Lock()
+	s.lastHash = sha256.Sum256(encrypted)
+	backups := s.backups
+	s.mu.Unlock()
+
+	if err := rotateBackups(s.path, backups); err != nil {
+		return err
+	}
+	if padded {
+		// The sibling keeps as many backups, so their count tells nothing
+		if err := rotateBackups(s.sibling, backups); err != nil {
+			return err
+		}
+	}
+	if err := writeFileAtomic(s.path, encrypted, 0600); err != nil {
 		return err
 	}
+	if padded {
+		if err := s.matchSibling(int64(len(encrypted))); err != nil {
+			return err
+		}
+	}
+	s.markSynced(data)
+	return s.saved(revision)
+}
 
-	dataPath := filepath.Join(configDir, "gopass", "data.enc")
-	return os.WriteFile(dataPath, encrypted, 0600)
+// markSynced notes data as what the vault file holds.
+func (s *Storage) markSynced(data models.ExportData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.synced = data
 }
 
-func (s *Storage) Load() error {
-	// First do all the expensive I/O operations without holding the lock
-	configDir, err := os.UserConfigDir()
+// unsaved reports whether the entries in memory differ from the ones last
+// loaded or saved. The caller holds s.mu.
+func (s *Storage) unsaved() bool {
+	return !sameEntries(s.synced.Passwords, s.passwords) ||
+		!sameEntries(s.synced.Notes, s.notes) ||
+		!sameEntries(s.synced.SSHKeys, s.sshKeys)
+}
+
+func sameEntries[T any](a, b []T) bool {
+	return len(a) == len(b) && (len(a) == 0 || reflect.DeepEqual(a, b))
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never observe a partially written vault.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
 	if err != nil {
 		return err
 	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func (s *Storage) Load() error {
+	return s.load(false)
+}
+
+// load reads the vault file. With keepUnsaved it leaves the vault alone and
+// returns ErrUnsavedChanges if there are changes in memory not saved yet.
+func (s *Storage) load(keepUnsaved bool) error {
+	if b := s.Backend(); b != nil {
+		data, err := b.Load()
+		if err != nil {
+			return err
+		}
+		if err := s.withKey(func(key []byte) error { return sealAll(key, &data) }); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if keepUnsaved && s.unsaved() {
+			return ErrUnsavedChanges
+		}
+		s.passwords = data.Passwords
+		s.notes = data.Notes
+		s.sshKeys = data.SSHKeys
+		s.synced = copyData(data)
+		return nil
+	}
 
-	dataPath := filepath.Join(configDir, "gopass", "data.enc")
-	encrypted, err := os.ReadFile(dataPath)
+	// First do all the expensive I/O operations without holding the lock
+	encrypted, err := os.ReadFile(s.path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil // No data file yet
@@ -118,26 +277,81 @@ func (s *Storage) Load() error {
 		return err
 	}
 
-	decrypted, err := s.decrypt(encrypted)
+	sealed := encrypted
+	if s.padded() {
+		if sealed, err = s.unpad(encrypted); err != nil {
+			return err
+		}
+	}
+	if err := s.checkHeader(sealed); err != nil {
+		return err
+	}
+	var file vaultFile
+	var suite Suite
+	err = s.withKey(func(key []byte) error {
+		decrypted, fileSuite, err := openFile(key, sealed)
+		if err != nil {
+			return err
+		}
+		// Vaults written before entries were sealed hold plain secrets
+		defer secmem.Wipe(decrypted)
+		if err := json.Unmarshal(decrypted, &file); err != nil {
+			return err
+		}
+		suite = fileSuite
+		return sealAll(key, &file.ExportData)
+	})
 	if err != nil {
 		return err
 	}
+	data := file.ExportData
 
-	var data models.ExportData
-	if err := json.Unmarshal(decrypted, &data); err != nil {
+	// Only lock when updating the in-memory state
+	err = func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if keepUnsaved && s.unsaved() {
+			return ErrUnsavedChanges
+		}
+		s.passwords = data.Passwords
+		s.notes = data.Notes
+		s.sshKeys = data.SSHKeys
+		s.suite = suite
+		s.lastHash = sha256.Sum256(encrypted)
+		s.synced = copyData(data)
+		return nil
+	}()
+	if err != nil {
 		return err
 	}
+	s.loaded(file.Revision)
+	return nil
+}
 
-	// Only lock when updating the in-memory state
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.passwords = data.Passwords
-	s.notes = data.Notes
+// saveAndPublish persists the vault and, once that succeeded, tells
+// subscribers about the change.
+func (s *Storage) saveAndPublish(e events.Event) error {
+	if err := s.save(e); err != nil {
+		return err
+	}
+	s.events.Publish(e)
 	return nil
 }
 
 // Password operations
 func (s *Storage) AddPassword(p models.Password) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	err := s.withKey(func(key []byte) (err error) {
+		p, err = sealPassword(key, p, nil)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
 	// First update memory
 	func() {
 		s.mu.Lock()
@@ -146,34 +360,47 @@ func (s *Storage) AddPassword(p models.Password) error {
 	}()
 	
 	// Then save to disk
-	return s.Save()
+	return s.saveAndPublish(events.Event{Type: events.Added, Kind: events.KindPassword, ID: p.ID})
 }
 
 func (s *Storage) UpdatePassword(p models.Password) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
 	var found bool
 	
 	// First update memory
-	func() {
+	err := s.withKey(func(key []byte) (err error) {
 		s.mu.Lock()
 		defer s.mu.Unlock()
 		for i, existing := range s.passwords {
 			if existing.ID == p.ID {
-				s.passwords[i] = p
+				if p, err = updatedPassword(key, p, existing); err == nil {
+					s.passwords[i] = p
+				}
 				found = true
 				break
 			}
 		}
-	}()
-	
+		return err
+	})
+	if err != nil {
+		return err
+	}
 	if !found {
 		return errors.New("password not found")
 	}
 	
 	// Then save to disk
-	return s.Save()
+	return s.saveAndPublish(events.Event{Type: events.Updated, Kind: events.KindPassword, ID: p.ID})
 }
 
 func (s *Storage) DeletePassword(id string) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
 	var found bool
 	
 	// First update memory
@@ -194,9 +421,11 @@ func (s *Storage) DeletePassword(id string) error {
 	}
 	
 	// Then save to disk
-	return s.Save()
+	return s.saveAndPublish(events.Event{Type: events.Deleted, Kind: events.KindPassword, ID: id})
 }
 
+// GetPasswords lists the passwords without their secrets; see
+// RevealPassword.
 func (s *Storage) GetPasswords() []models.Password {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -205,6 +434,18 @@ func (s *Storage) GetPasswords() []models.Password {
 
 // Note operations
 func (s *Storage) AddNote(n models.Note) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	err := s.withKey(func(key []byte) (err error) {
+		n, err = sealNote(key, n, nil)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
 	// First update memory
 	func() {
 		s.mu.Lock()
@@ -213,34 +454,47 @@ func (s *Storage) AddNote(n models.Note) error {
 	}()
 	
 	// Then save to disk
-	return s.Save()
+	return s.saveAndPublish(events.Event{Type: events.Added, Kind: events.KindNote, ID: n.ID})
 }
 
 func (s *Storage) UpdateNote(n models.Note) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
 	var found bool
 	
 	// First update memory
-	func() {
+	err := s.withKey(func(key []byte) (err error) {
 		s.mu.Lock()
 		defer s.mu.Unlock()
 		for i, existing := range s.notes {
 			if existing.ID == n.ID {
-				s.notes[i] = n
+				if n, err = updatedNote(key, n, existing); err == nil {
+					s.notes[i] = n
+				}
 				found = true
 				break
 			}
 		}
-	}()
-	
+		return err
+	})
+	if err != nil {
+		return err
+	}
 	if !found {
 		return errors.New("note not found")
 	}
 	
 	// Then save to disk
-	return s.Save()
+	return s.saveAndPublish(events.Event{Type: events.Updated, Kind: events.KindNote, ID: n.ID})
 }
 
 func (s *Storage) DeleteNote(id string) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
 	var found bool
 	
 	// First update memory
@@ -261,16 +515,17 @@ func (s *Storage) DeleteNote(id string) error {
 	}
 	
 	// Then save to disk
-	return s.Save()
+	return s.saveAndPublish(events.Event{Type: events.Deleted, Kind: events.KindNote, ID: id})
 }
 
+// GetNotes lists the notes without their content; see RevealNote.
 func (s *Storage) GetNotes() []models.Note {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return append([]models.Note{}, s.notes...)
 }
 
-// Search operations
+// Search matches entries by their metadata, so nothing is decrypted.
 func (s *Storage) Search(query string) models.SearchResult {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -279,14 +534,14 @@ func (s *Storage) Search(query string) models.SearchResult {
 
 	// Search passwords
 	for _, p := range s.passwords {
-		if contains(p.Name, query) || contains(p.Username, query) || contains(p.Note, query) {
+		if contains(p.Name, query) || contains(p.Username, query) || contains(p.URL, query) {
 			result.Passwords = append(result.Passwords, p)
 		}
 	}
 
 	// Search notes
 	for _, n := range s.notes {
-		if contains(n.Title, query) || contains(n.Content, query) {
+		if contains(n.Title, query) {
 			result.Notes = append(result.Notes, n)
 		}
 	}
@@ -294,35 +549,77 @@ func (s *Storage) Search(query string) models.SearchResult {
 	return result
 }
 
+// contains reports a case-insensitive substring match; an empty query
+// matches nothing.
 func contains(s, substr string) bool {
-	return len(substr) > 0 && len(s) > 0 && (s == substr || contains(s, substr))
+	return len(substr) > 0 && strings.Contains(strings.ToLower(s), strings.ToLower(substr))
 }
 
-// Export/Import operations
-func (s *Storage) Export() ([]byte, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// Export returns every entry with its secrets revealed.
+func (s *Storage) Export() (_ []byte, err error) {
+	defer func() {
+		if err == nil {
+			s.events.Publish(events.Event{Type: events.Exported})
+		}
+	}()
+	var data models.ExportData
+	err = s.withKey(func(key []byte) (err error) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
 
-	data := models.ExportData{
-		Passwords: s.passwords,
-		Notes:     s.notes,
+		data = models.ExportData{
+			Passwords: make([]models.Password, len(s.passwords)),
+			Notes:     make([]models.Note, len(s.notes)),
+			SSHKeys:   make([]models.SSHKey, len(s.sshKeys)),
+		}
+		for i, p := range s.passwords {
+			if data.Passwords[i], err = revealPassword(key, p); err != nil {
+				return err
+			}
+		}
+		for i, n := range s.notes {
+			if data.Notes[i], err = revealNote(key, n); err != nil {
+				return err
+			}
+		}
+		for i, k := range s.sshKeys {
+			if data.SSHKeys[i], err = revealSSHKey(key, k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-
 	return data.ToJSON()
 }
 
 func (s *Storage) Import(data []byte) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
 	var importData models.ExportData
 	if err := importData.FromJSON(data); err != nil {
 		return err
 	}
-
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	// Sealed entries belong to another vault key; exports are never sealed
+	if hasSealed(&importData) {
+		return errors.New("import contains sealed entries")
+	}
+	if err := s.withKey(func(key []byte) error { return sealAll(key, &importData) }); err != nil {
+		return err
+	}
 
 	// Merge imported data with existing data
-	s.passwords = append(s.passwords, importData.Passwords...)
-	s.notes = append(s.notes, importData.Notes...)
+	func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.passwords = append(s.passwords, importData.Passwords...)
+		s.notes = append(s.notes, importData.Notes...)
+		s.sshKeys = append(s.sshKeys, importData.SSHKeys...)
+	}()
 
-	return s.Save()
+	return s.saveAndPublish(events.Event{Type: events.Imported})
 }
@@ -0,0 +1,578 @@
+package storage
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopass/internal/events"
+	"gopass/internal/models"
+)
+
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	return NewStorageAt(filepath.Join(t.TempDir(), "data.enc"), "1234")
+}
+
+func TestLockRejectsSecondOwner(t *testing.T) {
+	first := newTestStorage(t)
+	require.NoError(t, first.Lock())
+	defer first.Close()
+
+	second := NewStorageAt(first.Path(), "1234")
+	err := second.Lock()
+	assert.True(t, errors.Is(err, ErrVaultInUse), "second lock should report vault in use")
+
+	var inUse *VaultInUseError
+	require.True(t, errors.As(err, &inUse))
+	assert.Equal(t, os.Getpid(), inUse.Owner.PID)
+
+	second.OpenReadOnly()
+	assert.Equal(t, ErrReadOnly, second.AddNote(models.Note{ID: "n1"}))
+
+	require.NoError(t, first.Close())
+	assert.NoError(t, second.Lock(), "lock should be free after Close")
+	second.Close()
+}
+
+func TestLockClearsStaleOwner(t *testing.T) {
+	s := newTestStorage(t)
+	host, _ := os.Hostname()
+
+	// A PID that cannot belong to a live process
+	stale, _ := json.Marshal(LockOwner{PID: 1 << 30, Host: host, Acquired: time.Now()})
+	require.NoError(t, os.WriteFile(s.Path()+".lock", stale, 0600))
+
+	assert.NoError(t, s.Lock())
+	s.Close()
+}
+
+func TestLockClearsCorruptLockAfterGrace(t *testing.T) {
+	s := newTestStorage(t)
+	lock := s.Path() + ".lock"
+	require.NoError(t, os.WriteFile(lock, nil, 0600))
+	assert.ErrorIs(t, s.Lock(), ErrVaultInUse, "its owner may still be writing it")
+
+	old := time.Now().Add(-time.Minute)
+	require.NoError(t, os.Chtimes(lock, old, old))
+	assert.NoError(t, s.Lock())
+	s.Close()
+}
+
+func TestReloadKeepsUnsavedChanges(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.AddPassword(models.Password{ID: "p1", Name: "mail"}))
+	other := NewStorageAt(s.Path(), "1234")
+	require.NoError(t, other.Load())
+	require.NoError(t, other.AddPassword(models.Password{ID: "p2", Name: "bank"}))
+
+	// As between an edit in memory and its save
+	s.mu.Lock()
+	s.notes = append(s.notes, models.Note{ID: "n1"})
+	s.mu.Unlock()
+	changed, err := s.Reload()
+	assert.ErrorIs(t, err, ErrUnsavedChanges)
+	assert.False(t, changed)
+	assert.Len(t, s.GetNotes(), 1)
+
+	require.NoError(t, s.Save())
+	changed, err = s.Reload()
+	require.NoError(t, err)
+	assert.False(t, changed, "the save replaced the file")
+}
+
+func TestReloadPicksUpExternalChanges(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.AddPassword(models.Password{ID: "p1", Name: "mail"}))
+
+	changed, err := s.Reload()
+	require.NoError(t, err)
+	assert.False(t, changed, "own writes should not count as external changes")
+
+	other := NewStorageAt(s.Path(), "1234")
+	require.NoError(t, other.Load())
+	require.NoError(t, other.AddPassword(models.Password{ID: "p2", Name: "bank"}))
+
+	changed, err = s.Reload()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Len(t, s.GetPasswords(), 2)
+}
+
+func TestWatchNotifiesOnExternalSave(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.Save())
+
+	notified := make(chan events.Event, 1)
+	s.Events().Subscribe(func(e events.Event) { notified <- e })
+	require.NoError(t, s.Watch())
+	defer s.Close()
+
+	other := NewStorageAt(s.Path(), "1234")
+	require.NoError(t, other.AddNote(models.Note{ID: "n1", Title: "wifi"}))
+
+	select {
+	case e := <-notified:
+		assert.Equal(t, events.Reloaded, e.Type)
+		assert.Len(t, s.GetNotes(), 1)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher did not report the external change")
+	}
+}
+
+func TestMutationsPublishEvents(t *testing.T) {
+	s := newTestStorage(t)
+
+	var got []events.Event
+	unsubscribe := s.Events().Subscribe(func(e events.Event) { got = append(got, e) })
+
+	require.NoError(t, s.AddPassword(models.Password{ID: "p1"}))
+	require.NoError(t, s.UpdatePassword(models.Password{ID: "p1", Name: "mail"}))
+	require.NoError(t, s.DeletePassword("p1"))
+	require.NoError(t, s.Import([]byte(`{"notes":[{"id":"n1","title":"wifi"}]}`)))
+	assert.Error(t, s.DeleteNote("missing"))
+
+	unsubscribe()
+	require.NoError(t, s.AddNote(models.Note{ID: "n2"}))
+
+	require.Len(t, got, 4)
+	assert.Equal(t, events.Event{Type: events.Added, Kind: events.KindPassword, ID: "p1", Time: got[0].Time}, got[0])
+	assert.Equal(t, events.Updated, got[1].Type)
+	assert.Equal(t, events.Deleted, got[2].Type)
+	assert.Equal(t, events.Imported, got[3].Type)
+	assert.Len(t, s.GetNotes(), 2)
+}
+
+func TestImportRefusesSealedEntries(t *testing.T) {
+	s := newTestStorage(t)
+	for _, data := range []string{
+		`{"passwords":[{"id":"p1","sealed":{"key":"AA==","data":"AA=="}}]}`,
+		`{"notes":[{"id":"n1","sealed":{"key":"AA==","data":"AA=="}}]}`,
+		`{"ssh_keys":[{"id":"k1","sealed":{"key":"AA==","data":"AA=="}}]}`,
+	} {
+		assert.Error(t, s.Import([]byte(data)), data)
+	}
+	assert.Empty(t, s.GetPasswords())
+	assert.Empty(t, s.GetNotes())
+	assert.Empty(t, s.GetSSHKeys())
+}
+
+func TestSaveKeepsConfiguredBackups(t *testing.T) {
+	s := newTestStorage(t)
+	s.SetBackupCount(2)
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, s.AddNote(models.Note{ID: string(rune('a' + i))}))
+	}
+
+	previous := NewStorageAt(s.Path()+".1", "1234")
+	require.NoError(t, previous.Load())
+	assert.Len(t, previous.GetNotes(), 3, "newest backup holds the version before the last save")
+
+	_, err := os.Stat(s.Path() + ".2")
+	assert.NoError(t, err)
+	_, err = os.Stat(s.Path() + ".3")
+	assert.True(t, os.IsNotExist(err), "older backups are pruned")
+}
+
+func TestSearchMatchesSubstringsIgnoringCase(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.AddPassword(models.Password{ID: "p1", Name: "GitHub", Username: "octo"}))
+	require.NoError(t, s.AddPassword(models.Password{ID: "p2", Name: "Bank"}))
+	require.NoError(t, s.AddNote(models.Note{ID: "n1", Title: "Wifi", Content: "guest network key"}))
+
+	result := s.Search("git")
+	require.Len(t, result.Passwords, 1)
+	assert.Equal(t, "p1", result.Passwords[0].ID)
+	assert.Len(t, s.Search("WIFI").Notes, 1)
+	assert.Empty(t, s.Search("network").Notes, "note content is sealed")
+	assert.Empty(t, s.Search("").Passwords)
+}
+
+func TestSSHKeysArePersisted(t *testing.T) {
+	s := newTestStorage(t)
+	key := models.SSHKey{ID: "k1", Name: "deploy", PrivateKey: "pem", Confirm: true, Lifetime: time.Hour}
+	require.NoError(t, s.AddSSHKey(key))
+
+	loaded := NewStorageAt(s.Path(), "1234")
+	require.NoError(t, loaded.Load())
+	revealed, err := loaded.RevealSSHKey("k1")
+	require.NoError(t, err)
+	assert.Equal(t, key, revealed)
+
+	require.NoError(t, s.DeleteSSHKey("k1"))
+	assert.Empty(t, s.GetSSHKeys())
+	assert.Error(t, s.DeleteSSHKey("k1"))
+}
+
+func TestRolesLimitChanges(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.AddPassword(models.Password{ID: "p1", Name: "mail"}))
+
+	s.SetRole(RoleViewer)
+	assert.ErrorIs(t, s.AddNote(models.Note{ID: "n1"}), ErrPermission)
+	assert.ErrorIs(t, s.DeletePassword("p1"), ErrPermission)
+	assert.Len(t, s.GetPasswords(), 1)
+
+	s.SetRole(RoleEditor)
+	require.NoError(t, s.AddNote(models.Note{ID: "n1"}))
+	assert.ErrorIs(t, s.Rekey(make([]byte, 32)), ErrPermission)
+
+	s.SetRole(RoleOwner)
+	key := make([]byte, 32)
+	require.NoError(t, s.Rekey(key))
+	loaded := NewStorageWithKey(s.Path(), key)
+	require.NoError(t, loaded.Load())
+	assert.Len(t, loaded.GetNotes(), 1)
+}
+
+func TestRekeyReplacesDataKeys(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.AddPassword(models.Password{ID: "p1", Name: "mail", Password: "s3cret"}))
+	oldKey := append([]byte{}, s.key...)
+	before := s.GetPasswords()[0].Sealed
+	dataKey, err := openSecure(oldKey, before.Key)
+	require.NoError(t, err)
+	defer dataKey.Destroy()
+
+	key := make([]byte, 32)
+	key[0] = 1
+	require.NoError(t, s.Rekey(key))
+	loaded := NewStorageWithKey(s.Path(), key)
+	require.NoError(t, loaded.Load())
+	after := loaded.GetPasswords()[0].Sealed
+	_, err = openWith(dataKey.Bytes(), after.Data)
+	assert.Error(t, err, "a data key kept from before no longer opens the entry")
+	_, err = openWith(oldKey, after.Key)
+	assert.Error(t, err)
+	p, err := loaded.RevealPassword("p1")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", p.Password)
+
+	// A failed save leaves the vault under its old key
+	require.NoError(t, os.Remove(s.Path()))
+	require.NoError(t, os.MkdirAll(filepath.Join(s.Path(), "in-the-way"), 0700))
+	assert.Error(t, s.Rekey(make([]byte, 32)))
+	p, err = s.RevealPassword("p1")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", p.Password)
+}
+
+func TestEntrySecretsAreSealed(t *testing.T) {
+	s := newTestStorage(t)
+	p := models.Password{ID: "p1", Name: "mail", Password: "s3cret", Fields: []models.Field{{Name: "pin", Value: "42"}}}
+	require.NoError(t, s.AddPassword(p))
+
+	listed := s.GetPasswords()[0]
+	assert.Equal(t, "mail", listed.Name)
+	assert.Empty(t, listed.Password)
+	assert.Empty(t, listed.Fields)
+	require.NotNil(t, listed.Sealed)
+
+	revealed, err := s.RevealPassword("p1")
+	require.NoError(t, err)
+	assert.Equal(t, p, revealed)
+
+	// Metadata changes keep the sealed secrets
+	listed.Name = "work mail"
+	require.NoError(t, s.UpdatePassword(listed))
+	revealed, err = s.RevealPassword("p1")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", revealed.Password)
+	assert.Equal(t, "work mail", revealed.Name)
+
+	listed.Password = "new"
+	assert.ErrorIs(t, s.UpdatePassword(listed), ErrNotRevealed)
+
+	revealed.Password = "new"
+	require.NoError(t, s.UpdatePassword(revealed))
+	before := s.GetPasswords()[0].Sealed
+	require.NoError(t, s.RekeyEntry("p1"))
+	after := s.GetPasswords()[0].Sealed
+	assert.NotEqual(t, before.Key, after.Key)
+
+	loaded := NewStorageAt(s.Path(), "1234")
+	require.NoError(t, loaded.Load())
+	revealed, err = loaded.RevealPassword("p1")
+	require.NoError(t, err)
+	assert.Equal(t, "new", revealed.Password)
+}
+
+func TestLoadSealsPlainVaults(t *testing.T) {
+	s := newTestStorage(t)
+	plain, err := json.Marshal(models.ExportData{Notes: []models.Note{{ID: "n1", Title: "wifi", Content: "key"}}})
+	require.NoError(t, err)
+	encrypted, err := s.encrypt(plain)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(s.Path(), encrypted, 0600))
+
+	require.NoError(t, s.Load())
+	assert.Empty(t, s.GetNotes()[0].Content)
+	n, err := s.RevealNote("n1")
+	require.NoError(t, err)
+	assert.Equal(t, "key", n.Content)
+}
+
+func TestClosedStorageCannotEncrypt(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.AddPassword(models.Password{ID: "p1", Name: "vpn", Password: "secret"}))
+	require.NoError(t, s.Close())
+
+	_, err := s.Encrypt([]byte("data"))
+	assert.Error(t, err)
+	_, err = s.RevealPassword("p1")
+	assert.Error(t, err)
+}
+
+func TestChangedHeaderFailsToOpen(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.AddPassword(models.Password{ID: "p1", Name: "mail"}))
+	data, err := os.ReadFile(s.Path())
+	require.NoError(t, err)
+	require.Equal(t, fileHeader(AES256GCM), data[:fileHeaderSize])
+
+	data[fileHeaderSize-1] ^= 1
+	require.NoError(t, os.WriteFile(s.Path(), data, 0600))
+	assert.Error(t, NewStorageAt(s.Path(), "1234").Load())
+}
+
+func TestLoadWarnsOfOlderRevision(t *testing.T) {
+	s := newTestStorage(t)
+	s.TrackRevision(filepath.Join(filepath.Dir(s.Path()), "revision.seen"))
+	require.NoError(t, s.AddPassword(models.Password{ID: "p1", Name: "mail"}))
+	old, err := os.ReadFile(s.Path())
+	require.NoError(t, err)
+	require.NoError(t, s.AddPassword(models.Password{ID: "p2", Name: "bank"}))
+	assert.Equal(t, uint64(2), s.Revision())
+
+	require.NoError(t, os.WriteFile(s.Path(), old, 0600))
+	require.NoError(t, s.Load())
+	rb, ok := s.RolledBack()
+	require.True(t, ok)
+	assert.Equal(t, Rollback{Seen: 2, Loaded: 1}, rb)
+
+	// Saving accepts the older vault, above the revision seen
+	require.NoError(t, s.AddPassword(models.Password{ID: "p3", Name: "shop"}))
+	_, ok = s.RolledBack()
+	assert.False(t, ok)
+	assert.Equal(t, uint64(3), s.Revision())
+	require.NoError(t, s.Load())
+	_, ok = s.RolledBack()
+	assert.False(t, ok)
+}
+
+func TestHeaderlessFileRefusedOnceRevisionSeen(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.AddPassword(models.Password{ID: "p1", Name: "mail"}))
+	data, err := os.ReadFile(s.Path())
+	require.NoError(t, err)
+	plain, _, err := openFile(s.key, data)
+	require.NoError(t, err)
+	legacy, err := sealWith(s.key, plain)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(s.Path(), legacy, 0600))
+
+	// A vault never seen here may still be in the old format
+	seen := filepath.Join(filepath.Dir(s.Path()), "revision.seen")
+	s.TrackRevision(seen)
+	require.NoError(t, s.Load())
+	require.NoError(t, s.AddPassword(models.Password{ID: "p2", Name: "bank"}))
+
+	require.NoError(t, os.WriteFile(s.Path(), legacy, 0600))
+	assert.ErrorIs(t, s.Load(), errHeaderless)
+}
+
+func TestLoadWarnsOfCorruptRevisionRecord(t *testing.T) {
+	s := newTestStorage(t)
+	seen := filepath.Join(filepath.Dir(s.Path()), "revision.seen")
+	s.TrackRevision(seen)
+	require.NoError(t, s.AddPassword(models.Password{ID: "p1", Name: "mail"}))
+
+	require.NoError(t, os.WriteFile(seen, []byte("garbage\n"), 0600))
+	require.NoError(t, s.Load())
+	rb, ok := s.RolledBack()
+	require.True(t, ok)
+	assert.ErrorIs(t, rb.Err, errCorruptSeen)
+
+	// Saving accepts the vault and starts the record afresh
+	require.NoError(t, s.AddPassword(models.Password{ID: "p2", Name: "bank"}))
+	require.NoError(t, s.Load())
+	_, ok = s.RolledBack()
+	assert.False(t, ok)
+}
+
+// Published test vectors: the GCM specification's test case 16, and the
+// XChaCha20-Poly1305 example of draft-irtf-cfrg-xchacha.
+var suiteVectors = []struct {
+	suite                                   Suite
+	key, nonce, plain, aad, ciphertext, tag string
+}{
+	{
+		suite:      AES256GCM,
+		key:        "feffe9928665731c6d6a8f9467308308feffe9928665731c6d6a8f9467308308",
+		nonce:      "cafebabefacedbaddecaf888",
+		plain:      "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a721c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b39",
+		aad:        "feedfacedeadbeeffeedfacedeadbeefabaddad2",
+		ciphertext: "522dc1f099567d07f47f37a32a84427d643a8cdcbfe5c0c97598a2bd2555d1aa8cb08e48590dbb3da7b08b1056828838c5f61e6393ba7a0abcc9f662",
+		tag:        "76fc6ece0f4e1768cddf8853bb2d551b",
+	},
+	{
+		suite:      XChaCha20Poly1305,
+		key:        "808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f",
+		nonce:      "404142434445464748494a4b4c4d4e4f5051525354555657",
+		plain:      hex.EncodeToString([]byte("Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future, sunscreen would be it.")),
+		aad:        "50515253c0c1c2c3c4c5c6c7",
+		ciphertext: "bd6d179d3e83d43b9576579493c0e939572a1700252bfaccbed2902c21396cbb731c7f1b0b4aa6440bf3a82f4eda7e39ae64c6708c54c216cb96b72e1213b4522f8c9ba40db5d945b11b69b982c1bb9e3f3fac2bc369488f76b2383565d3fff921f9664c97637da9768812f615c68b13b52e",
+		tag:        "c0875924c1c7987947deafd8780acf49",
+	},
+}
+
+func TestSuiteVectors(t *testing.T) {
+	unhex := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		require.NoError(t, err)
+		return b
+	}
+	for _, v := range suiteVectors {
+		t.Run(v.suite.String(), func(t *testing.T) {
+			aead, err := v.suite.aead(unhex(v.key))
+			require.NoError(t, err)
+			sealed := aead.Seal(nil, unhex(v.nonce), unhex(v.plain), unhex(v.aad))
+			assert.Equal(t, v.ciphertext+v.tag, hex.EncodeToString(sealed))
+
+			plain, err := aead.Open(nil, unhex(v.nonce), sealed, unhex(v.aad))
+			require.NoError(t, err)
+			assert.Equal(t, v.plain, hex.EncodeToString(plain))
+		})
+	}
+}
+
+func TestSetSuiteReencryptsVault(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.AddPassword(models.Password{ID: "p1", Name: "mail", Password: "secret"}))
+	assert.Equal(t, AES256GCM, s.Suite())
+
+	require.NoError(t, s.SetSuite(XChaCha20Poly1305))
+	data, err := os.ReadFile(s.Path())
+	require.NoError(t, err)
+	assert.Equal(t, fileHeader(XChaCha20Poly1305), data[:fileHeaderSize])
+
+	other := NewStorageAt(s.Path(), "1234")
+	require.NoError(t, other.Load())
+	assert.Equal(t, XChaCha20Poly1305, other.Suite())
+	p, err := other.RevealPassword("p1")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", p.Password)
+
+	// Later saves keep the suite the vault was loaded with
+	require.NoError(t, other.AddNote(models.Note{ID: "n1", Title: "wifi"}))
+	require.NoError(t, s.Load())
+	assert.Equal(t, XChaCha20Poly1305, s.Suite())
+	assert.Len(t, s.GetNotes(), 1)
+
+	require.NoError(t, s.SetSuite(AES256GCM))
+	require.NoError(t, other.Load())
+	assert.Equal(t, AES256GCM, other.Suite())
+}
+
+func TestPaddedVaultIsPairedWithFiller(t *testing.T) {
+	legacy := newTestStorage(t)
+	require.NoError(t, legacy.AddPassword(models.Password{ID: "p1", Name: "mail"}))
+	dir := filepath.Dir(legacy.Path())
+	sibling := filepath.Join(dir, "data.alt.enc")
+	seen := filepath.Join(dir, "revision.seen")
+
+	s := NewStorageAt(legacy.Path(), "1234")
+	s.SetPadding(sibling)
+	s.TrackRevision(seen)
+	s.SetBackupCount(1)
+	require.NoError(t, s.Load(), "files from before padding still open")
+	require.NoError(t, s.AddNote(models.Note{ID: "n1", Title: "wifi"}))
+	require.NoError(t, s.AddNote(models.Note{ID: "n2", Title: "door"}))
+
+	a, err := os.Stat(s.Path())
+	require.NoError(t, err)
+	b, err := os.Stat(sibling)
+	require.NoError(t, err)
+	assert.Equal(t, a.Size(), b.Size())
+	assert.Equal(t, a.ModTime(), b.ModTime())
+	for _, p := range []string{s.Path(), sibling} {
+		_, err := os.Stat(p + ".1")
+		assert.NoError(t, err, "both files keep backups")
+	}
+	assert.True(t, OpensWith(s.Path(), s.key))
+	assert.False(t, OpensWith(sibling, s.key))
+	assert.True(t, IsFiller(sibling, s.key))
+	lines := func() int {
+		data, err := os.ReadFile(seen)
+		require.NoError(t, err)
+		return len(strings.Fields(string(data)))
+	}
+	assert.Equal(t, 2, lines())
+
+	require.NoError(t, s.SetSuite(XChaCha20Poly1305))
+	filler, err := os.ReadFile(sibling)
+	require.NoError(t, err)
+	assert.Equal(t, fileHeader(XChaCha20Poly1305), filler[headerSize:headerSize+fileHeaderSize])
+
+	decoy := NewStorageAt(sibling, "9999")
+	decoy.SetPadding(s.Path())
+	decoy.TrackRevision(seen)
+	require.NoError(t, decoy.Save())
+	assert.False(t, IsFiller(sibling, s.key))
+	assert.Error(t, s.SetSuite(AES256GCM), "the decoy would keep the old suite")
+	assert.Equal(t, 2, lines())
+	require.NoError(t, s.Load())
+	assert.Len(t, s.GetNotes(), 2)
+}
+
+func TestSecretsDecodeIntoSecureMemory(t *testing.T) {
+	s := newTestStorage(t)
+	password := "p\"w\\\n\té世\U0001F600</>\x01"
+	require.NoError(t, s.AddPassword(models.Password{ID: "p1", Name: "mail", Password: password}))
+	require.NoError(t, s.AddSSHKey(models.SSHKey{ID: "k1", Name: "deploy", PrivateKey: "-----BEGIN KEY-----\nabc\n"}))
+
+	secret, err := s.PasswordSecret("p1")
+	require.NoError(t, err)
+	assert.Equal(t, password, string(secret.Bytes()))
+	secret.Destroy()
+
+	key, err := s.SSHKeySecret("k1")
+	require.NoError(t, err)
+	assert.Equal(t, "-----BEGIN KEY-----\nabc\n", string(key.Bytes()))
+	key.Destroy()
+
+	_, err = s.PasswordSecret("missing")
+	assert.Error(t, err)
+}
+
+func TestCloseWaitsForKeyUsers(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.AddPassword(models.Password{ID: "p1", Name: "mail", Password: "secret"}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				s.RevealPassword("p1")
+				s.UpdatePassword(models.Password{ID: "p1", Name: "mail", Password: "other"})
+			}
+		}()
+	}
+	require.NoError(t, s.Close())
+	wg.Wait()
+
+	_, err := s.RevealPassword("p1")
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.ErrorIs(t, s.Save(), ErrClosed)
+}
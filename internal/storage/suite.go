@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"gopass/internal/events"
+)
+
+// Suite is the cipher a vault file is sealed with. It is recorded in the
+// file header, so a vault opens whichever suite it was last saved with.
+//
+// The suite covers the file as a whole only. Entry secrets, their data
+// keys and the padding header stay sealed with AES-256-GCM whatever the
+// suite, so picking another one does not take AES out of the vault.
+type Suite byte
+
+const (
+	AES256GCM Suite = iota
+	// XChaCha20Poly1305 has nonces long enough to always pick at random,
+	// and is fast on machines without AES instructions.
+	XChaCha20Poly1305
+)
+
+// Suites lists every suite, for the user to choose from.
+var Suites = []Suite{AES256GCM, XChaCha20Poly1305}
+
+func (s Suite) String() string {
+	switch s {
+	case AES256GCM:
+		return "aes-256-gcm"
+	case XChaCha20Poly1305:
+		return "xchacha20-poly1305"
+	}
+	return fmt.Sprintf("suite %d", byte(s))
+}
+
+func ParseSuite(name string) (Suite, error) {
+	for _, s := range Suites {
+		if strings.EqualFold(name, s.String()) {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown cipher %q", name)
+}
+
+func (s Suite) aead(key []byte) (cipher.AEAD, error) {
+	switch s {
+	case AES256GCM:
+		return newGCM(key)
+	case XChaCha20Poly1305:
+		return chacha20poly1305.NewX(key)
+	}
+	return nil, fmt.Errorf("unsupported vault cipher %s", s)
+}
+
+// Suite is the cipher the vault is saved with.
+func (s *Storage) Suite() Suite {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.suite
+}
+
+// SetSuite re-encrypts the vault file with suite.
+func (s *Storage) SetSuite(suite Suite) error {
+	if _, err := suite.aead(make([]byte, 32)); err != nil {
+		return err
+	}
+	if err := s.writable(); err != nil {
+		return err
+	}
+	if !s.Role().CanManage() {
+		return ErrPermission
+	}
+	if s.Backend() != nil {
+		return errors.New("only file vaults can change their cipher")
+	}
+	padded := s.padded()
+	if padded && !s.siblingIsFiller() {
+		// The other vault of the pair would keep the old one
+		return errors.New("the cipher of a vault with a duress PIN cannot be changed")
+	}
+
+	s.mu.Lock()
+	previous := s.suite
+	s.suite = suite
+	s.mu.Unlock()
+	if err := s.save(events.Event{}); err != nil {
+		s.mu.Lock()
+		s.suite = previous
+		s.mu.Unlock()
+		return err
+	}
+	if padded {
+		// The filler names the suite like a decoy would
+		return s.renewFiller()
+	}
+	return nil
+}
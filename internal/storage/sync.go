@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"encoding/json"
+
+	"gopass/internal/events"
+	"gopass/internal/models"
+)
+
+// Snapshot returns a copy of everything in the vault.
+func (s *Storage) Snapshot() models.ExportData {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return models.ExportData{
+		Passwords: append([]models.Password{}, s.passwords...),
+		Notes:     append([]models.Note{}, s.notes...),
+		SSHKeys:   append([]models.SSHKey{}, s.sshKeys...),
+	}
+}
+
+// Replace swaps the whole vault for data, as after merging with a remote.
+func (s *Storage) Replace(data models.ExportData) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+	if err := s.withKey(func(key []byte) error { return sealAll(key, &data) }); err != nil {
+		return err
+	}
+
+	func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.passwords = data.Passwords
+		s.notes = data.Notes
+		s.sshKeys = data.SSHKeys
+	}()
+
+	return s.saveAndPublish(events.Event{Type: events.Synced})
+}
+
+// Seal encrypts data with the vault key in the format of the vault file, for
+// copies of the vault kept elsewhere.
+func (s *Storage) Seal(data models.ExportData) ([]byte, error) {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return s.encrypt(jsonData)
+}
+
+// Unseal decrypts a copy made by Seal.
+func (s *Storage) Unseal(sealed []byte) (models.ExportData, error) {
+	var data models.ExportData
+	decrypted, err := s.decrypt(sealed)
+	if err != nil {
+		return data, err
+	}
+	err = json.Unmarshal(decrypted, &data)
+	return data, err
+}
+
+// RevealAll returns data with the secrets of every entry decrypted, so
+// merges can compare them field by field. Nothing is reported as revealed.
+func (s *Storage) RevealAll(data models.ExportData) (models.ExportData, error) {
+	data = copyData(data)
+	err := s.withKey(func(key []byte) (err error) {
+		for i, p := range data.Passwords {
+			if data.Passwords[i], err = revealPassword(key, p); err != nil {
+				return err
+			}
+		}
+		for i, n := range data.Notes {
+			if data.Notes[i], err = revealNote(key, n); err != nil {
+				return err
+			}
+		}
+		for i, k := range data.SSHKeys {
+			if data.SSHKeys[i], err = revealSSHKey(key, k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return data, err
+}
+
+// SealAll returns data with the entries that carry plain secrets sealed.
+func (s *Storage) SealAll(data models.ExportData) (models.ExportData, error) {
+	data = copyData(data)
+	err := s.withKey(func(key []byte) error { return sealAll(key, &data) })
+	return data, err
+}
+
+func copyData(data models.ExportData) models.ExportData {
+	return models.ExportData{
+		Passwords: append([]models.Password(nil), data.Passwords...),
+		Notes:     append([]models.Note(nil), data.Notes...),
+		SSHKeys:   append([]models.SSHKey(nil), data.SSHKeys...),
+	}
+}
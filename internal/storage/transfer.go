@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TransferPassword copies the password with the given ID into dst. A copy
+// gets a fresh ID; with move set the entry keeps its ID and is deleted from s
+// once dst has saved it.
+func (s *Storage) TransferPassword(id string, dst *Storage, move bool) error {
+	if s == dst {
+		return errors.New("source and destination vault are the same")
+	}
+	if move {
+		if err := s.writable(); err != nil {
+			return err
+		}
+	}
+
+	// The destination seals the entry under its own key
+	password, err := s.RevealPassword(id)
+	if err != nil {
+		return err
+	}
+
+	if !move {
+		password.ID = uuid.New().String()
+		password.CreatedAt = time.Now()
+		password.UpdatedAt = password.CreatedAt
+	}
+	if err := dst.AddPassword(password); err != nil {
+		return err
+	}
+	if move {
+		return s.DeletePassword(id)
+	}
+	return nil
+}
+
+// TransferNote is the note counterpart of TransferPassword.
+func (s *Storage) TransferNote(id string, dst *Storage, move bool) error {
+	if s == dst {
+		return errors.New("source and destination vault are the same")
+	}
+	if move {
+		if err := s.writable(); err != nil {
+			return err
+		}
+	}
+
+	note, err := s.RevealNote(id)
+	if err != nil {
+		return err
+	}
+
+	if !move {
+		note.ID = uuid.New().String()
+		note.CreatedAt = time.Now()
+		note.UpdatedAt = note.CreatedAt
+	}
+	if err := dst.AddNote(note); err != nil {
+		return err
+	}
+	if move {
+		return s.DeleteNote(id)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"gopass/internal/events"
+)
+
+// Editors and sync tools often write a file in several steps, so wait for
+// the directory to settle before reloading.
+const watchDebounce = 200 * time.Millisecond
+
+// Watch reloads the vault whenever its file is changed by another process
+// and publishes a Reloaded event. Writes made through this Storage are ignored.
+func (s *Storage) Watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	// Watch the directory rather than the file, since atomic saves replace it
+	if err := watcher.Add(filepath.Dir(s.path)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	s.mu.Lock()
+	if s.watcher != nil {
+		s.watcher.Close()
+	}
+	s.watcher = watcher
+	s.mu.Unlock()
+
+	go s.watchLoop(watcher)
+	return nil
+}
+
+func (s *Storage) watchLoop(watcher *fsnotify.Watcher) {
+	name := filepath.Clean(s.path)
+	var timer <-chan time.Time
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != name {
+				continue
+			}
+			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Rename) {
+				timer = time.After(watchDebounce)
+			}
+		case _, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+		case <-timer:
+			timer = nil
+			if _, err := s.Reload(); errors.Is(err, ErrUnsavedChanges) {
+				// Try again once our save is done
+				timer = time.After(watchDebounce)
+			}
+		}
+	}
+}
+
+// Reload re-reads the vault file if its contents differ from what this
+// Storage last loaded or saved. It reports whether anything was reloaded,
+// and returns ErrUnsavedChanges rather than drop changes not saved yet.
+func (s *Storage) Reload() (bool, error) {
+	encrypted, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	s.mu.RLock()
+	unchanged := sha256.Sum256(encrypted) == s.lastHash
+	s.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	if err := s.load(true); err != nil {
+		return false, err
+	}
+	s.events.Publish(events.Event{Type: events.Reloaded})
+	return true, nil
+}
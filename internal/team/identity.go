@@ -0,0 +1,63 @@
+package team
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"gopass/internal/share"
+)
+
+// The member's identity never leaves their machine. It is kept encrypted
+// with a key derived from their PIN.
+
+func SaveIdentity(path string, key []byte, id share.Identity) error {
+	plain, err := json.Marshal(id)
+	if err != nil {
+		return err
+	}
+	gcm, err := identityCipher(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, gcm.Seal(nonce, nonce, plain, nil), 0600)
+}
+
+func LoadIdentity(path string, key []byte) (share.Identity, error) {
+	var id share.Identity
+	sealed, err := os.ReadFile(path)
+	if err != nil {
+		return id, err
+	}
+	gcm, err := identityCipher(key)
+	if err != nil {
+		return id, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return id, errors.New("identity file too short")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return id, errors.New("cannot decrypt identity; wrong PIN?")
+	}
+	return id, json.Unmarshal(plain, &id)
+}
+
+func identityCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
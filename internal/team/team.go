@@ -0,0 +1,314 @@
+// Package team keeps the member list of a team vault: the vault key wrapped
+// for each member's public key and the role each member has.
+package team
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopass/internal/share"
+	"gopass/internal/storage"
+)
+
+// RosterFile sits next to the vault file in the shared directory.
+const RosterFile = "team.json"
+
+var (
+	ErrNotMember = errors.New("not a member of this team vault; ask an owner to add your public key")
+	ErrLastOwner = errors.New("a team vault needs at least one owner")
+)
+
+type Member struct {
+	Name       string       `json:"name"`
+	PublicKey  string       `json:"public_key"`
+	Role       storage.Role `json:"role"`
+	WrappedKey []byte       `json:"wrapped_key"`
+	AddedAt    time.Time    `json:"added_at"`
+}
+
+// Roster is the team file. It is signed by the owner who last changed it.
+type Roster struct {
+	Members []Member `json:"members"`
+	// Generation counts key rotations.
+	Generation int    `json:"generation"`
+	Signer     string `json:"signer"`
+	Signature  []byte `json:"signature,omitempty"`
+}
+
+func (r Roster) signedBytes() ([]byte, error) {
+	r.Signature = nil
+	return json.Marshal(r)
+}
+
+// verify checks that an owner listed in the roster signed it.
+func (r Roster) verify() error {
+	signer, err := share.ParsePublicKey(r.Signer)
+	if err != nil {
+		return fmt.Errorf("team roster signer: %w", err)
+	}
+	owner := false
+	for _, m := range r.Members {
+		if m.PublicKey == signer.String() && m.Role == storage.RoleOwner {
+			owner = true
+		}
+	}
+	signed, err := r.signedBytes()
+	if err != nil {
+		return err
+	}
+	if !owner || !signer.Verify(signed, r.Signature) {
+		return errors.New("team roster is not signed by an owner")
+	}
+	return nil
+}
+
+func (r Roster) owners() int {
+	n := 0
+	for _, m := range r.Members {
+		if m.Role == storage.RoleOwner {
+			n++
+		}
+	}
+	return n
+}
+
+// Team is a team vault unlocked with one member's identity.
+type Team struct {
+	dir    string
+	id     share.Identity
+	me     string
+	roster Roster
+	key    []byte
+}
+
+func newKey() ([]byte, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	return key, err
+}
+
+// Create starts a team vault in dir with a fresh key and member as its
+// only owner.
+func Create(dir, member string, id share.Identity) (*Team, error) {
+	if _, err := os.Stat(filepath.Join(dir, RosterFile)); err == nil {
+		return nil, fmt.Errorf("%s already holds a team vault", dir)
+	}
+	key, err := newKey()
+	if err != nil {
+		return nil, err
+	}
+	t := &Team{dir: dir, id: id, key: key}
+	pub, err := id.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := t.add(member, pub, storage.RoleOwner); err != nil {
+		return nil, err
+	}
+	t.me = t.roster.Members[0].Name
+	return t, t.save()
+}
+
+// Open reads the roster in dir and unwraps the vault key for id.
+func Open(dir string, id share.Identity) (*Team, error) {
+	data, err := os.ReadFile(filepath.Join(dir, RosterFile))
+	if err != nil {
+		return nil, err
+	}
+	t := &Team{dir: dir, id: id}
+	if err := json.Unmarshal(data, &t.roster); err != nil {
+		return nil, fmt.Errorf("invalid team roster: %w", err)
+	}
+	if err := t.roster.verify(); err != nil {
+		return nil, err
+	}
+
+	pub, err := id.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range t.roster.Members {
+		if m.PublicKey == pub.String() {
+			t.me = m.Name
+			if t.key, err = id.Unwrap(m.WrappedKey); err != nil {
+				return nil, fmt.Errorf("unwrap team key: %w", err)
+			}
+			return t, nil
+		}
+	}
+	return nil, ErrNotMember
+}
+
+// Key is the vault key the entries are encrypted with.
+func (t *Team) Key() []byte {
+	return t.key
+}
+
+// Member is the member the team was opened as.
+func (t *Team) Member() Member {
+	m, _ := t.member(t.me)
+	return m
+}
+
+func (t *Team) Members() []Member {
+	return append([]Member{}, t.roster.Members...)
+}
+
+func (t *Team) member(name string) (Member, int) {
+	for i, m := range t.roster.Members {
+		if m.Name == name {
+			return m, i
+		}
+	}
+	return Member{}, -1
+}
+
+func (t *Team) requireOwner() error {
+	if t.Member().Role != storage.RoleOwner {
+		return storage.ErrPermission
+	}
+	return nil
+}
+
+// Add wraps the vault key for a new member.
+func (t *Team) Add(name, publicKey string, role storage.Role) error {
+	if err := t.requireOwner(); err != nil {
+		return err
+	}
+	pub, err := share.ParsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	if err := t.add(name, pub, role); err != nil {
+		return err
+	}
+	return t.save()
+}
+
+func (t *Team) add(name string, pub share.PublicKey, role storage.Role) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("member name must not be empty")
+	}
+	if _, err := storage.ParseRole(string(role)); err != nil {
+		return err
+	}
+	for _, m := range t.roster.Members {
+		if m.Name == name {
+			return fmt.Errorf("member %q already exists", name)
+		}
+		if m.PublicKey == pub.String() {
+			return fmt.Errorf("key already belongs to member %q", m.Name)
+		}
+	}
+	wrapped, err := share.Wrap(pub, t.key)
+	if err != nil {
+		return err
+	}
+	t.roster.Members = append(t.roster.Members, Member{
+		Name:       name,
+		PublicKey:  pub.String(),
+		Role:       role,
+		WrappedKey: wrapped,
+		AddedAt:    time.Now(),
+	})
+	return nil
+}
+
+func (t *Team) SetRole(name string, role storage.Role) error {
+	if err := t.requireOwner(); err != nil {
+		return err
+	}
+	if _, err := storage.ParseRole(string(role)); err != nil {
+		return err
+	}
+	m, i := t.member(name)
+	if i < 0 {
+		return fmt.Errorf("no member named %q", name)
+	}
+	if m.Role == storage.RoleOwner && role != storage.RoleOwner && t.roster.owners() == 1 {
+		return ErrLastOwner
+	}
+	t.roster.Members[i].Role = role
+	return t.save()
+}
+
+// Remove drops a member and rotates the vault key so what they may have
+// kept stops opening the vault. rekey re-encrypts the entries under the new
+// key before the roster hands it to the remaining members; when the roster
+// cannot be saved, rekey is called again with the old key.
+func (t *Team) Remove(name string, rekey func(key []byte) error) error {
+	if err := t.requireOwner(); err != nil {
+		return err
+	}
+	m, i := t.member(name)
+	if i < 0 {
+		return fmt.Errorf("no member named %q", name)
+	}
+	if m.Role == storage.RoleOwner && t.roster.owners() == 1 {
+		return ErrLastOwner
+	}
+
+	key, err := newKey()
+	if err != nil {
+		return err
+	}
+	members := append(append([]Member{}, t.roster.Members[:i]...), t.roster.Members[i+1:]...)
+	for j := range members {
+		pub, err := share.ParsePublicKey(members[j].PublicKey)
+		if err != nil {
+			return err
+		}
+		if members[j].WrappedKey, err = share.Wrap(pub, key); err != nil {
+			return err
+		}
+	}
+	if err := rekey(key); err != nil {
+		return err
+	}
+	roster := t.roster
+	t.roster.Members = members
+	t.roster.Generation++
+	if err := t.save(); err != nil {
+		// The roster still hands out the old key, so the vault goes back to it
+		t.roster = roster
+		if rerr := rekey(t.key); rerr != nil {
+			return fmt.Errorf("%w; moving the vault back to its old key failed too: %v", err, rerr)
+		}
+		return err
+	}
+	t.key = key
+	return nil
+}
+
+func (t *Team) save() error {
+	pub, err := t.id.PublicKey()
+	if err != nil {
+		return err
+	}
+	t.roster.Signer = pub.String()
+	signed, err := t.roster.signedBytes()
+	if err != nil {
+		return err
+	}
+	t.roster.Signature = t.id.Sign(signed)
+
+	data, err := json.MarshalIndent(t.roster, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(t.dir, 0700); err != nil {
+		return err
+	}
+	path := filepath.Join(t.dir, RosterFile)
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,110 @@
+package team
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopass/internal/share"
+	"gopass/internal/storage"
+)
+
+func identity(t *testing.T) (share.Identity, string) {
+	id, err := share.NewIdentity()
+	require.NoError(t, err)
+	pub, err := id.PublicKey()
+	require.NoError(t, err)
+	return id, pub.String()
+}
+
+func TestMembersShareTheVaultKey(t *testing.T) {
+	dir := t.TempDir()
+	alice, _ := identity(t)
+	bob, bobKey := identity(t)
+
+	owner, err := Create(dir, "alice", alice)
+	require.NoError(t, err)
+	_, err = Open(dir, bob)
+	assert.ErrorIs(t, err, ErrNotMember)
+
+	require.NoError(t, owner.Add("bob", bobKey, storage.RoleViewer))
+	member, err := Open(dir, bob)
+	require.NoError(t, err)
+	assert.Equal(t, owner.Key(), member.Key())
+	assert.Equal(t, storage.RoleViewer, member.Member().Role)
+
+	// Only owners manage members
+	_, carolKey := identity(t)
+	assert.ErrorIs(t, member.Add("carol", carolKey, storage.RoleEditor), storage.ErrPermission)
+}
+
+func TestRemoveRotatesKey(t *testing.T) {
+	dir := t.TempDir()
+	alice, _ := identity(t)
+	bob, bobKey := identity(t)
+	carol, carolKey := identity(t)
+
+	owner, err := Create(dir, "alice", alice)
+	require.NoError(t, err)
+	require.NoError(t, owner.Add("bob", bobKey, storage.RoleEditor))
+	require.NoError(t, owner.Add("carol", carolKey, storage.RoleEditor))
+	oldKey := owner.Key()
+
+	var rekeyed []byte
+	require.NoError(t, owner.Remove("bob", func(key []byte) error {
+		rekeyed = key
+		return nil
+	}))
+	assert.NotEqual(t, oldKey, owner.Key())
+	assert.Equal(t, rekeyed, owner.Key())
+
+	_, err = Open(dir, bob)
+	assert.ErrorIs(t, err, ErrNotMember)
+	remaining, err := Open(dir, carol)
+	require.NoError(t, err)
+	assert.Equal(t, owner.Key(), remaining.Key())
+
+	assert.ErrorIs(t, owner.Remove("alice", func([]byte) error { return nil }), ErrLastOwner)
+	assert.ErrorIs(t, owner.SetRole("alice", storage.RoleEditor), ErrLastOwner)
+}
+
+func TestRosterMustBeSignedByOwner(t *testing.T) {
+	dir := t.TempDir()
+	alice, _ := identity(t)
+	bob, bobKey := identity(t)
+	owner, err := Create(dir, "alice", alice)
+	require.NoError(t, err)
+	require.NoError(t, owner.Add("bob", bobKey, storage.RoleViewer))
+
+	// A viewer promoting themselves by editing the file
+	path := filepath.Join(dir, RosterFile)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var r Roster
+	require.NoError(t, json.Unmarshal(data, &r))
+	r.Members[1].Role = storage.RoleOwner
+	data, err = json.Marshal(r)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	_, err = Open(dir, bob)
+	assert.ErrorContains(t, err, "not signed by an owner")
+}
+
+func TestIdentityFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "identity.enc")
+	id, _ := identity(t)
+	key := make([]byte, 32)
+	require.NoError(t, SaveIdentity(path, key, id))
+
+	loaded, err := LoadIdentity(path, key)
+	require.NoError(t, err)
+	assert.Equal(t, id, loaded)
+
+	key[0] = 1
+	_, err = LoadIdentity(path, key)
+	assert.ErrorContains(t, err, "wrong PIN")
+}
@@ -0,0 +1,162 @@
+// Package urlmatch decides which password entries belong to a web page.
+package urlmatch
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+	"gopass/internal/models"
+)
+
+// Rules for models.Password.URLMatch. The empty rule means RuleDomain.
+const (
+	// RuleDomain matches any page on the same registrable domain, so an entry
+	// for accounts.example.co.uk also fills login.example.co.uk.
+	RuleDomain = "domain"
+	// RuleHost only matches pages on exactly the entry's host.
+	RuleHost = "host"
+	// RuleSubdomain matches the entry's host and hosts below it.
+	RuleSubdomain = "subdomain"
+	// RuleRegex treats the entry URL as a regular expression the whole page
+	// URL must match.
+	RuleRegex = "regex"
+	// RuleNever keeps the entry out of autofill.
+	RuleNever = "never"
+)
+
+var Rules = []string{RuleDomain, RuleHost, RuleSubdomain, RuleRegex, RuleNever}
+
+// ValidRule reports whether rule is known, accepting the empty default.
+func ValidRule(rule string) bool {
+	if rule == "" {
+		return true
+	}
+	for _, r := range Rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// Strength orders matches; a more specific rule ranks higher.
+type Strength int
+
+const (
+	NoMatch Strength = iota
+	DomainMatch
+	SubdomainMatch
+	RegexMatch
+	HostMatch
+)
+
+// hostOf returns the lower-case host without port. Entry URLs may omit the
+// scheme.
+func hostOf(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
+
+// registrableDomain is the public suffix plus one label, or the host itself
+// for IP addresses, single-label hosts and bare public suffixes.
+func registrableDomain(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// Match reports how well page, a full page URL or origin, fits the entry.
+func Match(p models.Password, page string) Strength {
+	// Autofill on plain HTTP pages only for entries saved for HTTP
+	if strings.HasPrefix(strings.ToLower(page), "http://") && !allowsHTTP(p) {
+		return NoMatch
+	}
+	if p.URLMatch == RuleRegex {
+		re, err := regexp.Compile(`^(?:` + p.URL + `)$`)
+		if err != nil || !re.MatchString(page) {
+			return NoMatch
+		}
+		return RegexMatch
+	}
+
+	have, want := hostOf(p.URL), hostOf(page)
+	if have == "" || want == "" {
+		return NoMatch
+	}
+
+	switch p.URLMatch {
+	case RuleNever:
+		return NoMatch
+	case RuleHost:
+		if have == want {
+			return HostMatch
+		}
+	case RuleSubdomain:
+		if have == want {
+			return HostMatch
+		}
+		if strings.HasSuffix(want, "."+have) {
+			return SubdomainMatch
+		}
+	default:
+		if have == want {
+			return HostMatch
+		}
+		if registrableDomain(have) == registrableDomain(want) {
+			return DomainMatch
+		}
+	}
+	return NoMatch
+}
+
+// allowsHTTP reports whether the entry may fill plain HTTP pages. Regular
+// expressions have to spell out the http scheme.
+func allowsHTTP(p models.Password) bool {
+	u := strings.ToLower(strings.TrimSpace(p.URL))
+	if p.URLMatch == RuleRegex {
+		return strings.HasPrefix(strings.TrimPrefix(u, "^"), "http://")
+	}
+	return !strings.HasPrefix(u, "https://")
+}
+
+// Find returns the entries matching page, best matches first.
+func Find(passwords []models.Password, page string) []models.Password {
+	type scored struct {
+		p        models.Password
+		strength Strength
+	}
+	var found []scored
+	for _, p := range passwords {
+		if s := Match(p, page); s != NoMatch {
+			found = append(found, scored{p, s})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].strength > found[j].strength
+	})
+
+	result := make([]models.Password, len(found))
+	for i, f := range found {
+		result[i] = f.p
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+package urlmatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopass/internal/models"
+)
+
+func TestMatchRules(t *testing.T) {
+	tests := []struct {
+		rule, entry, page string
+		want              Strength
+	}{
+		{"", "https://accounts.example.co.uk/login", "https://login.example.co.uk", DomainMatch},
+		{"", "example.com", "https://example.com/path", HostMatch},
+		{"", "https://foo.co.uk", "https://bar.co.uk", NoMatch},
+		{"", "https://user.github.io", "https://other.github.io", NoMatch},
+		{"", "https://example.com", "http://example.com", NoMatch},
+		{RuleHost, "https://example.com", "https://www.example.com", NoMatch},
+		{RuleHost, "https://example.com:8443", "https://EXAMPLE.com", HostMatch},
+		{RuleSubdomain, "https://corp.example.com", "https://sso.corp.example.com", SubdomainMatch},
+		{RuleSubdomain, "https://corp.example.com", "https://example.com", NoMatch},
+		{RuleSubdomain, "https://corp.example.com", "https://evilcorp.example.com", NoMatch},
+		{RuleRegex, `https://(dev|staging)\.example\.com/.*`, "https://staging.example.com/login", RegexMatch},
+		{RuleRegex, `https://(dev|staging)\.example\.com/.*`, "https://prod.example.com/login", NoMatch},
+		{RuleRegex, `https://(dev|staging)\.example\.com/.*`, "https://evil.test/?https://dev.example.com/", NoMatch},
+		{RuleRegex, `.*example\.com.*`, "http://example.com/", NoMatch},
+		{RuleRegex, `http://192\.168\.1\.1/.*`, "http://192.168.1.1/admin", RegexMatch},
+		{RuleRegex, `(`, "https://example.com", NoMatch},
+		{RuleNever, "https://example.com", "https://example.com", NoMatch},
+		{"", "http://192.168.1.1", "http://192.168.1.1/admin", HostMatch},
+		{"", "http://192.168.1.1", "http://192.168.1.2", NoMatch},
+	}
+	for _, tt := range tests {
+		p := models.Password{URL: tt.entry, URLMatch: tt.rule}
+		assert.Equal(t, tt.want, Match(p, tt.page), "%s %s on %s", tt.rule, tt.entry, tt.page)
+	}
+}
+
+func TestFindOrdersBySpecificity(t *testing.T) {
+	passwords := []models.Password{
+		{ID: "domain", URL: "https://example.com"},
+		{ID: "other", URL: "https://example.org"},
+		{ID: "host", URL: "https://login.example.com"},
+	}
+	var ids []string
+	for _, p := range Find(passwords, "https://login.example.com/signin") {
+		ids = append(ids, p.ID)
+	}
+	assert.Equal(t, []string{"host", "domain"}, ids)
+}
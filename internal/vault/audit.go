@@ -0,0 +1,31 @@
+package vault
+
+import (
+	"path/filepath"
+
+	"gopass/internal/audit"
+)
+
+// AuditPath is the vault's audit log. It is kept with the other state
+// private to this machine.
+func (v Vault) AuditPath() string {
+	return filepath.Join(v.LocalPath(), "audit.log")
+}
+
+// OpenAudit opens the audit log with the key pin unlocks, recording
+// entries as done by client. Team vaults use the member's key, which
+// outlives rotations of the team key.
+func (v Vault) OpenAudit(pin, client string) (*audit.Log, error) {
+	if v.paired() {
+		key, slot, err := v.unlock(pin)
+		if err != nil {
+			return nil, err
+		}
+		return audit.OpenSlot(v.AuditPath(), key, slot, client)
+	}
+	key, err := v.Key(pin)
+	if err != nil {
+		return nil, err
+	}
+	return audit.Open(v.AuditPath(), key, client)
+}
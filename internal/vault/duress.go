@@ -0,0 +1,173 @@
+package vault
+
+import (
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"gopass/internal/audit"
+	"gopass/internal/secmem"
+	"gopass/internal/storage"
+)
+
+// A file vault of your own keeps two vault files in the same padded
+// format and size: the vault and filler looking like a decoy, or once a
+// duress PIN is set, the real vault and the decoy the duress PIN opens.
+// Which file is which is chosen at random and only the keys tell.
+
+var errDuressSet = errors.New("the vault has a duress PIN")
+
+// slotPath is the vault file in slot.
+func (v Vault) slotPath(slot int) string {
+	if slot == 0 {
+		return v.DataPath()
+	}
+	return filepath.Join(v.Path, "data.alt.enc")
+}
+
+// paired reports whether v keeps its vault file paired with another.
+func (v Vault) paired() bool {
+	return v.Team == nil && (v.Backend == BackendFile || v.Backend == "")
+}
+
+// slot finds the vault file sealed with key. A vault never saved, or last
+// saved before files were paired, is in slot 0.
+func (v Vault) slot(key []byte) (int, bool) {
+	for slot := 0; slot < 2; slot++ {
+		if storage.OpensWith(v.slotPath(slot), key) {
+			return slot, true
+		}
+	}
+	return 0, false
+}
+
+// unlock returns the key pin unlocks and the slot of its vault file. The
+// decoy is keyed with the duress PIN directly, as there is no wrapped key
+// for it.
+func (v Vault) unlock(pin string) ([]byte, int, error) {
+	key, err := v.Key(pin)
+	if !errors.Is(err, errUnwrap) {
+		if err != nil {
+			return nil, 0, err
+		}
+		slot, _ := v.slot(key)
+		return key, slot, nil
+	}
+	if key, err = v.KDF.DeriveKey(pin); err != nil {
+		return nil, 0, err
+	}
+	slot, ok := v.slot(key)
+	if !ok {
+		secmem.Wipe(key)
+		return nil, 0, errUnwrap
+	}
+	return key, slot, nil
+}
+
+// hasDecoy reports whether the vault file keyed with key is paired with
+// another vault rather than filler: the decoy, or under the duress PIN the
+// real vault. Settings that would have to be made for both vaults at once
+// are refused for such vaults, whichever PIN was used.
+func (v Vault) hasDecoy(key []byte) bool {
+	slot, _ := v.slot(key)
+	return !storage.IsFiller(v.slotPath(1-slot), key)
+}
+
+// pairedStorage opens the vault file of slot with key.
+func (v Vault) pairedStorage(key []byte, slot int) *storage.Storage {
+	s := storage.NewStorageWithKey(v.slotPath(slot), key)
+	s.SetPadding(v.slotPath(1 - slot))
+	s.TrackRevision(v.RevisionPath())
+	return s
+}
+
+// SetDuressPIN adds duressPIN, which opens an empty decoy vault instead of
+// the real one. The decoy should be filled with plausible entries by
+// unlocking it with the duress PIN. Old backups are removed, since they
+// would give the real vault away.
+func (v Vault) SetDuressPIN(pin, duressPIN string) error {
+	if !v.paired() {
+		return errors.New("duress PINs need a file vault of your own")
+	}
+	if pin == duressPIN {
+		return errors.New("the duress PIN must differ from the PIN")
+	}
+	a := v.NewAuth()
+	if err := a.LoadPINHash(); err != nil {
+		return err
+	}
+	if !a.ValidatePIN(pin) {
+		return errors.New("invalid PIN")
+	}
+	key, slot, err := v.unlock(pin)
+	if err != nil {
+		return err
+	}
+	defer secmem.Wipe(key)
+	if v.hasDecoy(key) {
+		return errDuressSet
+	}
+
+	real := v.pairedStorage(key, slot)
+	if err := real.Lock(); err != nil {
+		return err
+	}
+	defer real.Close()
+	if err := real.Load(); err != nil {
+		return err
+	}
+
+	var coin [1]byte
+	if _, err := rand.Read(coin[:]); err != nil {
+		return err
+	}
+	realSlot := int(coin[0] & 1)
+
+	for slot := 0; slot < 2; slot++ {
+		backups, err := filepath.Glob(v.slotPath(slot) + ".[0-9]*")
+		if err != nil {
+			return err
+		}
+		for _, b := range backups {
+			if err := os.Remove(b); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := v.pairedStorage(key, realSlot).Replace(real.Snapshot()); err != nil {
+		return err
+	}
+	if realSlot != slot {
+		// The decoy takes the line of the head file the vault had
+		if err := audit.MoveHead(v.AuditPath(), key, realSlot); err != nil {
+			return err
+		}
+	}
+	decoyKey, err := v.KDF.DeriveKey(duressPIN)
+	if err != nil {
+		return err
+	}
+	defer secmem.Wipe(decoyKey)
+	decoy := storage.NewStorageWithKey(v.slotPath(1-realSlot), decoyKey)
+	if suite := real.Suite(); suite != decoy.Suite() {
+		// Saved in place of the filler, it names the same suite
+		if err := decoy.SetSuite(suite); err != nil {
+			return err
+		}
+	}
+	decoy.SetPadding(v.slotPath(realSlot))
+	decoy.TrackRevision(v.RevisionPath())
+	if err := decoy.Save(); err != nil {
+		return err
+	}
+
+	if realSlot == 1 {
+		if err := a.SetSlotPIN(1, pin); err != nil {
+			return err
+		}
+		return a.SetSlotPIN(0, duressPIN)
+	}
+	return a.SetSlotPIN(1, duressPIN)
+}
@@ -0,0 +1,55 @@
+package vault
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// KDFLegacy is the unsalted SHA-256 of the PIN used by the original
+	// single-vault layout. It is kept so existing vaults still open.
+	KDFLegacy   = "sha256"
+	KDFArgon2id = "argon2id"
+)
+
+// KDF records how a vault's encryption key is derived from its PIN.
+type KDF struct {
+	Algorithm string `json:"algorithm"`
+	Salt      []byte `json:"salt,omitempty"`
+	Time      uint32 `json:"time,omitempty"`
+	MemoryKiB uint32 `json:"memory_kib,omitempty"`
+	Threads   uint8  `json:"threads,omitempty"`
+}
+
+// NewKDF returns Argon2id settings with a fresh random salt.
+func NewKDF() (KDF, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return KDF{}, err
+	}
+	return KDF{
+		Algorithm: KDFArgon2id,
+		Salt:      salt,
+		Time:      3,
+		MemoryKiB: 64 * 1024,
+		Threads:   4,
+	}, nil
+}
+
+func (k KDF) DeriveKey(pin string) ([]byte, error) {
+	switch k.Algorithm {
+	case KDFLegacy, "":
+		key := sha256.Sum256([]byte(pin))
+		return key[:], nil
+	case KDFArgon2id:
+		if len(k.Salt) == 0 || k.Time == 0 || k.MemoryKiB == 0 || k.Threads == 0 {
+			return nil, fmt.Errorf("incomplete %s settings", k.Algorithm)
+		}
+		return argon2.IDKey([]byte(pin), k.Salt, k.Time, k.MemoryKiB, k.Threads, 32), nil
+	default:
+		return nil, fmt.Errorf("unknown key derivation %q", k.Algorithm)
+	}
+}
@@ -0,0 +1,181 @@
+package vault
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopass/internal/audit"
+	"gopass/internal/secmem"
+)
+
+var (
+	ErrKeyFileNeeded = errors.New("this vault needs its key file")
+	ErrWrongKeyFile  = errors.New("wrong key file for this vault")
+)
+
+// KeyFile marks a vault that is unlocked with the contents of a key file as
+// well as the PIN. Check tells the right file from a wrong one; it is too
+// short to help anyone guess the file.
+type KeyFile struct {
+	Check []byte `json:"check"`
+}
+
+// keyFileSize is the amount of random data in a generated key file. Any
+// other file can serve as a key file too.
+const keyFileSize = 64
+
+// NewKeyFile returns the contents of a new random key file.
+func NewKeyFile() ([]byte, error) {
+	data := make([]byte, keyFileSize)
+	_, err := rand.Read(data)
+	return data, err
+}
+
+// GenerateKeyFile writes a new random key file to path, which must not exist.
+func GenerateKeyFile(path string) ([]byte, error) {
+	data, err := NewKeyFile()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return data, f.Close()
+}
+
+func ReadKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, errors.New("key file is empty")
+	}
+	return data, nil
+}
+
+func (v Vault) keyFileCheck(digest []byte) []byte {
+	mac := hmac.New(sha256.New, digest)
+	mac.Write([]byte("gopass key file check"))
+	mac.Write(v.KDF.Salt)
+	return mac.Sum(nil)[:4]
+}
+
+// WithKeyFile returns v set up to unlock with the key file data. Vaults
+// that need no key file ignore it.
+func (v Vault) WithKeyFile(data []byte) (Vault, error) {
+	if v.KeyFile == nil {
+		return v, nil
+	}
+	digest := sha256.Sum256(data)
+	if !hmac.Equal(v.keyFileCheck(digest[:]), v.KeyFile.Check) {
+		return v, ErrWrongKeyFile
+	}
+	v.keyFile = data
+	return v, nil
+}
+
+// pinKey derives the key that unlocks v from the PIN and, when v needs
+// one, the key file.
+func (v Vault) pinKey(pin string) ([]byte, error) {
+	key, err := v.KDF.DeriveKey(pin)
+	if err != nil || v.KeyFile == nil {
+		return key, err
+	}
+	if v.keyFile == nil {
+		return nil, ErrKeyFileNeeded
+	}
+	digest := sha256.Sum256(v.keyFile)
+	return compositeKey(key, digest[:]), nil
+}
+
+func compositeKey(pinKey, digest []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte("gopass composite key"))
+	h.Write(pinKey)
+	h.Write(digest)
+	return h.Sum(nil)
+}
+
+var errKeyFileVault = errors.New("only file vaults of your own can need a key file")
+
+// SetKeyFile makes the vault need keyFile besides the PIN from now on, or
+// only the PIN when keyFile is nil. v must be unlockable with pin, carrying
+// its current key file if it has one.
+//
+// Adding a key file moves the vault to a new random key, so nothing the
+// PIN alone derives opens it. rekey re-encrypts the open vault under that
+// key before the registry hands it out. A recovery secret set up for the
+// old key stops working and is removed.
+func (r *Registry) SetKeyFile(v Vault, pin string, keyFile []byte, rekey func(key []byte) error) (Vault, error) {
+	if !v.paired() {
+		return Vault{}, errKeyFileVault
+	}
+	a := v.NewAuth()
+	if err := a.LoadPINHash(); err != nil {
+		return Vault{}, err
+	}
+	if !a.ValidatePIN(pin) {
+		return Vault{}, errors.New("invalid PIN")
+	}
+	key, err := v.Key(pin)
+	if err != nil {
+		return Vault{}, err
+	}
+	defer secmem.Wipe(key)
+	if v.hasDecoy(key) {
+		return Vault{}, errDuressSet
+	}
+	pinKey, err := v.KDF.DeriveKey(pin)
+	if err != nil {
+		return Vault{}, err
+	}
+	v.KeyFile, v.keyFile = nil, nil
+	newKey := key
+	if keyFile != nil {
+		digest := sha256.Sum256(keyFile)
+		v.KeyFile = &KeyFile{Check: v.keyFileCheck(digest[:])}
+		v.keyFile = keyFile
+		pinKey = compositeKey(pinKey, digest[:])
+		newKey = make([]byte, len(key))
+		if _, err := rand.Read(newKey); err != nil {
+			return Vault{}, err
+		}
+		defer secmem.Wipe(newKey)
+		if err := rekey(newKey); err != nil {
+			return Vault{}, err
+		}
+	}
+	if v.WrappedKey, err = wrapKey(pinKey, newKey); err == nil {
+		err = r.update(v)
+	}
+	if err != nil {
+		if keyFile != nil {
+			// Put the vault back under the key the registry still hands out
+			rekey(key)
+		}
+		return Vault{}, err
+	}
+
+	if keyFile != nil {
+		if err := audit.Rekey(v.AuditPath(), key, newKey); err != nil {
+			return v, fmt.Errorf("audit log: %w", err)
+		}
+		if err := os.Remove(v.RecoveryPath()); err != nil && !os.IsNotExist(err) {
+			return v, err
+		}
+	}
+	// The PIN check is bound to the key file as well
+	a = v.NewAuth()
+	a.LoadPINHash()
+	return v, a.SetSlotPIN(0, pin)
+}
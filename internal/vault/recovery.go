@@ -0,0 +1,151 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopass/internal/recovery"
+)
+
+// RecoveryPath holds the vault key sealed with the recovery secret.
+func (v Vault) RecoveryPath() string {
+	return filepath.Join(v.LocalPath(), "recovery.enc")
+}
+
+func (v Vault) HasRecovery() bool {
+	_, err := os.Stat(v.RecoveryPath())
+	return err == nil
+}
+
+// Key returns the key pin unlocks: the vault key, or for team vaults the
+// key protecting the member's identity.
+func (v Vault) Key(pin string) ([]byte, error) {
+	key, err := v.pinKey(pin)
+	if err != nil || v.WrappedKey == nil {
+		return key, err
+	}
+	return unwrapKey(key, v.WrappedKey)
+}
+
+// EnableRecovery seals the key pin unlocks with a new recovery secret and
+// returns the secret, to be printed as a key or split into shares. A
+// secret set up earlier stops working.
+func (v Vault) EnableRecovery(pin string) ([]byte, error) {
+	key, err := v.Key(pin)
+	if err != nil {
+		return nil, err
+	}
+	if v.paired() && v.hasDecoy(key) {
+		return nil, errDuressSet
+	}
+	secret, err := recovery.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := recovery.Seal(secret, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(v.LocalPath(), 0700); err != nil {
+		return nil, err
+	}
+	return secret, os.WriteFile(v.RecoveryPath(), sealed, 0600)
+}
+
+// Recover unlocks the named vault with its recovery secret and sets
+// newPIN, dropping any key file. The vault key itself is kept, so remotes,
+// clones and the recovery secret go on working.
+func (r *Registry) Recover(name string, secret []byte, newPIN string) (Vault, error) {
+	v, err := r.Get(name)
+	if err != nil {
+		return Vault{}, err
+	}
+	sealed, err := os.ReadFile(v.RecoveryPath())
+	if os.IsNotExist(err) {
+		return Vault{}, fmt.Errorf("vault %s has no recovery set up", name)
+	}
+	if err != nil {
+		return Vault{}, err
+	}
+	key, err := recovery.Open(secret, sealed)
+	if err != nil {
+		return Vault{}, err
+	}
+	v.KeyFile = nil
+
+	// The decoy of a duress pair is keyed by the KDF, so that has to stay
+	a := v.NewAuth()
+	slot := 0
+	if v.paired() && v.hasDecoy(key) {
+		if err := a.LoadPINHash(); err != nil {
+			return Vault{}, err
+		}
+		var ok bool
+		if slot, ok = v.slot(key); !ok {
+			return Vault{}, errors.New("no vault file opens with the recovered key")
+		}
+		if a.ValidatePIN(newPIN) && a.Slot() != slot {
+			return Vault{}, errors.New("the new PIN must differ from the duress PIN")
+		}
+	} else {
+		if v.KDF, err = NewKDF(); err != nil {
+			return Vault{}, err
+		}
+		// The PIN is checked with the new key derivation from now on
+		a = v.NewAuth()
+	}
+
+	pinKey, err := v.KDF.DeriveKey(newPIN)
+	if err != nil {
+		return Vault{}, err
+	}
+	if v.WrappedKey, err = wrapKey(pinKey, key); err != nil {
+		return Vault{}, err
+	}
+	if err := r.update(v); err != nil {
+		return Vault{}, err
+	}
+	return v, a.SetSlotPIN(slot, newPIN)
+}
+
+var errUnwrap = errors.New("cannot unwrap the vault key; wrong PIN?")
+
+func keyCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func wrapKey(pinKey, key []byte) ([]byte, error) {
+	gcm, err := keyCipher(pinKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, key, nil), nil
+}
+
+func unwrapKey(pinKey, wrapped []byte) ([]byte, error) {
+	gcm, err := keyCipher(pinKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < gcm.NonceSize() {
+		return nil, errors.New("wrapped vault key too short")
+	}
+	key, err := gcm.Open(nil, wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errUnwrap
+	}
+	return key, nil
+}
@@ -0,0 +1,546 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopass/internal/auth"
+	"gopass/internal/gitvault"
+	"gopass/internal/remote"
+	"gopass/internal/secmem"
+	"gopass/internal/share"
+	"gopass/internal/storage"
+	"gopass/internal/team"
+)
+
+const (
+	DefaultName = "default"
+	BackendFile = "file"
+	// BackendGit keeps entries in a git repository inside the vault
+	// directory, see gitvault.
+	BackendGit = "git"
+)
+
+var ErrNotFound = errors.New("vault not found")
+
+// Vault is one entry of the registry: a named vault with its own PIN and key.
+type Vault struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Backend string `json:"backend"`
+	KDF     KDF    `json:"kdf"`
+	// Remotes are the places this vault syncs with.
+	Remotes []remote.Config `json:"remotes,omitempty"`
+	// Team is set for team vaults, whose Path is shared with the other
+	// members.
+	Team *Membership `json:"team,omitempty"`
+	// WrappedKey is set once the vault key no longer comes from the PIN
+	// alone: after the PIN was reset through recovery, or a key file was
+	// added. It holds the vault key, sealed with the key KDF derives from the
+	// PIN (and key file).
+	WrappedKey []byte `json:"wrapped_key,omitempty"`
+	// KeyFile is set when the vault needs a key file besides the PIN.
+	KeyFile *KeyFile `json:"key_file,omitempty"`
+
+	// keyFile is the key file given for this session, see WithKeyFile.
+	keyFile []byte
+}
+
+// Membership is this machine's side of a team vault. KDF then protects the
+// member's identity, which unwraps the vault key from the team roster.
+type Membership struct {
+	Member string `json:"member"`
+	// Local holds the PIN hash and identity, which must not be shared.
+	Local string `json:"local"`
+}
+
+// LocalPath is where state private to this machine is kept.
+func (v Vault) LocalPath() string {
+	if v.Team != nil {
+		return v.Team.Local
+	}
+	return v.Path
+}
+
+func (v Vault) identityPath() string {
+	return filepath.Join(v.LocalPath(), "identity.enc")
+}
+
+// DataPath is the encrypted vault file inside the vault directory.
+func (v Vault) DataPath() string {
+	return filepath.Join(v.Path, "data.enc")
+}
+
+// BasePath is where the state agreed on at the last sync with the named
+// remote is kept.
+func (v Vault) BasePath(remote string) string {
+	return filepath.Join(v.LocalPath(), "sync", remote+".base")
+}
+
+func (v Vault) Remote(name string) (remote.Config, error) {
+	for _, r := range v.Remotes {
+		if r.Name == name {
+			return r, nil
+		}
+	}
+	return remote.Config{}, fmt.Errorf("vault %s has no remote %q", v.Name, name)
+}
+
+// SharingPath is the encrypted keyring holding the vault's sharing identity
+// and contacts.
+func (v Vault) SharingPath() string {
+	return filepath.Join(v.LocalPath(), "sharing.enc")
+}
+
+// RevisionPath remembers the highest revision of the vault file seen on
+// this machine, to notice the file being rolled back.
+func (v Vault) RevisionPath() string {
+	return filepath.Join(v.LocalPath(), "revision.seen")
+}
+
+// RepoPath is the git repository of a git vault.
+func (v Vault) RepoPath() string {
+	return filepath.Join(v.Path, "repo")
+}
+
+// NewAuth returns the PIN store belonging to this vault. PINs are checked
+// with the vault's key derivation and key file, so pin.hash is no shortcut
+// past either. Vaults that need a key file must carry it, see WithKeyFile.
+func (v Vault) NewAuth() *auth.Auth {
+	return auth.NewAuthAt(v.LocalPath(), v.pinKey)
+}
+
+// NewStorage derives the vault key from pin and returns an unloaded Storage.
+func (v Vault) NewStorage(pin string) (*storage.Storage, error) {
+	if v.Backend != BackendFile && v.Backend != BackendGit && v.Backend != "" {
+		return nil, fmt.Errorf("unsupported vault backend %q", v.Backend)
+	}
+	if v.Team != nil {
+		t, err := v.OpenTeam(pin)
+		if err != nil {
+			return nil, err
+		}
+		return v.TeamStorage(t), nil
+	}
+	if v.paired() {
+		key, slot, err := v.unlock(pin)
+		if err != nil {
+			return nil, err
+		}
+		// The storage keeps its own copy in secure memory
+		defer secmem.Wipe(key)
+		return v.pairedStorage(key, slot), nil
+	}
+	key, err := v.Key(pin)
+	if err != nil {
+		return nil, err
+	}
+	defer secmem.Wipe(key)
+	s := storage.NewStorageWithKey(v.DataPath(), key)
+	repo, err := gitvault.Open(v.RepoPath(), s.Encrypt, s.Decrypt)
+	if err != nil {
+		return nil, err
+	}
+	s.SetBackend(repo)
+	return s, nil
+}
+
+// OpenTeam unlocks the member's identity with pin and opens the team roster
+// with it.
+func (v Vault) OpenTeam(pin string) (*team.Team, error) {
+	if v.Team == nil {
+		return nil, fmt.Errorf("vault %s is not a team vault", v.Name)
+	}
+	key, err := v.Key(pin)
+	if err != nil {
+		return nil, err
+	}
+	id, err := team.LoadIdentity(v.identityPath(), key)
+	if err != nil {
+		return nil, err
+	}
+	t, err := team.Open(v.Path, id)
+	if errors.Is(err, team.ErrNotMember) {
+		pub, _ := id.PublicKey()
+		return nil, fmt.Errorf("%w (your key: %s)", err, pub)
+	}
+	return t, err
+}
+
+// TeamStorage returns the unloaded entries of an opened team vault, limited
+// to what the member's role allows.
+func (v Vault) TeamStorage(t *team.Team) *storage.Storage {
+	s := storage.NewStorageWithKey(v.DataPath(), t.Key())
+	s.SetRole(t.Member().Role)
+	s.TrackRevision(v.RevisionPath())
+	return s
+}
+
+// gitMeta is what a git vault's repository records about the vault, so a
+// clone derives the same key from the same PIN.
+type gitMeta struct {
+	KDF KDF `json:"kdf"`
+}
+
+// Registry lists every vault known on this machine.
+type Registry struct {
+	Default string  `json:"default"`
+	Vaults  []Vault `json:"vaults"`
+	path    string
+}
+
+const registryFile = "vaults.json"
+
+// NewRegistry returns an unsaved registry for dir whose only vault is the
+// original single vault kept directly in dir.
+func NewRegistry(dir string) *Registry {
+	return &Registry{
+		Default: DefaultName,
+		Vaults: []Vault{{
+			Name:    DefaultName,
+			Path:    dir,
+			Backend: BackendFile,
+			KDF:     KDF{Algorithm: KDFLegacy},
+		}},
+		path: filepath.Join(dir, registryFile),
+	}
+}
+
+// LoadRegistry reads vaults.json from dir, falling back to NewRegistry when
+// no registry has been written yet.
+func LoadRegistry(dir string) (*Registry, error) {
+	path := filepath.Join(dir, registryFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NewRegistry(dir), nil
+		}
+		return nil, err
+	}
+
+	r := &Registry{path: path}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("invalid vault registry %s: %w", path, err)
+	}
+	return r, nil
+}
+
+func (r *Registry) Save() error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0600)
+}
+
+func (r *Registry) Get(name string) (Vault, error) {
+	for _, v := range r.Vaults {
+		if v.Name == name {
+			return v, nil
+		}
+	}
+	return Vault{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// Current returns the named vault, or the default one when name is empty.
+func (r *Registry) Current(name string) (Vault, error) {
+	if name == "" {
+		name = r.Default
+	}
+	return r.Get(name)
+}
+
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.Vaults))
+	for _, v := range r.Vaults {
+		names = append(names, v.Name)
+	}
+	return names
+}
+
+// Create registers a new file vault called name in dir, or next to the
+// registry when dir is empty, with fresh KDF settings.
+func (r *Registry) Create(name, dir string) (Vault, error) {
+	return r.CreateWithBackend(name, dir, BackendFile)
+}
+
+// CreateWithBackend is Create for a vault stored by backend.
+func (r *Registry) CreateWithBackend(name, dir, backend string) (Vault, error) {
+	name = strings.TrimSpace(name)
+	if backend != BackendFile && backend != BackendGit {
+		return Vault{}, fmt.Errorf("unsupported vault backend %q", backend)
+	}
+	dir, err := r.newVaultDir(name, dir)
+	if err != nil {
+		return Vault{}, err
+	}
+	kdf, err := NewKDF()
+	if err != nil {
+		return Vault{}, err
+	}
+
+	v := Vault{Name: name, Path: dir, Backend: backend, KDF: kdf}
+	if backend == BackendGit {
+		meta, err := json.MarshalIndent(gitMeta{KDF: kdf}, "", "  ")
+		if err != nil {
+			return Vault{}, err
+		}
+		if err := gitvault.Init(v.RepoPath(), meta); err != nil {
+			return Vault{}, err
+		}
+	}
+	return v, r.add(v)
+}
+
+// Clone registers a git vault called name from a clone of the repository
+// at url, taking its key derivation settings from the repository.
+func (r *Registry) Clone(ctx context.Context, name, url, dir string) (Vault, error) {
+	name = strings.TrimSpace(name)
+	dir, err := r.newVaultDir(name, dir)
+	if err != nil {
+		return Vault{}, err
+	}
+	v := Vault{Name: name, Path: dir, Backend: BackendGit}
+	if err := gitvault.Clone(ctx, url, v.RepoPath()); err != nil {
+		return Vault{}, err
+	}
+	data, err := gitvault.ReadMeta(v.RepoPath())
+	if err != nil {
+		return Vault{}, fmt.Errorf("%s is not a gopass vault: %w", url, err)
+	}
+	var meta gitMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return Vault{}, fmt.Errorf("%s is not a gopass vault: %w", url, err)
+	}
+	v.KDF = meta.KDF
+	return v, r.add(v)
+}
+
+// remoteMeta is what a vault publishes with its copy on a sync remote, so
+// another device derives the same key from the same PIN and key file.
+type remoteMeta struct {
+	KDF        KDF      `json:"kdf"`
+	WrappedKey []byte   `json:"wrapped_key,omitempty"`
+	KeyFile    *KeyFile `json:"key_file,omitempty"`
+}
+
+// RemoteMeta returns the key settings v publishes on its sync remotes.
+func (v Vault) RemoteMeta() ([]byte, error) {
+	m := remoteMeta{KDF: v.KDF, WrappedKey: v.WrappedKey, KeyFile: v.KeyFile}
+	if m.KDF.Algorithm == "" {
+		m.KDF.Algorithm = KDFLegacy
+	}
+	return json.Marshal(m)
+}
+
+// CloneRemote registers a file vault called name that syncs with the remote
+// c, taking its key settings from meta, as published on the remote. The
+// vault is empty until its first sync.
+func (r *Registry) CloneRemote(name, dir string, c remote.Config, meta []byte) (Vault, error) {
+	if err := c.Validate(); err != nil {
+		return Vault{}, err
+	}
+	var m remoteMeta
+	if err := json.Unmarshal(meta, &m); err != nil || m.KDF.Algorithm == "" {
+		return Vault{}, fmt.Errorf("remote %s holds no gopass vault settings", c.Name)
+	}
+	name = strings.TrimSpace(name)
+	dir, err := r.newVaultDir(name, dir)
+	if err != nil {
+		return Vault{}, err
+	}
+	v := Vault{
+		Name:       name,
+		Path:       dir,
+		Backend:    BackendFile,
+		KDF:        m.KDF,
+		Remotes:    []remote.Config{c},
+		WrappedKey: m.WrappedKey,
+		KeyFile:    m.KeyFile,
+	}
+	return v, r.add(v)
+}
+
+// CreateTeam registers a new team vault in the shared directory dir with
+// member as its owner. The member's identity is protected by pin.
+func (r *Registry) CreateTeam(name, dir, member, pin string) (Vault, error) {
+	v, id, err := r.newMembership(name, dir, member, pin)
+	if err != nil {
+		return Vault{}, err
+	}
+	if _, err := team.Create(v.Path, member, id); err != nil {
+		return Vault{}, err
+	}
+	return v, r.add(v)
+}
+
+// JoinTeam registers the team vault in the shared directory dir for a new
+// member. The vault opens once an owner has added the returned key.
+func (r *Registry) JoinTeam(name, dir, member, pin string) (Vault, share.PublicKey, error) {
+	if _, err := os.Stat(filepath.Join(dir, team.RosterFile)); err != nil {
+		return Vault{}, share.PublicKey{}, fmt.Errorf("%s holds no team vault", dir)
+	}
+	v, id, err := r.newMembership(name, dir, member, pin)
+	if err != nil {
+		return Vault{}, share.PublicKey{}, err
+	}
+	pub, err := id.PublicKey()
+	if err != nil {
+		return Vault{}, share.PublicKey{}, err
+	}
+	return v, pub, r.add(v)
+}
+
+// newMembership prepares a team vault entry with a fresh identity in a
+// local directory of its own.
+func (r *Registry) newMembership(name, dir, member, pin string) (Vault, share.Identity, error) {
+	name = strings.TrimSpace(name)
+	if dir == "" {
+		return Vault{}, share.Identity{}, errors.New("a team vault needs a shared directory")
+	}
+	local, err := r.newVaultDir(name, "")
+	if err != nil {
+		return Vault{}, share.Identity{}, err
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return Vault{}, share.Identity{}, err
+	}
+	kdf, err := NewKDF()
+	if err != nil {
+		return Vault{}, share.Identity{}, err
+	}
+	key, err := kdf.DeriveKey(pin)
+	if err != nil {
+		return Vault{}, share.Identity{}, err
+	}
+	id, err := share.NewIdentity()
+	if err != nil {
+		return Vault{}, share.Identity{}, err
+	}
+	v := Vault{
+		Name:    name,
+		Path:    dir,
+		Backend: BackendFile,
+		KDF:     kdf,
+		Team:    &Membership{Member: strings.TrimSpace(member), Local: local},
+	}
+	return v, id, team.SaveIdentity(v.identityPath(), key, id)
+}
+
+// newVaultDir checks that name is free and creates the directory for it.
+func (r *Registry) newVaultDir(name, dir string) (string, error) {
+	if !validName(name) {
+		return "", fmt.Errorf("invalid vault name %q", name)
+	}
+	if _, err := r.Get(name); err == nil {
+		return "", fmt.Errorf("vault %q already exists", name)
+	}
+
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(r.path), "vaults", name)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return dir, os.MkdirAll(dir, 0700)
+}
+
+// validName reports whether name can be the directory of a vault inside
+// the vaults directory, and nothing above or beside it.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." &&
+		!strings.ContainsAny(name, "/\\\x00") && filepath.Base(name) == name
+}
+
+// update stores changed settings of a registered vault. The session's key
+// file stays with the caller.
+func (r *Registry) update(v Vault) error {
+	v.keyFile = nil
+	for i := range r.Vaults {
+		if r.Vaults[i].Name == v.Name {
+			r.Vaults[i] = v
+			return r.Save()
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrNotFound, v.Name)
+}
+
+func (r *Registry) add(v Vault) error {
+	r.Vaults = append(r.Vaults, v)
+	if r.Default == "" {
+		r.Default = v.Name
+	}
+	return r.Save()
+}
+
+// Remove forgets the vault; its files are left on disk.
+func (r *Registry) Remove(name string) error {
+	for i, v := range r.Vaults {
+		if v.Name == name {
+			r.Vaults = append(r.Vaults[:i], r.Vaults[i+1:]...)
+			if r.Default == name {
+				r.Default = ""
+				if len(r.Vaults) > 0 {
+					r.Default = r.Vaults[0].Name
+				}
+			}
+			return r.Save()
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+func (r *Registry) SetDefault(name string) error {
+	if _, err := r.Get(name); err != nil {
+		return err
+	}
+	r.Default = name
+	return r.Save()
+}
+
+// AddRemote records a remote for the named vault.
+func (r *Registry) AddRemote(name string, c remote.Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	for i, v := range r.Vaults {
+		if v.Name != name {
+			continue
+		}
+		if _, err := v.Remote(c.Name); err == nil {
+			return fmt.Errorf("vault %s already has a remote %q", name, c.Name)
+		}
+		r.Vaults[i].Remotes = append(v.Remotes, c)
+		return r.Save()
+	}
+	return fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// RemoveRemote forgets a remote of the named vault along with its sync state.
+func (r *Registry) RemoveRemote(name, remoteName string) error {
+	for i, v := range r.Vaults {
+		if v.Name != name {
+			continue
+		}
+		for j, c := range v.Remotes {
+			if c.Name == remoteName {
+				r.Vaults[i].Remotes = append(v.Remotes[:j], v.Remotes[j+1:]...)
+				if err := os.Remove(v.BasePath(remoteName)); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				return r.Save()
+			}
+		}
+		return fmt.Errorf("vault %s has no remote %q", name, remoteName)
+	}
+	return fmt.Errorf("%w: %s", ErrNotFound, name)
+}
@@ -0,0 +1,332 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopass/internal/audit"
+	"gopass/internal/models"
+	"gopass/internal/recovery"
+	"gopass/internal/remote"
+	"gopass/internal/storage"
+)
+
+func TestLoadRegistryDefaultsToLegacyVault(t *testing.T) {
+	dir := t.TempDir()
+
+	r, err := LoadRegistry(dir)
+	require.NoError(t, err)
+
+	v, err := r.Current("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultName, v.Name)
+	assert.Equal(t, KDFLegacy, v.KDF.Algorithm)
+	assert.Equal(t, filepath.Join(dir, "data.enc"), v.DataPath())
+}
+
+func TestVaultNamesStayInsideTheVaultsDirectory(t *testing.T) {
+	dir := t.TempDir()
+	r, err := LoadRegistry(dir)
+	require.NoError(t, err)
+	for _, name := range []string{"", ".", "..", "../x", "a/b", `a\b`, "a\x00b"} {
+		_, err := r.Create(name, "")
+		assert.Error(t, err, "%q", name)
+	}
+	_, err = os.Stat(filepath.Join(dir, "vaults"))
+	assert.True(t, os.IsNotExist(err), "nothing is created for rejected names")
+
+	v, err := r.Create("work.2024", "")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "vaults", "work.2024"), v.Path)
+}
+
+func TestCreateVaultPersistsAndIsolatesKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	r, err := LoadRegistry(dir)
+	require.NoError(t, err)
+	team, err := r.Create("team-infra", "")
+	require.NoError(t, err)
+	_, err = r.Create("team-infra", "")
+	assert.Error(t, err, "vault names must be unique")
+
+	assert.Equal(t, filepath.Join(dir, "vaults", "team-infra"), team.Path)
+
+	reloaded, err := LoadRegistry(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{DefaultName, "team-infra"}, reloaded.Names())
+
+	stored, err := reloaded.Get("team-infra")
+	require.NoError(t, err)
+	assert.Equal(t, team, stored)
+
+	keyA, err := stored.KDF.DeriveKey("1234")
+	require.NoError(t, err)
+	keyB, err := stored.KDF.DeriveKey("1234")
+	require.NoError(t, err)
+	legacyKey, err := KDF{Algorithm: KDFLegacy}.DeriveKey("1234")
+	require.NoError(t, err)
+	assert.Equal(t, keyA, keyB)
+	assert.NotEqual(t, legacyKey, keyA)
+
+	require.NoError(t, reloaded.Remove("team-infra"))
+	_, err = reloaded.Get("team-infra")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestTransferBetweenVaults(t *testing.T) {
+	r, err := LoadRegistry(t.TempDir())
+	require.NoError(t, err)
+
+	personal, err := r.Create("personal", "")
+	require.NoError(t, err)
+	client, err := r.Create("client-x", "")
+	require.NoError(t, err)
+
+	src, err := personal.NewStorage("1111")
+	require.NoError(t, err)
+	dst, err := client.NewStorage("2222")
+	require.NoError(t, err)
+
+	require.NoError(t, src.AddPassword(models.Password{ID: "p1", Name: "vpn"}))
+	require.NoError(t, src.AddNote(models.Note{ID: "n1", Title: "runbook"}))
+
+	require.NoError(t, src.TransferPassword("p1", dst, false))
+	require.NoError(t, src.TransferNote("n1", dst, true))
+
+	assert.Len(t, src.GetPasswords(), 1, "copy keeps the original")
+	assert.Empty(t, src.GetNotes(), "move removes the original")
+	require.Len(t, dst.GetPasswords(), 1)
+	assert.NotEqual(t, "p1", dst.GetPasswords()[0].ID, "copies get a fresh ID")
+	assert.Equal(t, "n1", dst.GetNotes()[0].ID, "moved entries keep their ID")
+
+	wrongKey, err := client.NewStorage("1111")
+	require.NoError(t, err)
+	assert.Error(t, wrongKey.Load(), "each vault has its own key")
+}
+
+func TestRemotesArePersisted(t *testing.T) {
+	dir := t.TempDir()
+	r, err := LoadRegistry(dir)
+	require.NoError(t, err)
+
+	nas := remote.Config{Name: "nas", Type: remote.TypeWebDAV, URL: "https://nas.local/dav/gopass.vault"}
+	require.NoError(t, r.AddRemote(DefaultName, nas))
+	assert.Error(t, r.AddRemote(DefaultName, nas), "remote names are unique per vault")
+	assert.ErrorIs(t, r.AddRemote("missing", nas), ErrNotFound)
+
+	reloaded, err := LoadRegistry(dir)
+	require.NoError(t, err)
+	v, err := reloaded.Current("")
+	require.NoError(t, err)
+	got, err := v.Remote("nas")
+	require.NoError(t, err)
+	assert.Equal(t, nas, got)
+
+	require.NoError(t, reloaded.RemoveRemote(DefaultName, "nas"))
+	v, _ = reloaded.Current("")
+	assert.Empty(t, v.Remotes)
+}
+
+func TestRecoverSetsNewPINAndKeepsData(t *testing.T) {
+	dir := t.TempDir()
+	r, err := LoadRegistry(dir)
+	require.NoError(t, err)
+	v, err := r.Create("personal", "")
+	require.NoError(t, err)
+	require.NoError(t, v.NewAuth().SetPIN("1111"))
+	s, err := v.NewStorage("1111")
+	require.NoError(t, err)
+	require.NoError(t, s.AddPassword(models.Password{ID: "p1", Name: "vpn", Password: "secret"}))
+
+	secret, err := v.EnableRecovery("1111")
+	require.NoError(t, err)
+	assert.True(t, v.HasRecovery())
+
+	other, _ := recovery.NewSecret()
+	_, err = r.Recover("personal", other, "2222")
+	assert.ErrorIs(t, err, recovery.ErrWrongSecret)
+
+	_, err = r.Recover("personal", secret, "2222")
+	require.NoError(t, err)
+	reloaded, err := LoadRegistry(dir)
+	require.NoError(t, err)
+	v, err = reloaded.Get("personal")
+	require.NoError(t, err)
+
+	a := v.NewAuth()
+	require.NoError(t, a.LoadPINHash())
+	assert.True(t, a.ValidatePIN("2222"))
+	assert.False(t, a.ValidatePIN("1111"))
+
+	s, err = v.NewStorage("2222")
+	require.NoError(t, err)
+	require.NoError(t, s.Load())
+	p, err := s.RevealPassword("p1")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", p.Password)
+
+	_, err = v.NewStorage("1111")
+	assert.Error(t, err, "the old PIN no longer unwraps the key")
+
+	// The same secret recovers the vault again
+	_, err = reloaded.Recover("personal", secret, "3333")
+	require.NoError(t, err)
+}
+
+func TestKeyFileIsNeededBesidesThePIN(t *testing.T) {
+	dir := t.TempDir()
+	r, err := LoadRegistry(dir)
+	require.NoError(t, err)
+	v, err := r.Create("personal", "")
+	require.NoError(t, err)
+	require.NoError(t, v.NewAuth().SetPIN("1111"))
+	s, err := v.NewStorage("1111")
+	require.NoError(t, err)
+	require.NoError(t, s.AddPassword(models.Password{ID: "p1", Name: "vpn", Password: "secret"}))
+
+	keyFile, err := GenerateKeyFile(filepath.Join(dir, "usb.key"))
+	require.NoError(t, err)
+	_, err = GenerateKeyFile(filepath.Join(dir, "usb.key"))
+	assert.Error(t, err, "existing key files are not overwritten")
+	_, err = r.SetKeyFile(v, "2222", keyFile, s.Rekey)
+	assert.Error(t, err, "a wrong PIN is refused")
+	_, err = r.SetKeyFile(v, "1111", keyFile, s.Rekey)
+	require.NoError(t, err)
+	s.Close()
+
+	// The PIN alone derives nothing that opens the vault any more
+	pinOnly, err := v.KDF.DeriveKey("1111")
+	require.NoError(t, err)
+	s = storage.NewStorageWithKey(v.DataPath(), pinOnly)
+	assert.Error(t, s.Load())
+
+	reloaded, err := LoadRegistry(dir)
+	require.NoError(t, err)
+	v, err = reloaded.Get("personal")
+	require.NoError(t, err)
+	_, err = v.NewStorage("1111")
+	assert.ErrorIs(t, err, ErrKeyFileNeeded)
+	_, err = v.WithKeyFile([]byte("some other file"))
+	assert.ErrorIs(t, err, ErrWrongKeyFile)
+
+	read, err := ReadKeyFile(filepath.Join(dir, "usb.key"))
+	require.NoError(t, err)
+	withFile, err := v.WithKeyFile(read)
+	require.NoError(t, err)
+	a := withFile.NewAuth()
+	require.NoError(t, a.LoadPINHash())
+	assert.True(t, a.ValidatePIN("1111"))
+	a = v.NewAuth()
+	require.NoError(t, a.LoadPINHash())
+	assert.False(t, a.ValidatePIN("1111"), "the PIN check needs the key file too")
+	s, err = withFile.NewStorage("1111")
+	require.NoError(t, err)
+	require.NoError(t, s.Load())
+	p, err := s.RevealPassword("p1")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", p.Password)
+
+	v, err = reloaded.SetKeyFile(withFile, "1111", nil, nil)
+	require.NoError(t, err)
+	s, err = v.NewStorage("1111")
+	require.NoError(t, err)
+	require.NoError(t, s.Load())
+	assert.Len(t, s.GetPasswords(), 1)
+}
+
+func TestDuressPINOpensLookalikeDecoy(t *testing.T) {
+	dir := t.TempDir()
+	r, err := LoadRegistry(dir)
+	require.NoError(t, err)
+	v, err := r.Create("personal", "")
+	require.NoError(t, err)
+	require.NoError(t, v.NewAuth().SetPIN("1111"))
+	s, err := v.NewStorage("1111")
+	require.NoError(t, err)
+	require.NoError(t, s.AddPassword(models.Password{ID: "p1", Name: "vpn", Password: "secret"}))
+	secret, err := v.EnableRecovery("1111")
+	require.NoError(t, err)
+	pinHash := func() []string {
+		data, err := os.ReadFile(filepath.Join(v.LocalPath(), "pin.hash"))
+		require.NoError(t, err)
+		return strings.Fields(string(data))
+	}
+	before := pinHash()
+	lines := func(path string) int {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return len(strings.Fields(string(data)))
+	}
+	record := func(pin string) {
+		log, err := v.OpenAudit(pin, "test")
+		require.NoError(t, err)
+		require.NoError(t, log.Record(audit.Unlock, "", ""))
+		_, err = log.Entries()
+		require.NoError(t, err, "each vault keeps its own chain and head")
+	}
+	record("1111")
+	head := v.AuditPath() + ".head"
+	files := func() []int64 {
+		var sizes []int64
+		for slot := 0; slot < 2; slot++ {
+			info, err := os.Stat(v.slotPath(slot))
+			require.NoError(t, err, "both vault files exist with or without a duress PIN")
+			sizes = append(sizes, info.Size())
+		}
+		return sizes
+	}
+	plain := files()
+	assert.Equal(t, plain[0], plain[1])
+	assert.Equal(t, 2, lines(v.RevisionPath()))
+	assert.Equal(t, 2, lines(head))
+
+	assert.Error(t, v.SetDuressPIN("1111", "1111"))
+	require.NoError(t, v.SetDuressPIN("1111", "9999"))
+	assert.Len(t, pinHash(), len(before), "pin.hash must not show the duress PIN")
+	record("9999")
+	record("1111")
+	assert.Equal(t, plain, files())
+	assert.Equal(t, 2, lines(v.RevisionPath()))
+	assert.Equal(t, 2, lines(head))
+	assert.Error(t, v.SetDuressPIN("1111", "8888"))
+	_, err = v.EnableRecovery("1111")
+	assert.Error(t, err)
+
+	decoy, err := v.NewStorage("9999")
+	require.NoError(t, err)
+	require.NoError(t, decoy.Load())
+	assert.Empty(t, decoy.GetPasswords())
+	require.NoError(t, decoy.AddPassword(models.Password{ID: "d1", Name: "mail", Password: "decoy"}))
+
+	real, err := v.NewStorage("1111")
+	require.NoError(t, err)
+	require.NoError(t, real.Load())
+	p, err := real.RevealPassword("p1")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", p.Password)
+
+	a, err := os.Stat(v.slotPath(0))
+	require.NoError(t, err)
+	b, err := os.Stat(v.slotPath(1))
+	require.NoError(t, err)
+	assert.Equal(t, a.Size(), b.Size())
+	assert.Equal(t, a.ModTime(), b.ModTime())
+
+	_, err = r.Recover("personal", secret, "9999")
+	assert.Error(t, err, "the new PIN must not be the duress PIN")
+	v, err = r.Recover("personal", secret, "2222")
+	require.NoError(t, err)
+	real, err = v.NewStorage("2222")
+	require.NoError(t, err)
+	require.NoError(t, real.Load())
+	assert.Len(t, real.GetPasswords(), 1)
+	decoy, err = v.NewStorage("9999")
+	require.NoError(t, err)
+	require.NoError(t, decoy.Load())
+	assert.Equal(t, "mail", decoy.GetPasswords()[0].Name)
+}
@@ -0,0 +1,49 @@
+package vaultsync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"gopass/internal/remote"
+)
+
+// A remote holds the sealed vault together with the vault's key settings,
+// in the clear, so another device can derive the same key from the same
+// PIN. Remotes written before the settings were published hold the sealed
+// vault alone.
+
+type blob struct {
+	Meta  json.RawMessage `json:"meta"`
+	Vault []byte          `json:"vault"`
+}
+
+func encodeBlob(meta, sealed []byte) ([]byte, error) {
+	if meta == nil {
+		return sealed, nil
+	}
+	return json.Marshal(blob{Meta: meta, Vault: sealed})
+}
+
+// decodeBlob splits what a remote holds. Sealed vault files are binary, so
+// they never pass for a blob.
+func decodeBlob(data []byte) (meta, sealed []byte) {
+	var b blob
+	if json.Unmarshal(data, &b) != nil || b.Vault == nil {
+		return nil, data
+	}
+	return b.Meta, b.Vault
+}
+
+// Meta returns the key settings published with the vault on r.
+func Meta(ctx context.Context, r remote.Remote) ([]byte, error) {
+	data, _, err := r.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	meta, _ := decodeBlob(data)
+	if meta == nil {
+		return nil, errors.New("the remote vault publishes no key settings; sync it once from a device that has the vault")
+	}
+	return meta, nil
+}
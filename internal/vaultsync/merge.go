@@ -0,0 +1,256 @@
+// Package vaultsync keeps a local vault and a remote copy of it in step with
+// a three-way merge against the state both had at the last sync.
+package vaultsync
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"gopass/internal/events"
+	"gopass/internal/models"
+)
+
+// Conflict is an entry both sides changed in ways that cannot be combined.
+// The merged vault keeps the most recently updated version under the
+// original ID.
+type Conflict struct {
+	Kind events.Kind
+	ID   string
+	Name string
+	// Fields lists what both sides changed differently. It is empty when one
+	// side deleted the entry and the other changed it, in which case the
+	// changed entry is kept.
+	Fields []string
+	// CopyID is the entry holding the other side's version, added next to
+	// the original so nothing is lost.
+	CopyID string
+}
+
+// ConflictSuffix marks the name of the copy holding the losing version.
+const ConflictSuffix = " (conflicted copy)"
+
+// Merge combines the changes local and remote made since base.
+func Merge(base, local, remote models.ExportData) (models.ExportData, []Conflict) {
+	var merged models.ExportData
+	var conflicts, c []Conflict
+	merged.Passwords, c = mergeEntries(events.KindPassword, base.Passwords, local.Passwords, remote.Passwords)
+	conflicts = append(conflicts, c...)
+	merged.Notes, c = mergeEntries(events.KindNote, base.Notes, local.Notes, remote.Notes)
+	conflicts = append(conflicts, c...)
+	merged.SSHKeys, c = mergeEntries(events.KindSSHKey, base.SSHKeys, local.SSHKeys, remote.SSHKeys)
+	conflicts = append(conflicts, c...)
+	return merged, conflicts
+}
+
+// Secrets opens and seals the secret fields of entries. Sealed secrets
+// change as a whole, so merging them unopened turns edits of two different
+// secret fields into a conflict.
+type Secrets interface {
+	RevealAll(models.ExportData) (models.ExportData, error)
+	SealAll(models.ExportData) (models.ExportData, error)
+}
+
+// MergeSealed merges vaults whose entries are sealed, comparing their
+// secrets in the open. Merged entries unchanged from local or remote keep
+// their sealed form; the others are sealed afresh.
+func MergeSealed(sec Secrets, base, local, remote models.ExportData) (models.ExportData, []Conflict, error) {
+	var open [3]models.ExportData
+	for i, data := range []models.ExportData{base, local, remote} {
+		var err error
+		if open[i], err = sec.RevealAll(data); err != nil {
+			return models.ExportData{}, nil, err
+		}
+	}
+	merged, conflicts := Merge(open[0], open[1], open[2])
+	keepSealed(merged.Passwords, open[1].Passwords, local.Passwords, open[2].Passwords, remote.Passwords)
+	keepSealed(merged.Notes, open[1].Notes, local.Notes, open[2].Notes, remote.Notes)
+	keepSealed(merged.SSHKeys, open[1].SSHKeys, local.SSHKeys, open[2].SSHKeys, remote.SSHKeys)
+	merged, err := sec.SealAll(merged)
+	if err != nil {
+		return models.ExportData{}, nil, err
+	}
+	return merged, conflicts, nil
+}
+
+// keepSealed replaces each merged entry that equals an opened input with
+// the sealed input it came from.
+func keepSealed[T any](merged, openLocal, local, openRemote, remote []T) {
+	for _, side := range [][2][]T{{openLocal, local}, {openRemote, remote}} {
+		opened, sealed := byID(side[0]), byID(side[1])
+		for i, m := range merged {
+			if o, ok := opened[idOf(m)]; ok && reflect.DeepEqual(o, m) {
+				merged[i] = sealed[idOf(m)]
+			}
+		}
+	}
+}
+
+// Equal reports whether a and b hold the same entries, in any order.
+func Equal(a, b models.ExportData) bool {
+	return sameEntries(a.Passwords, b.Passwords) &&
+		sameEntries(a.Notes, b.Notes) &&
+		sameEntries(a.SSHKeys, b.SSHKeys)
+}
+
+func mergeEntries[T any](kind events.Kind, base, local, remote []T) ([]T, []Conflict) {
+	b, l, r := byID(base), byID(local), byID(remote)
+	merged := make([]T, 0, len(local))
+	var conflicts []Conflict
+
+	decide := func(id string) {
+		be, inBase := b[id]
+		le, inLocal := l[id]
+		re, inRemote := r[id]
+
+		switch {
+		case inLocal && inRemote:
+			switch {
+			case sameContent(le, re), inBase && sameContent(be, re):
+				merged = append(merged, le)
+			case inBase && sameContent(be, le):
+				merged = append(merged, re)
+			default:
+				m, fields, loser := mergeFields(be, le, re)
+				merged = append(merged, m)
+				if len(fields) > 0 {
+					dup := conflictCopy(loser)
+					merged = append(merged, dup)
+					conflicts = append(conflicts, Conflict{Kind: kind, ID: id, Name: nameOf(m), Fields: fields, CopyID: idOf(dup)})
+				}
+			}
+		case inLocal, inRemote:
+			e := le
+			if inRemote {
+				e = re
+			}
+			switch {
+			case !inBase:
+				// Added on one side
+				merged = append(merged, e)
+			case sameContent(be, e):
+				// Deleted on the other side
+			default:
+				merged = append(merged, e)
+				conflicts = append(conflicts, Conflict{Kind: kind, ID: id, Name: nameOf(e)})
+			}
+		}
+	}
+
+	for _, e := range local {
+		decide(idOf(e))
+	}
+	for _, e := range remote {
+		if _, ok := l[idOf(e)]; !ok {
+			decide(idOf(e))
+		}
+	}
+	return merged, conflicts
+}
+
+// mergeFields merges two versions of an entry field by field. Fields both
+// sides changed differently take the value of the more recently updated
+// version; they are returned along with the version that lost.
+func mergeFields[T any](base, local, remote T) (T, []string, T) {
+	winner, loser := local, remote
+	if updatedAt(remote).After(updatedAt(local)) {
+		winner, loser = remote, local
+	}
+
+	merged := winner
+	mv := reflect.ValueOf(&merged).Elem()
+	bv, lv, rv := reflect.ValueOf(base), reflect.ValueOf(local), reflect.ValueOf(remote)
+	var conflicts []string
+	for i := 0; i < mv.NumField(); i++ {
+		name := mv.Type().Field(i).Name
+		if name == "ID" || name == "CreatedAt" || name == "UpdatedAt" {
+			continue
+		}
+		b, l, r := bv.Field(i), lv.Field(i), rv.Field(i)
+		switch {
+		case valuesEqual(l, r), valuesEqual(b, r):
+			mv.Field(i).Set(l)
+		case valuesEqual(b, l):
+			mv.Field(i).Set(r)
+		default:
+			conflicts = append(conflicts, name)
+		}
+	}
+	return merged, conflicts, loser
+}
+
+// conflictCopy turns the losing version into a new entry of its own.
+func conflictCopy[T any](e T) T {
+	v := reflect.ValueOf(&e).Elem()
+	v.FieldByName("ID").SetString(uuid.New().String())
+	if f := nameField(v); f.IsValid() {
+		f.SetString(f.String() + ConflictSuffix)
+	}
+	return e
+}
+
+func byID[T any](entries []T) map[string]T {
+	m := make(map[string]T, len(entries))
+	for _, e := range entries {
+		m[idOf(e)] = e
+	}
+	return m
+}
+
+func sameEntries[T any](a, b []T) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	bm := byID(b)
+	for _, e := range a {
+		other, ok := bm[idOf(e)]
+		if !ok || !sameContent(e, other) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameContent compares two versions of an entry, ignoring timestamps.
+func sameContent[T any](a, b T) bool {
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	for i := 0; i < av.NumField(); i++ {
+		name := av.Type().Field(i).Name
+		if name == "CreatedAt" || name == "UpdatedAt" {
+			continue
+		}
+		if !valuesEqual(av.Field(i), bv.Field(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// valuesEqual treats nil and empty slices alike, as they are after a trip
+// through JSON.
+func valuesEqual(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Slice && a.Len() == 0 && b.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+func idOf[T any](e T) string {
+	return reflect.ValueOf(e).FieldByName("ID").String()
+}
+
+func updatedAt[T any](e T) time.Time {
+	return reflect.ValueOf(e).FieldByName("UpdatedAt").Interface().(time.Time)
+}
+
+// nameField is the field users know an entry by: Name, or Title for notes.
+func nameField(v reflect.Value) reflect.Value {
+	if f := v.FieldByName("Name"); f.IsValid() {
+		return f
+	}
+	return v.FieldByName("Title")
+}
+
+func nameOf[T any](e T) string {
+	return nameField(reflect.ValueOf(e)).String()
+}
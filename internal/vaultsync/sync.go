@@ -0,0 +1,149 @@
+package vaultsync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopass/internal/models"
+	"gopass/internal/remote"
+	"gopass/internal/storage"
+)
+
+// Result describes what one sync did.
+type Result struct {
+	// Pulled is set when the local vault took changes from the remote.
+	Pulled bool
+	// Pushed is set when the remote was updated.
+	Pushed    bool
+	Conflicts []Conflict
+}
+
+// maxAttempts bounds how often a sync starts over after losing a race
+// with another device writing the remote.
+const maxAttempts = 3
+
+// Sync merges s with the remote r. basePath holds the state both agreed on
+// at the last sync with r, encrypted like the vault itself. meta, the
+// vault's key settings, is published with every upload, see Meta.
+func Sync(ctx context.Context, s *storage.Storage, r remote.Remote, basePath string, meta []byte) (Result, error) {
+	if s.IsReadOnly() {
+		return Result{}, storage.ErrReadOnly
+	}
+	for attempt := 1; ; attempt++ {
+		res, err := syncOnce(ctx, s, r, basePath, meta)
+		if !errors.Is(err, remote.ErrModified) || attempt == maxAttempts {
+			return res, err
+		}
+	}
+}
+
+func syncOnce(ctx context.Context, s *storage.Storage, r remote.Remote, basePath string, meta []byte) (Result, error) {
+	var res Result
+	var theirs models.ExportData
+	var theirMeta []byte
+	exists := true
+	data, tag, err := r.Get(ctx)
+	switch {
+	case errors.Is(err, remote.ErrNotFound):
+		exists = false
+	case err != nil:
+		return res, err
+	default:
+		var sealed []byte
+		theirMeta, sealed = decodeBlob(data)
+		if theirs, err = s.Unseal(sealed); err != nil {
+			return res, fmt.Errorf("cannot decrypt remote vault, was it made with another PIN? %w", err)
+		}
+	}
+
+	base, err := loadBase(s, basePath)
+	if err != nil {
+		return res, err
+	}
+	ours := s.Snapshot()
+	merged, conflicts, err := MergeSealed(s, base, ours, theirs)
+	if err != nil {
+		return res, err
+	}
+	res.Conflicts = conflicts
+
+	// Upload first: if another device got there before us nothing local has
+	// changed yet and the sync simply starts over.
+	if !exists || !Equal(merged, theirs) || !bytes.Equal(meta, theirMeta) {
+		sealed, err := s.Seal(merged)
+		if err != nil {
+			return res, err
+		}
+		data, err := encodeBlob(meta, sealed)
+		if err != nil {
+			return res, err
+		}
+		if _, err := r.Put(ctx, data, tag); err != nil {
+			return res, err
+		}
+		res.Pushed = true
+	}
+	if !Equal(merged, ours) {
+		if err := s.Replace(merged); err != nil {
+			return res, err
+		}
+		res.Pulled = true
+	}
+	return res, saveBase(s, basePath, merged)
+}
+
+func loadBase(s *storage.Storage, path string) (models.ExportData, error) {
+	sealed, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return models.ExportData{}, nil
+	}
+	if err != nil {
+		return models.ExportData{}, err
+	}
+	return s.Unseal(sealed)
+}
+
+func saveBase(s *storage.Storage, path string, data models.ExportData) error {
+	sealed, err := s.Seal(data)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, sealed, 0600)
+}
+
+// Restore makes an earlier version of the remote the current state of both
+// the remote and the local vault. Local changes that were not synced are
+// discarded.
+func Restore(ctx context.Context, s *storage.Storage, r remote.Versioned, versionID, basePath string) error {
+	if s.IsReadOnly() {
+		return storage.ErrReadOnly
+	}
+	version, err := r.GetVersion(ctx, versionID)
+	if err != nil {
+		return err
+	}
+	_, sealed := decodeBlob(version)
+	data, err := s.Unseal(sealed)
+	if err != nil {
+		return fmt.Errorf("cannot decrypt version %s, was it made with another PIN? %w", versionID, err)
+	}
+
+	_, tag, err := r.Get(ctx)
+	if err != nil && !errors.Is(err, remote.ErrNotFound) {
+		return err
+	}
+	if _, err := r.Put(ctx, version, tag); err != nil {
+		return err
+	}
+	if err := s.Replace(data); err != nil {
+		return err
+	}
+	return saveBase(s, basePath, data)
+}
@@ -0,0 +1,252 @@
+package vaultsync
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopass/internal/events"
+	"gopass/internal/models"
+	"gopass/internal/remote"
+	"gopass/internal/storage"
+)
+
+var t0 = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func password(id, name, user, pw string, updated time.Time) models.Password {
+	return models.Password{ID: id, Name: name, Username: user, Password: pw, CreatedAt: t0, UpdatedAt: updated}
+}
+
+func TestMergeCombinesIndependentChanges(t *testing.T) {
+	base := models.ExportData{Passwords: []models.Password{
+		password("a", "GitHub", "me", "old", t0),
+		password("b", "Bank", "acct", "pin", t0),
+		password("c", "Mail", "me", "mail", t0),
+	}}
+	local := models.ExportData{Passwords: []models.Password{
+		password("a", "GitHub", "me@example.com", "old", t0.Add(time.Hour)),
+		password("c", "Mail", "me", "mail", t0),
+	}}
+	remote := models.ExportData{Passwords: []models.Password{
+		password("a", "GitHub", "me", "new", t0.Add(2*time.Hour)),
+		password("b", "Bank", "acct", "pin", t0),
+		password("d", "Shop", "me", "shop", t0),
+	}}
+
+	merged, conflicts := Merge(base, local, remote)
+	assert.Empty(t, conflicts)
+	require.Len(t, merged.Passwords, 2, "b deleted locally, c deleted remotely")
+	assert.Equal(t, "me@example.com", merged.Passwords[0].Username)
+	assert.Equal(t, "new", merged.Passwords[0].Password)
+	assert.Equal(t, "d", merged.Passwords[1].ID)
+}
+
+func TestMergeSurfacesConflicts(t *testing.T) {
+	base := models.ExportData{
+		Passwords: []models.Password{password("a", "GitHub", "me", "old", t0)},
+		Notes:     []models.Note{{ID: "n", Title: "Wifi", Content: "pw1", UpdatedAt: t0}},
+	}
+	local := models.ExportData{
+		Passwords: []models.Password{password("a", "GitHub", "me", "laptop", t0.Add(2*time.Hour))},
+		Notes:     []models.Note{{ID: "n", Title: "Wifi", Content: "pw2", UpdatedAt: t0.Add(time.Hour)}},
+	}
+	remote := models.ExportData{
+		Passwords: []models.Password{password("a", "GitHub (work)", "me", "desktop", t0.Add(time.Hour))},
+	}
+
+	merged, conflicts := Merge(base, local, remote)
+	require.Len(t, conflicts, 2)
+
+	assert.Equal(t, events.KindPassword, conflicts[0].Kind)
+	assert.Equal(t, []string{"Password"}, conflicts[0].Fields)
+	require.Len(t, merged.Passwords, 2)
+	assert.Equal(t, "GitHub (work)", merged.Passwords[0].Name, "the rename did not conflict")
+	assert.Equal(t, "laptop", merged.Passwords[0].Password, "the newer change wins")
+	assert.Equal(t, conflicts[0].CopyID, merged.Passwords[1].ID)
+	assert.Equal(t, "desktop", merged.Passwords[1].Password, "the older change is kept as a copy")
+	assert.Equal(t, "GitHub (work)"+ConflictSuffix, merged.Passwords[1].Name)
+
+	assert.Equal(t, events.KindNote, conflicts[1].Kind)
+	assert.Empty(t, conflicts[1].Fields, "deleted remotely, changed locally")
+	assert.Equal(t, "pw2", merged.Notes[0].Content)
+}
+
+// memRemote is a Versioned remote whose tags count versions. beforePut
+// lets a test write the remote behind the syncing device's back.
+type memRemote struct {
+	mu        sync.Mutex
+	data      []byte
+	version   int
+	history   [][]byte
+	beforePut func()
+}
+
+func (m *memRemote) Get(ctx context.Context) ([]byte, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.data == nil {
+		return nil, "", remote.ErrNotFound
+	}
+	return m.data, strconv.Itoa(m.version), nil
+}
+
+func (m *memRemote) Put(ctx context.Context, data []byte, tag string) (string, error) {
+	if fn := m.beforePut; fn != nil {
+		m.beforePut = nil
+		fn()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if (tag == "" && m.data != nil) || (tag != "" && tag != strconv.Itoa(m.version)) {
+		return "", remote.ErrModified
+	}
+	m.data = data
+	m.version++
+	m.history = append(m.history, data)
+	return strconv.Itoa(m.version), nil
+}
+
+func (m *memRemote) Versions(ctx context.Context) ([]remote.Version, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var versions []remote.Version
+	for i := len(m.history) - 1; i >= 0; i-- {
+		versions = append(versions, remote.Version{ID: strconv.Itoa(i + 1), Size: int64(len(m.history[i])), Latest: i == len(m.history)-1})
+	}
+	return versions, nil
+}
+
+func (m *memRemote) GetVersion(ctx context.Context, id string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := strconv.Atoi(id)
+	if err != nil || n < 1 || n > len(m.history) {
+		return nil, remote.ErrNotFound
+	}
+	return m.history[n-1], nil
+}
+
+func newDevice(t *testing.T, key []byte) (*storage.Storage, string) {
+	dir := t.TempDir()
+	s := storage.NewStorageWithKey(filepath.Join(dir, "data.enc"), key)
+	require.NoError(t, s.Load())
+	return s, filepath.Join(dir, "sync", "remote.base")
+}
+
+func TestSyncTwoDevices(t *testing.T) {
+	ctx := context.Background()
+	key := make([]byte, 32)
+	r := &memRemote{}
+	laptop, laptopBase := newDevice(t, key)
+	desktop, desktopBase := newDevice(t, key)
+
+	require.NoError(t, laptop.AddPassword(password("a", "GitHub", "me", "pw", t0)))
+	res, err := Sync(ctx, laptop, r, laptopBase, nil)
+	require.NoError(t, err)
+	assert.True(t, res.Pushed)
+	assert.False(t, res.Pulled)
+
+	res, err = Sync(ctx, desktop, r, desktopBase, nil)
+	require.NoError(t, err)
+	assert.True(t, res.Pulled)
+	assert.False(t, res.Pushed)
+	require.Len(t, desktop.GetPasswords(), 1)
+
+	// The desktop deletes while the laptop adds; the laptop's sync races
+	// with the desktop's upload and has to start over.
+	require.NoError(t, desktop.DeletePassword("a"))
+	require.NoError(t, laptop.AddNote(models.Note{ID: "n", Title: "Wifi", UpdatedAt: t0}))
+	r.beforePut = func() {
+		_, err := Sync(ctx, desktop, r, desktopBase, nil)
+		require.NoError(t, err)
+	}
+	res, err = Sync(ctx, laptop, r, laptopBase, nil)
+	require.NoError(t, err)
+	assert.Empty(t, res.Conflicts)
+	assert.Empty(t, laptop.GetPasswords())
+	assert.Len(t, laptop.GetNotes(), 1)
+
+	_, err = Sync(ctx, desktop, r, desktopBase, nil)
+	require.NoError(t, err)
+	assert.Equal(t, laptop.Snapshot(), desktop.Snapshot())
+}
+
+func TestSyncMergesChangesToDifferentSecrets(t *testing.T) {
+	ctx := context.Background()
+	key := make([]byte, 32)
+	r := &memRemote{}
+	laptop, laptopBase := newDevice(t, key)
+	desktop, desktopBase := newDevice(t, key)
+
+	require.NoError(t, laptop.AddPassword(password("a", "GitHub", "me", "pw", t0)))
+	_, err := Sync(ctx, laptop, r, laptopBase, nil)
+	require.NoError(t, err)
+	_, err = Sync(ctx, desktop, r, desktopBase, nil)
+	require.NoError(t, err)
+
+	p, err := laptop.RevealPassword("a")
+	require.NoError(t, err)
+	p.Password, p.UpdatedAt = "new", t0.Add(time.Hour)
+	require.NoError(t, laptop.UpdatePassword(p))
+	p, err = desktop.RevealPassword("a")
+	require.NoError(t, err)
+	p.Note, p.UpdatedAt = "recovery codes", t0.Add(2*time.Hour)
+	require.NoError(t, desktop.UpdatePassword(p))
+
+	_, err = Sync(ctx, laptop, r, laptopBase, nil)
+	require.NoError(t, err)
+	res, err := Sync(ctx, desktop, r, desktopBase, nil)
+	require.NoError(t, err)
+	assert.Empty(t, res.Conflicts)
+
+	p, err = desktop.RevealPassword("a")
+	require.NoError(t, err)
+	assert.Equal(t, "new", p.Password)
+	assert.Equal(t, "recovery codes", p.Note)
+
+	// Entries nobody changed keep their sealed form
+	res, err = Sync(ctx, laptop, r, laptopBase, nil)
+	require.NoError(t, err)
+	assert.False(t, res.Pushed)
+	assert.Equal(t, desktop.Snapshot(), laptop.Snapshot())
+}
+
+func TestSyncRefusesForeignRemote(t *testing.T) {
+	ctx := context.Background()
+	r := &memRemote{}
+	mine, mineBase := newDevice(t, make([]byte, 32))
+	other, otherBase := newDevice(t, []byte("0123456789abcdef0123456789abcdef"))
+
+	_, err := Sync(ctx, other, r, otherBase, nil)
+	require.NoError(t, err)
+	_, err = Sync(ctx, mine, r, mineBase, nil)
+	assert.ErrorContains(t, err, "cannot decrypt")
+}
+
+func TestRestoreEarlierVersion(t *testing.T) {
+	ctx := context.Background()
+	r := &memRemote{}
+	s, base := newDevice(t, make([]byte, 32))
+
+	require.NoError(t, s.AddPassword(password("a", "GitHub", "me", "pw", t0)))
+	_, err := Sync(ctx, s, r, base, nil)
+	require.NoError(t, err)
+	require.NoError(t, s.DeletePassword("a"))
+	_, err = Sync(ctx, s, r, base, nil)
+	require.NoError(t, err)
+
+	versions, err := r.Versions(ctx)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.NoError(t, Restore(ctx, s, r, versions[1].ID, base))
+	assert.Len(t, s.GetPasswords(), 1)
+
+	res, err := Sync(ctx, s, r, base, nil)
+	require.NoError(t, err)
+	assert.False(t, res.Pulled || res.Pushed, "the restored version is current on both sides")
+}
@@ -1,15 +1,40 @@
 package main
 
 import (
-	"fyne.io/fyne/v2"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
 	"fyne.io/fyne/v2/app"
+	"gopass/internal/cli"
 	"gopass/internal/gui"
 )
 
+// firefoxExtensionID matches the IDs Firefox passes a native-messaging host:
+// a GUID in braces or an address-like name.
+var firefoxExtensionID = regexp.MustCompile(`^(\{[0-9a-fA-F-]{36}\}|[A-Za-z0-9._+-]*@[A-Za-z0-9.-]+)$`)
+
 func main() {
+	// Installed as docker-credential-gopass, docker runs us as its helper
+	if strings.HasPrefix(filepath.Base(os.Args[0]), "docker-credential-") {
+		os.Exit(cli.Run(append([]string{"docker-credential"}, os.Args[1:]...)))
+	}
+
+	// Browsers start native-messaging hosts with the extension origin
+	// (Chromium) or the manifest path and extension ID (Firefox)
+	if len(os.Args) > 1 && (strings.HasPrefix(os.Args[1], "chrome-extension://") ||
+		(len(os.Args) == 3 && strings.HasSuffix(os.Args[1], ".json") && firefoxExtensionID.MatchString(os.Args[2]))) {
+		os.Exit(cli.Run([]string{"browser", "host"}))
+	}
+
+	// Any arguments select the command line interface
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
+
 	a := app.New()
 	w := a.NewWindow("GoPass - Password Manager")
-	w.Resize(fyne.NewSize(800, 600))
 
 	mainApp := gui.NewMainApp(w)
 	mainApp.LoadAuth()